@@ -0,0 +1,147 @@
+package widevineauth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+)
+
+// JWK is a JSON Web Key (RFC 7517) of type oct, RSA or EC.
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+
+	K string `json:"k"` // oct
+
+	N string `json:"n"` // RSA
+	E string `json:"e"`
+
+	Crv string `json:"crv"` // EC
+	X   string `json:"x"`
+	Y   string `json:"y"`
+
+	key interface{}
+}
+
+// JWKS is a JSON Web Key Set.
+type JWKS struct {
+	Keys []*JWK `json:"keys"`
+}
+
+// LoadJWKS reads a JSON Web Key Set from a local file.
+func LoadJWKS(path string) (*JWKS, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseJWKS(b)
+}
+
+// ParseJWKS decodes a JSON Web Key Set and its keys.
+func ParseJWKS(b []byte) (*JWKS, error) {
+	var set JWKS
+	if err := json.Unmarshal(b, &set); err != nil {
+		return nil, err
+	}
+	if len(set.Keys) == 0 {
+		return nil, fmt.Errorf("jwks: no keys")
+	}
+	for i, k := range set.Keys {
+		if err := k.decode(); err != nil {
+			return nil, fmt.Errorf("jwks: key %d (%s): %v", i, k.Kid, err)
+		}
+	}
+	return &set, nil
+}
+
+// lookup returns the key for the token header, the only key of the set being used when the token has no kid.
+func (set *JWKS) lookup(kid, alg string) (*JWK, error) {
+	var candidates []*JWK
+	for _, k := range set.Keys {
+		if (kid == "" || k.Kid == kid) && k.accepts(alg) {
+			candidates = append(candidates, k)
+		}
+	}
+	if len(candidates) != 1 {
+		return nil, fmt.Errorf("no unique key for kid %q and alg %s", kid, alg)
+	}
+	return candidates[0], nil
+}
+
+// accepts reports whether the key may verify a signature made with alg.
+// The key type must match the algorithm so that a public key is never used as an HMAC secret.
+func (k *JWK) accepts(alg string) bool {
+	if k.Alg != "" && k.Alg != alg {
+		return false
+	}
+	if k.Use != "" && k.Use != "sig" {
+		return false
+	}
+	switch alg {
+	case AlgHS256:
+		return k.Kty == "oct"
+	case AlgRS256:
+		return k.Kty == "RSA"
+	case AlgES256:
+		return k.Kty == "EC" && k.Crv == "P-256"
+	}
+	return false
+}
+
+func (k *JWK) decode() error {
+	switch k.Kty {
+	case "oct":
+		secret, err := base64.RawURLEncoding.DecodeString(k.K)
+		if err != nil || len(secret) == 0 {
+			return fmt.Errorf("invalid k")
+		}
+		k.key = secret
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return fmt.Errorf("invalid n")
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil || !e.IsInt64() {
+			return fmt.Errorf("invalid e")
+		}
+		k.key = &rsa.PublicKey{N: n, E: int(e.Int64())}
+	case "EC":
+		if k.Crv != "P-256" {
+			return fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return fmt.Errorf("invalid x")
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return fmt.Errorf("invalid y")
+		}
+		if !elliptic.P256().IsOnCurve(x, y) {
+			return fmt.Errorf("point not on curve")
+		}
+		k.key = &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+	default:
+		return fmt.Errorf("unsupported kty %s", k.Kty)
+	}
+	return nil
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(b) == 0 {
+		return nil, fmt.Errorf("empty")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
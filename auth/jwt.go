@@ -0,0 +1,209 @@
+// Package widevineauth verifies the signed tokens sent by players along with the CDM challenge
+// and turns them into widevineproxy.Claims.
+package widevineauth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	widevineproxy "github.com/cooomma/widevine-proxy/proxy"
+)
+
+// Supported JWS algorithms.
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgES256 = "ES256"
+)
+
+// ErrInvalidToken is wrapped by every verification failure.
+var ErrInvalidToken = errors.New("invalid token")
+
+// TokenClaims is the payload of the JWT sent by the player.
+type TokenClaims struct {
+	Subject   string   `json:"sub"`
+	Issuer    string   `json:"iss"`
+	Audience  audience `json:"aud"`
+	ExpiresAt int64    `json:"exp"`
+	NotBefore int64    `json:"nbf"`
+	IssuedAt  int64    `json:"iat"`
+
+	// ContentIDs restricts the licenses to these content ids. Empty allows any content.
+	ContentIDs []string `json:"content_ids"`
+	// MaxTrackType caps the allowed track types, e.g. SD_HD for an HD subscription.
+	MaxTrackType widevineproxy.AllowedTrackType `json:"max_track_type"`
+	// PolicyOverrides narrows the policy of the license down, see widevineproxy.PolicyOverrides.Narrow.
+	// The token withholds the permissions set to false, e.g. "can_persist": false, those left out are kept.
+	PolicyOverrides *widevineproxy.PolicyOverrides `json:"policy_overrides"`
+}
+
+// audience accepts both the string and the array form of the aud claim.
+type audience []string
+
+func (a *audience) UnmarshalJSON(b []byte) error {
+	var single string
+	if err := json.Unmarshal(b, &single); err == nil {
+		*a = audience{single}
+		return nil
+	}
+	var multiple []string
+	if err := json.Unmarshal(b, &multiple); err != nil {
+		return err
+	}
+	*a = multiple
+	return nil
+}
+
+// Verifier checks the signature and the registered claims of JWTs.
+type Verifier struct {
+	Keys     *JWKS
+	Issuer   string        // Expected iss, not checked if empty.
+	Audience string        // Expected aud, not checked if empty.
+	Leeway   time.Duration // Tolerated clock skew on exp and nbf.
+
+	Now func() time.Time
+}
+
+// NewVerifier creates a Verifier trusting the given keys.
+func NewVerifier(keys *JWKS) *Verifier {
+	return &Verifier{Keys: keys, Leeway: 30 * time.Second, Now: time.Now}
+}
+
+// Verify checks token and returns the claims it carries.
+func (v *Verifier) Verify(token string) (*widevineproxy.Claims, error) {
+	tc, extra, err := v.verify(token)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	return &widevineproxy.Claims{
+		UserID:          tc.Subject,
+		ContentIDs:      tc.ContentIDs,
+		MaxTrackType:    tc.MaxTrackType,
+		PolicyOverrides: tc.PolicyOverrides,
+		ExpiresAt:       time.Unix(tc.ExpiresAt, 0),
+		Attributes:      extra,
+	}, nil
+}
+
+func (v *Verifier) verify(token string) (*TokenClaims, map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, nil, fmt.Errorf("malformed jwt")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, nil, fmt.Errorf("header: %v", err)
+	}
+	key, err := v.Keys.lookup(header.Kid, header.Alg)
+	if err != nil {
+		return nil, nil, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, nil, fmt.Errorf("signature: %v", err)
+	}
+	if err := verifySignature(header.Alg, key, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return nil, nil, err
+	}
+
+	// The overrides are decoded over the permissions, so that the token only withholds those it sets to false.
+	tc := TokenClaims{PolicyOverrides: &widevineproxy.PolicyOverrides{CanPlay: true, CanPersist: true, CanRenew: true}}
+	if err := decodeSegment(parts[1], &tc); err != nil {
+		return nil, nil, fmt.Errorf("claims: %v", err)
+	}
+	var extra map[string]interface{}
+	if err := decodeSegment(parts[1], &extra); err != nil {
+		return nil, nil, fmt.Errorf("claims: %v", err)
+	}
+	if _, ok := extra["policy_overrides"]; !ok {
+		tc.PolicyOverrides = nil
+	}
+	for _, registered := range []string{"sub", "iss", "aud", "exp", "nbf", "iat", "jti", "content_ids", "max_track_type", "policy_overrides"} {
+		delete(extra, registered)
+	}
+	if err := v.validate(&tc); err != nil {
+		return nil, nil, err
+	}
+	return &tc, extra, nil
+}
+
+func (v *Verifier) validate(tc *TokenClaims) error {
+	now := v.Now()
+	if tc.ExpiresAt == 0 {
+		return fmt.Errorf("missing exp")
+	}
+	if now.After(time.Unix(tc.ExpiresAt, 0).Add(v.Leeway)) {
+		return fmt.Errorf("token expired")
+	}
+	if tc.NotBefore != 0 && now.Add(v.Leeway).Before(time.Unix(tc.NotBefore, 0)) {
+		return fmt.Errorf("token not valid yet")
+	}
+	if tc.Subject == "" {
+		return fmt.Errorf("missing sub")
+	}
+	if v.Issuer != "" && tc.Issuer != v.Issuer {
+		return fmt.Errorf("unexpected issuer %q", tc.Issuer)
+	}
+	if v.Audience != "" && !tc.Audience.contains(v.Audience) {
+		return fmt.Errorf("unexpected audience %v", []string(tc.Audience))
+	}
+	return nil
+}
+
+func (a audience) contains(s string) bool {
+	for _, aud := range a {
+		if aud == s {
+			return true
+		}
+	}
+	return false
+}
+
+func verifySignature(alg string, key *JWK, signingInput, signature []byte) error {
+	digest := sha256.Sum256(signingInput)
+	switch alg {
+	case AlgHS256:
+		mac := hmac.New(sha256.New, key.key.([]byte))
+		mac.Write(signingInput)
+		if hmac.Equal(mac.Sum(nil), signature) {
+			return nil
+		}
+	case AlgRS256:
+		if rsa.VerifyPKCS1v15(key.key.(*rsa.PublicKey), crypto.SHA256, digest[:], signature) == nil {
+			return nil
+		}
+	case AlgES256:
+		if len(signature) != 64 {
+			return fmt.Errorf("invalid ES256 signature length")
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		if ecdsa.Verify(key.key.(*ecdsa.PublicKey), digest[:], r, s) {
+			return nil
+		}
+	default:
+		return fmt.Errorf("unsupported alg %s", alg)
+	}
+	return fmt.Errorf("signature mismatch")
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
@@ -0,0 +1,179 @@
+package widevineauth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	widevineproxy "github.com/cooomma/widevine-proxy/proxy"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	hmacSecret = []byte("a very secret hmac key of 32 bytes")
+	rsaKey, _  = rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _   = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	now        = time.Unix(1600000000, 0)
+)
+
+func b64(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func fixedBytes(i *big.Int) []byte {
+	b := make([]byte, 32)
+	return i.FillBytes(b)
+}
+
+func testJWKS(t *testing.T) *JWKS {
+	keys, _ := json.Marshal(map[string]interface{}{
+		"keys": []map[string]string{
+			{"kty": "oct", "kid": "hs", "k": b64(hmacSecret)},
+			{"kty": "RSA", "kid": "rs", "alg": "RS256", "n": b64(rsaKey.N.Bytes()), "e": b64(big.NewInt(int64(rsaKey.E)).Bytes())},
+			{"kty": "EC", "kid": "es", "crv": "P-256", "x": b64(fixedBytes(ecKey.X)), "y": b64(fixedBytes(ecKey.Y))},
+		},
+	})
+	set, err := ParseJWKS(keys)
+	assert.NoError(t, err)
+	return set
+}
+
+func sign(t *testing.T, alg, kid string, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": alg, "kid": kid, "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	input := b64(header) + "." + b64(payload)
+	digest := sha256.Sum256([]byte(input))
+
+	var signature []byte
+	switch alg {
+	case AlgHS256:
+		mac := hmac.New(sha256.New, hmacSecret)
+		mac.Write([]byte(input))
+		signature = mac.Sum(nil)
+	case AlgRS256:
+		var err error
+		signature, err = rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, digest[:])
+		assert.NoError(t, err)
+	case AlgES256:
+		r, s, err := ecdsa.Sign(rand.Reader, ecKey, digest[:])
+		assert.NoError(t, err)
+		signature = append(fixedBytes(r), fixedBytes(s)...)
+	}
+	return input + "." + b64(signature)
+}
+
+func validClaims() map[string]interface{} {
+	return map[string]interface{}{
+		"sub":            "user-1",
+		"iss":            "https://auth.example.com",
+		"aud":            "widevine-proxy",
+		"exp":            now.Add(time.Hour).Unix(),
+		"content_ids":    []string{"movie-1"},
+		"max_track_type": "SD_HD",
+		"tier":           "premium",
+	}
+}
+
+func testVerifier(t *testing.T) *Verifier {
+	v := NewVerifier(testJWKS(t))
+	v.Issuer = "https://auth.example.com"
+	v.Audience = "widevine-proxy"
+	v.Now = func() time.Time { return now }
+	return v
+}
+
+func TestVerify(t *testing.T) {
+	v := testVerifier(t)
+	for alg, kid := range map[string]string{AlgHS256: "hs", AlgRS256: "rs", AlgES256: "es"} {
+		claims, err := v.Verify(sign(t, alg, kid, validClaims()))
+		if assert.NoError(t, err, alg) {
+			assert.Equal(t, "user-1", claims.UserID)
+			assert.Equal(t, []string{"movie-1"}, claims.ContentIDs)
+			assert.Equal(t, widevineproxy.AllowedTrackType(widevineproxy.AllowedTrackTypeHD), claims.MaxTrackType)
+			assert.Equal(t, now.Add(time.Hour).Unix(), claims.ExpiresAt.Unix())
+			assert.Equal(t, map[string]interface{}{"tier": "premium"}, claims.Attributes)
+			assert.Nil(t, claims.PolicyOverrides)
+		}
+	}
+
+	// The permissions left out of the overrides are not withheld.
+	payload := validClaims()
+	payload["policy_overrides"] = map[string]interface{}{"can_persist": false, "rental_duration_seconds": 3600}
+	claims, err := v.Verify(sign(t, AlgHS256, "hs", payload))
+	if assert.NoError(t, err) {
+		assert.Equal(t, &widevineproxy.PolicyOverrides{CanPlay: true, CanRenew: true, RentalDurationSeconds: 3600}, claims.PolicyOverrides)
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	v := testVerifier(t)
+	with := func(key string, value interface{}) map[string]interface{} {
+		c := validClaims()
+		if value == nil {
+			delete(c, key)
+		} else {
+			c[key] = value
+		}
+		return c
+	}
+
+	rs := sign(t, AlgRS256, "rs", validClaims())
+	es := sign(t, AlgES256, "es", validClaims())
+	tokens := map[string]string{
+		"expired":         sign(t, AlgHS256, "hs", with("exp", now.Add(-time.Hour).Unix())),
+		"missing exp":     sign(t, AlgHS256, "hs", with("exp", nil)),
+		"not before":      sign(t, AlgHS256, "hs", with("nbf", now.Add(time.Hour).Unix())),
+		"missing sub":     sign(t, AlgHS256, "hs", with("sub", nil)),
+		"wrong issuer":    sign(t, AlgHS256, "hs", with("iss", "https://evil.example.com")),
+		"wrong audience":  sign(t, AlgHS256, "hs", with("aud", []string{"other"})),
+		"unknown kid":     sign(t, AlgHS256, "unknown", validClaims()),
+		"alg mismatch":    sign(t, AlgHS256, "rs", validClaims()),
+		"alg none":        sign(t, "none", "hs", validClaims()),
+		"not a jwt":       "not.a-jwt",
+		"tampered":        rs[:40] + "x" + rs[41:],
+		"wrong signature": es[:len(es)-4] + "AAAA",
+	}
+	for name, token := range tokens {
+		_, err := v.Verify(token)
+		assert.True(t, errors.Is(err, ErrInvalidToken), name)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	v := testVerifier(t)
+	var claims *widevineproxy.Claims
+	h := Middleware(v)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims = widevineproxy.ClaimsFromContext(r.Context())
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/license", nil))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	req := httptest.NewRequest(http.MethodPost, "/license", nil)
+	req.Header.Set("Authorization", "Bearer garbage")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/license", nil)
+	req.Header.Set("Authorization", "Bearer "+sign(t, AlgES256, "es", validClaims()))
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	if assert.NotNil(t, claims) {
+		assert.Equal(t, "user-1", claims.UserID)
+	}
+}
@@ -0,0 +1,39 @@
+package widevineauth
+
+import (
+	"net/http"
+	"strings"
+
+	widevineproxy "github.com/cooomma/widevine-proxy/proxy"
+)
+
+// Middleware verifies the bearer token of each request and stores its claims in the request context,
+// see widevineproxy.ClaimsFromContext. Requests without a valid token are answered 401.
+func Middleware(v *Verifier) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := BearerToken(r)
+			if token == "" {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="widevine-proxy"`)
+				http.Error(w, "missing bearer token", http.StatusUnauthorized)
+				return
+			}
+			claims, err := v.Verify(token)
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="widevine-proxy", error="invalid_token"`)
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(widevineproxy.WithClaims(r.Context(), claims)))
+		})
+	}
+}
+
+// BearerToken returns the token of the Authorization header, empty if none.
+func BearerToken(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+	if len(header) > 7 && strings.EqualFold(header[:7], "bearer ") {
+		return strings.TrimSpace(header[7:])
+	}
+	return ""
+}
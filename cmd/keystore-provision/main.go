@@ -0,0 +1,102 @@
+// Command keystore-provision generates the content keys of a content into a JSON key store, and prints
+// the Widevine pssh box and the CPIX document the packager encrypts the content with.
+//
+//	keystore-provision -store keys.json -content movie-1 -tracks SD,HD,AUDIO -provider widevine_test -cpix movie-1.cpix.xml
+//
+// Provisioning a content again returns the keys already in the store.
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	widevinekeystore "github.com/cooomma/widevine-proxy/keystore"
+	widevineproxy "github.com/cooomma/widevine-proxy/proxy"
+)
+
+func main() {
+	storePath := flag.String("store", "keys.json", "path of the JSON key store")
+	contentID := flag.String("content", "", "content id")
+	tracks := flag.String("tracks", "SD,HD,AUDIO", "comma separated track types to generate a key for")
+	provider := flag.String("provider", "", "provider written into the pssh data")
+	scheme := flag.String("scheme", widevinekeystore.SchemeCENC, "protection scheme, cenc or cbcs")
+	cpixPath := flag.String("cpix", "", "path of the CPIX document to write, stdout if -")
+	keyringPath := flag.String("keyring", "", "path of the keyring wrapping the keys of the store")
+	keyringEnv := flag.String("keyring-env", "", "environment variable holding the keyring, instead of -keyring")
+	flag.Parse()
+
+	if *contentID == "" {
+		fail(fmt.Errorf("-content is required"))
+	}
+	if *scheme != widevinekeystore.SchemeCENC && *scheme != widevinekeystore.SchemeCBCS {
+		fail(fmt.Errorf("unsupported protection scheme %q", *scheme))
+	}
+	var trackTypes []widevineproxy.ContentTrackType
+	for _, t := range strings.Split(*tracks, ",") {
+		trackTypes = append(trackTypes, widevineproxy.ContentTrackType(strings.ToUpper(strings.TrimSpace(t))))
+	}
+
+	fileStore, err := widevinekeystore.LoadFileStore(*storePath)
+	if err != nil {
+		fail(err)
+	}
+	var store widevinekeystore.KeyStore = fileStore
+	var keyring *widevinekeystore.Keyring
+	switch {
+	case *keyringPath != "":
+		keyring, err = widevinekeystore.LoadKeyring(*keyringPath)
+	case *keyringEnv != "":
+		keyring, err = widevinekeystore.KeyringFromEnv(*keyringEnv)
+	}
+	if err != nil {
+		fail(err)
+	}
+	if keyring != nil {
+		store = widevinekeystore.NewWrappingStore(fileStore, keyring)
+	}
+
+	p := widevinekeystore.NewProvisioner(store, *provider)
+	p.ProtectionScheme = *scheme
+	p.Keyring = keyring
+	provisioned, err := p.Provision(context.Background(), *contentID, trackTypes...)
+	if err != nil {
+		fail(err)
+	}
+
+	for _, key := range provisioned.Keys {
+		fmt.Fprintf(os.Stderr, "%-5s kid %x\n", key.TrackType, []byte(key.KeyID))
+	}
+	fmt.Fprintln(os.Stderr, "pssh", base64.StdEncoding.EncodeToString(provisioned.PSSH))
+
+	switch *cpixPath {
+	case "":
+	case "-":
+		err = provisioned.WriteCPIX(os.Stdout)
+	default:
+		err = writeCPIX(*cpixPath, provisioned)
+	}
+	if err != nil {
+		fail(err)
+	}
+}
+
+func writeCPIX(path string, provisioned *widevinekeystore.Provisioned) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if err := provisioned.WriteCPIX(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "keystore-provision:", err)
+	os.Exit(1)
+}
@@ -0,0 +1,57 @@
+// Command keystore-rewrap wraps every key of a JSON key store under the primary key encryption key of a keyring,
+// e.g. after adding a new KEK version to rotate it. Plaintext keys are wrapped as well.
+//
+//	keystore-rewrap -store keys.json -keyring keyring.json
+//	WIDEVINE_KEK=2:<64 hex>,1:<64 hex> keystore-rewrap -store keys.json -keyring-env WIDEVINE_KEK
+//
+// The keyring must still hold the KEK versions the keys are currently wrapped under.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	widevinekeystore "github.com/cooomma/widevine-proxy/keystore"
+)
+
+func main() {
+	storePath := flag.String("store", "keys.json", "path of the JSON key store")
+	keyringPath := flag.String("keyring", "", "path of the keyring file")
+	keyringEnv := flag.String("keyring-env", "", "environment variable holding the keyring, instead of -keyring")
+	flag.Parse()
+
+	var (
+		keyring *widevinekeystore.Keyring
+		err     error
+	)
+	switch {
+	case *keyringPath != "" && *keyringEnv != "":
+		fail(fmt.Errorf("-keyring and -keyring-env are exclusive"))
+	case *keyringPath != "":
+		keyring, err = widevinekeystore.LoadKeyring(*keyringPath)
+	case *keyringEnv != "":
+		keyring, err = widevinekeystore.KeyringFromEnv(*keyringEnv)
+	default:
+		fail(fmt.Errorf("-keyring or -keyring-env is required"))
+	}
+	if err != nil {
+		fail(err)
+	}
+
+	store, err := widevinekeystore.LoadFileStore(*storePath)
+	if err != nil {
+		fail(err)
+	}
+	n, err := widevinekeystore.Rewrap(context.Background(), store, keyring)
+	if err != nil {
+		fail(fmt.Errorf("rewrap %s: %v (%d keys rewrapped)", *storePath, err, n))
+	}
+	fmt.Printf("%d keys rewrapped under kek version %d\n", n, keyring.Primary)
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, "keystore-rewrap:", err)
+	os.Exit(1)
+}
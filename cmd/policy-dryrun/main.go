@@ -0,0 +1,109 @@
+// Command policy-dryrun evaluates the license policy rules against a request, or against test cases.
+//
+//	policy-dryrun -policies policies.yaml -content-id movie-1 -tier premium -security-level 1 -country FR
+//	policy-dryrun -policies policies.yaml -cases cases.yaml
+//
+// A single request prints the evaluation of every rule and the resulting license message skeleton, as JSON.
+// The cases are a YAML or JSON list of {name, input, expect, denied}, expect naming the policy expected;
+// the exit status is 1 when a case fails.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	widevinepolicy "github.com/cooomma/widevine-proxy/policy"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// testCase is a request and the policy it is expected to get.
+type testCase struct {
+	Name   string               `json:"name"`
+	Input  widevinepolicy.Input `json:"input"`
+	Expect string               `json:"expect"` // Name of the policy.
+	Denied bool                 `json:"denied"` // No policy applies.
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("policy-dryrun", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	policies := flags.String("policies", "policies.yaml", "path of the YAML or JSON policy rules")
+	cases := flags.String("cases", "", "path of the YAML or JSON test cases")
+	var in widevinepolicy.Input
+	flags.StringVar(&in.ContentID, "content-id", "", "content id, raw or base64")
+	flags.StringVar(&in.Tier, "tier", "", "user tier")
+	flags.Int64Var(&in.SecurityLevel, "security-level", 0, "device security level")
+	flags.StringVar(&in.Make, "make", "", "device make")
+	flags.StringVar(&in.Model, "model", "", "device model")
+	flags.StringVar(&in.Platform, "platform", "", "device platform")
+	flags.StringVar(&in.Country, "country", "", "user country")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	engine, err := widevinepolicy.Load(*policies)
+	if err != nil {
+		fmt.Fprintln(stderr, "policy-dryrun:", err)
+		return 1
+	}
+	if *cases != "" {
+		return runCases(engine, *cases, stdout, stderr)
+	}
+
+	encoder := json.NewEncoder(stdout)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(engine.DryRun(&in))
+	return 0
+}
+
+func runCases(engine *widevinepolicy.Engine, path string, stdout, stderr io.Writer) int {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		fmt.Fprintln(stderr, "policy-dryrun:", err)
+		return 1
+	}
+	var cases []testCase
+	if err := widevinepolicy.Unmarshal(b, &cases); err != nil {
+		fmt.Fprintf(stderr, "policy-dryrun: cases %s: %v\n", path, err)
+		return 1
+	}
+
+	status := 0
+	for i, c := range cases {
+		name := c.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+		decision, err := engine.Evaluate(&c.Input)
+		var got string
+		switch {
+		case err != nil && c.Denied:
+			fmt.Fprintf(stdout, "PASS %s: denied\n", name)
+			continue
+		case err != nil:
+			got = "denied"
+		case !c.Denied && decision.Policy == c.Expect:
+			fmt.Fprintf(stdout, "PASS %s: %s\n", name, decision.Policy)
+			continue
+		default:
+			got = decision.Policy
+			if decision.Rule != "" {
+				got += " (rule " + decision.Rule + ")"
+			}
+		}
+		want := c.Expect
+		if c.Denied {
+			want = "denied"
+		}
+		fmt.Fprintf(stdout, "FAIL %s: got %s, want %s\n", name, got, want)
+		status = 1
+	}
+	return status
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	widevinepolicy "github.com/cooomma/widevine-proxy/policy"
+	"github.com/stretchr/testify/assert"
+)
+
+const testPolicies = `
+default_policy: sd
+policies:
+  sd:
+    allowed_track_types: SD_ONLY
+  hd:
+    allowed_track_types: SD_HD
+rules:
+  - name: premium
+    policy: hd
+    match:
+      tiers: [premium]
+`
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func runDryRun(args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestDryRun(t *testing.T) {
+	policies := writeFile(t, "policies.yaml", testPolicies)
+	code, stdout, stderr := runDryRun("-policies", policies, "-content-id", "movie-1", "-tier", "premium")
+	assert.Equal(t, 0, code, stderr)
+	var report widevinepolicy.Report
+	if assert.NoError(t, json.Unmarshal([]byte(stdout), &report)) && assert.NotNil(t, report.Decision) {
+		assert.Equal(t, "hd", report.Decision.Policy)
+		assert.EqualValues(t, "SD_HD", report.Decision.Message.AllowedTrackTypes)
+	}
+
+	code, _, _ = runDryRun("-policies", filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.Equal(t, 1, code)
+}
+
+func TestDryRunCases(t *testing.T) {
+	policies := writeFile(t, "policies.yaml", testPolicies)
+	cases := writeFile(t, "cases.yaml", `
+- name: premium
+  input: {content_id: movie-1, tier: premium}
+  expect: hd
+- name: free
+  input: {content_id: movie-1}
+  expect: sd
+`)
+	code, stdout, stderr := runDryRun("-policies", policies, "-cases", cases)
+	assert.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, "PASS premium: hd")
+
+	cases = writeFile(t, "cases.json", `[{"name": "free", "input": {"content_id": "movie-1"}, "expect": "hd"}]`)
+	code, stdout, _ = runDryRun("-policies", policies, "-cases", cases)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stdout, "FAIL free: got sd, want hd")
+}
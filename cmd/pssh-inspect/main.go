@@ -0,0 +1,299 @@
+// Command pssh-inspect prints the pssh boxes of a content and the Widevine and PlayReady data they carry.
+//
+//	pssh-inspect AAAAOHBzc2gAAAAA7e+LqXnWSs6jyCfc1R0h7QAAABgSEJPk...
+//	pssh-inspect -json init.mp4
+//	pssh-inspect https://cdn.example.com/movie-1/manifest.mpd
+//
+// The input is a base64 or hex pssh box (or concatenated boxes), or the path or URL of a file holding pssh boxes,
+// an fMP4 init segment, a DASH manifest or an HLS playlist. An argument valid in both encodings is taken as base64,
+// unless it is a hex pssh box: -base64 or -hex forces the decoding. The exit status is 1 when the input is invalid,
+// or when the DRM systems carry different key ids.
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	widevineutils "github.com/cooomma/widevine-proxy/utils"
+)
+
+// Input kinds.
+const (
+	kindPSSH     = "pssh"
+	kindMP4      = "mp4"
+	kindMPD      = "mpd"
+	kindPlaylist = "hls"
+)
+
+// Argument encodings.
+const (
+	encodingHex    = "hex"
+	encodingBase64 = "base64"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("pssh-inspect", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	asJSON := flags.Bool("json", false, "print the summary as JSON")
+	kind := flags.String("type", "", "input type: pssh, mp4, mpd or hls, guessed if empty")
+	asHex := flags.Bool("hex", false, "decode the argument as hex")
+	asBase64 := flags.Bool("base64", false, "decode the argument as base64")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 1 || (*asHex && *asBase64) {
+		fmt.Fprintln(stderr, "usage: pssh-inspect [-json] [-type pssh|mp4|mpd|hls] [-hex|-base64] <base64|hex|path|url>")
+		return 2
+	}
+
+	encoding := ""
+	switch {
+	case *asHex:
+		encoding = encodingHex
+	case *asBase64:
+		encoding = encodingBase64
+	}
+	input, err := readInput(flags.Arg(0), encoding)
+	if err != nil {
+		fmt.Fprintln(stderr, "pssh-inspect:", err)
+		return 1
+	}
+	if *kind == "" {
+		*kind = guessKind(input)
+	}
+	r, err := inspect(*kind, input)
+	if err != nil {
+		fmt.Fprintln(stderr, "pssh-inspect:", err)
+		return 1
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(r)
+	} else {
+		r.print(stdout)
+	}
+	if r.KeyIDMismatch != "" {
+		fmt.Fprintln(stderr, "pssh-inspect:", r.KeyIDMismatch)
+		return 1
+	}
+	return 0
+}
+
+// readInput returns the bytes of a URL, of a file, or of the argument itself decoded from the given encoding.
+// Without an encoding, an argument valid in both is taken as base64 unless it is a hex pssh box:
+// short base64 strings are often made of hex digits only, while a base64 pssh box starts with "AAAA".
+func readInput(arg, encoding string) ([]byte, error) {
+	if encoding == "" {
+		if strings.HasPrefix(arg, "http://") || strings.HasPrefix(arg, "https://") {
+			return fetch(arg)
+		}
+		if b, err := ioutil.ReadFile(arg); err == nil {
+			return b, nil
+		}
+	}
+	arg = strings.TrimSpace(arg)
+	hexBytes, hexErr := hex.DecodeString(arg)
+	base64Bytes, base64Err := decodeBase64(arg)
+	switch encoding {
+	case encodingHex:
+		if hexErr != nil {
+			return nil, fmt.Errorf("%q is not hex", arg)
+		}
+		return hexBytes, nil
+	case encodingBase64:
+		if base64Err != nil {
+			return nil, fmt.Errorf("%q is not base64", arg)
+		}
+		return base64Bytes, nil
+	}
+	switch {
+	case hexErr == nil && (base64Err != nil || guessKind(hexBytes) == kindPSSH):
+		return hexBytes, nil
+	case base64Err == nil:
+		return base64Bytes, nil
+	}
+	return nil, fmt.Errorf("%q is neither a file, a URL, hex nor base64", arg)
+}
+
+// decodeBase64 decodes standard or URL base64, padded or not.
+func decodeBase64(s string) ([]byte, error) {
+	var err error
+	for _, encoding := range []*base64.Encoding{base64.StdEncoding, base64.URLEncoding, base64.RawStdEncoding, base64.RawURLEncoding} {
+		var b []byte
+		if b, err = encoding.DecodeString(s); err == nil {
+			return b, nil
+		}
+	}
+	return nil, err
+}
+
+func fetch(url string) ([]byte, error) {
+	client := &http.Client{Timeout: 30 * time.Second}
+	res, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", url, res.Status)
+	}
+	return ioutil.ReadAll(res.Body)
+}
+
+// guessKind tells manifests from boxes by their first bytes.
+func guessKind(b []byte) string {
+	text := bytes.TrimSpace(b)
+	switch {
+	case bytes.HasPrefix(text, []byte("#EXTM3U")):
+		return kindPlaylist
+	case bytes.HasPrefix(text, []byte("<")):
+		return kindMPD
+	case len(b) >= 8 && string(b[4:8]) == "pssh":
+		return kindPSSH
+	}
+	return kindMP4
+}
+
+// report is the summary printed for an input.
+type report struct {
+	Type          string             `json:"type"`
+	Boxes         []*box             `json:"pssh"`
+	PlayReady     []*playReadyReport `json:"playready,omitempty"`
+	DefaultKeyIDs []string           `json:"default_key_ids,omitempty"`
+	Schemes       []string           `json:"schemes,omitempty"`
+	KeyIDMismatch string             `json:"key_id_mismatch,omitempty"`
+
+	summaries []*widevineutils.PSSHSummary
+}
+
+type box struct {
+	Size      int64                       `json:"size"`
+	Version   string                      `json:"version"`
+	Flags     string                      `json:"flags"`
+	SystemID  string                      `json:"system_id"`
+	DRM       string                      `json:"drm,omitempty"`
+	KeyIDs    []string                    `json:"key_ids,omitempty"`
+	DataSize  int64                       `json:"data_size"`
+	Data      string                      `json:"data"`
+	Widevine  *widevineutils.WidevineInfo `json:"widevine,omitempty"`
+	PlayReady *playReadyReport            `json:"playready,omitempty"`
+}
+
+type playReadyReport struct {
+	Version   string   `json:"version"`
+	KeyIDs    []string `json:"key_ids,omitempty"`
+	Algorithm string   `json:"algorithm,omitempty"`
+	LAURL     string   `json:"la_url,omitempty"`
+	LUIURL    string   `json:"lui_url,omitempty"`
+	WRMHeader string   `json:"wrmheader"`
+}
+
+func inspect(kind string, input []byte) (*report, error) {
+	var (
+		protection *widevineutils.ProtectionSummary
+		err        error
+	)
+	switch kind {
+	case kindPSSH:
+		protection = &widevineutils.ProtectionSummary{}
+		protection.PSSH, err = widevineutils.ParseBoxes(input)
+	case kindMP4:
+		protection, err = widevineutils.ParseInitSegment(input)
+	case kindMPD:
+		protection, err = widevineutils.ParseMPD(input)
+	case kindPlaylist:
+		protection, err = widevineutils.ParsePlaylist(input)
+	default:
+		return nil, fmt.Errorf("unknown input type %q", kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	r := &report{
+		Type:          kind,
+		DefaultKeyIDs: protection.DefaultKeyIDs,
+		Schemes:       protection.Schemes,
+		summaries:     protection.PSSH,
+	}
+	for _, summary := range protection.PSSH {
+		b := &box{
+			Size:     summary.SizeDecimal,
+			Version:  summary.Version,
+			Flags:    summary.Flag,
+			SystemID: summary.DRMSystemID,
+			DRM:      summary.DRMName,
+			KeyIDs:   summary.KeyIDs,
+			DataSize: summary.DataSize,
+			Data:     summary.DataHex,
+		}
+		if summary.Widevine != nil {
+			b.Widevine = widevineutils.NewWidevineInfo(summary.Widevine)
+		}
+		if summary.PlayReady != nil {
+			b.PlayReady = newPlayReadyReport(summary.PlayReady)
+		}
+		r.Boxes = append(r.Boxes, b)
+	}
+	// PlayReady Objects given outside of a pssh box are checked as pssh boxes of their own.
+	summaries := protection.PSSH
+	for _, pr := range protection.PlayReady {
+		r.PlayReady = append(r.PlayReady, newPlayReadyReport(pr))
+		summaries = append(summaries, &widevineutils.PSSHSummary{DRMName: "playready", KeyIDs: pr.KeyIDs})
+	}
+	if len(summaries) == 0 {
+		return nil, errors.New("no pssh box found")
+	}
+	if err := widevineutils.MatchKeyIDs(summaries); err != nil {
+		r.KeyIDMismatch = err.Error()
+	}
+	return r, nil
+}
+
+func newPlayReadyReport(pr *widevineutils.PlayReadyHeader) *playReadyReport {
+	return &playReadyReport{
+		Version:   pr.Version,
+		KeyIDs:    pr.KeyIDs,
+		Algorithm: pr.Algorithm,
+		LAURL:     pr.LAURL,
+		LUIURL:    pr.LUIURL,
+		WRMHeader: pr.WRMHeader,
+	}
+}
+
+func (r *report) print(w io.Writer) {
+	for _, summary := range r.summaries {
+		(&widevineutils.PSSH{Summary: summary}).Fprint(w)
+		fmt.Fprintln(w)
+	}
+	for _, pr := range r.PlayReady {
+		fmt.Fprintln(w, "[PlayReady Object]")
+		fmt.Fprintln(w, "WRMHEADER     ", pr.Version)
+		fmt.Fprintln(w, "KeyIDs        ", pr.KeyIDs)
+		fmt.Fprintln(w, "Algorithm     ", pr.Algorithm)
+		fmt.Fprintln(w, "LA_URL        ", pr.LAURL)
+		fmt.Fprintln(w)
+	}
+	if len(r.DefaultKeyIDs) > 0 || len(r.Schemes) > 0 {
+		fmt.Fprintln(w, "[Tracks]")
+		fmt.Fprintln(w, "DefaultKeyIDs ", r.DefaultKeyIDs)
+		fmt.Fprintln(w, "Schemes       ", r.Schemes)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/cooomma/widevine-proxy/proxy/widevinetest"
+	widevineutils "github.com/cooomma/widevine-proxy/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+const testPSSH = "AAAAOHBzc2gAAAAA7e+LqXnWSs6jyCfc1R0h7QAAABgSEJPkt/Dij+qHMEpog1vObHFI49yVmwY="
+
+func runInspect(args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(args, &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+func TestInspectBase64(t *testing.T) {
+	code, stdout, stderr := runInspect(testPSSH)
+	assert.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, "widevine")
+	assert.Contains(t, stdout, "93e4b7f0e28fea87304a68835bce6c71")
+	assert.Contains(t, stdout, "cenc")
+}
+
+func TestInspectEncoding(t *testing.T) {
+	// "deadbeef" is both hex and base64: base64 wins unless it is asked for hex.
+	b, err := readInput("deadbeef", "")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0x75, 0xe6, 0x9d, 0x6d, 0xe7, 0x9f}, b)
+	b, err = readInput("deadbeef", encodingHex)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0xde, 0xad, 0xbe, 0xef}, b)
+
+	// A hex pssh box is valid base64 too.
+	box, _ := (&widevineutils.PSSHBuilder{KeyIDs: [][]byte{bytes.Repeat([]byte{0x11}, 16)}}).Hex()
+	b, err = readInput(box, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "pssh", string(b[4:8]))
+
+	code, _, stderr := runInspect("-base64", testPSSH)
+	assert.Equal(t, 0, code, stderr)
+	code, _, stderr = runInspect("-hex", testPSSH)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr, "is not hex")
+	code, _, _ = runInspect("-hex", "-base64", testPSSH)
+	assert.Equal(t, 2, code)
+}
+
+func TestInspectJSON(t *testing.T) {
+	cryptoPeriod := uint32(3)
+	box, _ := (&widevineutils.PSSHBuilder{
+		Version:           1,
+		KeyIDs:            [][]byte{bytes.Repeat([]byte{0x11}, 16)},
+		ContentID:         []byte("movie-1"),
+		Provider:          "widevine_test",
+		ProtectionScheme:  "cbcs",
+		CryptoPeriodIndex: &cryptoPeriod,
+	}).Hex()
+
+	code, stdout, stderr := runInspect("-json", box)
+	assert.Equal(t, 0, code, stderr)
+	var r report
+	if assert.NoError(t, json.Unmarshal([]byte(stdout), &r)) && assert.Len(t, r.Boxes, 1) {
+		wv := r.Boxes[0].Widevine
+		assert.Equal(t, "01", r.Boxes[0].Version)
+		assert.Equal(t, "widevine_test", wv.Provider)
+		assert.Equal(t, "movie-1", wv.ContentID)
+		assert.Equal(t, "cbcs", wv.ProtectionScheme)
+		assert.Equal(t, uint32(3), *wv.CryptoPeriodIndex)
+		assert.Equal(t, []string{"11111111111111111111111111111111"}, wv.KeyIDs)
+	}
+}
+
+func TestInspectFileAndURL(t *testing.T) {
+	keyID := bytes.Repeat([]byte{0x11}, 16)
+	widevine, _ := (&widevineutils.PSSHBuilder{KeyIDs: [][]byte{keyID}}).Base64()
+	mpd := `<MPD xmlns:cenc="urn:mpeg:cenc:2013"><Period><AdaptationSet>
+<ContentProtection schemeIdUri="urn:mpeg:dash:mp4protection:2011" value="cenc" cenc:default_KID="11111111-1111-1111-1111-111111111111"/>
+<ContentProtection schemeIdUri="urn:uuid:edef8ba9-79d6-4ace-a3c8-27dcd51d21ed"><cenc:pssh>` + widevine + `</cenc:pssh></ContentProtection>
+</AdaptationSet></Period></MPD>`
+	path := filepath.Join(t.TempDir(), "manifest.mpd")
+	ioutil.WriteFile(path, []byte(mpd), 0644)
+
+	code, stdout, stderr := runInspect(path)
+	assert.Equal(t, 0, code, stderr)
+	assert.Contains(t, stdout, "DefaultKeyIDs  [11111111111111111111111111111111]")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(mpd))
+	}))
+	defer server.Close()
+	code, _, stderr = runInspect(server.URL + "/manifest.mpd")
+	assert.Equal(t, 0, code, stderr)
+}
+
+func TestInspectInvalid(t *testing.T) {
+	box, _ := hex.DecodeString("000000387073736800000000edef8ba979d64acea3c827dcd51d21ed00000018")
+	for _, arg := range []string{hex.EncodeToString(box), "not a pssh box!", ""} {
+		code, _, stderr := runInspect(arg)
+		assert.Equal(t, 1, code, arg)
+		assert.Contains(t, stderr, "pssh-inspect:", arg)
+	}
+}
+
+func TestInspectKeyIDMismatch(t *testing.T) {
+	widevine, _ := (&widevineutils.PSSHBuilder{KeyIDs: [][]byte{bytes.Repeat([]byte{0x11}, 16)}}).Build()
+	systemID, _ := hex.DecodeString(widevineutils.PLAYREADY_SYSTEM_ID)
+	playready, _ := widevineutils.BuildPSSHBox(0, systemID, nil, widevinetest.PlayReadyObject([][]byte{bytes.Repeat([]byte{0x22}, 16)}))
+
+	code, stdout, stderr := runInspect(hex.EncodeToString(append(widevine, playready...)))
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stdout, "playready")
+	assert.Contains(t, stderr, "key ids of the DRM systems do not match")
+}
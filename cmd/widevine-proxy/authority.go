@@ -0,0 +1,86 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+
+	widevinekeystore "github.com/cooomma/widevine-proxy/keystore"
+	widevineproxy "github.com/cooomma/widevine-proxy/proxy"
+)
+
+// newAuthority returns the LicenseAuthority of a tenant: a key store backed one if key_store is set.
+func newAuthority(cfg *TenantConfig) (widevineproxy.LicenseAuthority, error) {
+	ca := newConfigAuthority(cfg)
+	if cfg.KeyStore == "" {
+		return ca, nil
+	}
+	store, err := widevinekeystore.LoadFileStore(cfg.KeyStore)
+	if err != nil {
+		return nil, err
+	}
+	keyring, err := loadKeyring(cfg.KeyStoreKeyring, cfg.KeyStoreKeyringEnv)
+	if err != nil {
+		return nil, err
+	}
+	return &widevinekeystore.Authority{
+		Store:             store,
+		Keyring:           keyring,
+		LicenseServerURL:  cfg.LicenseServer,
+		Provider:          cfg.Provider,
+		SigningKey:        ca.key,
+		SigningIV:         ca.iv,
+		AllowedTrackTypes: cfg.AllowedTrackTypes,
+		PolicyOverrides:   cfg.PolicyOverrides,
+	}, nil
+}
+
+// loadKeyring reads the keyring from path or from the environment variable env, nil if neither is set.
+func loadKeyring(path, env string) (*widevinekeystore.Keyring, error) {
+	switch {
+	case path != "":
+		return widevinekeystore.LoadKeyring(path)
+	case env != "":
+		return widevinekeystore.KeyringFromEnv(env)
+	}
+	return nil, nil
+}
+
+// configAuthority is a LicenseAuthority driven by the configuration of a tenant.
+// Content keys are left to the Widevine service, which derives them from the content id.
+type configAuthority struct {
+	cfg *TenantConfig
+	key []byte
+	iv  []byte
+}
+
+func newConfigAuthority(cfg *TenantConfig) *configAuthority {
+	key, _ := hex.DecodeString(cfg.Key)
+	iv, _ := hex.DecodeString(cfg.IV)
+	return &configAuthority{cfg: cfg, key: key, iv: iv}
+}
+
+func (a *configAuthority) BuildLicenseMessage(reqBody []byte, psshData *widevineproxy.PsshData) (*widevineproxy.Message, error) {
+	return &widevineproxy.Message{
+		Payload:           base64.StdEncoding.EncodeToString(reqBody),
+		Provider:          a.cfg.Provider,
+		ContentID:         psshData.ContentID,
+		AllowedTrackTypes: a.cfg.AllowedTrackTypes,
+		PolicyOverrides:   a.cfg.PolicyOverrides.Copy(),
+	}, nil
+}
+
+func (a *configAuthority) GetLicenseServerURL() string {
+	return a.cfg.LicenseServer
+}
+
+func (a *configAuthority) GetSigningKey() []byte {
+	return a.key
+}
+
+func (a *configAuthority) GetSigningIV() []byte {
+	return a.iv
+}
+
+func (a *configAuthority) GetProvider() string {
+	return a.cfg.Provider
+}
@@ -0,0 +1,324 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	widevineoffline "github.com/cooomma/widevine-proxy/offline"
+	widevineproxy "github.com/cooomma/widevine-proxy/proxy"
+	widevinesession "github.com/cooomma/widevine-proxy/session"
+)
+
+// Config is the JSON configuration of the license server.
+// The provider settings at the top level configure the default tenant, optional when tenants are configured.
+type Config struct {
+	Listen string `json:"listen"`
+	// AdminListen is the address of the admin server serving /debug/vars, kept off the license listener. Disabled if empty.
+	AdminListen string `json:"admin_listen"`
+	TenantConfig
+
+	// TenantHeader names the request header selecting a tenant by its header_value.
+	TenantHeader string         `json:"tenant_header"`
+	Tenants      []TenantConfig `json:"tenants"`
+
+	RequestTimeout  int `json:"request_timeout_seconds"`  // Deadline of a license request, upstream calls included.
+	UpstreamTimeout int `json:"upstream_timeout_seconds"` // Deadline of each call to the license service.
+
+	Retry          RetryConfig          `json:"retry"`
+	CircuitBreaker CircuitBreakerConfig `json:"circuit_breaker"`
+
+	Log LogConfig `json:"log"`
+}
+
+// TenantConfig is a provider served by the license server, with the requests selecting it.
+type TenantConfig struct {
+	Name        string   `json:"name"`
+	Hosts       []string `json:"hosts"`
+	PathPrefix  string   `json:"path_prefix"`  // e.g. /brand-a, serving POST /brand-a/license.
+	HeaderValue string   `json:"header_value"` // Value of the tenant_header.
+
+	LicenseServer string `json:"license_server"`
+	Provider      string `json:"provider"`
+	Key           string `json:"key"` // Hex encoded 32 bytes signing key given by Widevine.
+	IV            string `json:"iv"`  // Hex encoded 16 bytes signing IV given by Widevine.
+
+	AllowedTrackTypes widevineproxy.AllowedTrackType `json:"allowed_track_types"`
+	PolicyOverrides   *widevineproxy.PolicyOverrides `json:"policy_overrides"`
+
+	// Auth verifies the bearer tokens of the tenant. The auth at the top level applies to the tenants without their own,
+	// whose tokens are then accepted by all of them.
+	Auth *AuthConfig `json:"auth"`
+	// Policies is the path of the YAML or JSON policy rules, replacing allowed_track_types and policy_overrides.
+	Policies string `json:"policies"`
+	// DeviceRules drops the keys of the track types whose requirements the client device does not meet,
+	// applied after the policies.
+	DeviceRules map[widevineproxy.ContentTrackType]widevineproxy.TrackRequirement `json:"device_rules"`
+	// DeviceList is the path of the JSON allow and deny lists of devices, reloaded when changed.
+	// DeviceListAudit is the path of the file the denied devices are appended to, as JSON lines.
+	DeviceList      string `json:"device_list"`
+	DeviceListAudit string `json:"device_list_audit"`
+	// KeyStore is the path of a JSON key store. When set, licenses carry the content keys of the store
+	// instead of keys derived by the Widevine service.
+	KeyStore string `json:"key_store"`
+	// KeyStoreKeyring is the path of the keyring unwrapping the keys of the key store.
+	// KeyStoreKeyringEnv names the environment variable holding it instead.
+	KeyStoreKeyring    string `json:"key_store_keyring"`
+	KeyStoreKeyringEnv string `json:"key_store_keyring_env"`
+	// Sessions limits the concurrent streams and the registered devices of the authenticated users.
+	Sessions *SessionsConfig `json:"sessions"`
+	// Offline caps the offline licenses of the authenticated users, which they can list and revoke.
+	Offline *OfflineConfig `json:"offline"`
+}
+
+// SessionsConfig limits the sessions of the users, counted in memory by each instance of the proxy.
+// The limits embedded at the top level apply to the users whose tier is not listed in tiers.
+type SessionsConfig struct {
+	widevinesession.Limits
+	Tiers         map[string]widevinesession.Limits `json:"tiers"`
+	TierAttribute string                            `json:"tier_attribute"` // Claim holding the tier, tier by default.
+
+	HeartbeatInterval int `json:"heartbeat_interval_seconds"` // Renewal delay of the streaming licenses, 60 by default.
+	HeartbeatTimeout  int `json:"heartbeat_timeout_seconds"`  // Ends the sessions without heartbeat, 3 intervals by default.
+	DeviceTTL         int `json:"device_ttl_hours"`           // Unregisters the unused devices, never if 0.
+}
+
+// OfflineConfig limits the offline licenses of the users, recorded in the ledger file, or in memory if empty.
+// The limits embedded at the top level apply to the users whose tier is not listed in tiers.
+type OfflineConfig struct {
+	widevineoffline.Limits
+	Tiers         map[string]widevineoffline.Limits `json:"tiers"`
+	TierAttribute string                            `json:"tier_attribute"` // Claim holding the tier, tier by default.
+	// TitleDownloads overrides max_title_downloads for some content ids (raw or base64).
+	TitleDownloads map[string]int `json:"title_downloads"`
+	Ledger         string         `json:"ledger"`
+}
+
+// defaultTenant is the name of the tenant configured at the top level.
+const defaultTenant = "default"
+
+// RetryConfig controls the retries of the idempotent calls to the license service.
+type RetryConfig struct {
+	MaxAttempts int `json:"max_attempts"` // 1 disables retries.
+	BaseDelay   int `json:"base_delay_ms"`
+	MaxDelay    int `json:"max_delay_ms"`
+}
+
+// CircuitBreakerConfig controls when calls to a degraded license service fail fast.
+type CircuitBreakerConfig struct {
+	FailureThreshold int `json:"failure_threshold"` // Consecutive failures opening the circuit.
+	OpenTimeout      int `json:"open_seconds"`      // Time before probing the license service again.
+}
+
+// AuthConfig enables bearer token verification on /license, with the keys, issuer and audience of a tenant.
+type AuthConfig struct {
+	JWKS     string `json:"jwks"` // Path of the JSON Web Key Set trusted to sign tokens.
+	Issuer   string `json:"issuer"`
+	Audience string `json:"audience"`
+}
+
+// LogConfig controls where and how the server writes its logs.
+type LogConfig struct {
+	Level            string `json:"level"`
+	Path             string `json:"path"` // strftime pattern, e.g. /var/log/widevine-proxy/proxy.%Y%m%d.log. Stdout if empty.
+	MaxAgeDays       int    `json:"max_age_days"`
+	RotationInterval int    `json:"rotation_hours"`
+}
+
+// LoadConfig reads the JSON configuration from path and validates it.
+func LoadConfig(path string) (*Config, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &Config{
+		Listen:          ":8080",
+		AdminListen:     "127.0.0.1:8081",
+		TenantConfig:    TenantConfig{AllowedTrackTypes: widevineproxy.AllowedTrackTypeSD},
+		RequestTimeout:  15,
+		UpstreamTimeout: 5,
+		Retry: RetryConfig{
+			MaxAttempts: 3,
+			BaseDelay:   100,
+			MaxDelay:    2000,
+		},
+		CircuitBreaker: CircuitBreakerConfig{
+			FailureThreshold: 5,
+			OpenTimeout:      30,
+		},
+		Log: LogConfig{
+			Level:            "info",
+			MaxAgeDays:       7,
+			RotationInterval: 24,
+		},
+	}
+	if err := json.Unmarshal(b, cfg); err != nil {
+		return nil, fmt.Errorf("decode config %s: %v", path, err)
+	}
+	for i := range cfg.Tenants {
+		if cfg.Tenants[i].AllowedTrackTypes == "" {
+			cfg.Tenants[i].AllowedTrackTypes = widevineproxy.AllowedTrackTypeSD
+		}
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %v", path, err)
+	}
+	return cfg, nil
+}
+
+func (cfg *Config) validate() error {
+	if len(cfg.Tenants) == 0 || cfg.LicenseServer != "" {
+		if err := cfg.TenantConfig.validate(); err != nil {
+			return err
+		}
+	}
+	names := map[string]bool{defaultTenant: true}
+	for i := range cfg.Tenants {
+		tc := &cfg.Tenants[i]
+		if tc.Name == "" || names[tc.Name] {
+			return fmt.Errorf("tenants[%d]: name is required, unique and not %s", i, defaultTenant)
+		}
+		names[tc.Name] = true
+		if len(tc.Hosts) == 0 && tc.PathPrefix == "" && tc.HeaderValue == "" {
+			return fmt.Errorf("tenant %s: hosts, path_prefix or header_value is required", tc.Name)
+		}
+		if tc.HeaderValue != "" && cfg.TenantHeader == "" {
+			return fmt.Errorf("tenant %s: header_value requires tenant_header", tc.Name)
+		}
+		if err := tc.validate(); err != nil {
+			return fmt.Errorf("tenant %s: %v", tc.Name, err)
+		}
+	}
+	if cfg.RequestTimeout < 0 || cfg.UpstreamTimeout <= 0 {
+		return fmt.Errorf("request_timeout_seconds must not be negative and upstream_timeout_seconds must be positive")
+	}
+	if cfg.Retry.MaxAttempts < 1 || cfg.Retry.BaseDelay < 0 || cfg.Retry.MaxDelay < 0 {
+		return fmt.Errorf("retry.max_attempts must be positive and delays must not be negative")
+	}
+	if cfg.CircuitBreaker.FailureThreshold < 1 || cfg.CircuitBreaker.OpenTimeout < 0 {
+		return fmt.Errorf("circuit_breaker.failure_threshold must be positive and open_seconds must not be negative")
+	}
+	if cfg.Auth != nil && cfg.Auth.JWKS == "" {
+		return fmt.Errorf("auth.jwks is required")
+	}
+	if cfg.Auth == nil && cfg.Sessions != nil {
+		return fmt.Errorf("sessions requires auth")
+	}
+	if cfg.Auth == nil && cfg.Offline != nil {
+		return fmt.Errorf("offline requires auth")
+	}
+	for i := range cfg.Tenants {
+		tc := &cfg.Tenants[i]
+		if cfg.authOf(tc) == nil && tc.Sessions != nil {
+			return fmt.Errorf("tenant %s: sessions requires auth", tc.Name)
+		}
+		if cfg.authOf(tc) == nil && tc.Offline != nil {
+			return fmt.Errorf("tenant %s: offline requires auth", tc.Name)
+		}
+	}
+	return nil
+}
+
+// authOf returns the auth of tc, the auth at the top level if tc has none. Nil if tokens are not required.
+func (cfg *Config) authOf(tc *TenantConfig) *AuthConfig {
+	if tc.Auth != nil {
+		return tc.Auth
+	}
+	return cfg.Auth
+}
+
+func (tc *TenantConfig) validate() error {
+	if tc.LicenseServer == "" {
+		return fmt.Errorf("license_server is required")
+	}
+	if tc.Provider == "" {
+		return fmt.Errorf("provider is required")
+	}
+	if key, err := hex.DecodeString(tc.Key); err != nil || len(key) != 32 {
+		return fmt.Errorf("key must be 32 bytes hex encoded")
+	}
+	if iv, err := hex.DecodeString(tc.IV); err != nil || len(iv) != 16 {
+		return fmt.Errorf("iv must be 16 bytes hex encoded")
+	}
+	if tc.KeyStoreKeyring != "" && tc.KeyStoreKeyringEnv != "" {
+		return fmt.Errorf("key_store_keyring and key_store_keyring_env are exclusive")
+	}
+	if tc.Auth != nil && tc.Auth.JWKS == "" {
+		return fmt.Errorf("auth.jwks is required")
+	}
+	if tc.DeviceListAudit != "" && tc.DeviceList == "" {
+		return fmt.Errorf("device_list_audit requires device_list")
+	}
+	if tc.Sessions != nil {
+		if err := tc.Sessions.validate(); err != nil {
+			return fmt.Errorf("sessions: %v", err)
+		}
+	}
+	if tc.Offline != nil {
+		if err := tc.Offline.validate(); err != nil {
+			return fmt.Errorf("offline: %v", err)
+		}
+	}
+	for trackType := range tc.DeviceRules {
+		switch trackType {
+		case widevineproxy.ContentTrackTypeAudio, widevineproxy.ContentTrackTypeSD, widevineproxy.ContentTrackTypeHD,
+			widevineproxy.ContentTrackTypeUHD1, widevineproxy.ContentTrackTypeUHD2:
+		default:
+			return fmt.Errorf("device_rules: unknown track type %s", trackType)
+		}
+	}
+	return nil
+}
+
+func (sc *SessionsConfig) validate() error {
+	if sc.HeartbeatInterval < 0 || sc.HeartbeatTimeout < 0 || sc.DeviceTTL < 0 {
+		return fmt.Errorf("heartbeat_interval_seconds, heartbeat_timeout_seconds and device_ttl_hours must not be negative")
+	}
+	if interval, timeout := sc.heartbeat(); timeout <= interval {
+		return fmt.Errorf("heartbeat_timeout_seconds must exceed heartbeat_interval_seconds")
+	}
+	for tier, limits := range sc.Tiers {
+		if limits.MaxStreams < 0 || limits.MaxDevices < 0 || limits.MaxDeviceStreams < 0 {
+			return fmt.Errorf("tier %s: limits must not be negative", tier)
+		}
+	}
+	if sc.MaxStreams < 0 || sc.MaxDevices < 0 || sc.MaxDeviceStreams < 0 {
+		return fmt.Errorf("limits must not be negative")
+	}
+	return nil
+}
+
+// heartbeat returns the heartbeat interval and timeout, defaults applied.
+func (sc *SessionsConfig) heartbeat() (interval, timeout time.Duration) {
+	interval = widevinesession.DefaultHeartbeatInterval
+	if sc.HeartbeatInterval > 0 {
+		interval = time.Duration(sc.HeartbeatInterval) * time.Second
+	}
+	timeout = 3 * interval
+	if sc.HeartbeatTimeout > 0 {
+		timeout = time.Duration(sc.HeartbeatTimeout) * time.Second
+	}
+	return interval, timeout
+}
+
+func (oc *OfflineConfig) validate() error {
+	negative := func(l widevineoffline.Limits) bool {
+		return l.MaxLicenses < 0 || l.MaxTitleLicenses < 0 || l.MaxTitleDownloads < 0
+	}
+	for tier, limits := range oc.Tiers {
+		if negative(limits) {
+			return fmt.Errorf("tier %s: limits must not be negative", tier)
+		}
+	}
+	for contentID, max := range oc.TitleDownloads {
+		if max < 0 {
+			return fmt.Errorf("title_downloads %s must not be negative", contentID)
+		}
+	}
+	if negative(oc.Limits) {
+		return fmt.Errorf("limits must not be negative")
+	}
+	return nil
+}
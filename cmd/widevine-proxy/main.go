@@ -0,0 +1,114 @@
+// Command widevine-proxy runs an HTTP license server in front of the Widevine cloud license service.
+//
+//	POST /license     raw CDM challenge in, raw license (or service certificate) out
+//	POST <prefix>/license  the same, for the tenant of the path prefix
+//	GET  /healthz     liveness probe
+//
+// and, on the admin listener,
+//
+//	GET  /debug/vars  expvar metrics, including the circuit breakers and license requests of the tenants
+package main
+
+import (
+	"context"
+	"expvar"
+	"flag"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	widevineproxy "github.com/cooomma/widevine-proxy/proxy"
+	"github.com/labstack/echo/v4"
+	rotatelogs "github.com/lestrrat-go/file-rotatelogs"
+	"github.com/sirupsen/logrus"
+)
+
+func main() {
+	configPath := flag.String("config", "config.json", "path of the JSON configuration file")
+	flag.Parse()
+
+	cfg, err := LoadConfig(*configPath)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+	logger, err := newLogger(cfg.Log)
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	verifiers, err := newVerifiers(cfg)
+	if err != nil {
+		logger.WithError(err).Fatal("JWKS Load Failure")
+	}
+
+	registry, err := newRegistry(cfg, logger)
+	if err != nil {
+		logger.WithError(err).Fatal("Tenant Initialization Failure")
+	}
+	expvar.Publish("license_service_circuit", expvar.Func(func() interface{} {
+		stats := map[string]widevineproxy.CircuitStats{}
+		for _, tenant := range registry.Tenants() {
+			stats[tenant.Name] = tenant.Proxy.Breaker.Stats()
+		}
+		return stats
+	}))
+	e := newServer(registry, verifiers, time.Duration(cfg.RequestTimeout)*time.Second, logger)
+
+	go func() {
+		logger.WithField("listen", cfg.Listen).Info("Widevine Proxy Started")
+		if err := e.Start(cfg.Listen); err != nil && err != http.ErrServerClosed {
+			logger.WithError(err).Fatal("Widevine Proxy Stopped")
+		}
+	}()
+
+	var admin *echo.Echo
+	if cfg.AdminListen != "" {
+		admin = newAdminServer()
+		go func() {
+			logger.WithField("listen", cfg.AdminListen).Info("Admin Server Started")
+			if err := admin.Start(cfg.AdminListen); err != nil && err != http.ErrServerClosed {
+				logger.WithError(err).Fatal("Admin Server Stopped")
+			}
+		}()
+	}
+
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	<-quit
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancel()
+	if err := e.Shutdown(ctx); err != nil {
+		logger.WithError(err).Error("Graceful Shutdown Failure")
+	}
+	if admin != nil {
+		if err := admin.Shutdown(ctx); err != nil {
+			logger.WithError(err).Error("Admin Server Shutdown Failure")
+		}
+	}
+}
+
+func newLogger(cfg LogConfig) (*logrus.Logger, error) {
+	logger := logrus.New()
+	logger.SetFormatter(&logrus.JSONFormatter{})
+
+	level, err := logrus.ParseLevel(cfg.Level)
+	if err != nil {
+		return nil, err
+	}
+	logger.SetLevel(level)
+
+	if cfg.Path != "" {
+		writer, err := rotatelogs.New(cfg.Path,
+			rotatelogs.WithMaxAge(time.Duration(cfg.MaxAgeDays)*24*time.Hour),
+			rotatelogs.WithRotationTime(time.Duration(cfg.RotationInterval)*time.Hour),
+		)
+		if err != nil {
+			return nil, err
+		}
+		logger.SetOutput(writer)
+	}
+	return logger, nil
+}
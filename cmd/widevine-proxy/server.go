@@ -0,0 +1,330 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	widevineauth "github.com/cooomma/widevine-proxy/auth"
+	widevinekeystore "github.com/cooomma/widevine-proxy/keystore"
+	widevineoffline "github.com/cooomma/widevine-proxy/offline"
+	widevineproxy "github.com/cooomma/widevine-proxy/proxy"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/sirupsen/logrus"
+)
+
+// maxChallengeSize bounds the CDM challenge accepted on /license.
+const maxChallengeSize = "64K"
+
+// traceHeaders are carried from the player request to the calls to the license service.
+var traceHeaders = []string{echo.HeaderXRequestID, "Traceparent", "Tracestate"}
+
+// licenseRequests counts the license requests per tenant and HTTP status.
+var (
+	licenseRequests   = expvar.NewMap("license_requests")
+	licenseRequestsMu sync.Mutex
+)
+
+// tenantKey is the key of the name of the tenant in the echo context.
+const tenantKey = "tenant"
+
+// tenantVerifiers holds the bearer token verifier of each tenant requiring tokens, keyed by tenant name.
+type tenantVerifiers map[string]*widevineauth.Verifier
+
+type server struct {
+	registry *widevineproxy.TenantRegistry
+	logger   *logrus.Logger
+	// auth verifies the bearer tokens of a tenant, keyed by tenant name. Tenants without entry require no token.
+	auth map[string]echo.MiddlewareFunc
+
+	// requestTimeout bounds the handling of a license request, upstream calls included. Unbounded if zero.
+	requestTimeout time.Duration
+}
+
+// newServer wires the license endpoints on top of the proxies of the tenants of registry.
+// POST /license serves the tenant selected by header or host, and POST <path_prefix>/license the tenant of the prefix.
+// The tenants with a verifier require a bearer token signed for them, whose claims restrict the license.
+// requestTimeout bounds each license request, zero leaves it to the client.
+// With verifiers, GET /offline-licenses lists the offline licenses of the user of the token,
+// and DELETE /offline-licenses/:id revokes one, on the tenants recording them.
+func newServer(registry *widevineproxy.TenantRegistry, verifiers tenantVerifiers, requestTimeout time.Duration, logger *logrus.Logger) *echo.Echo {
+	s := &server{registry: registry, logger: logger, auth: map[string]echo.MiddlewareFunc{}, requestTimeout: requestTimeout}
+	for name, verifier := range verifiers {
+		s.auth[name] = echo.WrapMiddleware(widevineauth.Middleware(verifier))
+	}
+
+	e := echo.New()
+	e.HideBanner = true
+	e.HidePort = true
+	e.Use(middleware.Recover())
+	e.Use(s.trace)
+	e.Use(s.accessLog)
+
+	e.GET("/healthz", s.healthz)
+	prefixes := []string{""}
+	for _, tenant := range registry.Tenants() {
+		if tenant.PathPrefix != "" {
+			prefixes = append(prefixes, tenant.PathPrefix)
+		}
+	}
+	for _, prefix := range prefixes {
+		e.POST(prefix+"/license", s.license, middleware.BodyLimit(maxChallengeSize), s.authenticate)
+		if len(verifiers) > 0 {
+			e.GET(prefix+"/offline-licenses", s.offlineLicenses, s.authenticate)
+			e.DELETE(prefix+"/offline-licenses/:id", s.revokeOfflineLicense, s.authenticate)
+		}
+	}
+	return e
+}
+
+// newAdminServer serves the expvar metrics on GET /debug/vars, for a listener apart from the license endpoints.
+func newAdminServer() *echo.Echo {
+	e := echo.New()
+	e.HideBanner = true
+	e.HidePort = true
+	e.Use(middleware.Recover())
+	e.GET("/debug/vars", echo.WrapHandler(expvar.Handler()))
+	return e
+}
+
+// authenticate verifies the bearer token of the request with the verifier of its tenant, if any.
+func (s *server) authenticate(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		tenant, err := s.resolve(c)
+		if err != nil {
+			return err
+		}
+		if auth, ok := s.auth[tenant.Name]; ok {
+			return auth(next)(c)
+		}
+		return next(c)
+	}
+}
+
+func (s *server) healthz(c echo.Context) error {
+	return c.String(http.StatusOK, "ok")
+}
+
+// license takes the raw CDM challenge and answers with the raw license (or service certificate) bytes.
+// The claims of the bearer token, if any, are carried by the request context.
+func (s *server) license(c echo.Context) error {
+	body, err := ioutil.ReadAll(c.Request().Body)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "could not read license challenge")
+	}
+	if len(body) == 0 {
+		return echo.NewHTTPError(http.StatusBadRequest, "empty license challenge")
+	}
+
+	tenant, err := s.resolve(c)
+	if err != nil {
+		return err
+	}
+	c.Set(tenantKey, tenant.Name)
+
+	ctx := c.Request().Context()
+	if s.requestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.requestTimeout)
+		defer cancel()
+	}
+	response, err := tenant.Proxy.GetLicenseWithContext(ctx, body, nil)
+	if err != nil {
+		return s.licenseError(err, tenant)
+	}
+	license, err := base64.StdEncoding.DecodeString(response.License)
+	if err != nil {
+		s.logger.WithError(err).WithField("tenant", tenant.Name).Error("License Decode Error")
+		return echo.NewHTTPError(http.StatusBadGateway, "malformed license from license service")
+	}
+	return c.Blob(http.StatusOK, "application/octet-stream", license)
+}
+
+// resolve returns the tenant of the request. A 404 if unknown, a 403 if the request selects another tenant than its host.
+func (s *server) resolve(c echo.Context) (*widevineproxy.Tenant, error) {
+	tenant, err := s.registry.Resolve(c.Request())
+	if errors.Is(err, widevineproxy.ErrTenantMismatch) {
+		return nil, echo.NewHTTPError(http.StatusForbidden, err.Error())
+	}
+	if err != nil {
+		return nil, echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
+	return tenant, nil
+}
+
+// offlineLicenses answers with the offline licenses holding a slot of the user of the token, the revoked ones included.
+func (s *server) offlineLicenses(c echo.Context) error {
+	tenant, ledger, userID, err := s.offlineLedger(c)
+	if err != nil {
+		return err
+	}
+	licenses, err := ledger.Licenses(c.Request().Context(), userID)
+	if err != nil {
+		s.logger.WithError(err).WithField("tenant", tenant.Name).Error("Offline License Listing Failure")
+		return echo.NewHTTPError(http.StatusInternalServerError, "could not list offline licenses")
+	}
+	if licenses == nil {
+		licenses = []*widevineoffline.License{}
+	}
+	return c.JSON(http.StatusOK, map[string]interface{}{"licenses": licenses})
+}
+
+// revokeOfflineLicense revokes an offline license of the user of the token.
+func (s *server) revokeOfflineLicense(c echo.Context) error {
+	tenant, ledger, userID, err := s.offlineLedger(c)
+	if err != nil {
+		return err
+	}
+	// Base64 license ids may come escaped.
+	id, err := url.PathUnescape(c.Param("id"))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, "malformed license id")
+	}
+	err = ledger.Revoke(c.Request().Context(), userID, id)
+	if errors.Is(err, widevineoffline.ErrLicenseNotFound) {
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	}
+	if err != nil {
+		s.logger.WithError(err).WithField("tenant", tenant.Name).Error("Offline License Revocation Failure")
+		return echo.NewHTTPError(http.StatusInternalServerError, "could not revoke offline license")
+	}
+	s.logger.WithFields(logrus.Fields{"tenant": tenant.Name, "user_id": userID, "license_id": id}).Info("Offline License Revoked")
+	return c.NoContent(http.StatusNoContent)
+}
+
+// offlineLedger returns the tenant of the request, its offline ledger and the user of the token.
+func (s *server) offlineLedger(c echo.Context) (tenant *widevineproxy.Tenant, ledger *widevineoffline.Ledger, userID string, err error) {
+	if tenant, err = s.resolve(c); err != nil {
+		return nil, nil, "", err
+	}
+	if ledger = ledgerOf(tenant); ledger == nil {
+		return nil, nil, "", echo.NewHTTPError(http.StatusNotFound, "offline licenses are not tracked")
+	}
+	claims := widevineproxy.ClaimsFromContext(c.Request().Context())
+	if claims == nil || claims.UserID == "" {
+		return nil, nil, "", echo.NewHTTPError(http.StatusForbidden, "token without user id")
+	}
+	return tenant, ledger, claims.UserID, nil
+}
+
+// licenseError maps a proxy failure onto the HTTP status and message returned to the player.
+func (s *server) licenseError(err error, tenant *widevineproxy.Tenant) error {
+	logger := s.logger.WithError(err).WithField("tenant", tenant.Name)
+	logger.Warn("License Request Rejected")
+
+	var (
+		malformed     *widevineproxy.MalformedMessageError
+		upstream      *widevineproxy.UpstreamError
+		rejected      *widevineproxy.LicenseError
+		denied        *widevineproxy.AuthorizationDeniedError
+		renewalDenied *widevineproxy.RenewalDeniedError
+		syntaxErr     *json.SyntaxError
+		typeErr       *json.UnmarshalTypeError
+	)
+	switch {
+	case errors.As(err, &malformed):
+		return echo.NewHTTPError(http.StatusBadRequest, malformed.Error())
+	case errors.Is(err, context.Canceled):
+		return echo.NewHTTPError(http.StatusServiceUnavailable, "license request cancelled")
+	case errors.Is(err, context.DeadlineExceeded):
+		return echo.NewHTTPError(http.StatusGatewayTimeout, "license service timeout")
+	case errors.Is(err, widevineproxy.ErrCircuitOpen):
+		return echo.NewHTTPError(http.StatusServiceUnavailable, "license service unavailable")
+	case errors.As(err, &upstream):
+		if upstream.Timeout() {
+			return echo.NewHTTPError(http.StatusGatewayTimeout, "license service timeout")
+		}
+		if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+			return echo.NewHTTPError(http.StatusBadGateway, "malformed response from license service")
+		}
+		return echo.NewHTTPError(http.StatusBadGateway, "license service unavailable")
+	case errors.Is(err, widevineproxy.ErrInvalidLicenseChallenge):
+		return echo.NewHTTPError(http.StatusBadRequest, "license challenge rejected by license service")
+	case errors.Is(err, widevineproxy.ErrSignatureFailure):
+		logger.Error("License Service Rejected Proxy Credentials")
+		return echo.NewHTTPError(http.StatusInternalServerError, "license server misconfigured")
+	case errors.Is(err, widevineproxy.ErrProviderAccessDenied):
+		return echo.NewHTTPError(http.StatusForbidden, "provider not allowed to license this content")
+	case errors.Is(err, widevineproxy.ErrUpstreamUnavailable):
+		return echo.NewHTTPError(http.StatusBadGateway, "license service unavailable")
+	case errors.As(err, &rejected):
+		return echo.NewHTTPError(http.StatusForbidden, "license denied: "+rejected.Response.Status)
+	case errors.As(err, &denied), errors.As(err, &renewalDenied):
+		return echo.NewHTTPError(http.StatusForbidden, err.Error())
+	case errors.Is(err, widevinekeystore.ErrKeyNotFound):
+		return echo.NewHTTPError(http.StatusForbidden, "no content key for this content")
+	}
+	return echo.NewHTTPError(http.StatusInternalServerError, "license request failed")
+}
+
+// trace puts the tracing headers of the request, and a generated X-Request-Id if missing, into the request context.
+func (s *server) trace(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		req := c.Request()
+		if req.Header.Get(echo.HeaderXRequestID) == "" {
+			req.Header.Set(echo.HeaderXRequestID, newRequestID())
+		}
+		c.Response().Header().Set(echo.HeaderXRequestID, req.Header.Get(echo.HeaderXRequestID))
+
+		md := widevineproxy.TraceMetadata{}
+		for _, header := range traceHeaders {
+			if value := req.Header.Get(header); value != "" {
+				md[header] = value
+			}
+		}
+		c.SetRequest(req.WithContext(widevineproxy.WithTraceMetadata(req.Context(), md)))
+		return next(c)
+	}
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (s *server) accessLog(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		start := time.Now()
+		err := next(c)
+		if err != nil {
+			c.Error(err)
+		}
+		fields := logrus.Fields{
+			"method":  c.Request().Method,
+			"path":    c.Request().URL.Path,
+			"status":  c.Response().Status,
+			"remote":  c.RealIP(),
+			"latency": time.Since(start).String(),
+			"request": c.Response().Header().Get(echo.HeaderXRequestID),
+		}
+		if tenant, ok := c.Get(tenantKey).(string); ok {
+			fields["tenant"] = tenant
+			countLicenseRequest(tenant, c.Response().Status)
+		}
+		s.logger.WithFields(fields).Info("Request Served")
+		return nil
+	}
+}
+
+func countLicenseRequest(tenant string, status int) {
+	licenseRequestsMu.Lock()
+	statuses, ok := licenseRequests.Get(tenant).(*expvar.Map)
+	if !ok {
+		statuses = new(expvar.Map).Init()
+		licenseRequests.Set(tenant, statuses)
+	}
+	licenseRequestsMu.Unlock()
+	statuses.Add(strconv.Itoa(status), 1)
+}
@@ -0,0 +1,696 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"expvar"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	widevineauth "github.com/cooomma/widevine-proxy/auth"
+	widevineoffline "github.com/cooomma/widevine-proxy/offline"
+	pb "github.com/cooomma/widevine-proxy/proto"
+	widevineproxy "github.com/cooomma/widevine-proxy/proxy"
+	"github.com/cooomma/widevine-proxy/proxy/widevinetest"
+	widevinesession "github.com/cooomma/widevine-proxy/session"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+var testChallenge = widevinetest.LicenseChallenge(&pb.WidevineCencHeader{
+	KeyId:     [][]byte{bytes.Repeat([]byte{0x11}, 16)},
+	ContentId: []byte("fake-content"),
+}, pb.LicenseType_STREAMING)
+
+func newTestServer(t *testing.T, upstream http.Handler) http.Handler {
+	t.Helper()
+	ls := httptest.NewServer(upstream)
+	t.Cleanup(ls.Close)
+
+	cfg := &Config{
+		TenantConfig:    testTenantConfig(ls.URL),
+		UpstreamTimeout: 5,
+		Retry:           RetryConfig{MaxAttempts: 1},
+		CircuitBreaker:  CircuitBreakerConfig{FailureThreshold: 5},
+	}
+	return newTestServerWithConfig(t, cfg)
+}
+
+func testTenantConfig(licenseServer string) TenantConfig {
+	return TenantConfig{
+		LicenseServer:     licenseServer,
+		Provider:          "widevine_test",
+		Key:               "1ae8ccd0e7985cc0b6203a55855a1034afc252980e970ca90e5202689f947ab9",
+		IV:                "d58ce954203b7c9a9a9d467f59839249",
+		AllowedTrackTypes: widevineproxy.AllowedTrackTypeSD,
+	}
+}
+
+func newTestServerWithConfig(t *testing.T, cfg *Config) http.Handler {
+	t.Helper()
+	assert.NoError(t, cfg.validate())
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	registry, err := newRegistry(cfg, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newServer(registry, nil, 0, logger)
+}
+
+func newTestService() *widevinetest.Service {
+	key, _ := hex.DecodeString("1ae8ccd0e7985cc0b6203a55855a1034afc252980e970ca90e5202689f947ab9")
+	iv, _ := hex.DecodeString("d58ce954203b7c9a9a9d467f59839249")
+	return widevinetest.NewService("widevine_test", key, iv)
+}
+
+func postLicense(h http.Handler, body []byte) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/license", bytes.NewReader(body)))
+	return rec
+}
+
+func TestHealthz(t *testing.T) {
+	h := newTestServer(t, newTestService())
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestAdminServer(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newTestServer(t, newTestService()).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/vars", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = httptest.NewRecorder()
+	newAdminServer().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/vars", nil))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"license_requests"`)
+}
+
+func TestLicenseReturnsDecodedBlob(t *testing.T) {
+	svc := newTestService()
+	h := newTestServer(t, svc)
+
+	rec := postLicense(h, testChallenge)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/octet-stream", rec.Header().Get("Content-Type"))
+	assert.Equal(t, svc.License, rec.Body.Bytes())
+
+	rec = postLicense(h, widevinetest.ServiceCertificateRequest)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, svc.ServiceCertificate, rec.Body.Bytes())
+}
+
+func TestLicenseErrorStatus(t *testing.T) {
+	svc := newTestService()
+	svc.Statuses[widevinetest.RequestTypeLicense] = widevinetest.StatusSignatureFailure
+	svc.Statuses[widevinetest.RequestTypeCertificate] = widevinetest.StatusSignatureFailure
+	h := newTestServer(t, svc)
+	assert.Equal(t, http.StatusBadRequest, postLicense(h, nil).Code)
+	assert.Equal(t, http.StatusBadRequest, postLicense(h, []byte("garbage")).Code)
+	assert.Equal(t, http.StatusInternalServerError, postLicense(h, testChallenge).Code)
+	assert.Equal(t, http.StatusInternalServerError, postLicense(h, widevinetest.ServiceCertificateRequest).Code)
+
+	statuses := map[string]int{
+		widevinetest.StatusInvalidLicenseChallenge: http.StatusBadRequest,
+		widevinetest.StatusProviderAccessDenied:    http.StatusForbidden,
+		widevinetest.StatusInternalError:           http.StatusBadGateway,
+		"DRM_DEVICE_CERTIFICATE_REVOKED":           http.StatusForbidden,
+	}
+	for status, code := range statuses {
+		svc.Statuses[widevinetest.RequestTypeLicense] = status
+		assert.Equal(t, code, postLicense(h, testChallenge).Code, status)
+	}
+
+	h = newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	assert.Equal(t, http.StatusBadGateway, postLicense(h, testChallenge).Code)
+
+	h = newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html>"))
+	}))
+	assert.Equal(t, http.StatusBadGateway, postLicense(h, testChallenge).Code)
+}
+
+func TestLicensePropagatesRequestID(t *testing.T) {
+	svc := newTestService()
+	var requestIDs []string
+	h := newTestServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestIDs = append(requestIDs, r.Header.Get("X-Request-Id"))
+		svc.ServeHTTP(w, r)
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/license", bytes.NewReader(testChallenge))
+	req.Header.Set("X-Request-Id", "req-1")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "req-1", rec.Header().Get("X-Request-Id"))
+	assert.Equal(t, []string{"req-1"}, requestIDs)
+
+	rec = postLicense(h, testChallenge)
+	assert.Len(t, rec.Header().Get("X-Request-Id"), 32)
+	assert.Equal(t, rec.Header().Get("X-Request-Id"), requestIDs[1])
+}
+
+func TestLicenseTenants(t *testing.T) {
+	services := map[string]*widevinetest.Service{}
+	cfg := &Config{
+		TenantHeader:    "X-Tenant",
+		UpstreamTimeout: 5,
+		Retry:           RetryConfig{MaxAttempts: 1},
+		CircuitBreaker:  CircuitBreakerConfig{FailureThreshold: 5},
+	}
+	for _, name := range []string{"brand-a", "brand-b"} {
+		svc := newTestService()
+		ls := httptest.NewServer(svc)
+		t.Cleanup(ls.Close)
+		services[name] = svc
+
+		tc := testTenantConfig(ls.URL)
+		tc.Name = name
+		cfg.Tenants = append(cfg.Tenants, tc)
+	}
+	cfg.Tenants[0].PathPrefix = "/brand-a"
+	cfg.Tenants[1].Hosts = []string{"license.brand-b.com"}
+	cfg.Tenants[1].HeaderValue = "b"
+	h := newTestServerWithConfig(t, cfg)
+
+	post := func(target, host, header string) int {
+		req := httptest.NewRequest(http.MethodPost, target, bytes.NewReader(testChallenge))
+		req.Host = host
+		if header != "" {
+			req.Header.Set("X-Tenant", header)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	assert.Equal(t, http.StatusOK, post("/brand-a/license", "proxy.example.com", ""))
+	assert.Len(t, services["brand-a"].Requests(), 1)
+	assert.Equal(t, http.StatusOK, post("/license", "license.brand-b.com", ""))
+	assert.Equal(t, http.StatusOK, post("/license", "proxy.example.com", "b"))
+	assert.Len(t, services["brand-b"].Requests(), 2)
+	assert.Equal(t, http.StatusNotFound, post("/license", "proxy.example.com", ""))
+	assert.Equal(t, http.StatusForbidden, post("/brand-a/license", "license.brand-b.com", ""))
+	assert.Len(t, services["brand-a"].Requests(), 1)
+
+	statuses, ok := licenseRequests.Get("brand-b").(*expvar.Map)
+	if assert.True(t, ok) {
+		assert.Equal(t, "2", statuses.Get("200").String())
+	}
+}
+
+func TestConfigTenants(t *testing.T) {
+	tc := testTenantConfig("https://license.example.com")
+	cfg := &Config{UpstreamTimeout: 5, Retry: RetryConfig{MaxAttempts: 1}, CircuitBreaker: CircuitBreakerConfig{FailureThreshold: 1}}
+	assert.Error(t, cfg.validate(), "a provider is required")
+
+	tc.Name = "brand-a"
+	tc.HeaderValue = "a"
+	cfg.Tenants = []TenantConfig{tc}
+	assert.Error(t, cfg.validate(), "header_value requires tenant_header")
+	cfg.TenantHeader = "X-Tenant"
+	assert.NoError(t, cfg.validate())
+
+	cfg.Tenants = append(cfg.Tenants, tc)
+	assert.Error(t, cfg.validate(), "duplicate tenant")
+	cfg.Tenants[1].Name = "brand-b"
+	cfg.Tenants[1].HeaderValue = ""
+	assert.Error(t, cfg.validate(), "no selector")
+	cfg.Tenants[1].Hosts = []string{"license.brand-b.com"}
+	cfg.Tenants[1].Key = "00"
+	assert.Error(t, cfg.validate(), "invalid key")
+}
+
+func TestLicensePolicies(t *testing.T) {
+	svc := newTestService()
+	ls := httptest.NewServer(svc)
+	t.Cleanup(ls.Close)
+
+	policies := filepath.Join(t.TempDir(), "policies.yaml")
+	err := ioutil.WriteFile(policies, []byte(`
+default_policy: rental
+policies:
+  rental:
+    allowed_track_types: SD_HD
+    policy_overrides: {can_play: true, rental_duration_seconds: 172800}
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &Config{
+		TenantConfig:    testTenantConfig(ls.URL),
+		UpstreamTimeout: 5,
+		Retry:           RetryConfig{MaxAttempts: 1},
+		CircuitBreaker:  CircuitBreakerConfig{FailureThreshold: 5},
+	}
+	cfg.Policies = policies
+	h := newTestServerWithConfig(t, cfg)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/license", bytes.NewReader(testChallenge)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	if licenses := svc.RequestsOf(widevinetest.RequestTypeLicense); assert.Len(t, licenses, 1) {
+		assert.EqualValues(t, widevineproxy.AllowedTrackTypeHD, licenses[0].Message.AllowedTrackTypes)
+		assert.Equal(t, uint64(172800), licenses[0].Message.PolicyOverrides.RentalDurationSeconds)
+	}
+
+	cfg.Policies = filepath.Join(t.TempDir(), "missing.yaml")
+	_, err = newRegistry(cfg, logrus.New())
+	assert.Error(t, err)
+}
+
+func TestLicenseDeviceRules(t *testing.T) {
+	svc := newTestService()
+	ls := httptest.NewServer(svc)
+	t.Cleanup(ls.Close)
+
+	cfg := &Config{
+		TenantConfig:    testTenantConfig(ls.URL),
+		UpstreamTimeout: 5,
+		Retry:           RetryConfig{MaxAttempts: 1},
+		CircuitBreaker:  CircuitBreakerConfig{FailureThreshold: 5},
+	}
+	cfg.AllowedTrackTypes = widevineproxy.AllowedTrackTypeHD
+	cfg.DeviceRules = map[widevineproxy.ContentTrackType]widevineproxy.TrackRequirement{
+		widevineproxy.ContentTrackTypeHD: {HDCP: widevineproxy.HDCPVersionV1},
+	}
+	h := newTestServerWithConfig(t, cfg)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/license", bytes.NewReader(testChallenge)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Len(t, svc.RequestsOf(widevinetest.RequestTypeParseOnly), 1)
+	if licenses := svc.RequestsOf(widevinetest.RequestTypeLicense); assert.Len(t, licenses, 1) {
+		assert.EqualValues(t, widevineproxy.AllowedTrackTypeSD, licenses[0].Message.AllowedTrackTypes)
+	}
+
+	cfg.DeviceRules["4K"] = widevineproxy.TrackRequirement{}
+	assert.Error(t, cfg.validate())
+}
+
+func TestLicenseDeviceList(t *testing.T) {
+	svc := newTestService()
+	ls := httptest.NewServer(svc)
+	t.Cleanup(ls.Close)
+
+	dir := t.TempDir()
+	cfg := &Config{
+		TenantConfig:    testTenantConfig(ls.URL),
+		UpstreamTimeout: 5,
+		Retry:           RetryConfig{MaxAttempts: 1},
+		CircuitBreaker:  CircuitBreakerConfig{FailureThreshold: 5},
+	}
+	cfg.DeviceList = filepath.Join(dir, "devices.json")
+	cfg.DeviceListAudit = filepath.Join(dir, "audit.log")
+	err := ioutil.WriteFile(cfg.DeviceList, []byte(`{"deny": [{"make": "google", "model": "chromecdm-*", "reason": "leaked keybox"}]}`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := newTestServerWithConfig(t, cfg)
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/license", bytes.NewReader(testChallenge)))
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Contains(t, rec.Body.String(), "leaked keybox")
+	assert.Empty(t, svc.RequestsOf(widevinetest.RequestTypeLicense))
+
+	audit, _ := ioutil.ReadFile(cfg.DeviceListAudit)
+	assert.Contains(t, string(audit), `"system_id":4464`)
+
+	cfg.DeviceList = ""
+	assert.Error(t, cfg.validate())
+}
+
+var testHMACSecret = []byte("widevine-proxy-test-secret")
+
+// signToken returns a HS256 JWT carrying claims, trusted by testVerifier.
+func signToken(claims map[string]interface{}) string {
+	b64 := base64.RawURLEncoding.EncodeToString
+	header, _ := json.Marshal(map[string]string{"alg": "HS256", "kid": "test", "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	input := b64(header) + "." + b64(payload)
+	mac := hmac.New(sha256.New, testHMACSecret)
+	mac.Write([]byte(input))
+	return input + "." + b64(mac.Sum(nil))
+}
+
+func testVerifier(t *testing.T) *widevineauth.Verifier {
+	t.Helper()
+	keys, err := widevineauth.ParseJWKS([]byte(`{"keys": [{"kty": "oct", "kid": "test", "k": "` +
+		base64.RawURLEncoding.EncodeToString(testHMACSecret) + `"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return widevineauth.NewVerifier(keys)
+}
+
+func TestLicenseTenantAuth(t *testing.T) {
+	jwks := filepath.Join(t.TempDir(), "jwks.json")
+	assert.NoError(t, ioutil.WriteFile(jwks, []byte(`{"keys": [{"kty": "oct", "kid": "test", "k": "`+
+		base64.RawURLEncoding.EncodeToString(testHMACSecret)+`"}]}`), 0600))
+	cfg := &Config{
+		TenantHeader:    "X-Tenant",
+		UpstreamTimeout: 5,
+		Retry:           RetryConfig{MaxAttempts: 1},
+		CircuitBreaker:  CircuitBreakerConfig{FailureThreshold: 5},
+	}
+	cfg.Auth = &AuthConfig{JWKS: jwks, Audience: "brand-a"}
+	for _, name := range []string{"brand-a", "brand-b"} {
+		svc := newTestService()
+		ls := httptest.NewServer(svc)
+		t.Cleanup(ls.Close)
+		tc := testTenantConfig(ls.URL)
+		tc.Name = name
+		cfg.Tenants = append(cfg.Tenants, tc)
+	}
+	cfg.Tenants[0].PathPrefix = "/brand-a"
+	cfg.Tenants[1].Hosts = []string{"license.brand-b.com"}
+	cfg.Tenants[1].Auth = &AuthConfig{JWKS: jwks, Audience: "brand-b"}
+	assert.NoError(t, cfg.validate())
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	registry, err := newRegistry(cfg, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifiers, err := newVerifiers(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := newServer(registry, verifiers, 0, logger)
+	post := func(target, host, audience string) int {
+		token := signToken(map[string]interface{}{"sub": "user-1", "aud": audience, "exp": time.Now().Add(time.Hour).Unix()})
+		req := httptest.NewRequest(http.MethodPost, target, bytes.NewReader(testChallenge))
+		req.Host = host
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// A token is only accepted by the tenant it is issued for.
+	assert.Equal(t, http.StatusOK, post("/brand-a/license", "proxy.example.com", "brand-a"))
+	assert.Equal(t, http.StatusUnauthorized, post("/brand-a/license", "proxy.example.com", "brand-b"))
+	assert.Equal(t, http.StatusOK, post("/license", "license.brand-b.com", "brand-b"))
+	assert.Equal(t, http.StatusUnauthorized, post("/license", "license.brand-b.com", "brand-a"))
+
+	cfg.Tenants[1].Auth.JWKS = ""
+	assert.Error(t, cfg.validate())
+}
+
+func TestLicenseSessions(t *testing.T) {
+	svc := newTestService()
+	ls := httptest.NewServer(svc)
+	t.Cleanup(ls.Close)
+
+	cfg := &Config{
+		TenantConfig:    testTenantConfig(ls.URL),
+		UpstreamTimeout: 5,
+		Retry:           RetryConfig{MaxAttempts: 1},
+		CircuitBreaker:  CircuitBreakerConfig{FailureThreshold: 5},
+	}
+	cfg.Auth = &AuthConfig{JWKS: "jwks.json"}
+	cfg.Sessions = &SessionsConfig{
+		Limits: widevinesession.Limits{MaxStreams: 1},
+		Tiers:  map[string]widevinesession.Limits{"premium": {MaxStreams: 2}},
+	}
+	assert.NoError(t, cfg.validate())
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	registry, err := newRegistry(cfg, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := newServer(registry, tenantVerifiers{defaultTenant: testVerifier(t)}, 0, logger)
+	token := signToken(map[string]interface{}{"sub": "user-1", "exp": time.Now().Add(time.Hour).Unix()})
+	post := func(body []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/license", bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	assert.Equal(t, http.StatusOK, post(testChallenge).Code)
+	if licenses := svc.RequestsOf(widevinetest.RequestTypeLicense); assert.Len(t, licenses, 1) {
+		assert.True(t, licenses[0].Message.PolicyOverrides.CanRenew)
+		assert.Equal(t, uint64(60), licenses[0].Message.PolicyOverrides.RenewalDelaySeconds)
+	}
+	rec := post(testChallenge)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Contains(t, rec.Body.String(), "limit of 1 concurrent streams")
+
+	// Releasing the license frees the stream, its heartbeats are refused from then on.
+	licenseID := &pb.LicenseIdentification{
+		RequestId: []byte("request-1"),
+		SessionId: []byte("session-1"),
+		Type:      pb.LicenseType_STREAMING.Enum(),
+	}
+	assert.Equal(t, http.StatusOK, post(widevinetest.ReleaseChallenge(licenseID)).Code)
+	assert.Equal(t, http.StatusForbidden, post(widevinetest.RenewalChallenge(licenseID)).Code)
+	assert.Equal(t, http.StatusOK, post(testChallenge).Code)
+
+	cfg.Auth = nil
+	assert.Error(t, cfg.validate())
+	cfg.Auth = &AuthConfig{JWKS: "jwks.json"}
+	cfg.Sessions.HeartbeatInterval, cfg.Sessions.HeartbeatTimeout = 60, 30
+	assert.Error(t, cfg.validate())
+}
+
+func TestLicenseSessionsTokenPolicy(t *testing.T) {
+	svc := newTestService()
+	ls := httptest.NewServer(svc)
+	t.Cleanup(ls.Close)
+
+	cfg := &Config{
+		TenantConfig:    testTenantConfig(ls.URL),
+		UpstreamTimeout: 5,
+		Retry:           RetryConfig{MaxAttempts: 1},
+		CircuitBreaker:  CircuitBreakerConfig{FailureThreshold: 5},
+	}
+	cfg.Auth = &AuthConfig{JWKS: "jwks.json"}
+	cfg.Sessions = &SessionsConfig{Limits: widevinesession.Limits{MaxStreams: 1}}
+	assert.NoError(t, cfg.validate())
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	registry, err := newRegistry(cfg, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := newServer(registry, tenantVerifiers{defaultTenant: testVerifier(t)}, 0, logger)
+
+	// The overrides of the token must not drop the heartbeat the session relies on, nor extend the license past it.
+	token := signToken(map[string]interface{}{
+		"sub":              "user-1",
+		"exp":              time.Now().Add(time.Hour).Unix(),
+		"policy_overrides": map[string]interface{}{"license_duration_seconds": 7200},
+	})
+	req := httptest.NewRequest(http.MethodPost, "/license", bytes.NewReader(testChallenge))
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	if licenses := svc.RequestsOf(widevinetest.RequestTypeLicense); assert.Len(t, licenses, 1) {
+		overrides := licenses[0].Message.PolicyOverrides
+		assert.Equal(t, uint64(180), overrides.LicenseDurationSeconds)
+		assert.True(t, overrides.CanPlay)
+		assert.True(t, overrides.CanRenew)
+		assert.Equal(t, uint64(60), overrides.RenewalDelaySeconds)
+	}
+}
+
+func TestLicenseTokenPolicy(t *testing.T) {
+	svc := newTestService()
+	ls := httptest.NewServer(svc)
+	t.Cleanup(ls.Close)
+
+	cfg := &Config{
+		TenantConfig:    testTenantConfig(ls.URL),
+		UpstreamTimeout: 5,
+		Retry:           RetryConfig{MaxAttempts: 1},
+		CircuitBreaker:  CircuitBreakerConfig{FailureThreshold: 5},
+	}
+	cfg.PolicyOverrides = &widevineproxy.PolicyOverrides{CanPlay: true, CanPersist: true, LicenseDurationSeconds: 3600}
+	assert.NoError(t, cfg.validate())
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	registry, err := newRegistry(cfg, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := newServer(registry, tenantVerifiers{defaultTenant: testVerifier(t)}, 0, logger)
+	post := func(overrides map[string]interface{}) {
+		token := signToken(map[string]interface{}{"sub": "user-1", "exp": time.Now().Add(time.Hour).Unix(), "policy_overrides": overrides})
+		req := httptest.NewRequest(http.MethodPost, "/license", bytes.NewReader(testChallenge))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	}
+
+	// A token narrows the policy down: it withholds persistence, but cannot grant renewals nor extend the license.
+	post(map[string]interface{}{"can_persist": false})
+	post(map[string]interface{}{"can_renew": true, "license_duration_seconds": 7200})
+	if licenses := svc.RequestsOf(widevinetest.RequestTypeLicense); assert.Len(t, licenses, 2) {
+		assert.Equal(t, &widevineproxy.PolicyOverrides{CanPlay: true, LicenseDurationSeconds: 3600}, licenses[0].Message.PolicyOverrides)
+		assert.Equal(t, &widevineproxy.PolicyOverrides{CanPlay: true, CanPersist: true, LicenseDurationSeconds: 3600}, licenses[1].Message.PolicyOverrides)
+	}
+	assert.True(t, cfg.PolicyOverrides.CanPersist)
+}
+
+func TestLicenseSessionsSharedPolicy(t *testing.T) {
+	svc := newTestService()
+	ls := httptest.NewServer(svc)
+	t.Cleanup(ls.Close)
+
+	cfg := &Config{
+		TenantConfig:    testTenantConfig(ls.URL),
+		UpstreamTimeout: 5,
+		Retry:           RetryConfig{MaxAttempts: 1},
+		CircuitBreaker:  CircuitBreakerConfig{FailureThreshold: 5},
+	}
+	cfg.Auth = &AuthConfig{JWKS: "jwks.json"}
+	cfg.PolicyOverrides = &widevineproxy.PolicyOverrides{CanPlay: true}
+	cfg.Sessions = &SessionsConfig{Limits: widevinesession.Limits{MaxStreams: 2}}
+	assert.NoError(t, cfg.validate())
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	registry, err := newRegistry(cfg, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := newServer(registry, tenantVerifiers{defaultTenant: testVerifier(t)}, 0, logger)
+	token := signToken(map[string]interface{}{"sub": "user-1", "exp": time.Now().Add(time.Hour).Unix()})
+	post := func(body []byte) int {
+		req := httptest.NewRequest(http.MethodPost, "/license", bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// The heartbeat of the streaming license must not leak into the configuration, nor into the offline license.
+	assert.Equal(t, http.StatusOK, post(testChallenge))
+	assert.Equal(t, http.StatusOK, post(widevinetest.LicenseChallenge(&pb.WidevineCencHeader{
+		KeyId:     [][]byte{bytes.Repeat([]byte{0x11}, 16)},
+		ContentId: []byte("fake-content"),
+	}, pb.LicenseType_OFFLINE)))
+	assert.Equal(t, &widevineproxy.PolicyOverrides{CanPlay: true}, cfg.PolicyOverrides)
+	if licenses := svc.RequestsOf(widevinetest.RequestTypeLicense); assert.Len(t, licenses, 2) {
+		assert.True(t, licenses[0].Message.PolicyOverrides.CanRenew)
+		assert.False(t, licenses[1].Message.PolicyOverrides.CanRenew)
+	}
+}
+
+func TestLicenseOffline(t *testing.T) {
+	svc := newTestService()
+	ls := httptest.NewServer(svc)
+	t.Cleanup(ls.Close)
+
+	cfg := &Config{
+		TenantConfig:    testTenantConfig(ls.URL),
+		UpstreamTimeout: 5,
+		Retry:           RetryConfig{MaxAttempts: 1},
+		CircuitBreaker:  CircuitBreakerConfig{FailureThreshold: 5},
+	}
+	cfg.Auth = &AuthConfig{JWKS: "jwks.json"}
+	ledgerPath := filepath.Join(t.TempDir(), "offline.json")
+	cfg.Offline = &OfflineConfig{Limits: widevineoffline.Limits{MaxLicenses: 1}, Ledger: ledgerPath}
+	assert.NoError(t, cfg.validate())
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	registry, err := newRegistry(cfg, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := newServer(registry, tenantVerifiers{defaultTenant: testVerifier(t)}, 0, logger)
+	serve := func(method, path, subject string, body []byte) *httptest.ResponseRecorder {
+		token := signToken(map[string]interface{}{"sub": subject, "exp": time.Now().Add(time.Hour).Unix()})
+		req := httptest.NewRequest(method, path, bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	list := func() []*widevineoffline.License {
+		rec := serve(http.MethodGet, "/offline-licenses", "user-1", nil)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		var body struct {
+			Licenses []*widevineoffline.License `json:"licenses"`
+		}
+		assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+		return body.Licenses
+	}
+	offlineChallenge := widevinetest.LicenseChallenge(&pb.WidevineCencHeader{
+		KeyId:     [][]byte{bytes.Repeat([]byte{0x11}, 16)},
+		ContentId: []byte("fake-content"),
+	}, pb.LicenseType_OFFLINE)
+
+	assert.Equal(t, http.StatusOK, serve(http.MethodPost, "/license", "user-1", offlineChallenge).Code)
+	rec := serve(http.MethodPost, "/license", "user-1", offlineChallenge)
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Contains(t, rec.Body.String(), "limit of 1 offline licenses")
+	assert.Equal(t, http.StatusOK, serve(http.MethodPost, "/license", "user-1", testChallenge).Code)
+	licenses := list()
+	if assert.Len(t, licenses, 1) {
+		assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("session-1")), licenses[0].ID)
+		assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("fake-content")), licenses[0].ContentID)
+	}
+
+	// Releasing the license frees its slot.
+	licenseID := &pb.LicenseIdentification{
+		RequestId: []byte("request-1"),
+		SessionId: []byte("session-1"),
+		Type:      pb.LicenseType_OFFLINE.Enum(),
+	}
+	assert.Equal(t, http.StatusOK, serve(http.MethodPost, "/license", "user-1", widevinetest.ReleaseChallenge(licenseID)).Code)
+	assert.Empty(t, list())
+	assert.Equal(t, http.StatusOK, serve(http.MethodPost, "/license", "user-1", offlineChallenge).Code)
+
+	// Revoking the license refuses its renewals, but it holds its slot until released by the device.
+	licenses = list()
+	if !assert.Len(t, licenses, 1) {
+		return
+	}
+	path := "/offline-licenses/" + url.PathEscape(licenses[0].ID)
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodDelete, path, "user-2", nil).Code)
+	assert.Equal(t, http.StatusNoContent, serve(http.MethodDelete, path, "user-1", nil).Code)
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodDelete, "/offline-licenses/dW5rbm93bg==", "user-1", nil).Code)
+	if revoked := list(); assert.Len(t, revoked, 1) {
+		assert.Equal(t, widevineoffline.StatusRevoked, revoked[0].Status)
+	}
+	sessionID, _ := base64.StdEncoding.DecodeString(licenses[0].ID)
+	licenseID.SessionId = sessionID
+	assert.Equal(t, http.StatusForbidden, serve(http.MethodPost, "/license", "user-1", widevinetest.RenewalChallenge(licenseID)).Code)
+	assert.Equal(t, http.StatusForbidden, serve(http.MethodPost, "/license", "user-1", offlineChallenge).Code)
+	assert.Equal(t, http.StatusOK, serve(http.MethodPost, "/license", "user-1", widevinetest.ReleaseChallenge(licenseID)).Code)
+	assert.Empty(t, list())
+
+	// The ledger outlives the proxy.
+	store, err := widevineoffline.LoadFileStore(ledgerPath)
+	if assert.NoError(t, err) {
+		recorded, err := store.Licenses(context.Background(), "user-1")
+		assert.NoError(t, err)
+		assert.Len(t, recorded, 2)
+	}
+
+	cfg.Auth = nil
+	assert.Error(t, cfg.validate())
+	cfg.Auth = &AuthConfig{JWKS: "jwks.json"}
+	cfg.Offline.TitleDownloads = map[string]int{"fake-content": -1}
+	assert.Error(t, cfg.validate())
+}
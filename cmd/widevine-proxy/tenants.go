@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	widevineauth "github.com/cooomma/widevine-proxy/auth"
+	widevinedevicelist "github.com/cooomma/widevine-proxy/devicelist"
+	widevineoffline "github.com/cooomma/widevine-proxy/offline"
+	widevinepolicy "github.com/cooomma/widevine-proxy/policy"
+	widevineproxy "github.com/cooomma/widevine-proxy/proxy"
+	widevinesession "github.com/cooomma/widevine-proxy/session"
+	"github.com/sirupsen/logrus"
+)
+
+// newRegistry creates a proxy per tenant of the configuration, each with its own HTTP client and circuit breaker.
+// The tenant configured at the top level, if any, is the default one.
+func newRegistry(cfg *Config, logger *logrus.Logger) (*widevineproxy.TenantRegistry, error) {
+	registry := widevineproxy.NewTenantRegistry(cfg.TenantHeader)
+	if cfg.LicenseServer != "" {
+		tc := cfg.TenantConfig
+		tc.Name = defaultTenant
+		tenant, err := newTenant(cfg, &tc, logger)
+		if err != nil {
+			return nil, err
+		}
+		if err := registry.Add(tenant); err != nil {
+			return nil, err
+		}
+		registry.Default = tenant
+	}
+	for i := range cfg.Tenants {
+		tenant, err := newTenant(cfg, &cfg.Tenants[i], logger)
+		if err != nil {
+			return nil, err
+		}
+		if err := registry.Add(tenant); err != nil {
+			return nil, err
+		}
+	}
+	return registry, nil
+}
+
+// newVerifiers creates the bearer token verifier of each tenant of the configuration with auth, keyed by tenant name.
+// The tenants without their own auth share the verifier of the top level auth.
+func newVerifiers(cfg *Config) (tenantVerifiers, error) {
+	verifiers := tenantVerifiers{}
+	created := map[*AuthConfig]*widevineauth.Verifier{}
+	add := func(name string, ac *AuthConfig) error {
+		if ac == nil {
+			return nil
+		}
+		if created[ac] == nil {
+			keys, err := widevineauth.LoadJWKS(ac.JWKS)
+			if err != nil {
+				return fmt.Errorf("tenant %s: %v", name, err)
+			}
+			verifier := widevineauth.NewVerifier(keys)
+			verifier.Issuer = ac.Issuer
+			verifier.Audience = ac.Audience
+			created[ac] = verifier
+		}
+		verifiers[name] = created[ac]
+		return nil
+	}
+	if cfg.LicenseServer != "" {
+		if err := add(defaultTenant, cfg.Auth); err != nil {
+			return nil, err
+		}
+	}
+	for i := range cfg.Tenants {
+		tc := &cfg.Tenants[i]
+		if err := add(tc.Name, cfg.authOf(tc)); err != nil {
+			return nil, err
+		}
+	}
+	return verifiers, nil
+}
+
+func newTenant(cfg *Config, tc *TenantConfig, logger *logrus.Logger) (*widevineproxy.Tenant, error) {
+	authority, err := newAuthority(tc)
+	if err != nil {
+		return nil, err
+	}
+	proxy := widevineproxy.NewWidevineProxy(authority, logger)
+	proxy.CallTimeout = time.Duration(cfg.UpstreamTimeout) * time.Second
+	proxy.Retry = widevineproxy.RetryPolicy{
+		MaxAttempts: cfg.Retry.MaxAttempts,
+		BaseDelay:   time.Duration(cfg.Retry.BaseDelay) * time.Millisecond,
+		MaxDelay:    time.Duration(cfg.Retry.MaxDelay) * time.Millisecond,
+	}
+	proxy.Breaker.FailureThreshold = cfg.CircuitBreaker.FailureThreshold
+	proxy.Breaker.OpenTimeout = time.Duration(cfg.CircuitBreaker.OpenTimeout) * time.Second
+	if tc.DeviceList != "" {
+		authorizer, err := newDeviceListAuthorizer(tc, logger)
+		if err != nil {
+			return nil, err
+		}
+		proxy.Authorizer = authorizer
+	}
+	var policies widevineproxy.PolicyChain
+	if tc.Policies != "" {
+		engine, err := widevinepolicy.Load(tc.Policies)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, engine)
+	}
+	if len(tc.DeviceRules) > 0 {
+		policies = append(policies, &widevineproxy.DeviceGate{Tracks: tc.DeviceRules})
+	}
+	var trackers widevineproxy.TrackerChain
+	if tc.Sessions != nil {
+		tracker := newSessionTracker(tc.Sessions)
+		trackers = append(trackers, tracker)
+		policies = append(policies, tracker)
+	}
+	if tc.Offline != nil {
+		ledger, err := newOfflineLedger(tc.Offline)
+		if err != nil {
+			return nil, err
+		}
+		trackers = append(trackers, ledger)
+	}
+	if len(trackers) > 0 {
+		proxy.Tracker = trackers
+	}
+	if len(policies) > 0 {
+		proxy.Policy = policies
+	}
+	return &widevineproxy.Tenant{
+		Name:        tc.Name,
+		Proxy:       proxy,
+		Hosts:       tc.Hosts,
+		PathPrefix:  tc.PathPrefix,
+		HeaderValue: tc.HeaderValue,
+	}, nil
+}
+
+// newDeviceListAuthorizer refuses the licenses of the devices denied by the device list of a tenant.
+func newDeviceListAuthorizer(tc *TenantConfig, logger *logrus.Logger) (*widevinedevicelist.Authorizer, error) {
+	list, err := widevinedevicelist.LoadFile(tc.DeviceList)
+	if err != nil {
+		return nil, err
+	}
+	list.OnReloadError = func(err error) {
+		logger.WithError(err).WithField("tenant", tc.Name).Error("Device List Reload Failure")
+	}
+	authorizer := &widevinedevicelist.Authorizer{Checker: list}
+	authorizer.OnAuditError = func(err error) {
+		logger.WithError(err).WithField("tenant", tc.Name).Error("Device List Audit Failure")
+	}
+	if tc.DeviceListAudit != "" {
+		f, err := os.OpenFile(tc.DeviceListAudit, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+		if err != nil {
+			return nil, err
+		}
+		authorizer.Auditor = &widevinedevicelist.JSONAuditor{W: f}
+	}
+	return authorizer, nil
+}
+
+// newSessionTracker limits the sessions of the users of a tenant, in memory.
+func newSessionTracker(sc *SessionsConfig) *widevinesession.Tracker {
+	tracker := widevinesession.NewTracker(widevinesession.NewMemoryStore(), sc.Limits)
+	tracker.Tiers = sc.Tiers
+	tracker.TierAttribute = sc.TierAttribute
+	tracker.HeartbeatInterval, tracker.HeartbeatTimeout = sc.heartbeat()
+	tracker.DeviceTTL = time.Duration(sc.DeviceTTL) * time.Hour
+	return tracker
+}
+
+// newOfflineLedger limits the offline licenses of the users of a tenant, recorded in its ledger file.
+func newOfflineLedger(oc *OfflineConfig) (*widevineoffline.Ledger, error) {
+	var store widevineoffline.Store = widevineoffline.NewMemoryStore()
+	if oc.Ledger != "" {
+		fileStore, err := widevineoffline.LoadFileStore(oc.Ledger)
+		if err != nil {
+			return nil, err
+		}
+		store = fileStore
+	}
+	ledger := widevineoffline.NewLedger(store, oc.Limits)
+	ledger.Tiers = oc.Tiers
+	ledger.TierAttribute = oc.TierAttribute
+	ledger.TitleDownloads = oc.TitleDownloads
+	return ledger, nil
+}
+
+// ledgerOf returns the offline ledger of tenant, nil if its offline licenses are not limited.
+func ledgerOf(tenant *widevineproxy.Tenant) *widevineoffline.Ledger {
+	trackers, _ := tenant.Proxy.Tracker.(widevineproxy.TrackerChain)
+	for _, tracker := range trackers {
+		if ledger, ok := tracker.(*widevineoffline.Ledger); ok {
+			return ledger
+		}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package widevinedevicelist
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	widevineproxy "github.com/cooomma/widevine-proxy/proxy"
+)
+
+// Checker denies devices, e.g. a List or a FileList.
+type Checker interface {
+	Check(device *widevineproxy.DeviceInfo) error
+}
+
+// AuditRecord is the trace of a license refused to a device.
+type AuditRecord struct {
+	Time      time.Time `json:"time"`
+	RequestID string    `json:"request_id,omitempty"`
+	UserID    string    `json:"user_id,omitempty"`
+	ContentID string    `json:"content_id"`
+
+	SystemID            int64  `json:"system_id"`
+	DRMCertSerialNumber string `json:"drm_cert_serial_number,omitempty"`
+	Make                string `json:"make"`
+	Model               string `json:"model"`
+	DeviceState         string `json:"device_state,omitempty"`
+
+	List  string `json:"list"`
+	Entry *Entry `json:"entry,omitempty"`
+}
+
+// Auditor records the devices denied by an Authorizer.
+type Auditor interface {
+	Audit(ctx context.Context, record *AuditRecord) error
+}
+
+// JSONAuditor writes the audit records to W, one JSON object per line.
+type JSONAuditor struct {
+	W  io.Writer
+	mu sync.Mutex
+}
+
+// Audit writes record as a line of JSON.
+func (a *JSONAuditor) Audit(ctx context.Context, record *AuditRecord) error {
+	b, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	_, err = a.W.Write(append(b, '\n'))
+	return err
+}
+
+// Authorizer is a widevineproxy.Authorizer refusing the licenses of the devices denied by its Checker,
+// with a DeviceDeniedError. The denials are recorded by the Auditor, if any.
+type Authorizer struct {
+	Checker Checker
+	Auditor Auditor // Optional.
+	// OnAuditError, if not nil, is called when the Auditor fails to record a denial, which is returned anyway.
+	OnAuditError func(err error)
+
+	now func() time.Time // time.Now if nil.
+}
+
+// Authorize checks the device of req.
+func (a *Authorizer) Authorize(ctx context.Context, req *widevineproxy.AuthorizationRequest) error {
+	err := a.Checker.Check(&req.Device)
+	var denied *DeviceDeniedError
+	if !errors.As(err, &denied) || a.Auditor == nil {
+		return err
+	}
+
+	now := time.Now
+	if a.now != nil {
+		now = a.now
+	}
+	record := &AuditRecord{
+		Time:                now().UTC(),
+		RequestID:           widevineproxy.TraceMetadataFromContext(ctx)["X-Request-Id"],
+		ContentID:           req.PsshData.ContentID,
+		SystemID:            req.Device.SystemID,
+		DRMCertSerialNumber: req.Device.DRMCertSerialNumber,
+		Make:                req.Device.Make,
+		Model:               req.Device.Model,
+		DeviceState:         req.Device.DeviceState,
+		List:                denied.List,
+		Entry:               denied.Entry,
+	}
+	if req.Claims != nil {
+		record.UserID = req.Claims.UserID
+	}
+	if auditErr := a.Auditor.Audit(ctx, record); auditErr != nil && a.OnAuditError != nil {
+		a.OnAuditError(auditErr)
+	}
+	return err
+}
+
+// RequiresDeviceInfo always reports true, the device being known only after a PARSE_ONLY request.
+func (a *Authorizer) RequiresDeviceInfo(psshData *widevineproxy.PsshData) bool {
+	return true
+}
@@ -0,0 +1,138 @@
+// Package widevinedevicelist blocks compromised devices with allow and deny lists matched against
+// the device reported by the PARSE_ONLY response: system id, DRM certificate serial number, make, model
+// and device state.
+package widevinedevicelist
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	widevineproxy "github.com/cooomma/widevine-proxy/proxy"
+)
+
+// ErrDeviceDenied matches the DeviceDeniedError with errors.Is.
+var ErrDeviceDenied = errors.New("device denied")
+
+// Lists of an Entry.
+const (
+	ListDeny  = "deny"
+	ListAllow = "allow"
+)
+
+// Entry matches the devices meeting all of its non empty fields.
+// Make and model are case insensitive patterns, in which * matches any sequence of characters (see path.Match).
+type Entry struct {
+	SystemID            int64  `json:"system_id,omitempty"`
+	DRMCertSerialNumber string `json:"drm_cert_serial_number,omitempty"` // Base64, as in the PARSE_ONLY response.
+	Make                string `json:"make,omitempty"`
+	Model               string `json:"model,omitempty"`
+	DeviceState         string `json:"device_state,omitempty"` // e.g. REVOKED.
+	// Reason is recorded when the entry denies a device.
+	Reason string `json:"reason,omitempty"`
+}
+
+// List holds the deny and allow entries. A device matching a deny entry is denied; when Allow is not empty,
+// a device matching none of its entries is denied as well.
+type List struct {
+	Deny  []*Entry `json:"deny"`
+	Allow []*Entry `json:"allow"`
+}
+
+// DeviceDeniedError is returned for a device denied by a List. It unwraps to an AuthorizationDeniedError.
+type DeviceDeniedError struct {
+	Device widevineproxy.DeviceInfo
+	List   string // ListDeny, or ListAllow when the device is not allowed.
+	Entry  *Entry // The matching deny entry, nil for ListAllow.
+}
+
+func (e *DeviceDeniedError) Error() string {
+	return fmt.Sprintf("%v: %s", ErrDeviceDenied, e.reason())
+}
+
+func (e *DeviceDeniedError) reason() string {
+	device := fmt.Sprintf("%s %s (system id %d)", e.Device.Make, e.Device.Model, e.Device.SystemID)
+	if e.List == ListAllow {
+		return device + " is not allowed"
+	}
+	if e.Entry.Reason != "" {
+		return device + " is denied: " + e.Entry.Reason
+	}
+	return device + " is denied"
+}
+
+// Is reports whether target is ErrDeviceDenied.
+func (e *DeviceDeniedError) Is(target error) bool {
+	return target == ErrDeviceDenied
+}
+
+// Unwrap returns the AuthorizationDeniedError of the denial.
+func (e *DeviceDeniedError) Unwrap() error {
+	return &widevineproxy.AuthorizationDeniedError{Reason: e.reason()}
+}
+
+// Check returns a DeviceDeniedError if device is denied.
+func (l *List) Check(device *widevineproxy.DeviceInfo) error {
+	for _, entry := range l.Deny {
+		if entry.matches(device) {
+			return &DeviceDeniedError{Device: *device, List: ListDeny, Entry: entry}
+		}
+	}
+	if len(l.Allow) == 0 {
+		return nil
+	}
+	for _, entry := range l.Allow {
+		if entry.matches(device) {
+			return nil
+		}
+	}
+	return &DeviceDeniedError{Device: *device, List: ListAllow}
+}
+
+// Validate checks that every entry has a condition and valid patterns.
+func (l *List) Validate() error {
+	for _, entries := range []struct {
+		name    string
+		entries []*Entry
+	}{{ListDeny, l.Deny}, {ListAllow, l.Allow}} {
+		for i, entry := range entries.entries {
+			if err := entry.validate(); err != nil {
+				return fmt.Errorf("%s[%d]: %v", entries.name, i, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (e *Entry) validate() error {
+	if e.SystemID == 0 && e.DRMCertSerialNumber == "" && e.Make == "" && e.Model == "" && e.DeviceState == "" {
+		return fmt.Errorf("entry matches any device")
+	}
+	for _, pattern := range []string{e.Make, e.Model} {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q", pattern)
+		}
+	}
+	return nil
+}
+
+func (e *Entry) matches(device *widevineproxy.DeviceInfo) bool {
+	switch {
+	case e.SystemID != 0 && e.SystemID != device.SystemID:
+		return false
+	case e.DRMCertSerialNumber != "" && e.DRMCertSerialNumber != device.DRMCertSerialNumber:
+		return false
+	case e.DeviceState != "" && !strings.EqualFold(e.DeviceState, device.DeviceState):
+		return false
+	}
+	return matchFold(e.Make, device.Make) && matchFold(e.Model, device.Model)
+}
+
+func matchFold(pattern, s string) bool {
+	if pattern == "" {
+		return true
+	}
+	matched, _ := path.Match(strings.ToLower(pattern), strings.ToLower(s))
+	return matched
+}
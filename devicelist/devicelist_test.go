@@ -0,0 +1,164 @@
+package widevinedevicelist
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	widevineproxy "github.com/cooomma/widevine-proxy/proxy"
+	"github.com/stretchr/testify/assert"
+)
+
+var chromeCDM = widevineproxy.DeviceInfo{
+	Make:                "Google",
+	Model:               "ChromeCDM-Linux-x64",
+	SystemID:            4464,
+	DRMCertSerialNumber: "ZmFrZS1kcm0tY2VydC1zZXJpYWw=",
+	DeviceState:         "RELEASED",
+}
+
+func TestListCheck(t *testing.T) {
+	tests := []struct {
+		list   List
+		device widevineproxy.DeviceInfo
+		denied string
+	}{
+		{List{}, chromeCDM, ""},
+		{List{Deny: []*Entry{{SystemID: 4464}}}, chromeCDM, ListDeny},
+		{List{Deny: []*Entry{{SystemID: 4445}}}, chromeCDM, ""},
+		{List{Deny: []*Entry{{DRMCertSerialNumber: chromeCDM.DRMCertSerialNumber}}}, chromeCDM, ListDeny},
+		{List{Deny: []*Entry{{Make: "google", Model: "chromecdm-*"}}}, chromeCDM, ListDeny},
+		{List{Deny: []*Entry{{Make: "google", Model: "*-Windows-*"}}}, chromeCDM, ""},
+		{List{Deny: []*Entry{{DeviceState: "revoked"}}}, chromeCDM, ""},
+		{List{Deny: []*Entry{{DeviceState: "revoked"}}}, widevineproxy.DeviceInfo{DeviceState: "REVOKED"}, ListDeny},
+		{List{Allow: []*Entry{{Make: "Google"}}}, chromeCDM, ""},
+		{List{Allow: []*Entry{{Make: "Samsung"}}}, chromeCDM, ListAllow},
+		{List{Deny: []*Entry{{SystemID: 4464}}, Allow: []*Entry{{Make: "Google"}}}, chromeCDM, ListDeny},
+	}
+	for _, test := range tests {
+		err := test.list.Check(&test.device)
+		if test.denied == "" {
+			assert.NoError(t, err)
+			continue
+		}
+		var denied *DeviceDeniedError
+		if assert.True(t, errors.As(err, &denied), test.list) {
+			assert.Equal(t, test.denied, denied.List)
+		}
+	}
+}
+
+func TestDeviceDeniedError(t *testing.T) {
+	list := &List{Deny: []*Entry{{SystemID: 4464, Reason: "leaked keybox"}}}
+	err := list.Check(&chromeCDM)
+	assert.True(t, errors.Is(err, ErrDeviceDenied))
+	var authErr *widevineproxy.AuthorizationDeniedError
+	if assert.True(t, errors.As(err, &authErr)) {
+		assert.Contains(t, authErr.Reason, "leaked keybox")
+	}
+	assert.Contains(t, err.Error(), "ChromeCDM-Linux-x64")
+}
+
+func TestListValidate(t *testing.T) {
+	assert.NoError(t, (&List{Deny: []*Entry{{Make: "acme", Model: "x1-*"}}}).Validate())
+	assert.Error(t, (&List{Deny: []*Entry{{Reason: "everything"}}}).Validate())
+	assert.Error(t, (&List{Allow: []*Entry{{Model: "x1-["}}}).Validate())
+}
+
+func writeList(t *testing.T, path string, list *List, modTime time.Time) {
+	t.Helper()
+	b, _ := json.Marshal(list)
+	if err := ioutil.WriteFile(path, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFileListReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "devices.json")
+	start := time.Now().Add(-time.Hour)
+	writeList(t, path, &List{}, start)
+
+	f, err := LoadFile(path)
+	if !assert.NoError(t, err) {
+		return
+	}
+	now := time.Now()
+	f.now = func() time.Time { return now }
+	var reloadErr error
+	f.OnReloadError = func(err error) { reloadErr = err }
+	assert.NoError(t, f.Check(&chromeCDM))
+
+	// The change is picked up once the reload interval elapsed.
+	writeList(t, path, &List{Deny: []*Entry{{SystemID: 4464}}}, start.Add(time.Minute))
+	assert.NoError(t, f.Check(&chromeCDM))
+	now = now.Add(DefaultReloadInterval)
+	assert.True(t, errors.Is(f.Check(&chromeCDM), ErrDeviceDenied))
+
+	// A broken file keeps the previous list.
+	if err := ioutil.WriteFile(path, []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(DefaultReloadInterval)
+	assert.True(t, errors.Is(f.Check(&chromeCDM), ErrDeviceDenied))
+	assert.Error(t, reloadErr)
+
+	_, err = LoadFile(filepath.Join(t.TempDir(), "missing.json"))
+	assert.Error(t, err)
+}
+
+func TestAuthorizer(t *testing.T) {
+	var audit bytes.Buffer
+	a := &Authorizer{
+		Checker: &List{Deny: []*Entry{{Make: "Google", Reason: "test"}}},
+		Auditor: &JSONAuditor{W: &audit},
+		now:     func() time.Time { return time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC) },
+	}
+	ctx := widevineproxy.WithTraceMetadata(context.Background(), widevineproxy.TraceMetadata{"X-Request-Id": "request-1"})
+	req := &widevineproxy.AuthorizationRequest{
+		Claims:   &widevineproxy.Claims{UserID: "user-1"},
+		PsshData: widevineproxy.PsshData{ContentID: "bW92aWUtMQ=="},
+		Device:   chromeCDM,
+	}
+	err := a.Authorize(ctx, req)
+	assert.True(t, errors.Is(err, ErrDeviceDenied))
+	assert.True(t, a.RequiresDeviceInfo(&req.PsshData))
+
+	var record AuditRecord
+	if assert.NoError(t, json.Unmarshal(audit.Bytes(), &record)) {
+		assert.Equal(t, "request-1", record.RequestID)
+		assert.Equal(t, "user-1", record.UserID)
+		assert.Equal(t, int64(4464), record.SystemID)
+		assert.Equal(t, ListDeny, record.List)
+		assert.Equal(t, "test", record.Entry.Reason)
+		assert.Equal(t, 2021, record.Time.Year())
+	}
+
+	req.Device = widevineproxy.DeviceInfo{Make: "Samsung"}
+	audit.Reset()
+	assert.NoError(t, a.Authorize(ctx, req))
+	assert.Zero(t, audit.Len())
+
+	// A failing audit does not hide the denial.
+	var auditErr error
+	a.Auditor = &JSONAuditor{W: failingWriter{}}
+	a.OnAuditError = func(err error) { auditErr = err }
+	req.Device = chromeCDM
+	var denied *DeviceDeniedError
+	if assert.True(t, errors.As(a.Authorize(ctx, req), &denied)) {
+		assert.Equal(t, "test", denied.Entry.Reason)
+	}
+	assert.Error(t, auditErr)
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) { return 0, errors.New("disk full") }
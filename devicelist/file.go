@@ -0,0 +1,107 @@
+package widevinedevicelist
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	widevineproxy "github.com/cooomma/widevine-proxy/proxy"
+)
+
+// DefaultReloadInterval is the ReloadInterval of the lists loaded by LoadFile.
+const DefaultReloadInterval = 10 * time.Second
+
+// FileList is a List read from a JSON file, reloaded when the file changes:
+//
+//	{"deny": [{"system_id": 4464, "reason": "leaked keybox"}, {"make": "acme", "model": "x1-*"}], "allow": []}
+//
+// The modification time of the file is checked by Check, at most once per ReloadInterval.
+// A file failing to load keeps the previous list in use.
+type FileList struct {
+	ReloadInterval time.Duration
+	// OnReloadError, if not nil, is called when the changed file fails to load.
+	OnReloadError func(err error)
+
+	path    string
+	mu      sync.Mutex
+	list    *List
+	modTime time.Time
+	checked time.Time
+	now     func() time.Time
+}
+
+// LoadFile reads the list of the file at path.
+func LoadFile(path string) (*FileList, error) {
+	f := &FileList{ReloadInterval: DefaultReloadInterval, path: path, now: time.Now}
+	if err := f.Reload(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Reload reads the file again. The previous list is kept on error.
+func (f *FileList) Reload() error {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return err
+	}
+	list, err := readList(f.path)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	f.list, f.modTime = list, info.ModTime()
+	f.mu.Unlock()
+	return nil
+}
+
+func readList(path string) (*List, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	list := &List{}
+	if err := json.Unmarshal(b, list); err != nil {
+		return nil, fmt.Errorf("decode device list %s: %v", path, err)
+	}
+	if err := list.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid device list %s: %v", path, err)
+	}
+	return list, nil
+}
+
+// List returns the current list, reloading the file first if it changed.
+func (f *FileList) List() *List {
+	f.mu.Lock()
+	now := f.now()
+	if now.Sub(f.checked) < f.ReloadInterval {
+		list := f.list
+		f.mu.Unlock()
+		return list
+	}
+	f.checked = now
+	modTime := f.modTime
+	f.mu.Unlock()
+
+	if info, err := os.Stat(f.path); err == nil && info.ModTime().Equal(modTime) {
+		return f.current()
+	}
+	if err := f.Reload(); err != nil && f.OnReloadError != nil {
+		f.OnReloadError(err)
+	}
+	return f.current()
+}
+
+func (f *FileList) current() *List {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.list
+}
+
+// Check returns a DeviceDeniedError if the current list denies device.
+func (f *FileList) Check(device *widevineproxy.DeviceInfo) error {
+	return f.List().Check(device)
+}
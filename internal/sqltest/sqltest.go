@@ -0,0 +1,235 @@
+// Package sqltest registers Driver, a database/sql driver keeping its tables in memory for the tests of
+// the SQL stores. Each data source name is a database of its own, e.g. the name of the test. It understands
+// the few statements the stores issue, with their conditions joined by AND:
+//
+//	INSERT INTO t (a, b) VALUES (?, ?)
+//	DELETE FROM t WHERE a = ? AND b = ?
+//	UPDATE t SET a = ?, b = ? WHERE c = ? AND d > ?
+//	SELECT a, b FROM t WHERE c = ? ORDER BY a, b
+//
+// The statements of a transaction apply at once: a rollback does not undo them.
+package sqltest
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Driver is the name the driver is registered with.
+const Driver = "sqltest"
+
+func init() {
+	sql.Register(Driver, &fakeDriver{dbs: map[string]map[string][]row{}})
+}
+
+// row holds the values of a row, keyed by column name.
+type row map[string]driver.Value
+
+type fakeDriver struct {
+	mu  sync.Mutex
+	dbs map[string]map[string][]row // Rows of the tables, keyed by table name.
+}
+
+func (d *fakeDriver) Open(name string) (driver.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.dbs[name] == nil {
+		d.dbs[name] = map[string][]row{}
+	}
+	return &fakeConn{d: d, tables: d.dbs[name]}, nil
+}
+
+type fakeConn struct {
+	d      *fakeDriver
+	tables map[string][]row
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{c: c, query: query}, nil
+}
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return c, nil }
+func (c *fakeConn) Commit() error             { return nil }
+func (c *fakeConn) Rollback() error           { return nil }
+
+var (
+	insertPattern = regexp.MustCompile(`^INSERT INTO (\w+) \((.+)\) VALUES`)
+	deletePattern = regexp.MustCompile(`^DELETE FROM (\w+) WHERE (.+)$`)
+	updatePattern = regexp.MustCompile(`^UPDATE (\w+) SET (.+) WHERE (.+)$`)
+	selectPattern = regexp.MustCompile(`^SELECT (.+) FROM (\w+)(?: WHERE (.+?))?(?: ORDER BY (.+))?$`)
+	condPattern   = regexp.MustCompile(`^(\w+) (=|>) (?:\?|\$\d+)$`)
+)
+
+// condition is a comparison of a column with an argument.
+type condition struct {
+	column string
+	op     string // = or >.
+}
+
+// conditions parses "a = ? AND b > ?", or "a = ?, b = ?" with sep ",".
+func conditions(s, sep string) ([]condition, error) {
+	var conds []condition
+	for _, c := range strings.Split(s, sep) {
+		m := condPattern.FindStringSubmatch(strings.TrimSpace(c))
+		if m == nil {
+			return nil, fmt.Errorf("unexpected condition %q", c)
+		}
+		conds = append(conds, condition{column: m[1], op: m[2]})
+	}
+	return conds, nil
+}
+
+func (r row) matches(conds []condition, args []driver.Value) bool {
+	for i, c := range conds {
+		switch n := compare(r[c.column], args[i]); c.op {
+		case "=":
+			if n != 0 {
+				return false
+			}
+		case ">":
+			if n <= 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// compare orders the int64 and string values the stores bind.
+func compare(a, b driver.Value) int {
+	if x, ok := a.(int64); ok {
+		if y, ok := b.(int64); ok {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		}
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+type fakeStmt struct {
+	c     *fakeConn
+	query string
+}
+
+func (s *fakeStmt) Close() error  { return nil }
+func (s *fakeStmt) NumInput() int { return -1 }
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.c.d.mu.Lock()
+	defer s.c.d.mu.Unlock()
+	if m := insertPattern.FindStringSubmatch(s.query); m != nil {
+		r := row{}
+		for i, name := range strings.Split(m[2], ", ") {
+			r[name] = args[i]
+		}
+		s.c.tables[m[1]] = append(s.c.tables[m[1]], r)
+		return driver.RowsAffected(1), nil
+	}
+	if m := deletePattern.FindStringSubmatch(s.query); m != nil {
+		where, err := conditions(m[2], " AND ")
+		if err != nil {
+			return nil, err
+		}
+		var kept []row
+		for _, r := range s.c.tables[m[1]] {
+			if !r.matches(where, args) {
+				kept = append(kept, r)
+			}
+		}
+		deleted := len(s.c.tables[m[1]]) - len(kept)
+		s.c.tables[m[1]] = kept
+		return driver.RowsAffected(deleted), nil
+	}
+	if m := updatePattern.FindStringSubmatch(s.query); m != nil {
+		set, err := conditions(m[2], ",")
+		if err != nil {
+			return nil, err
+		}
+		where, err := conditions(m[3], " AND ")
+		if err != nil {
+			return nil, err
+		}
+		updated := 0
+		for _, r := range s.c.tables[m[1]] {
+			if !r.matches(where, args[len(set):]) {
+				continue
+			}
+			for i, c := range set {
+				r[c.column] = args[i]
+			}
+			updated++
+		}
+		return driver.RowsAffected(updated), nil
+	}
+	return nil, fmt.Errorf("unexpected statement %q", s.query)
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.c.d.mu.Lock()
+	defer s.c.d.mu.Unlock()
+	m := selectPattern.FindStringSubmatch(s.query)
+	if m == nil {
+		return nil, fmt.Errorf("unexpected query %q", s.query)
+	}
+	var where []condition
+	if m[3] != "" {
+		var err error
+		if where, err = conditions(m[3], " AND "); err != nil {
+			return nil, err
+		}
+	}
+	var selected []row
+	for _, r := range s.c.tables[m[2]] {
+		if r.matches(where, args) {
+			selected = append(selected, r)
+		}
+	}
+	if m[4] != "" {
+		order := strings.Split(m[4], ", ")
+		sort.SliceStable(selected, func(i, j int) bool {
+			for _, name := range order {
+				if n := compare(selected[i][name], selected[j][name]); n != 0 {
+					return n < 0
+				}
+			}
+			return false
+		})
+	}
+	rows := &fakeRows{columns: strings.Split(m[1], ", ")}
+	for _, r := range selected {
+		values := make([]driver.Value, len(rows.columns))
+		for i, name := range rows.columns {
+			values[i] = r[name]
+		}
+		rows.values = append(rows.values, values)
+	}
+	return rows, nil
+}
+
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
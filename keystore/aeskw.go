@@ -0,0 +1,69 @@
+package widevinekeystore
+
+import (
+	"crypto/aes"
+	"crypto/subtle"
+	"encoding/binary"
+	"errors"
+)
+
+// aesKWIV is the default initial value of RFC 3394.
+var aesKWIV = []byte{0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6, 0xa6}
+
+// aesKeyWrap wraps plaintext, a multiple of 8 bytes, with kek as specified by RFC 3394.
+func aesKeyWrap(kek, plaintext []byte) ([]byte, error) {
+	if len(plaintext) < 16 || len(plaintext)%8 != 0 {
+		return nil, errors.New("aes key wrap: plaintext must be a multiple of 8 bytes, at least 16")
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	n := len(plaintext) / 8
+	out := make([]byte, 8+len(plaintext))
+	copy(out, aesKWIV)
+	copy(out[8:], plaintext)
+
+	b := make([]byte, 16)
+	for j := 0; j < 6; j++ {
+		for i := 1; i <= n; i++ {
+			copy(b, out[:8])
+			copy(b[8:], out[i*8:i*8+8])
+			block.Encrypt(b, b)
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(out[:8], binary.BigEndian.Uint64(b[:8])^t)
+			copy(out[i*8:], b[8:])
+		}
+	}
+	return out, nil
+}
+
+// aesKeyUnwrap reverses aesKeyWrap and checks the integrity of the wrapped key.
+func aesKeyUnwrap(kek, wrapped []byte) ([]byte, error) {
+	if len(wrapped) < 24 || len(wrapped)%8 != 0 {
+		return nil, errors.New("aes key unwrap: wrapped key must be a multiple of 8 bytes, at least 24")
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	n := len(wrapped)/8 - 1
+	out := make([]byte, len(wrapped))
+	copy(out, wrapped)
+
+	b := make([]byte, 16)
+	for j := 5; j >= 0; j-- {
+		for i := n; i >= 1; i-- {
+			t := uint64(n*j + i)
+			binary.BigEndian.PutUint64(b, binary.BigEndian.Uint64(out[:8])^t)
+			copy(b[8:], out[i*8:i*8+8])
+			block.Decrypt(b, b)
+			copy(out[:8], b[:8])
+			copy(out[i*8:], b[8:])
+		}
+	}
+	if subtle.ConstantTimeCompare(out[:8], aesKWIV) != 1 {
+		return nil, errors.New("aes key unwrap: integrity check failed")
+	}
+	return out[8:], nil
+}
@@ -0,0 +1,108 @@
+package widevinekeystore
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+
+	widevineproxy "github.com/cooomma/widevine-proxy/proxy"
+)
+
+// Authority is a LicenseAuthority issuing licenses with the content keys of a KeyStore.
+// The ContentKeySpecs are built from the key ids of the pssh data, or from every key of the content
+// if the pssh data carries no key id.
+type Authority struct {
+	Store KeyStore
+
+	LicenseServerURL string
+	Provider         string
+	SigningKey       []byte
+	SigningIV        []byte
+
+	AllowedTrackTypes widevineproxy.AllowedTrackType
+	PolicyOverrides   *widevineproxy.PolicyOverrides
+	// SecurityLevels sets the security level of the keys per track type, the Widevine default if absent.
+	SecurityLevels map[widevineproxy.ContentTrackType]widevineproxy.SecurityLevel
+	// Keyring unwraps the keys wrapped in the store. Required if the store holds wrapped keys.
+	Keyring *Keyring
+}
+
+func (a *Authority) BuildLicenseMessage(reqBody []byte, psshData *widevineproxy.PsshData) (*widevineproxy.Message, error) {
+	return a.BuildLicenseMessageWithContext(context.Background(), reqBody, psshData)
+}
+
+// BuildLicenseMessageWithContext looks up the keys of the license in the store.
+func (a *Authority) BuildLicenseMessageWithContext(ctx context.Context, reqBody []byte, psshData *widevineproxy.PsshData) (*widevineproxy.Message, error) {
+	keys, err := a.keysOf(ctx, psshData)
+	if err != nil {
+		return nil, err
+	}
+	message := &widevineproxy.Message{
+		Payload:           base64.StdEncoding.EncodeToString(reqBody),
+		Provider:          a.Provider,
+		ContentID:         psshData.ContentID,
+		AllowedTrackTypes: a.AllowedTrackTypes,
+		PolicyOverrides:   a.PolicyOverrides.Copy(),
+	}
+	for _, key := range keys {
+		spec, err := a.contentKeySpec(key)
+		if err != nil {
+			return nil, err
+		}
+		message.ContentKeySpecs = append(message.ContentKeySpecs, spec)
+	}
+	return message, nil
+}
+
+func (a *Authority) keysOf(ctx context.Context, psshData *widevineproxy.PsshData) ([]*ContentKey, error) {
+	contentID, err := base64.StdEncoding.DecodeString(psshData.ContentID)
+	if err != nil {
+		return nil, fmt.Errorf("content id %q: %v", psshData.ContentID, err)
+	}
+	if len(psshData.KeyID) == 0 {
+		return a.Store.Keys(ctx, string(contentID))
+	}
+	keys := make([]*ContentKey, 0, len(psshData.KeyID))
+	for _, encoded := range psshData.KeyID {
+		keyID, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("key id %q: %v", encoded, err)
+		}
+		key, err := a.Store.Key(ctx, string(contentID), keyID)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// contentKeySpec unwraps key, if wrapped, only for the time of encoding it into the spec.
+func (a *Authority) contentKeySpec(key *ContentKey) (widevineproxy.ContentKeySpec, error) {
+	if key.Wrap != "" {
+		if a.Keyring == nil {
+			return widevineproxy.ContentKeySpec{}, fmt.Errorf("%w: no keyring to unwrap key %x of %s", ErrUnknownKEK, []byte(key.KeyID), key.ContentID)
+		}
+		plain, err := a.Keyring.UnwrapKey(key)
+		if err != nil {
+			return widevineproxy.ContentKeySpec{}, err
+		}
+		defer zero(plain.Key)
+		key = plain
+	}
+	spec := widevineproxy.ContentKeySpec{
+		TrackType:     key.TrackType,
+		SecurityLevel: a.SecurityLevels[key.TrackType],
+		KeyID:         base64.StdEncoding.EncodeToString(key.KeyID),
+		Key:           base64.StdEncoding.EncodeToString(key.Key),
+	}
+	if len(key.IV) > 0 {
+		spec.IV = base64.StdEncoding.EncodeToString(key.IV)
+	}
+	return spec, nil
+}
+
+func (a *Authority) GetLicenseServerURL() string { return a.LicenseServerURL }
+func (a *Authority) GetSigningKey() []byte       { return a.SigningKey }
+func (a *Authority) GetSigningIV() []byte        { return a.SigningIV }
+func (a *Authority) GetProvider() string         { return a.Provider }
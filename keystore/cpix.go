@@ -0,0 +1,106 @@
+package widevinekeystore
+
+import (
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+
+	widevineproxy "github.com/cooomma/widevine-proxy/proxy"
+)
+
+// Namespaces of a CPIX document.
+const (
+	CPIXNamespace = "urn:dashif:org:cpix"
+	PSKCNamespace = "urn:ietf:params:xml:ns:keyprov:pskc"
+)
+
+// widevineSystemID is the Widevine DRM system id in the UUID form used by CPIX.
+const widevineSystemID = "edef8ba9-79d6-4ace-a3c8-27dcd51d21ed"
+
+type cpixDocument struct {
+	XMLName   xml.Name `xml:"cpix:CPIX"`
+	CPIX      string   `xml:"xmlns:cpix,attr"`
+	PSKC      string   `xml:"xmlns:pskc,attr"`
+	ContentID string   `xml:"contentId,attr"`
+
+	ContentKeys []cpixContentKey `xml:"cpix:ContentKeyList>cpix:ContentKey"`
+	DRMSystems  []cpixDRMSystem  `xml:"cpix:DRMSystemList>cpix:DRMSystem"`
+	UsageRules  []cpixUsageRule  `xml:"cpix:ContentKeyUsageRuleList>cpix:ContentKeyUsageRule"`
+}
+
+type cpixContentKey struct {
+	KID        string `xml:"kid,attr"`
+	Scheme     string `xml:"commonEncryptionScheme,attr"`
+	PlainValue string `xml:"cpix:Data>pskc:Secret>pskc:PlainValue"`
+}
+
+type cpixDRMSystem struct {
+	KID      string `xml:"kid,attr"`
+	SystemID string `xml:"systemId,attr"`
+	PSSH     string `xml:"cpix:PSSH"`
+}
+
+type cpixUsageRule struct {
+	KID               string           `xml:"kid,attr"`
+	IntendedTrackType string           `xml:"intendedTrackType,attr"`
+	VideoFilter       *cpixVideoFilter `xml:"cpix:VideoFilter,omitempty"`
+	AudioFilter       *struct{}        `xml:"cpix:AudioFilter,omitempty"`
+}
+
+type cpixVideoFilter struct {
+	MinPixels int `xml:"minPixels,attr,omitempty"`
+	MaxPixels int `xml:"maxPixels,attr,omitempty"`
+}
+
+// videoFilters bound the resolution of the video tracks of each track type, in pixels per frame:
+// SD up to 576p, HD up to 1080p, UHD1 up to 4K.
+var videoFilters = map[widevineproxy.ContentTrackType]cpixVideoFilter{
+	widevineproxy.ContentTrackTypeSD:   {MaxPixels: 1024 * 576},
+	widevineproxy.ContentTrackTypeHD:   {MinPixels: 1024*576 + 1, MaxPixels: 1920 * 1080},
+	widevineproxy.ContentTrackTypeUHD1: {MinPixels: 1920*1080 + 1, MaxPixels: 4096 * 2160},
+	widevineproxy.ContentTrackTypeUHD2: {MinPixels: 4096*2160 + 1},
+}
+
+// WriteCPIX writes the DASH-IF CPIX document of p: the plaintext keys, the Widevine pssh box,
+// and a usage rule mapping each key to its track type.
+func (p *Provisioned) WriteCPIX(w io.Writer) error {
+	doc := cpixDocument{CPIX: CPIXNamespace, PSKC: PSKCNamespace, ContentID: p.ContentID}
+	pssh := base64.StdEncoding.EncodeToString(p.PSSH)
+	for _, key := range p.Keys {
+		if key.Wrap != "" {
+			return fmt.Errorf("key %x of %s is wrapped", []byte(key.KeyID), key.ContentID)
+		}
+		kid := uuid(key.KeyID)
+		doc.ContentKeys = append(doc.ContentKeys, cpixContentKey{
+			KID:        kid,
+			Scheme:     p.ProtectionScheme,
+			PlainValue: base64.StdEncoding.EncodeToString(key.Key),
+		})
+		doc.DRMSystems = append(doc.DRMSystems, cpixDRMSystem{KID: kid, SystemID: widevineSystemID, PSSH: pssh})
+
+		rule := cpixUsageRule{KID: kid, IntendedTrackType: string(key.TrackType)}
+		if key.TrackType == widevineproxy.ContentTrackTypeAudio {
+			rule.AudioFilter = &struct{}{}
+		} else if filter, ok := videoFilters[key.TrackType]; ok {
+			rule.VideoFilter = &filter
+		}
+		doc.UsageRules = append(doc.UsageRules, rule)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// uuid formats a 16 bytes key id as a UUID.
+func uuid(b []byte) string {
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
@@ -0,0 +1,84 @@
+package widevinekeystore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// FileStore is a KeyStore persisted as a JSON file:
+//
+//	{"keys": [{"content_id": "movie-1", "key_id": "<hex>", "key": "<hex>", "track_type": "HD"}]}
+//
+// The file is read once by LoadFileStore, and rewritten on each Put.
+type FileStore struct {
+	*MemoryStore
+	path string
+	mu   sync.Mutex // Serializes the writes of the file.
+}
+
+type keyFile struct {
+	Keys []*ContentKey `json:"keys"`
+}
+
+// LoadFileStore reads the keys of the file at path. A missing file is an empty store.
+func LoadFileStore(path string) (*FileStore, error) {
+	s := &FileStore{MemoryStore: NewMemoryStore(), path: path}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var f keyFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("decode key store %s: %v", path, err)
+	}
+	for _, key := range f.Keys {
+		if err := key.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid key store %s: %v", path, err)
+		}
+		s.put(key)
+	}
+	return s, nil
+}
+
+// Put adds or replaces a key and rewrites the file.
+func (s *FileStore) Put(ctx context.Context, key *ContentKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.MemoryStore.Put(ctx, key); err != nil {
+		return err
+	}
+	return s.save()
+}
+
+// save writes the keys to a temporary file renamed over the store, so that readers never see a partial file.
+func (s *FileStore) save() error {
+	b, err := json.MarshalIndent(keyFile{Keys: s.all()}, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
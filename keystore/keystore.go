@@ -0,0 +1,108 @@
+// Package widevinekeystore stores the content keys served by the proxy when working with foreign keys,
+// i.e. keys generated outside of the Widevine service and handed over in ContentKeySpecs.
+package widevinekeystore
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	widevineproxy "github.com/cooomma/widevine-proxy/proxy"
+)
+
+// ErrKeyNotFound is returned when a store has no key for the requested content or key id.
+var ErrKeyNotFound = errors.New("content key not found")
+
+// ContentKey is the key of one track of a content.
+type ContentKey struct {
+	ContentID string                         `json:"content_id"` // Raw content id, as in the WidevineCencHeader.
+	KeyID     HexBytes                       `json:"key_id"`     // 16 bytes.
+	Key       HexBytes                       `json:"key"`        // 16 bytes, or the wrapped key if Wrap is set.
+	IV        HexBytes                       `json:"iv,omitempty"`
+	TrackType widevineproxy.ContentTrackType `json:"track_type"`
+
+	// Wrap is the algorithm wrapping Key under the KEKVersion of a Keyring, empty for a plaintext key.
+	Wrap       string `json:"wrap,omitempty"`
+	KEKVersion int    `json:"kek_version,omitempty"`
+}
+
+// Validate checks the sizes of the key id, key and IV.
+func (k *ContentKey) Validate() error {
+	if k.ContentID == "" {
+		return fmt.Errorf("missing content id")
+	}
+	if len(k.KeyID) != 16 {
+		return fmt.Errorf("key id of %s must be 16 bytes", k.ContentID)
+	}
+	if k.Wrap == "" && len(k.Key) != 16 {
+		return fmt.Errorf("key %x must be 16 bytes", []byte(k.KeyID))
+	}
+	if k.Wrap != "" && len(k.Key) == 0 {
+		return fmt.Errorf("missing wrapped key %x", []byte(k.KeyID))
+	}
+	if len(k.IV) != 0 && len(k.IV) != 8 && len(k.IV) != 16 {
+		return fmt.Errorf("iv of key %x must be 8 or 16 bytes", []byte(k.KeyID))
+	}
+	return nil
+}
+
+// KeyStore looks up content keys.
+type KeyStore interface {
+	// Key returns the key keyID of contentID, or ErrKeyNotFound.
+	Key(ctx context.Context, contentID string, keyID []byte) (*ContentKey, error)
+	// Keys returns the keys of contentID, or ErrKeyNotFound if it has none.
+	Keys(ctx context.Context, contentID string) ([]*ContentKey, error)
+	// Put adds or replaces a key.
+	Put(ctx context.Context, key *ContentKey) error
+}
+
+// Lister is a KeyStore able to enumerate its keys, e.g. to rewrap them.
+type Lister interface {
+	KeyStore
+	// AllKeys returns every key of the store, ordered by content id and key id.
+	AllKeys(ctx context.Context) ([]*ContentKey, error)
+}
+
+// KeysByTrackType returns the keys of contentID for trackType.
+func KeysByTrackType(ctx context.Context, store KeyStore, contentID string, trackType widevineproxy.ContentTrackType) ([]*ContentKey, error) {
+	keys, err := store.Keys(ctx, contentID)
+	if err != nil {
+		return nil, err
+	}
+	var matching []*ContentKey
+	for _, key := range keys {
+		if key.TrackType == trackType {
+			matching = append(matching, key)
+		}
+	}
+	if len(matching) == 0 {
+		return nil, fmt.Errorf("%w: no %s key for %s", ErrKeyNotFound, trackType, contentID)
+	}
+	return matching, nil
+}
+
+// HexBytes is a byte slice encoded as hex in JSON.
+type HexBytes []byte
+
+// MarshalText encodes b as hex.
+func (b HexBytes) MarshalText() ([]byte, error) {
+	return []byte(hex.EncodeToString(b)), nil
+}
+
+// UnmarshalText decodes hex text into b.
+func (b *HexBytes) UnmarshalText(text []byte) error {
+	decoded, err := hex.DecodeString(string(text))
+	if err != nil {
+		return err
+	}
+	*b = decoded
+	return nil
+}
+
+func notFound(contentID string, keyID []byte) error {
+	if keyID == nil {
+		return fmt.Errorf("%w: no key for %s", ErrKeyNotFound, contentID)
+	}
+	return fmt.Errorf("%w: %x of %s", ErrKeyNotFound, keyID, contentID)
+}
@@ -0,0 +1,134 @@
+package widevinekeystore
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/cooomma/widevine-proxy/internal/sqltest"
+	widevineproxy "github.com/cooomma/widevine-proxy/proxy"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	sdKey = &ContentKey{
+		ContentID: "movie-1",
+		KeyID:     bytes.Repeat([]byte{0x11}, 16),
+		Key:       bytes.Repeat([]byte{0xaa}, 16),
+		TrackType: widevineproxy.ContentTrackTypeSD,
+	}
+	hdKey = &ContentKey{
+		ContentID: "movie-1",
+		KeyID:     bytes.Repeat([]byte{0x22}, 16),
+		Key:       bytes.Repeat([]byte{0xbb}, 16),
+		IV:        bytes.Repeat([]byte{0x01}, 16),
+		TrackType: widevineproxy.ContentTrackTypeHD,
+	}
+)
+
+// testStore checks the behavior shared by every KeyStore, starting from an empty store.
+func testStore(t *testing.T, store KeyStore) {
+	ctx := context.Background()
+	_, err := store.Key(ctx, "movie-1", sdKey.KeyID)
+	assert.True(t, errors.Is(err, ErrKeyNotFound))
+	_, err = store.Keys(ctx, "movie-1")
+	assert.True(t, errors.Is(err, ErrKeyNotFound))
+
+	assert.Error(t, store.Put(ctx, &ContentKey{ContentID: "movie-1", KeyID: []byte{1}, Key: sdKey.Key}))
+	assert.NoError(t, store.Put(ctx, hdKey))
+	assert.NoError(t, store.Put(ctx, sdKey))
+
+	key, err := store.Key(ctx, "movie-1", hdKey.KeyID)
+	if assert.NoError(t, err) {
+		assert.Equal(t, hdKey, key)
+	}
+	keys, err := store.Keys(ctx, "movie-1")
+	assert.NoError(t, err)
+	assert.Equal(t, []*ContentKey{sdKey, hdKey}, keys)
+
+	hd, err := KeysByTrackType(ctx, store, "movie-1", widevineproxy.ContentTrackTypeHD)
+	assert.NoError(t, err)
+	assert.Equal(t, []*ContentKey{hdKey}, hd)
+	_, err = KeysByTrackType(ctx, store, "movie-1", widevineproxy.ContentTrackTypeUHD1)
+	assert.True(t, errors.Is(err, ErrKeyNotFound))
+
+	replaced := *sdKey
+	replaced.Key = bytes.Repeat([]byte{0xcc}, 16)
+	assert.NoError(t, store.Put(ctx, &replaced))
+	key, _ = store.Key(ctx, "movie-1", sdKey.KeyID)
+	assert.Equal(t, &replaced, key)
+	assert.NoError(t, store.Put(ctx, sdKey))
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore())
+}
+
+func TestFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	store, err := LoadFileStore(path)
+	assert.NoError(t, err)
+	testStore(t, store)
+
+	reloaded, err := LoadFileStore(path)
+	assert.NoError(t, err)
+	keys, err := reloaded.Keys(context.Background(), "movie-1")
+	assert.NoError(t, err)
+	assert.Equal(t, []*ContentKey{sdKey, hdKey}, keys)
+
+	b, _ := ioutil.ReadFile(path)
+	assert.Contains(t, string(b), `"key_id": "11111111111111111111111111111111"`)
+}
+
+func TestSQLStore(t *testing.T) {
+	db, err := sql.Open(sqltest.Driver, t.Name())
+	assert.NoError(t, err)
+	defer db.Close()
+	testStore(t, NewSQLStore(db, PlaceholderQuestion))
+
+	store := NewSQLStore(db, PlaceholderDollar)
+	assert.Equal(t, "SELECT * FROM content_keys WHERE content_id = $1 AND key_id = $2",
+		store.query("SELECT * FROM %s WHERE content_id = ? AND key_id = ?"))
+}
+
+func TestAuthority(t *testing.T) {
+	a := &Authority{
+		Store:             NewMemoryStore(sdKey, hdKey),
+		Provider:          "widevine_test",
+		AllowedTrackTypes: widevineproxy.AllowedTrackTypeHD,
+		PolicyOverrides:   &widevineproxy.PolicyOverrides{CanPlay: true},
+		SecurityLevels: map[widevineproxy.ContentTrackType]widevineproxy.SecurityLevel{
+			widevineproxy.ContentTrackTypeHD: widevineproxy.SecurityLevelHardwareSecureAll,
+		},
+	}
+	b64 := base64.StdEncoding.EncodeToString
+	psshData := &widevineproxy.PsshData{ContentID: b64([]byte("movie-1")), KeyID: []string{b64(hdKey.KeyID)}}
+
+	message, err := a.BuildLicenseMessage([]byte("challenge"), psshData)
+	assert.NoError(t, err)
+	assert.Equal(t, []widevineproxy.ContentKeySpec{{
+		TrackType:     widevineproxy.ContentTrackTypeHD,
+		SecurityLevel: widevineproxy.SecurityLevelHardwareSecureAll,
+		KeyID:         b64(hdKey.KeyID),
+		Key:           b64(hdKey.Key),
+		IV:            b64(hdKey.IV),
+	}}, message.ContentKeySpecs)
+	assert.Equal(t, psshData.ContentID, message.ContentID)
+	// The message owns a copy of the overrides, free to change.
+	assert.Equal(t, a.PolicyOverrides, message.PolicyOverrides)
+	assert.False(t, a.PolicyOverrides == message.PolicyOverrides)
+
+	psshData.KeyID = nil
+	message, err = a.BuildLicenseMessage([]byte("challenge"), psshData)
+	assert.NoError(t, err)
+	assert.Len(t, message.ContentKeySpecs, 2)
+
+	psshData.KeyID = []string{b64(bytes.Repeat([]byte{0x33}, 16))}
+	_, err = a.BuildLicenseMessage([]byte("challenge"), psshData)
+	assert.True(t, errors.Is(err, ErrKeyNotFound))
+}
@@ -0,0 +1,94 @@
+package widevinekeystore
+
+import (
+	"context"
+	"encoding/hex"
+	"sort"
+	"sync"
+)
+
+// MemoryStore is a KeyStore held in memory, e.g. for tests or keys loaded at startup.
+type MemoryStore struct {
+	mu   sync.RWMutex
+	keys map[string]map[string]*ContentKey // Content id, then hex key id.
+}
+
+// NewMemoryStore creates a MemoryStore holding keys.
+func NewMemoryStore(keys ...*ContentKey) *MemoryStore {
+	s := &MemoryStore{keys: map[string]map[string]*ContentKey{}}
+	for _, key := range keys {
+		s.put(key)
+	}
+	return s
+}
+
+func (s *MemoryStore) Key(ctx context.Context, contentID string, keyID []byte) (*ContentKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	key, ok := s.keys[contentID][hex.EncodeToString(keyID)]
+	if !ok {
+		return nil, notFound(contentID, keyID)
+	}
+	copied := *key
+	return &copied, nil
+}
+
+// Keys returns the keys of contentID ordered by key id.
+func (s *MemoryStore) Keys(ctx context.Context, contentID string) ([]*ContentKey, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if len(s.keys[contentID]) == 0 {
+		return nil, notFound(contentID, nil)
+	}
+	keys := make([]*ContentKey, 0, len(s.keys[contentID]))
+	for _, key := range s.keys[contentID] {
+		copied := *key
+		keys = append(keys, &copied)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return hex.EncodeToString(keys[i].KeyID) < hex.EncodeToString(keys[j].KeyID)
+	})
+	return keys, nil
+}
+
+func (s *MemoryStore) Put(ctx context.Context, key *ContentKey) error {
+	if err := key.Validate(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.put(key)
+	return nil
+}
+
+func (s *MemoryStore) put(key *ContentKey) {
+	copied := *key
+	if s.keys[key.ContentID] == nil {
+		s.keys[key.ContentID] = map[string]*ContentKey{}
+	}
+	s.keys[key.ContentID][hex.EncodeToString(key.KeyID)] = &copied
+}
+
+// AllKeys returns every key of the store, ordered by content id and key id.
+func (s *MemoryStore) AllKeys(ctx context.Context) ([]*ContentKey, error) {
+	return s.all(), nil
+}
+
+func (s *MemoryStore) all() []*ContentKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var keys []*ContentKey
+	for _, byKeyID := range s.keys {
+		for _, key := range byKeyID {
+			copied := *key
+			keys = append(keys, &copied)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].ContentID != keys[j].ContentID {
+			return keys[i].ContentID < keys[j].ContentID
+		}
+		return hex.EncodeToString(keys[i].KeyID) < hex.EncodeToString(keys[j].KeyID)
+	})
+	return keys
+}
@@ -0,0 +1,135 @@
+package widevinekeystore
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+
+	widevineproxy "github.com/cooomma/widevine-proxy/proxy"
+	widevineutils "github.com/cooomma/widevine-proxy/utils"
+)
+
+// Protection schemes of the common encryption, ISO/IEC 23001-7.
+const (
+	SchemeCENC = "cenc"
+	SchemeCBCS = "cbcs"
+)
+
+// Provisioner generates the content keys of new contents into a KeyStore, for a packager to encrypt
+// the content with the keys the proxy serves.
+type Provisioner struct {
+	Store KeyStore
+	// Provider is written into the pssh data, as the provider of the Widevine service.
+	Provider string
+	// ProtectionScheme is written into the pssh data and the CPIX document, SchemeCENC if empty.
+	ProtectionScheme string
+	// Keyring unwraps the keys already in the store, if wrapped. Wrap the new keys with a WrappingStore.
+	Keyring *Keyring
+	// Rand is the source of the key ids and keys, crypto/rand if nil.
+	Rand io.Reader
+}
+
+// NewProvisioner creates a Provisioner generating keys into store.
+func NewProvisioner(store KeyStore, provider string) *Provisioner {
+	return &Provisioner{Store: store, Provider: provider}
+}
+
+// Provisioned holds the plaintext keys of a content and the pssh box of its Widevine protection.
+type Provisioned struct {
+	ContentID        string
+	ProtectionScheme string
+	Keys             []*ContentKey
+	PSSH             []byte // Widevine pssh box, version 0, carrying the key ids of Keys.
+}
+
+// Provision returns the keys of contentID for trackTypes, generating a key id and key for the track types
+// missing from the store. Provisioning a content twice returns the same keys.
+func (p *Provisioner) Provision(ctx context.Context, contentID string, trackTypes ...widevineproxy.ContentTrackType) (*Provisioned, error) {
+	if contentID == "" {
+		return nil, fmt.Errorf("missing content id")
+	}
+	if len(trackTypes) == 0 {
+		return nil, fmt.Errorf("no track type to provision for %s", contentID)
+	}
+	existing, err := p.Store.Keys(ctx, contentID)
+	if err != nil && !errors.Is(err, ErrKeyNotFound) {
+		return nil, err
+	}
+
+	provisioned := &Provisioned{ContentID: contentID, ProtectionScheme: p.protectionScheme()}
+	for _, trackType := range trackTypes {
+		key, err := p.existingKey(existing, trackType)
+		if err != nil {
+			return nil, err
+		}
+		if key == nil {
+			if key, err = p.generate(contentID, trackType); err != nil {
+				return nil, err
+			}
+			if err := p.Store.Put(ctx, key); err != nil {
+				return nil, err
+			}
+		}
+		provisioned.Keys = append(provisioned.Keys, key)
+	}
+
+	if provisioned.PSSH, err = p.PSSH(provisioned.Keys); err != nil {
+		return nil, err
+	}
+	return provisioned, nil
+}
+
+// PSSH returns the Widevine pssh box of the content of keys.
+func (p *Provisioner) PSSH(keys []*ContentKey) ([]byte, error) {
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no key to build the pssh box of")
+	}
+	b := &widevineutils.PSSHBuilder{
+		ContentID:        []byte(keys[0].ContentID),
+		Provider:         p.Provider,
+		ProtectionScheme: p.protectionScheme(),
+	}
+	for _, key := range keys {
+		b.KeyIDs = append(b.KeyIDs, key.KeyID)
+	}
+	return b.Build()
+}
+
+func (p *Provisioner) protectionScheme() string {
+	if p.ProtectionScheme == "" {
+		return SchemeCENC
+	}
+	return p.ProtectionScheme
+}
+
+func (p *Provisioner) existingKey(keys []*ContentKey, trackType widevineproxy.ContentTrackType) (*ContentKey, error) {
+	for _, key := range keys {
+		if key.TrackType != trackType {
+			continue
+		}
+		if key.Wrap == "" {
+			plain := *key
+			plain.Key = append(HexBytes(nil), key.Key...)
+			return &plain, nil
+		}
+		if p.Keyring == nil {
+			return nil, fmt.Errorf("%w: no keyring to unwrap key %x of %s", ErrUnknownKEK, []byte(key.KeyID), key.ContentID)
+		}
+		return p.Keyring.UnwrapKey(key)
+	}
+	return nil, nil
+}
+
+func (p *Provisioner) generate(contentID string, trackType widevineproxy.ContentTrackType) (*ContentKey, error) {
+	random := p.Rand
+	if random == nil {
+		random = rand.Reader
+	}
+	b := make([]byte, 32)
+	if _, err := io.ReadFull(random, b); err != nil {
+		return nil, fmt.Errorf("generate key of %s: %v", contentID, err)
+	}
+	return &ContentKey{ContentID: contentID, KeyID: b[:16], Key: b[16:], TrackType: trackType}, nil
+}
@@ -0,0 +1,130 @@
+package widevinekeystore
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"testing"
+
+	widevineproxy "github.com/cooomma/widevine-proxy/proxy"
+	widevineutils "github.com/cooomma/widevine-proxy/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProvision(t *testing.T) {
+	ctx := context.Background()
+	keyring, _ := NewKeyring(1, map[int][]byte{1: kek1})
+	store := NewMemoryStore()
+	p := NewProvisioner(NewWrappingStore(store, keyring), "widevine_test")
+	p.ProtectionScheme = SchemeCBCS
+	p.Keyring = keyring
+
+	provisioned, err := p.Provision(ctx, "movie-1", widevineproxy.ContentTrackTypeSD, widevineproxy.ContentTrackTypeHD)
+	if !assert.NoError(t, err) || !assert.Len(t, provisioned.Keys, 2) {
+		return
+	}
+	assert.NotEqual(t, provisioned.Keys[0].KeyID, provisioned.Keys[1].KeyID)
+	for _, key := range provisioned.Keys {
+		assert.NoError(t, key.Validate())
+		assert.Empty(t, key.Wrap)
+		stored, err := store.Key(ctx, "movie-1", key.KeyID)
+		if assert.NoError(t, err) {
+			assert.Equal(t, WrapAESKW, stored.Wrap, "the key is stored wrapped")
+		}
+	}
+
+	summary, err := widevineutils.NewPSSH(provisioned.PSSH).Parse()
+	if assert.NoError(t, err) && assert.NotNil(t, summary.Widevine) {
+		header := summary.Widevine
+		assert.Equal(t, "movie-1", string(header.ContentId))
+		assert.Equal(t, "widevine_test", header.GetProvider())
+		assert.Equal(t, uint32(0x63626373), header.GetProtectionScheme())
+		assert.Equal(t, [][]byte{provisioned.Keys[0].KeyID, provisioned.Keys[1].KeyID}, header.KeyId)
+	}
+
+	again, err := p.Provision(ctx, "movie-1", widevineproxy.ContentTrackTypeHD, widevineproxy.ContentTrackTypeAudio)
+	if assert.NoError(t, err) && assert.Len(t, again.Keys, 2) {
+		assert.Equal(t, provisioned.Keys[1], again.Keys[0], "existing keys are reused")
+	}
+	keys, _ := store.Keys(ctx, "movie-1")
+	assert.Len(t, keys, 3)
+
+	p.Keyring = nil
+	_, err = p.Provision(ctx, "movie-1", widevineproxy.ContentTrackTypeHD)
+	assert.True(t, errors.Is(err, ErrUnknownKEK))
+
+	// The plaintext keys are reused without a keyring, as copies.
+	p = NewProvisioner(store, "widevine_test")
+	plain, err := p.Provision(ctx, "movie-2", widevineproxy.ContentTrackTypeSD)
+	if !assert.NoError(t, err) || !assert.Len(t, plain.Keys, 1) {
+		return
+	}
+	again, err = p.Provision(ctx, "movie-2", widevineproxy.ContentTrackTypeSD)
+	if assert.NoError(t, err) && assert.Len(t, again.Keys, 1) {
+		assert.Equal(t, plain.Keys[0], again.Keys[0])
+		stored, _ := store.Key(ctx, "movie-2", plain.Keys[0].KeyID)
+		again.Keys[0].Key[0] ^= 0xff
+		assert.Equal(t, plain.Keys[0].Key, stored.Key, "the stored key is left as it is")
+	}
+}
+
+func TestWriteCPIX(t *testing.T) {
+	audioKey := &ContentKey{
+		ContentID: "movie-1",
+		KeyID:     bytes.Repeat([]byte{0x33}, 16),
+		Key:       bytes.Repeat([]byte{0xcc}, 16),
+		TrackType: widevineproxy.ContentTrackTypeAudio,
+	}
+	provisioned := &Provisioned{
+		ContentID:        "movie-1",
+		ProtectionScheme: SchemeCENC,
+		Keys:             []*ContentKey{sdKey, audioKey},
+		PSSH:             []byte("pssh"),
+	}
+	var b bytes.Buffer
+	assert.NoError(t, provisioned.WriteCPIX(&b))
+
+	var doc struct {
+		ContentID   string `xml:"contentId,attr"`
+		ContentKeys []struct {
+			KID        string `xml:"kid,attr"`
+			Scheme     string `xml:"commonEncryptionScheme,attr"`
+			PlainValue string `xml:"Data>Secret>PlainValue"`
+		} `xml:"ContentKeyList>ContentKey"`
+		DRMSystems []struct {
+			SystemID string `xml:"systemId,attr"`
+			PSSH     string `xml:"PSSH"`
+		} `xml:"DRMSystemList>DRMSystem"`
+		UsageRules []struct {
+			KID         string    `xml:"kid,attr"`
+			TrackType   string    `xml:"intendedTrackType,attr"`
+			VideoFilter *struct{} `xml:"VideoFilter"`
+			AudioFilter *struct{} `xml:"AudioFilter"`
+		} `xml:"ContentKeyUsageRuleList>ContentKeyUsageRule"`
+	}
+	if !assert.NoError(t, xml.Unmarshal(b.Bytes(), &doc), b.String()) {
+		return
+	}
+	assert.Contains(t, b.String(), `xmlns:cpix="urn:dashif:org:cpix"`)
+	assert.Equal(t, "movie-1", doc.ContentID)
+	if assert.Len(t, doc.ContentKeys, 2) {
+		assert.Equal(t, "11111111-1111-1111-1111-111111111111", doc.ContentKeys[0].KID)
+		assert.Equal(t, "cenc", doc.ContentKeys[0].Scheme)
+		assert.Equal(t, base64.StdEncoding.EncodeToString(sdKey.Key), doc.ContentKeys[0].PlainValue)
+	}
+	if assert.Len(t, doc.DRMSystems, 2) {
+		assert.Equal(t, widevineSystemID, doc.DRMSystems[0].SystemID)
+		assert.Equal(t, "cHNzaA==", doc.DRMSystems[0].PSSH)
+	}
+	if assert.Len(t, doc.UsageRules, 2) {
+		assert.Equal(t, "SD", doc.UsageRules[0].TrackType)
+		assert.NotNil(t, doc.UsageRules[0].VideoFilter)
+		assert.Equal(t, "AUDIO", doc.UsageRules[1].TrackType)
+		assert.NotNil(t, doc.UsageRules[1].AudioFilter)
+	}
+
+	provisioned.Keys = []*ContentKey{{ContentID: "movie-1", KeyID: sdKey.KeyID, Key: sdKey.Key, Wrap: WrapAESKW}}
+	assert.Error(t, provisioned.WriteCPIX(&b), "wrapped keys are not exported")
+}
@@ -0,0 +1,168 @@
+package widevinekeystore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	widevineproxy "github.com/cooomma/widevine-proxy/proxy"
+)
+
+// Schema creates the table of a SQLStore, with the default table name.
+// Binary values are stored hex encoded, so that the schema is portable across databases.
+const Schema = `CREATE TABLE content_keys (
+	content_id VARCHAR(255) NOT NULL,
+	key_id     CHAR(32)     NOT NULL,
+	key_value  VARCHAR(255) NOT NULL,
+	iv         VARCHAR(32)  NOT NULL DEFAULT '',
+	track_type VARCHAR(16)  NOT NULL,
+	wrap       VARCHAR(16)  NOT NULL DEFAULT '',
+	kek_version INTEGER     NOT NULL DEFAULT 0,
+	PRIMARY KEY (content_id, key_id)
+)`
+
+// PlaceholderStyle is the bind variable syntax of the database driver.
+type PlaceholderStyle int
+
+const (
+	PlaceholderQuestion PlaceholderStyle = iota // ?, e.g. MySQL and SQLite.
+	PlaceholderDollar                           // $1, e.g. PostgreSQL.
+)
+
+// SQLStore is a KeyStore backed by a database table, see Schema.
+// The driver is up to the caller, e.g. a blank import of github.com/lib/pq.
+type SQLStore struct {
+	DB           *sql.DB
+	Table        string
+	Placeholders PlaceholderStyle
+}
+
+// NewSQLStore creates a SQLStore on the content_keys table of db.
+func NewSQLStore(db *sql.DB, placeholders PlaceholderStyle) *SQLStore {
+	return &SQLStore{DB: db, Table: "content_keys", Placeholders: placeholders}
+}
+
+const keyColumns = "content_id, key_id, key_value, iv, track_type, wrap, kek_version"
+
+func (s *SQLStore) Key(ctx context.Context, contentID string, keyID []byte) (*ContentKey, error) {
+	row := s.DB.QueryRowContext(ctx,
+		s.query("SELECT "+keyColumns+" FROM %s WHERE content_id = ? AND key_id = ?"),
+		contentID, hex.EncodeToString(keyID))
+	key, err := scanKey(row)
+	if err == sql.ErrNoRows {
+		return nil, notFound(contentID, keyID)
+	}
+	return key, err
+}
+
+// Keys returns the keys of contentID ordered by key id.
+func (s *SQLStore) Keys(ctx context.Context, contentID string) ([]*ContentKey, error) {
+	rows, err := s.DB.QueryContext(ctx,
+		s.query("SELECT "+keyColumns+" FROM %s WHERE content_id = ? ORDER BY key_id"),
+		contentID)
+	if err != nil {
+		return nil, err
+	}
+	keys, err := scanKeys(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, notFound(contentID, nil)
+	}
+	return keys, nil
+}
+
+// AllKeys returns every key of the table, ordered by content id and key id.
+func (s *SQLStore) AllKeys(ctx context.Context) ([]*ContentKey, error) {
+	rows, err := s.DB.QueryContext(ctx, s.query("SELECT "+keyColumns+" FROM %s ORDER BY content_id, key_id"))
+	if err != nil {
+		return nil, err
+	}
+	return scanKeys(rows)
+}
+
+// Put replaces the key in a transaction, as upserts are not portable.
+func (s *SQLStore) Put(ctx context.Context, key *ContentKey) error {
+	if err := key.Validate(); err != nil {
+		return err
+	}
+	tx, err := s.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	keyID := hex.EncodeToString(key.KeyID)
+	if _, err := tx.ExecContext(ctx, s.query("DELETE FROM %s WHERE content_id = ? AND key_id = ?"), key.ContentID, keyID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx,
+		s.query("INSERT INTO %s ("+keyColumns+") VALUES (?, ?, ?, ?, ?, ?, ?)"),
+		key.ContentID, keyID, hex.EncodeToString(key.Key), hex.EncodeToString(key.IV), string(key.TrackType),
+		key.Wrap, int64(key.KEKVersion)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// query formats the table name into q and rewrites its placeholders.
+func (s *SQLStore) query(q string) string {
+	q = fmt.Sprintf(q, s.Table)
+	if s.Placeholders != PlaceholderDollar {
+		return q
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range q {
+		if r == '?' {
+			n++
+			fmt.Fprintf(&b, "$%d", n)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func scanKeys(rows *sql.Rows) ([]*ContentKey, error) {
+	defer rows.Close()
+	var keys []*ContentKey
+	for rows.Next() {
+		key, err := scanKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanKey(row scanner) (*ContentKey, error) {
+	var key ContentKey
+	var keyID, value, iv, trackType string
+	var kekVersion int64
+	if err := row.Scan(&key.ContentID, &keyID, &value, &iv, &trackType, &key.Wrap, &kekVersion); err != nil {
+		return nil, err
+	}
+	key.KEKVersion = int(kekVersion)
+	for _, field := range []struct {
+		dst *HexBytes
+		src string
+	}{{&key.KeyID, keyID}, {&key.Key, value}, {&key.IV, iv}} {
+		if err := field.dst.UnmarshalText([]byte(field.src)); err != nil {
+			return nil, fmt.Errorf("content key %s/%s: %v", key.ContentID, keyID, err)
+		}
+	}
+	if len(key.IV) == 0 {
+		key.IV = nil
+	}
+	key.TrackType = widevineproxy.ContentTrackType(trackType)
+	return &key, nil
+}
@@ -0,0 +1,270 @@
+package widevinekeystore
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Key wrapping algorithms.
+const (
+	WrapAESKW  = "A256KW"  // AES key wrap, RFC 3394.
+	WrapAESGCM = "A256GCM" // AES-GCM with a random nonce, authenticating the content id and key id.
+)
+
+// ErrUnknownKEK is returned when a key is wrapped under a KEK version missing from the Keyring.
+var ErrUnknownKEK = errors.New("unknown key encryption key version")
+
+// Keyring holds the versions of the key encryption key (KEK). Keys are wrapped under the Primary version,
+// and unwrapped with the version they were wrapped under, so that the KEK can be rotated.
+type Keyring struct {
+	Primary   int
+	Algorithm string // WrapAESKW if empty.
+	keks      map[int][]byte
+}
+
+// NewKeyring creates a Keyring wrapping under the primary version of keks, 32 bytes each.
+func NewKeyring(primary int, keks map[int][]byte) (*Keyring, error) {
+	for version, kek := range keks {
+		if len(kek) != 32 {
+			return nil, fmt.Errorf("kek version %d must be 32 bytes", version)
+		}
+	}
+	if _, ok := keks[primary]; !ok {
+		return nil, fmt.Errorf("%w: primary %d", ErrUnknownKEK, primary)
+	}
+	return &Keyring{Primary: primary, Algorithm: WrapAESKW, keks: keks}, nil
+}
+
+type keyringFile struct {
+	Primary   int               `json:"primary"`
+	Algorithm string            `json:"algorithm"`
+	Keys      map[string]string `json:"keys"` // Version to hex encoded KEK.
+}
+
+// LoadKeyring reads a keyring file:
+//
+//	{"primary": 2, "algorithm": "A256KW", "keys": {"1": "<64 hex>", "2": "<64 hex>"}}
+func LoadKeyring(path string) (*Keyring, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f keyringFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("decode keyring %s: %v", path, err)
+	}
+	keks := map[int][]byte{}
+	for version, encoded := range f.Keys {
+		v, err := strconv.Atoi(version)
+		if err != nil {
+			return nil, fmt.Errorf("keyring %s: invalid version %q", path, version)
+		}
+		if keks[v], err = hex.DecodeString(encoded); err != nil {
+			return nil, fmt.Errorf("keyring %s: kek version %d: %v", path, v, err)
+		}
+	}
+	keyring, err := NewKeyring(f.Primary, keks)
+	if err != nil {
+		return nil, fmt.Errorf("keyring %s: %v", path, err)
+	}
+	if f.Algorithm != "" {
+		keyring.Algorithm = f.Algorithm
+	}
+	return keyring, keyring.validate()
+}
+
+// KeyringFromEnv reads the keyring from the environment variable name, formatted as
+// "<version>:<64 hex>[,<version>:<64 hex>...]", the first version being the primary one.
+func KeyringFromEnv(name string) (*Keyring, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return nil, fmt.Errorf("%s is not set", name)
+	}
+	keks := map[int][]byte{}
+	primary := 0
+	for i, entry := range strings.Split(value, ",") {
+		parts := strings.SplitN(strings.TrimSpace(entry), ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("%s: entry %d is not <version>:<hex>", name, i+1)
+		}
+		version, err := strconv.Atoi(parts[0])
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid version %q", name, parts[0])
+		}
+		if keks[version], err = hex.DecodeString(parts[1]); err != nil {
+			return nil, fmt.Errorf("%s: kek version %d: %v", name, version, err)
+		}
+		if i == 0 {
+			primary = version
+		}
+	}
+	keyring, err := NewKeyring(primary, keks)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+	return keyring, nil
+}
+
+func (r *Keyring) validate() error {
+	switch r.Algorithm {
+	case WrapAESKW, WrapAESGCM:
+		return nil
+	}
+	return fmt.Errorf("unsupported key wrapping algorithm %q", r.Algorithm)
+}
+
+// WrapKey returns a copy of key wrapped under the primary KEK. Wrapped keys are rewrapped.
+func (r *Keyring) WrapKey(key *ContentKey) (*ContentKey, error) {
+	plain, err := r.UnwrapKey(key)
+	if err != nil {
+		return nil, err
+	}
+	defer zero(plain.Key)
+
+	kek := r.keks[r.Primary]
+	wrapped := *plain
+	wrapped.Wrap = r.Algorithm
+	wrapped.KEKVersion = r.Primary
+	switch r.Algorithm {
+	case WrapAESKW:
+		wrapped.Key, err = aesKeyWrap(kek, plain.Key)
+	case WrapAESGCM:
+		wrapped.Key, err = gcmSeal(kek, plain.Key, additionalData(plain))
+	default:
+		err = r.validate()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &wrapped, nil
+}
+
+// UnwrapKey returns a copy of key holding the plaintext key. Plaintext keys are returned as they are.
+// Callers should zero the key once used.
+func (r *Keyring) UnwrapKey(key *ContentKey) (*ContentKey, error) {
+	plain := *key
+	if key.Wrap == "" {
+		plain.Key = append(HexBytes(nil), key.Key...)
+		return &plain, nil
+	}
+	kek, ok := r.keks[key.KEKVersion]
+	if !ok {
+		return nil, fmt.Errorf("%w: %d for key %x of %s", ErrUnknownKEK, key.KEKVersion, []byte(key.KeyID), key.ContentID)
+	}
+
+	var err error
+	switch key.Wrap {
+	case WrapAESKW:
+		plain.Key, err = aesKeyUnwrap(kek, key.Key)
+	case WrapAESGCM:
+		plain.Key, err = gcmOpen(kek, key.Key, additionalData(key))
+	default:
+		err = fmt.Errorf("unsupported key wrapping algorithm %q", key.Wrap)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unwrap key %x of %s: %v", []byte(key.KeyID), key.ContentID, err)
+	}
+	plain.Wrap = ""
+	plain.KEKVersion = 0
+	return &plain, nil
+}
+
+// additionalData binds a GCM wrapped key to its content and key id.
+func additionalData(key *ContentKey) []byte {
+	return append([]byte(key.ContentID+"\x00"), key.KeyID...)
+}
+
+func gcmSeal(kek, plaintext, additionalData []byte) ([]byte, error) {
+	aead, err := newGCM(kek)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+func gcmOpen(kek, sealed, additionalData []byte) ([]byte, error) {
+	aead, err := newGCM(kek)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize()+aead.Overhead() {
+		return nil, errors.New("sealed key too short")
+	}
+	return aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], additionalData)
+}
+
+func newGCM(kek []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func zero(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+// WrappingStore wraps the keys put into a KeyStore under the primary KEK of a Keyring.
+// The keys it returns are still wrapped: they are unwrapped by the Authority when building the license.
+type WrappingStore struct {
+	KeyStore
+	Keyring *Keyring
+}
+
+// NewWrappingStore wraps the keys put into store with keyring.
+func NewWrappingStore(store KeyStore, keyring *Keyring) *WrappingStore {
+	return &WrappingStore{KeyStore: store, Keyring: keyring}
+}
+
+// Put wraps key and puts it into the underlying store.
+func (s *WrappingStore) Put(ctx context.Context, key *ContentKey) error {
+	if err := key.Validate(); err != nil {
+		return err
+	}
+	wrapped, err := s.Keyring.WrapKey(key)
+	if err != nil {
+		return err
+	}
+	return s.KeyStore.Put(ctx, wrapped)
+}
+
+// Rewrap wraps every key of store under the primary KEK of keyring, including the keys in plaintext,
+// and returns the number of keys rewritten. Keys already wrapped under the primary KEK are left as they are.
+func Rewrap(ctx context.Context, store Lister, keyring *Keyring) (int, error) {
+	keys, err := store.AllKeys(ctx)
+	if err != nil {
+		return 0, err
+	}
+	n := 0
+	for _, key := range keys {
+		if key.Wrap == keyring.Algorithm && key.KEKVersion == keyring.Primary {
+			continue
+		}
+		wrapped, err := keyring.WrapKey(key)
+		if err != nil {
+			return n, err
+		}
+		if err := store.Put(ctx, wrapped); err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
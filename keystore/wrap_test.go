@@ -0,0 +1,148 @@
+package widevinekeystore
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cooomma/widevine-proxy/internal/sqltest"
+	widevineproxy "github.com/cooomma/widevine-proxy/proxy"
+	"github.com/stretchr/testify/assert"
+)
+
+func mustHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+var (
+	kek1 = bytes.Repeat([]byte{0x01}, 32)
+	kek2 = bytes.Repeat([]byte{0x02}, 32)
+)
+
+func TestAESKeyWrap(t *testing.T) {
+	// RFC 3394 section 4.6, 128 bits of key data with a 256-bit KEK.
+	kek := mustHex("000102030405060708090A0B0C0D0E0F101112131415161718191A1B1C1D1E1F")
+	plaintext := mustHex("00112233445566778899AABBCCDDEEFF")
+	expected := mustHex("64E8C3F9CE0F5BA263E9777905818A2A93C8191E7D6E8AE7")
+
+	wrapped, err := aesKeyWrap(kek, plaintext)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, wrapped)
+
+	unwrapped, err := aesKeyUnwrap(kek, wrapped)
+	assert.NoError(t, err)
+	assert.Equal(t, plaintext, unwrapped)
+
+	wrapped[3] ^= 0xff
+	_, err = aesKeyUnwrap(kek, wrapped)
+	assert.Error(t, err)
+}
+
+func TestKeyringWrapKey(t *testing.T) {
+	for _, algorithm := range []string{WrapAESKW, WrapAESGCM} {
+		keyring, err := NewKeyring(1, map[int][]byte{1: kek1})
+		assert.NoError(t, err)
+		keyring.Algorithm = algorithm
+
+		wrapped, err := keyring.WrapKey(hdKey)
+		if !assert.NoError(t, err, algorithm) {
+			continue
+		}
+		assert.Equal(t, algorithm, wrapped.Wrap)
+		assert.Equal(t, 1, wrapped.KEKVersion)
+		assert.NotEqual(t, hdKey.Key, wrapped.Key)
+		assert.NoError(t, wrapped.Validate())
+
+		plain, err := keyring.UnwrapKey(wrapped)
+		assert.NoError(t, err)
+		assert.Equal(t, hdKey, plain, algorithm)
+
+		other, _ := NewKeyring(2, map[int][]byte{2: kek2})
+		_, err = other.UnwrapKey(wrapped)
+		assert.True(t, errors.Is(err, ErrUnknownKEK))
+	}
+
+	keyring, _ := NewKeyring(1, map[int][]byte{1: kek1})
+	keyring.Algorithm = WrapAESGCM
+	wrapped, _ := keyring.WrapKey(hdKey)
+	wrapped.ContentID = "movie-2"
+	_, err := keyring.UnwrapKey(wrapped)
+	assert.Error(t, err, "a GCM wrapped key is bound to its content id")
+}
+
+func TestLoadKeyring(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "kek.json")
+	ioutil.WriteFile(path, []byte(`{"primary": 2, "algorithm": "A256GCM", "keys": {"1": "`+hex.EncodeToString(kek1)+`", "2": "`+hex.EncodeToString(kek2)+`"}}`), 0600)
+	keyring, err := LoadKeyring(path)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 2, keyring.Primary)
+		assert.Equal(t, WrapAESGCM, keyring.Algorithm)
+	}
+
+	os.Setenv("WIDEVINE_TEST_KEK", "2:"+hex.EncodeToString(kek2)+", 1:"+hex.EncodeToString(kek1))
+	defer os.Unsetenv("WIDEVINE_TEST_KEK")
+	keyring, err = KeyringFromEnv("WIDEVINE_TEST_KEK")
+	if assert.NoError(t, err) {
+		assert.Equal(t, 2, keyring.Primary)
+		assert.Equal(t, WrapAESKW, keyring.Algorithm)
+	}
+
+	os.Setenv("WIDEVINE_TEST_KEK", "1:abcd")
+	_, err = KeyringFromEnv("WIDEVINE_TEST_KEK")
+	assert.Error(t, err)
+}
+
+func TestRewrap(t *testing.T) {
+	ctx := context.Background()
+	db, err := sql.Open(sqltest.Driver, t.Name())
+	assert.NoError(t, err)
+	defer db.Close()
+
+	old, _ := NewKeyring(1, map[int][]byte{1: kek1})
+	rotated, _ := NewKeyring(2, map[int][]byte{1: kek1, 2: kek2})
+	for name, store := range map[string]Lister{"memory": NewMemoryStore(), "sql": NewSQLStore(db, PlaceholderQuestion)} {
+		assert.NoError(t, NewWrappingStore(store, old).Put(ctx, hdKey))
+		assert.NoError(t, store.Put(ctx, sdKey))
+
+		n, err := Rewrap(ctx, store, rotated)
+		assert.NoError(t, err, name)
+		assert.Equal(t, 2, n, name)
+		n, _ = Rewrap(ctx, store, rotated)
+		assert.Equal(t, 0, n, name)
+
+		keys, _ := store.AllKeys(ctx)
+		for _, key := range keys {
+			assert.Equal(t, 2, key.KEKVersion, name)
+			plain, err := rotated.UnwrapKey(key)
+			assert.NoError(t, err, name)
+			assert.Contains(t, [][]byte{sdKey.Key, hdKey.Key}, []byte(plain.Key), name)
+		}
+	}
+}
+
+func TestAuthorityUnwrapsKeys(t *testing.T) {
+	keyring, _ := NewKeyring(1, map[int][]byte{1: kek1})
+	store := NewWrappingStore(NewMemoryStore(), keyring)
+	assert.NoError(t, store.Put(context.Background(), sdKey))
+
+	a := &Authority{Store: store}
+	psshData := &widevineproxy.PsshData{ContentID: "bW92aWUtMQ=="}
+	_, err := a.BuildLicenseMessage(nil, psshData)
+	assert.True(t, errors.Is(err, ErrUnknownKEK))
+
+	a.Keyring = keyring
+	message, err := a.BuildLicenseMessage(nil, psshData)
+	if assert.NoError(t, err) && assert.Len(t, message.ContentKeySpecs, 1) {
+		assert.Equal(t, "qqqqqqqqqqqqqqqqqqqqqg==", message.ContentKeySpecs[0].Key)
+	}
+}
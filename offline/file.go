@@ -0,0 +1,94 @@
+package widevineoffline
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FileStore is a Store persisted as a JSON file, for a single instance of the proxy:
+//
+//	{"licenses": [{"id": "<base64>", "user_id": "user-1", "content_id": "<base64>", "status": "active", ...}]}
+//
+// The file is read once by LoadFileStore, and rewritten on each change.
+type FileStore struct {
+	*MemoryStore
+	path string
+}
+
+type licenseFile struct {
+	Licenses []*License `json:"licenses"`
+}
+
+// LoadFileStore reads the licenses of the file at path. A missing file is an empty store.
+func LoadFileStore(path string) (*FileStore, error) {
+	s := &FileStore{MemoryStore: NewMemoryStore(), path: path}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var f licenseFile
+	if err := json.Unmarshal(b, &f); err != nil {
+		return nil, fmt.Errorf("decode offline license store %s: %v", path, err)
+	}
+	for _, l := range f.Licenses {
+		if l.ID == "" || l.UserID == "" {
+			return nil, fmt.Errorf("invalid offline license store %s: license without id or user id", path)
+		}
+		s.put(l)
+	}
+	return s, nil
+}
+
+// Add records the license and rewrites the file.
+func (s *FileStore) Add(ctx context.Context, l *License, limits Limits) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.add(l, limits); err != nil {
+		return err
+	}
+	return s.save()
+}
+
+// End ends the license and rewrites the file.
+func (s *FileStore) End(ctx context.Context, id, status string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	changed, err := s.end(id, status, at)
+	if err != nil || !changed {
+		return err
+	}
+	return s.save()
+}
+
+// save writes the licenses to a temporary file renamed over the store, so that readers never see a partial file.
+func (s *FileStore) save() error {
+	b, err := json.MarshalIndent(licenseFile{Licenses: s.all()}, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
@@ -0,0 +1,185 @@
+package widevineoffline
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	widevineproxy "github.com/cooomma/widevine-proxy/proxy"
+)
+
+// DefaultTierAttribute is the claims attribute naming the tier of a user.
+const DefaultTierAttribute = "tier"
+
+// Ledger is a widevineproxy.LicenseTracker recording the offline licenses of the authenticated users
+// within their Limits. The licenses of anonymous users and the streaming licenses are not recorded.
+type Ledger struct {
+	Store Store
+	// Limits applies to the users whose tier has no limits of its own.
+	Limits Limits
+	// Tiers holds the limits of the subscription tiers, read from the claims attribute TierAttribute.
+	Tiers         map[string]Limits
+	TierAttribute string // DefaultTierAttribute if empty.
+	// TitleDownloads overrides MaxTitleDownloads for some titles, keyed by content id (raw or base64).
+	TitleDownloads map[string]int
+
+	now func() time.Time // time.Now if nil.
+}
+
+// NewLedger creates a Ledger on store.
+func NewLedger(store Store, limits Limits) *Ledger {
+	return &Ledger{Store: store, Limits: limits}
+}
+
+// CheckLicense refuses an offline license with a LimitError when it would exceed the limits of the user.
+func (l *Ledger) CheckLicense(ctx context.Context, req *widevineproxy.AuthorizationRequest) error {
+	if !tracked(req.Claims) || req.LicenseType != widevineproxy.LicenseTypeOffline {
+		return nil
+	}
+	return l.Store.Check(ctx, l.license(req, "", nil), l.LimitsOf(req.Claims, req.PsshData.ContentID))
+}
+
+// LicenseIssued records an offline license, unless a concurrent license took the last slot.
+func (l *Ledger) LicenseIssued(ctx context.Context, license *widevineproxy.IssuedLicense) error {
+	req, licenseID := license.Request, license.Response.SessionState.LicenseID
+	if !tracked(req.Claims) || (req.LicenseType != widevineproxy.LicenseTypeOffline &&
+		licenseID.Type != widevineproxy.LicenseTypeOffline) {
+		return nil
+	}
+	if licenseID.SessionID == "" {
+		return fmt.Errorf("offline license of content %s has no session id", req.PsshData.ContentID)
+	}
+	return l.Store.Add(ctx, l.license(req, licenseID.SessionID, license.Message), l.LimitsOf(req.Claims, req.PsshData.ContentID))
+}
+
+// LicenseRenewed refuses with a RenewalDeniedError the renewal of a released or revoked offline license.
+func (l *Ledger) LicenseRenewed(ctx context.Context, renewal *widevineproxy.Renewal) error {
+	if renewal.LicenseID.Type != widevineproxy.LicenseTypeOffline {
+		return nil
+	}
+	license, err := l.Store.Get(ctx, renewal.LicenseID.SessionID)
+	if errors.Is(err, ErrLicenseNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if license.Status != StatusActive {
+		return &widevineproxy.RenewalDeniedError{LicenseID: renewal.LicenseID, Reason: "license " + license.Status}
+	}
+	return nil
+}
+
+// LicenseReleased marks an offline license of the user of the claims of ctx released, freeing its slot.
+// The release of an anonymous request, or of the license of another user, is ignored.
+func (l *Ledger) LicenseReleased(ctx context.Context, release *widevineproxy.Release) error {
+	claims := widevineproxy.ClaimsFromContext(ctx)
+	if !tracked(claims) || release.LicenseID.Type != widevineproxy.LicenseTypeOffline {
+		return nil
+	}
+	license, err := l.Store.Get(ctx, release.LicenseID.SessionID)
+	if errors.Is(err, ErrLicenseNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if license.UserID != claims.UserID {
+		return nil
+	}
+	err = l.Store.End(ctx, license.ID, StatusReleased, l.clock())
+	if errors.Is(err, ErrLicenseNotFound) {
+		return nil
+	}
+	return err
+}
+
+// Licenses returns the offline licenses holding a slot of userID, the revoked ones included, by issue time.
+func (l *Ledger) Licenses(ctx context.Context, userID string) ([]*License, error) {
+	all, err := l.Store.Licenses(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	now := l.clock()
+	var licenses []*License
+	for _, license := range all {
+		if license.Active(now) {
+			licenses = append(licenses, license)
+		}
+	}
+	return licenses, nil
+}
+
+// Revoke revokes the offline license id of userID, refusing its renewals. The license persisted by
+// the device plays until it expires: it holds its slot until then, or until the device releases it.
+// It returns ErrLicenseNotFound if the license is unknown or issued to another user.
+func (l *Ledger) Revoke(ctx context.Context, userID, id string) error {
+	license, err := l.Store.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if license.UserID != userID {
+		return ErrLicenseNotFound
+	}
+	return l.Store.End(ctx, id, StatusRevoked, l.clock())
+}
+
+// LimitsOf returns the limits of the user identified by claims for contentID.
+func (l *Ledger) LimitsOf(claims *widevineproxy.Claims, contentID string) Limits {
+	limits := l.Limits
+	name := l.TierAttribute
+	if name == "" {
+		name = DefaultTierAttribute
+	}
+	if v, ok := claims.Attributes[name]; ok && v != nil {
+		if tier, ok := l.Tiers[fmt.Sprint(v)]; ok {
+			limits = tier
+		}
+	}
+	if max, ok := l.TitleDownloads[contentID]; ok {
+		limits.MaxTitleDownloads = max
+	} else if raw, err := base64.StdEncoding.DecodeString(contentID); err == nil {
+		if max, ok := l.TitleDownloads[string(raw)]; ok {
+			limits.MaxTitleDownloads = max
+		}
+	}
+	return limits
+}
+
+// license returns the license of req, expiring with the rental or the license duration of message.
+func (l *Ledger) license(req *widevineproxy.AuthorizationRequest, id string, message *widevineproxy.Message) *License {
+	now := l.clock()
+	license := &License{
+		ID:        id,
+		UserID:    req.Claims.UserID,
+		ContentID: req.PsshData.ContentID,
+		DeviceID:  req.Device.DRMCertSerialNumber,
+		Issued:    now,
+		Status:    StatusActive,
+	}
+	if message != nil && message.PolicyOverrides != nil {
+		var seconds uint64
+		for _, d := range []uint64{message.PolicyOverrides.RentalDurationSeconds, message.PolicyOverrides.LicenseDurationSeconds} {
+			if d > 0 && (seconds == 0 || d < seconds) {
+				seconds = d
+			}
+		}
+		if seconds > 0 {
+			license.ExpiresAt = now.Add(time.Duration(seconds) * time.Second)
+		}
+	}
+	return license
+}
+
+func (l *Ledger) clock() time.Time {
+	if l.now != nil {
+		return l.now()
+	}
+	return time.Now()
+}
+
+func tracked(claims *widevineproxy.Claims) bool {
+	return claims != nil && claims.UserID != ""
+}
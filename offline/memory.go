@@ -0,0 +1,128 @@
+package widevineoffline
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore is a Store in memory, for a single instance of the proxy: its licenses are lost on restart.
+type MemoryStore struct {
+	mu       sync.Mutex
+	licenses map[string]*License            // Keyed by license id.
+	users    map[string]map[string]*License // Keyed by user id and license id.
+}
+
+// NewMemoryStore creates a MemoryStore holding licenses.
+func NewMemoryStore(licenses ...*License) *MemoryStore {
+	s := &MemoryStore{licenses: map[string]*License{}, users: map[string]map[string]*License{}}
+	for _, l := range licenses {
+		s.put(l)
+	}
+	return s
+}
+
+func (s *MemoryStore) Check(ctx context.Context, l *License, limits Limits) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return admit(l, limits, s.of(l.UserID))
+}
+
+func (s *MemoryStore) Add(ctx context.Context, l *License, limits Limits) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.add(l, limits)
+}
+
+func (s *MemoryStore) Get(ctx context.Context, id string) (*License, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l, ok := s.licenses[id]
+	if !ok {
+		return nil, ErrLicenseNotFound
+	}
+	copied := *l
+	return &copied, nil
+}
+
+func (s *MemoryStore) Licenses(ctx context.Context, userID string) ([]*License, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	held := s.of(userID)
+	licenses := make([]*License, len(held))
+	for i, l := range held {
+		copied := *l
+		licenses[i] = &copied
+	}
+	return licenses, nil
+}
+
+func (s *MemoryStore) End(ctx context.Context, id, status string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.end(id, status, at)
+	return err
+}
+
+func (s *MemoryStore) add(l *License, limits Limits) error {
+	if err := admit(l, limits, s.of(l.UserID)); err != nil {
+		return err
+	}
+	s.put(l)
+	return nil
+}
+
+// end reports whether the license id changed.
+func (s *MemoryStore) end(id, status string, at time.Time) (bool, error) {
+	l, ok := s.licenses[id]
+	if !ok {
+		return false, ErrLicenseNotFound
+	}
+	if l.Status != StatusActive && (l.Status != StatusRevoked || status != StatusReleased) {
+		return false, nil
+	}
+	l.Status, l.Ended = status, at
+	return true, nil
+}
+
+func (s *MemoryStore) put(l *License) {
+	copied := *l
+	if previous, ok := s.licenses[l.ID]; ok {
+		delete(s.users[previous.UserID], l.ID)
+	}
+	s.licenses[l.ID] = &copied
+	if s.users[l.UserID] == nil {
+		s.users[l.UserID] = map[string]*License{}
+	}
+	s.users[l.UserID][l.ID] = &copied
+}
+
+// of returns the licenses of userID by issue time.
+func (s *MemoryStore) of(userID string) []*License {
+	licenses := make([]*License, 0, len(s.users[userID]))
+	for _, l := range s.users[userID] {
+		licenses = append(licenses, l)
+	}
+	sortLicenses(licenses)
+	return licenses
+}
+
+// all returns the licenses by issue time.
+func (s *MemoryStore) all() []*License {
+	licenses := make([]*License, 0, len(s.licenses))
+	for _, l := range s.licenses {
+		licenses = append(licenses, l)
+	}
+	sortLicenses(licenses)
+	return licenses
+}
+
+func sortLicenses(licenses []*License) {
+	sort.Slice(licenses, func(i, j int) bool {
+		if !licenses[i].Issued.Equal(licenses[j].Issued) {
+			return licenses[i].Issued.Before(licenses[j].Issued)
+		}
+		return licenses[i].ID < licenses[j].ID
+	})
+}
@@ -0,0 +1,142 @@
+// Package widevineoffline keeps a ledger of the offline licenses, persisted by the devices to play the
+// downloaded content. A Ledger records a license per offline license issued to an authenticated user,
+// identified by the session id of the license (SessionState.LicenseID.SessionID), and caps the licenses
+// a user holds and downloads per title. The release of a license, when the download is deleted, frees
+// its slot; a revoked license can no longer be renewed, but holds its slot until released or expired.
+package widevineoffline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	widevineproxy "github.com/cooomma/widevine-proxy/proxy"
+)
+
+var (
+	// ErrLimitExceeded matches the LimitError with errors.Is.
+	ErrLimitExceeded = errors.New("limit exceeded")
+	// ErrLicenseNotFound is returned by a Store for an unknown license.
+	ErrLicenseNotFound = errors.New("offline license not found")
+)
+
+// Statuses of a License.
+const (
+	StatusActive   = "active"
+	StatusReleased = "released" // Released by the device, e.g. when the download was deleted.
+	StatusRevoked  = "revoked"  // Revoked by the service, still played by the device until released or expired.
+)
+
+// Limits of a LimitError.
+const (
+	LimitLicenses       = "licenses"
+	LimitTitleLicenses  = "title_licenses"
+	LimitTitleDownloads = "title_downloads"
+)
+
+// Limits bounds the offline licenses of a user. Zero values are unlimited.
+type Limits struct {
+	MaxLicenses       int `json:"max_licenses"`        // Active offline licenses of the user.
+	MaxTitleLicenses  int `json:"max_title_licenses"`  // Active offline licenses of the user for a title, e.g. on several devices.
+	MaxTitleDownloads int `json:"max_title_downloads"` // Offline licenses ever issued to the user for a title, released or not.
+}
+
+// License is an offline license issued to a user.
+type License struct {
+	ID        string `json:"id"` // Session id of the license, base64 encoded.
+	UserID    string `json:"user_id"`
+	ContentID string `json:"content_id"`
+	DeviceID  string `json:"device_id"` // DRM certificate serial number of the device, empty if unknown.
+
+	Issued    time.Time `json:"issued"`
+	ExpiresAt time.Time `json:"expires_at"` // End of the rental or of the license, zero if it does not expire.
+	Status    string    `json:"status"`
+	Ended     time.Time `json:"ended"` // Time of the release or of the revocation, zero while active.
+}
+
+// Active reports whether the license holds a slot of its user at now. A revoked license holds it
+// until released or expired, as the device keeps playing it.
+func (l *License) Active(now time.Time) bool {
+	return (l.Status == StatusActive || l.Status == StatusRevoked) && (l.ExpiresAt.IsZero() || now.Before(l.ExpiresAt))
+}
+
+// Store holds the offline licenses. The released, revoked and expired licenses are kept, as they count
+// against the downloads of their title.
+type Store interface {
+	// Check returns a LimitError if adding l, issued at l.Issued, would exceed limits.
+	Check(ctx context.Context, l *License, limits Limits) error
+	// Add records l, unless it exceeds limits: it then returns a LimitError.
+	// The check and the record are atomic for a user. A license added again, with the same id, is replaced.
+	Add(ctx context.Context, l *License, limits Limits) error
+	// Get returns the license id, or ErrLicenseNotFound.
+	Get(ctx context.Context, id string) (*License, error)
+	// Licenses returns the licenses of userID whatever their status, by issue time.
+	Licenses(ctx context.Context, userID string) ([]*License, error)
+	// End sets the status of the license id, if active, to StatusReleased or StatusRevoked at the time at.
+	// A revoked license may still be released. It returns ErrLicenseNotFound if the license is unknown.
+	End(ctx context.Context, id, status string, at time.Time) error
+}
+
+// LimitError is returned for a license exceeding the limits of its user. It unwraps to an AuthorizationDeniedError.
+type LimitError struct {
+	UserID    string
+	ContentID string
+	Limit     string // LimitLicenses, LimitTitleLicenses or LimitTitleDownloads.
+	Max       int
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%v: %s", ErrLimitExceeded, e.reason())
+}
+
+func (e *LimitError) reason() string {
+	switch e.Limit {
+	case LimitTitleLicenses:
+		return fmt.Sprintf("user %s reached the limit of %d offline licenses of content %s", e.UserID, e.Max, e.ContentID)
+	case LimitTitleDownloads:
+		return fmt.Sprintf("user %s reached the limit of %d downloads of content %s", e.UserID, e.Max, e.ContentID)
+	}
+	return fmt.Sprintf("user %s reached the limit of %d offline licenses", e.UserID, e.Max)
+}
+
+// Is reports whether target is ErrLimitExceeded.
+func (e *LimitError) Is(target error) bool {
+	return target == ErrLimitExceeded
+}
+
+// Unwrap returns the AuthorizationDeniedError of the denial.
+func (e *LimitError) Unwrap() error {
+	return &widevineproxy.AuthorizationDeniedError{Reason: e.reason()}
+}
+
+// admit checks l against the licenses of its user, for the implementations of Store.
+// A license added again, with the same id, does not count against itself.
+func admit(l *License, limits Limits, licenses []*License) error {
+	active, title, downloads := 0, 0, 0
+	for _, other := range licenses {
+		if l.ID != "" && other.ID == l.ID {
+			continue
+		}
+		if other.ContentID == l.ContentID {
+			downloads++
+		}
+		if !other.Active(l.Issued) {
+			continue
+		}
+		active++
+		if other.ContentID == l.ContentID {
+			title++
+		}
+	}
+	if limits.MaxLicenses > 0 && active >= limits.MaxLicenses {
+		return &LimitError{UserID: l.UserID, ContentID: l.ContentID, Limit: LimitLicenses, Max: limits.MaxLicenses}
+	}
+	if limits.MaxTitleLicenses > 0 && title >= limits.MaxTitleLicenses {
+		return &LimitError{UserID: l.UserID, ContentID: l.ContentID, Limit: LimitTitleLicenses, Max: limits.MaxTitleLicenses}
+	}
+	if limits.MaxTitleDownloads > 0 && downloads >= limits.MaxTitleDownloads {
+		return &LimitError{UserID: l.UserID, ContentID: l.ContentID, Limit: LimitTitleDownloads, Max: limits.MaxTitleDownloads}
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+package widevineoffline
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	widevineproxy "github.com/cooomma/widevine-proxy/proxy"
+	"github.com/stretchr/testify/assert"
+)
+
+var testStart = time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+
+func licenseIDs(licenses []*License) []string {
+	var ids []string
+	for _, l := range licenses {
+		ids = append(ids, l.ID)
+	}
+	return ids
+}
+
+func limitOf(err error) string {
+	var limitErr *LimitError
+	if errors.As(err, &limitErr) {
+		return limitErr.Limit
+	}
+	return ""
+}
+
+func testRequest(userID, tier, licenseType string) *widevineproxy.AuthorizationRequest {
+	req := &widevineproxy.AuthorizationRequest{
+		PsshData:    widevineproxy.PsshData{ContentID: "bW92aWUtMQ=="},
+		Device:      widevineproxy.DeviceInfo{DRMCertSerialNumber: "ZGV2aWNlLWE="},
+		LicenseType: licenseType,
+	}
+	if userID != "" {
+		req.Claims = &widevineproxy.Claims{UserID: userID, Attributes: map[string]interface{}{"tier": tier}}
+	}
+	return req
+}
+
+func issued(req *widevineproxy.AuthorizationRequest, sessionID string, policy *widevineproxy.PolicyOverrides) *widevineproxy.IssuedLicense {
+	response := &widevineproxy.LicenseResponse{}
+	response.SessionState.LicenseID = widevineproxy.LicenseID{SessionID: sessionID, Type: req.LicenseType}
+	return &widevineproxy.IssuedLicense{Request: req, Message: &widevineproxy.Message{PolicyOverrides: policy}, Response: response}
+}
+
+func TestLedger(t *testing.T) {
+	now := testStart
+	ledger := NewLedger(NewMemoryStore(), Limits{MaxLicenses: 1})
+	ledger.Tiers = map[string]Limits{"premium": {MaxLicenses: 2}}
+	ledger.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	req := testRequest("user-1", "basic", "OFFLINE")
+	rental := &widevineproxy.PolicyOverrides{CanPersist: true, LicenseDurationSeconds: 7200, RentalDurationSeconds: 3600}
+	assert.NoError(t, ledger.CheckLicense(ctx, req))
+	assert.NoError(t, ledger.LicenseIssued(ctx, issued(req, "b2ZmbGluZS0x", rental)))
+	assert.True(t, errors.Is(ledger.CheckLicense(ctx, req), ErrLimitExceeded))
+	assert.True(t, errors.Is(ledger.LicenseIssued(ctx, issued(req, "b2ZmbGluZS0y", nil)), ErrLimitExceeded))
+	assert.Error(t, ledger.LicenseIssued(ctx, issued(testRequest("user-2", "", "OFFLINE"), "", nil)))
+
+	// Neither the premium tier, the streaming licenses nor the anonymous users are bound by the basic limits.
+	premium := testRequest("user-3", "premium", "OFFLINE")
+	assert.NoError(t, ledger.LicenseIssued(ctx, issued(premium, "b2ZmbGluZS0z", nil)))
+	assert.NoError(t, ledger.CheckLicense(ctx, premium))
+	streaming := testRequest("user-1", "basic", "STREAMING")
+	assert.NoError(t, ledger.CheckLicense(ctx, streaming))
+	assert.NoError(t, ledger.LicenseIssued(ctx, issued(streaming, "c2Vzc2lvbi0x", nil)))
+	assert.NoError(t, ledger.CheckLicense(ctx, testRequest("", "", "OFFLINE")))
+
+	licenses, err := ledger.Licenses(ctx, "user-1")
+	assert.NoError(t, err)
+	if assert.Equal(t, []string{"b2ZmbGluZS0x"}, licenseIDs(licenses)) {
+		assert.Equal(t, "ZGV2aWNlLWE=", licenses[0].DeviceID)
+		assert.True(t, licenses[0].ExpiresAt.Equal(testStart.Add(time.Hour)))
+	}
+
+	// The rental ends.
+	now = now.Add(time.Hour)
+	assert.NoError(t, ledger.CheckLicense(ctx, req))
+	licenses, err = ledger.Licenses(ctx, "user-1")
+	assert.NoError(t, err)
+	assert.Empty(t, licenses)
+
+	// A release frees the slot at once, and the released license is no longer renewed.
+	assert.NoError(t, ledger.LicenseIssued(ctx, issued(req, "b2ZmbGluZS00", nil)))
+	assert.Error(t, ledger.CheckLicense(ctx, req))
+	licenseID := widevineproxy.LicenseID{SessionID: "b2ZmbGluZS00", Type: "OFFLINE"}
+	assert.NoError(t, ledger.LicenseRenewed(ctx, &widevineproxy.Renewal{LicenseID: licenseID}))
+	// Neither an anonymous release nor the release by another user frees the slot.
+	assert.NoError(t, ledger.LicenseReleased(ctx, &widevineproxy.Release{LicenseID: licenseID}))
+	otherCtx := widevineproxy.WithClaims(ctx, testRequest("user-2", "", "OFFLINE").Claims)
+	assert.NoError(t, ledger.LicenseReleased(otherCtx, &widevineproxy.Release{LicenseID: licenseID}))
+	assert.Error(t, ledger.CheckLicense(ctx, req))
+	userCtx := widevineproxy.WithClaims(ctx, req.Claims)
+	assert.NoError(t, ledger.LicenseReleased(userCtx, &widevineproxy.Release{LicenseID: licenseID}))
+	assert.NoError(t, ledger.CheckLicense(ctx, req))
+	var denied *widevineproxy.RenewalDeniedError
+	if assert.True(t, errors.As(ledger.LicenseRenewed(ctx, &widevineproxy.Renewal{LicenseID: licenseID}), &denied)) {
+		assert.Equal(t, "license released", denied.Reason)
+	}
+	unknown := widevineproxy.LicenseID{SessionID: "dW5rbm93bg==", Type: "OFFLINE"}
+	assert.NoError(t, ledger.LicenseReleased(userCtx, &widevineproxy.Release{LicenseID: unknown}))
+	assert.NoError(t, ledger.LicenseRenewed(ctx, &widevineproxy.Renewal{LicenseID: unknown}))
+}
+
+func TestLedgerRevoke(t *testing.T) {
+	ledger := NewLedger(NewMemoryStore(), Limits{MaxLicenses: 1})
+	ledger.TitleDownloads = map[string]int{"movie-1": 2}
+	ctx := context.Background()
+
+	req := testRequest("user-1", "", "OFFLINE")
+	userCtx := widevineproxy.WithClaims(ctx, req.Claims)
+	assert.Equal(t, 2, ledger.LimitsOf(req.Claims, req.PsshData.ContentID).MaxTitleDownloads)
+	assert.NoError(t, ledger.LicenseIssued(ctx, issued(req, "b2ZmbGluZS0x", nil)))
+
+	assert.Equal(t, ErrLicenseNotFound, ledger.Revoke(ctx, "user-2", "b2ZmbGluZS0x"))
+	assert.Equal(t, ErrLicenseNotFound, ledger.Revoke(ctx, "user-1", "dW5rbm93bg=="))
+	assert.NoError(t, ledger.Revoke(ctx, "user-1", "b2ZmbGluZS0x"))
+	var denied *widevineproxy.RenewalDeniedError
+	licenseID := widevineproxy.LicenseID{SessionID: "b2ZmbGluZS0x", Type: "OFFLINE"}
+	if assert.True(t, errors.As(ledger.LicenseRenewed(ctx, &widevineproxy.Renewal{LicenseID: licenseID}), &denied)) {
+		assert.Equal(t, "license revoked", denied.Reason)
+	}
+
+	// The device plays the revoked license on: it holds its slot until released, so that revoking
+	// and downloading again does not exceed the limits.
+	licenses, err := ledger.Licenses(ctx, "user-1")
+	assert.NoError(t, err)
+	if assert.Len(t, licenses, 1) {
+		assert.Equal(t, StatusRevoked, licenses[0].Status)
+	}
+	assert.Equal(t, LimitLicenses, limitOf(ledger.CheckLicense(ctx, req)))
+	assert.Equal(t, LimitLicenses, limitOf(ledger.LicenseIssued(ctx, issued(req, "b2ZmbGluZS0y", nil))))
+	assert.NoError(t, ledger.LicenseReleased(userCtx, &widevineproxy.Release{LicenseID: licenseID}))
+	licenses, err = ledger.Licenses(ctx, "user-1")
+	assert.NoError(t, err)
+	assert.Empty(t, licenses)
+
+	// The revoked license still counts against the downloads of its title.
+	assert.NoError(t, ledger.LicenseIssued(ctx, issued(req, "b2ZmbGluZS0y", nil)))
+	assert.NoError(t, ledger.Revoke(ctx, "user-1", "b2ZmbGluZS0y"))
+	released := widevineproxy.LicenseID{SessionID: "b2ZmbGluZS0y", Type: "OFFLINE"}
+	assert.NoError(t, ledger.LicenseReleased(userCtx, &widevineproxy.Release{LicenseID: released}))
+	assert.Equal(t, LimitTitleDownloads, limitOf(ledger.CheckLicense(ctx, req)))
+}
+
+func TestLedgerTitleLimits(t *testing.T) {
+	ledger := NewLedger(NewMemoryStore(), Limits{MaxTitleLicenses: 1, MaxTitleDownloads: 2})
+	ctx := context.Background()
+	req := testRequest("user-1", "", "OFFLINE")
+	userCtx := widevineproxy.WithClaims(ctx, req.Claims)
+	release := func(id string) {
+		assert.NoError(t, ledger.LicenseReleased(userCtx, &widevineproxy.Release{LicenseID: widevineproxy.LicenseID{SessionID: id, Type: "OFFLINE"}}))
+	}
+
+	// A title is held on a single device at a time, the other titles being free.
+	assert.NoError(t, ledger.LicenseIssued(ctx, issued(req, "b2ZmbGluZS0x", nil)))
+	assert.Equal(t, LimitTitleLicenses, limitOf(ledger.CheckLicense(ctx, req)))
+	other := testRequest("user-1", "", "OFFLINE")
+	other.PsshData.ContentID = "bW92aWUtMg=="
+	assert.NoError(t, ledger.LicenseIssued(ctx, issued(other, "b2ZmbGluZS0y", nil)))
+
+	// The released licenses still count against the downloads of their title.
+	release("b2ZmbGluZS0x")
+	assert.NoError(t, ledger.LicenseIssued(ctx, issued(req, "b2ZmbGluZS0z", nil)))
+	release("b2ZmbGluZS0z")
+	err := ledger.CheckLicense(ctx, req)
+	assert.True(t, errors.Is(err, ErrLimitExceeded))
+	var denied *widevineproxy.AuthorizationDeniedError
+	if assert.True(t, errors.As(err, &denied)) {
+		assert.Equal(t, "user user-1 reached the limit of 2 downloads of content bW92aWUtMQ==", denied.Reason)
+	}
+	assert.NoError(t, ledger.CheckLicense(ctx, testRequest("user-2", "", "OFFLINE")))
+}
+
+func TestLedgerFileStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "offline.json")
+	store, err := LoadFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	req := testRequest("user-1", "", "OFFLINE")
+	assert.NoError(t, NewLedger(store, Limits{}).LicenseIssued(ctx, issued(req, "b2ZmbGluZS0x", nil)))
+	assert.NoError(t, NewLedger(store, Limits{}).Revoke(ctx, "user-1", "b2ZmbGluZS0x"))
+
+	// The licenses outlive the proxy, and so do the slots they hold.
+	reloaded, err := LoadFileStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ledger := NewLedger(reloaded, Limits{MaxLicenses: 1})
+	assert.Equal(t, LimitLicenses, limitOf(ledger.CheckLicense(ctx, req)))
+	licenses, err := ledger.Licenses(ctx, "user-1")
+	assert.NoError(t, err)
+	if assert.Equal(t, []string{"b2ZmbGluZS0x"}, licenseIDs(licenses)) {
+		assert.Equal(t, StatusRevoked, licenses[0].Status)
+		assert.Equal(t, "ZGV2aWNlLWE=", licenses[0].DeviceID)
+	}
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte(`{"licenses": [{"user_id": "user-1"}]}`), 0600))
+	_, err = LoadFileStore(path)
+	assert.Error(t, err)
+}
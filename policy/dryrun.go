@@ -0,0 +1,40 @@
+package widevinepolicy
+
+// RuleResult is the evaluation of a rule by DryRun.
+type RuleResult struct {
+	Rule     string `json:"rule"`
+	Priority int    `json:"priority"`
+	Policy   string `json:"policy"`
+	Matched  bool   `json:"matched"`
+	// Mismatch is the first condition of the rule failed by the input.
+	Mismatch string `json:"mismatch,omitempty"`
+}
+
+// Report is the outcome of DryRun.
+type Report struct {
+	Input    *Input       `json:"input"`
+	Rules    []RuleResult `json:"rules"`
+	Decision *Decision    `json:"decision,omitempty"`
+	Error    string       `json:"error,omitempty"`
+}
+
+// DryRun evaluates every rule against in, in order of precedence, to tell which rule decides and why the others do not.
+func (e *Engine) DryRun(in *Input) *Report {
+	report := &Report{Input: in}
+	for _, rule := range e.rules {
+		mismatch := rule.Match.mismatch(in)
+		report.Rules = append(report.Rules, RuleResult{
+			Rule:     rule.Name,
+			Priority: rule.Priority,
+			Policy:   rule.Policy,
+			Matched:  mismatch == "",
+			Mismatch: mismatch,
+		})
+	}
+	decision, err := e.Evaluate(in)
+	if err != nil {
+		report.Error = err.Error()
+	}
+	report.Decision = decision
+	return report
+}
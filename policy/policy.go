@@ -0,0 +1,332 @@
+// Package widevinepolicy derives the policy of the Widevine licenses from declarative rules.
+//
+// A Config names policies, each the skeleton of a license message (allowed track types, SD only for L3,
+// policy overrides, output protection), and rules selecting a policy by content id, user tier,
+// device security level, make, model, platform and country. The Engine applies the policy of the
+// first matching rule, in order of priority, or the default policy.
+package widevinepolicy
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	widevineproxy "github.com/cooomma/widevine-proxy/proxy"
+	"gopkg.in/yaml.v3"
+)
+
+// Default claims attributes of the user tier and country.
+const (
+	DefaultTierAttribute    = "tier"
+	DefaultCountryAttribute = "country"
+)
+
+// Config is the policy configuration, in YAML or JSON.
+type Config struct {
+	Policies map[string]*Policy `json:"policies"`
+	Rules    []*Rule            `json:"rules"`
+	// DefaultPolicy applies when no rule matches. Licenses matching no rule are denied if empty.
+	DefaultPolicy string `json:"default_policy"`
+
+	// TierAttribute and CountryAttribute name the claims attributes of the user tier and country,
+	// DefaultTierAttribute and DefaultCountryAttribute if empty.
+	TierAttribute    string `json:"tier_attribute"`
+	CountryAttribute string `json:"country_attribute"`
+}
+
+// Policy is the skeleton of the license messages it applies to.
+type Policy struct {
+	AllowedTrackTypes             widevineproxy.AllowedTrackType `json:"allowed_track_types"`
+	SDOnlyForL3                   bool                           `json:"sd_only_for_l3"`
+	PolicyOverrides               *widevineproxy.PolicyOverrides `json:"policy_overrides"`
+	UsePolicyOverridesExclusively bool                           `json:"use_policy_overrides_exclusively"`
+
+	// OutputProtection is required for the keys of every track, unless TrackOutputProtection sets the one of its track type.
+	OutputProtection      *widevineproxy.OutputProtection                                   `json:"output_protection"`
+	TrackOutputProtection map[widevineproxy.ContentTrackType]widevineproxy.OutputProtection `json:"track_output_protection"`
+}
+
+// Rule selects the named Policy of the licenses matching it.
+// Rules are evaluated by decreasing Priority, then in their order of declaration.
+type Rule struct {
+	Name     string `json:"name"`
+	Priority int    `json:"priority"`
+	Policy   string `json:"policy"`
+	Match    Match  `json:"match"`
+}
+
+// Match lists the conditions of a Rule, all of which must hold. An empty list matches anything.
+// Patterns may hold * wildcards and, but for content ids, are case insensitive.
+type Match struct {
+	// ContentIDs match either the raw or the base64 content id.
+	ContentIDs     []string `json:"content_ids"`
+	Tiers          []string `json:"tiers"`
+	SecurityLevels []int64  `json:"security_levels"`
+	Makes          []string `json:"makes"`
+	Models         []string `json:"models"`
+	Platforms      []string `json:"platforms"`
+	Countries      []string `json:"countries"`
+}
+
+// Input is what the rules are evaluated against.
+type Input struct {
+	ContentID     string `json:"content_id"` // Raw or base64 encoded as in PsshData.
+	Tier          string `json:"tier,omitempty"`
+	SecurityLevel int64  `json:"security_level,omitempty"`
+	Make          string `json:"make,omitempty"`
+	Model         string `json:"model,omitempty"`
+	Platform      string `json:"platform,omitempty"`
+	Country       string `json:"country,omitempty"`
+}
+
+// Decision is the outcome of the evaluation of an Input.
+type Decision struct {
+	Rule   string `json:"rule,omitempty"` // Empty when the default policy applies.
+	Policy string `json:"policy"`
+	// Message is the skeleton of the license message, without payload nor content keys.
+	Message *widevineproxy.Message `json:"message"`
+
+	policy *Policy
+}
+
+// Apply sets the policy of the decision on message, and the output protection of its content keys.
+func (d *Decision) Apply(message *widevineproxy.Message) {
+	message.AllowedTrackTypes = d.Message.AllowedTrackTypes
+	message.SDOnlyForL3 = d.Message.SDOnlyForL3
+	message.PolicyOverrides = d.Message.PolicyOverrides
+	message.UsePolicyOverridesExclusively = d.Message.UsePolicyOverridesExclusively
+	for i := range message.ContentKeySpecs {
+		spec := &message.ContentKeySpecs[i]
+		if op, ok := d.policy.TrackOutputProtection[spec.TrackType]; ok {
+			spec.OutputProtection = op
+		} else if d.policy.OutputProtection != nil {
+			spec.OutputProtection = *d.policy.OutputProtection
+		}
+	}
+}
+
+// Engine evaluates the rules of a Config. It implements widevineproxy.Policy.
+type Engine struct {
+	config *Config
+	rules  []*Rule // By decreasing priority.
+}
+
+// NewEngine checks cfg and returns its Engine.
+func NewEngine(cfg *Config) (*Engine, error) {
+	if cfg.DefaultPolicy != "" && cfg.Policies[cfg.DefaultPolicy] == nil {
+		return nil, fmt.Errorf("unknown default policy %s", cfg.DefaultPolicy)
+	}
+	for name, policy := range cfg.Policies {
+		if policy == nil {
+			return nil, fmt.Errorf("policy %s is empty", name)
+		}
+	}
+	names := map[string]bool{}
+	for i, rule := range cfg.Rules {
+		if rule.Name == "" || names[rule.Name] {
+			return nil, fmt.Errorf("rules[%d]: name is required and unique", i)
+		}
+		names[rule.Name] = true
+		if cfg.Policies[rule.Policy] == nil {
+			return nil, fmt.Errorf("rule %s: unknown policy %s", rule.Name, rule.Policy)
+		}
+	}
+
+	e := &Engine{config: cfg, rules: append([]*Rule(nil), cfg.Rules...)}
+	sort.SliceStable(e.rules, func(i, j int) bool { return e.rules[i].Priority > e.rules[j].Priority })
+	return e, nil
+}
+
+// Parse reads a YAML or JSON Config.
+func Parse(b []byte) (*Engine, error) {
+	cfg := &Config{}
+	if err := Unmarshal(b, cfg); err != nil {
+		return nil, err
+	}
+	return NewEngine(cfg)
+}
+
+// Unmarshal decodes YAML or JSON into v. YAML being a superset of JSON, the fields are named by their JSON tags in both.
+func Unmarshal(b []byte, v interface{}) error {
+	var doc interface{}
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return err
+	}
+	j, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(j, v)
+}
+
+// Load reads the YAML or JSON Config at path.
+func Load(path string) (*Engine, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	e, err := Parse(b)
+	if err != nil {
+		return nil, fmt.Errorf("policies %s: %v", path, err)
+	}
+	return e, nil
+}
+
+// Evaluate returns the decision of the first rule matching in, or of the default policy.
+// It returns an AuthorizationDeniedError when neither applies.
+func (e *Engine) Evaluate(in *Input) (*Decision, error) {
+	for _, rule := range e.rules {
+		if rule.Match.mismatch(in) == "" {
+			return e.decision(rule.Name, rule.Policy), nil
+		}
+	}
+	if e.config.DefaultPolicy != "" {
+		return e.decision("", e.config.DefaultPolicy), nil
+	}
+	return nil, widevineproxy.Deny("no license policy applies to content %s", in.ContentID)
+}
+
+func (e *Engine) decision(rule, name string) *Decision {
+	policy := e.config.Policies[name]
+	message := &widevineproxy.Message{
+		AllowedTrackTypes:             policy.AllowedTrackTypes,
+		SDOnlyForL3:                   policy.SDOnlyForL3,
+		UsePolicyOverridesExclusively: policy.UsePolicyOverridesExclusively,
+	}
+	if policy.PolicyOverrides != nil {
+		overrides := *policy.PolicyOverrides
+		message.PolicyOverrides = &overrides
+	}
+	return &Decision{Rule: rule, Policy: name, Message: message, policy: policy}
+}
+
+// Input returns the input of a license request: the tier and country come from the claims attributes,
+// the device from the PARSE_ONLY response.
+func (e *Engine) Input(req *widevineproxy.AuthorizationRequest) *Input {
+	in := &Input{
+		ContentID:     req.PsshData.ContentID,
+		SecurityLevel: req.Device.SecurityLevel,
+		Make:          req.Device.Make,
+		Model:         req.Device.Model,
+		Platform:      req.Device.Platform,
+	}
+	if req.Claims != nil {
+		in.Tier = attribute(req.Claims, e.config.TierAttribute, DefaultTierAttribute)
+		in.Country = attribute(req.Claims, e.config.CountryAttribute, DefaultCountryAttribute)
+	}
+	return in
+}
+
+func attribute(claims *widevineproxy.Claims, name, defaultName string) string {
+	if name == "" {
+		name = defaultName
+	}
+	if v, ok := claims.Attributes[name]; ok && v != nil {
+		return fmt.Sprint(v)
+	}
+	return ""
+}
+
+// ApplyPolicy sets the policy of the license request on message.
+func (e *Engine) ApplyPolicy(ctx context.Context, req *widevineproxy.AuthorizationRequest, message *widevineproxy.Message) error {
+	decision, err := e.Evaluate(e.Input(req))
+	if err != nil {
+		return err
+	}
+	decision.Apply(message)
+	return nil
+}
+
+// RequiresDeviceInfo reports whether a rule matches on the device, known only after a PARSE_ONLY request.
+func (e *Engine) RequiresDeviceInfo(psshData *widevineproxy.PsshData) bool {
+	for _, rule := range e.rules {
+		m := &rule.Match
+		if len(m.SecurityLevels)+len(m.Makes)+len(m.Models)+len(m.Platforms) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// mismatch returns the first condition of m failed by in, empty if in matches.
+func (m *Match) mismatch(in *Input) string {
+	switch {
+	case !matchContentID(m.ContentIDs, in.ContentID):
+		return "content_ids"
+	case !matchFold(m.Tiers, in.Tier):
+		return "tiers"
+	case !matchSecurityLevel(m.SecurityLevels, in.SecurityLevel):
+		return "security_levels"
+	case !matchFold(m.Makes, in.Make):
+		return "makes"
+	case !matchFold(m.Models, in.Model):
+		return "models"
+	case !matchFold(m.Platforms, in.Platform):
+		return "platforms"
+	case !matchFold(m.Countries, in.Country):
+		return "countries"
+	}
+	return ""
+}
+
+func matchContentID(patterns []string, contentID string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	raw, _ := base64.StdEncoding.DecodeString(contentID)
+	for _, p := range patterns {
+		if wildcard(p, contentID) || wildcard(p, string(raw)) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchFold(patterns []string, s string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	s = strings.ToLower(s)
+	for _, p := range patterns {
+		if wildcard(strings.ToLower(p), s) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchSecurityLevel(levels []int64, level int64) bool {
+	if len(levels) == 0 {
+		return true
+	}
+	for _, l := range levels {
+		if l == level {
+			return true
+		}
+	}
+	return false
+}
+
+// wildcard reports whether s matches pattern, in which * matches any sequence of characters.
+func wildcard(pattern, s string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == s
+	}
+	if !strings.HasPrefix(s, parts[0]) {
+		return false
+	}
+	s = s[len(parts[0]):]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(s, part)
+		if i == -1 {
+			return false
+		}
+		s = s[i+len(part):]
+	}
+	return strings.HasSuffix(s, parts[len(parts)-1])
+}
@@ -0,0 +1,161 @@
+package widevinepolicy
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	widevineproxy "github.com/cooomma/widevine-proxy/proxy"
+	"github.com/stretchr/testify/assert"
+)
+
+const testPolicies = `
+default_policy: sd
+policies:
+  sd:
+    allowed_track_types: SD_ONLY
+    policy_overrides:
+      can_play: true
+      license_duration_seconds: 3600
+  hd:
+    allowed_track_types: SD_HD
+    sd_only_for_l3: true
+    policy_overrides:
+      can_play: true
+      license_duration_seconds: 86400
+    output_protection:
+      hdcp: HDCP_V1
+    track_output_protection:
+      HD:
+        hdcp: HDCP_V2
+  uhd:
+    allowed_track_types: SD_UHD1
+    use_policy_overrides_exclusively: true
+    policy_overrides:
+      can_play: true
+rules:
+  - name: premium
+    priority: 10
+    policy: hd
+    match:
+      tiers: [premium, family]
+  - name: premium-l1
+    priority: 20
+    policy: uhd
+    match:
+      tiers: [premium]
+      security_levels: [1]
+      makes: ["google*"]
+  - name: trailers
+    policy: hd
+    match:
+      content_ids: ["trailer-*"]
+      countries: [FR, DE]
+`
+
+func TestEvaluate(t *testing.T) {
+	engine, err := Parse([]byte(testPolicies))
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	tests := []struct {
+		in     Input
+		rule   string
+		policy string
+	}{
+		{Input{ContentID: "movie-1"}, "", "sd"},
+		{Input{ContentID: "movie-1", Tier: "Premium"}, "premium", "hd"},
+		{Input{ContentID: "movie-1", Tier: "premium", SecurityLevel: 1, Make: "Google"}, "premium-l1", "uhd"},
+		{Input{ContentID: "movie-1", Tier: "premium", SecurityLevel: 3, Make: "Google"}, "premium", "hd"},
+		{Input{ContentID: base64.StdEncoding.EncodeToString([]byte("trailer-1")), Country: "fr"}, "trailers", "hd"},
+		{Input{ContentID: "trailer-1", Country: "US"}, "", "sd"},
+	}
+	for _, test := range tests {
+		decision, err := engine.Evaluate(&test.in)
+		if assert.NoError(t, err) {
+			assert.Equal(t, test.rule, decision.Rule, test.in)
+			assert.Equal(t, test.policy, decision.Policy, test.in)
+		}
+	}
+
+	decision, _ := engine.Evaluate(&Input{Tier: "premium"})
+	assert.Equal(t, widevineproxy.AllowedTrackType(widevineproxy.AllowedTrackTypeHD), decision.Message.AllowedTrackTypes)
+	assert.True(t, decision.Message.SDOnlyForL3)
+	assert.Equal(t, uint64(86400), decision.Message.PolicyOverrides.LicenseDurationSeconds)
+}
+
+func TestEvaluateWithoutDefault(t *testing.T) {
+	engine, err := NewEngine(&Config{
+		Policies: map[string]*Policy{"hd": {AllowedTrackTypes: widevineproxy.AllowedTrackTypeHD}},
+		Rules:    []*Rule{{Name: "fr", Policy: "hd", Match: Match{Countries: []string{"FR"}}}},
+	})
+	if !assert.NoError(t, err) {
+		return
+	}
+	_, err = engine.Evaluate(&Input{ContentID: "movie-1", Country: "US"})
+	var denied *widevineproxy.AuthorizationDeniedError
+	assert.True(t, errors.As(err, &denied))
+}
+
+func TestNewEngineInvalid(t *testing.T) {
+	policies := map[string]*Policy{"sd": {}}
+	for _, cfg := range []*Config{
+		{Policies: policies, DefaultPolicy: "hd"},
+		{Policies: policies, Rules: []*Rule{{Name: "r", Policy: "hd"}}},
+		{Policies: policies, Rules: []*Rule{{Policy: "sd"}}},
+		{Policies: policies, Rules: []*Rule{{Name: "r", Policy: "sd"}, {Name: "r", Policy: "sd"}}},
+	} {
+		_, err := NewEngine(cfg)
+		assert.Error(t, err)
+	}
+
+	_, err := Parse([]byte(`{"policies": {"sd": {"allowed_track_types": "SD_ONLY"}}, "default_policy": "sd"}`))
+	assert.NoError(t, err, "JSON")
+	_, err = Parse([]byte("policies: [sd"))
+	assert.Error(t, err)
+}
+
+func TestApplyPolicy(t *testing.T) {
+	engine, _ := Parse([]byte(testPolicies))
+	message := &widevineproxy.Message{
+		AllowedTrackTypes: widevineproxy.AllowedTrackTypeUHD2,
+		ContentKeySpecs: []widevineproxy.ContentKeySpec{
+			{TrackType: widevineproxy.ContentTrackTypeSD},
+			{TrackType: widevineproxy.ContentTrackTypeHD},
+		},
+	}
+	req := &widevineproxy.AuthorizationRequest{
+		Claims:   &widevineproxy.Claims{UserID: "user-1", Attributes: map[string]interface{}{"tier": "premium"}},
+		PsshData: widevineproxy.PsshData{ContentID: "bW92aWUtMQ=="},
+		Device:   widevineproxy.DeviceInfo{SecurityLevel: 3},
+	}
+	assert.NoError(t, engine.ApplyPolicy(context.Background(), req, message))
+	assert.Equal(t, widevineproxy.AllowedTrackType(widevineproxy.AllowedTrackTypeHD), message.AllowedTrackTypes)
+	assert.Equal(t, widevineproxy.HDCPVersion(widevineproxy.HDCPVersionV1), message.ContentKeySpecs[0].OutputProtection.HDCP)
+	assert.Equal(t, widevineproxy.HDCPVersion(widevineproxy.HDCPVersionV2), message.ContentKeySpecs[1].OutputProtection.HDCP)
+	assert.True(t, engine.RequiresDeviceInfo(&req.PsshData))
+}
+
+func TestDryRun(t *testing.T) {
+	engine, _ := Parse([]byte(testPolicies))
+	report := engine.DryRun(&Input{ContentID: "movie-1", Tier: "premium", SecurityLevel: 3})
+	if assert.Len(t, report.Rules, 3) {
+		assert.Equal(t, RuleResult{Rule: "premium-l1", Priority: 20, Policy: "uhd", Mismatch: "security_levels"}, report.Rules[0])
+		assert.Equal(t, RuleResult{Rule: "premium", Priority: 10, Policy: "hd", Matched: true}, report.Rules[1])
+		assert.Equal(t, "content_ids", report.Rules[2].Mismatch)
+	}
+	assert.Equal(t, "premium", report.Decision.Rule)
+	assert.Empty(t, report.Error)
+}
+
+func TestWildcard(t *testing.T) {
+	assert.True(t, wildcard("*", ""))
+	assert.True(t, wildcard("pixel*", "pixel 7"))
+	assert.True(t, wildcard("*-uhd-*", "movie-uhd-1"))
+	assert.True(t, wildcard("a*b*c", "abc"))
+	assert.False(t, wildcard("a*b*c", "acb"))
+	assert.False(t, wildcard("ab*ba", "aba"))
+	assert.False(t, wildcard("movie", "movie-1"))
+}
@@ -0,0 +1,2002 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.26.0
+// 	protoc        (unknown)
+// source: license_protocol.proto
+
+package proto
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type LicenseType int32
+
+const (
+	LicenseType_STREAMING LicenseType = 1
+	LicenseType_OFFLINE   LicenseType = 2
+	// License type decision is left to provider.
+	LicenseType_AUTOMATIC LicenseType = 3
+)
+
+// Enum value maps for LicenseType.
+var (
+	LicenseType_name = map[int32]string{
+		1: "STREAMING",
+		2: "OFFLINE",
+		3: "AUTOMATIC",
+	}
+	LicenseType_value = map[string]int32{
+		"STREAMING": 1,
+		"OFFLINE":   2,
+		"AUTOMATIC": 3,
+	}
+)
+
+func (x LicenseType) Enum() *LicenseType {
+	p := new(LicenseType)
+	*p = x
+	return p
+}
+
+func (x LicenseType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (LicenseType) Descriptor() protoreflect.EnumDescriptor {
+	return file_license_protocol_proto_enumTypes[0].Descriptor()
+}
+
+func (LicenseType) Type() protoreflect.EnumType {
+	return &file_license_protocol_proto_enumTypes[0]
+}
+
+func (x LicenseType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Do not use.
+func (x *LicenseType) UnmarshalJSON(b []byte) error {
+	num, err := protoimpl.X.UnmarshalJSONEnum(x.Descriptor(), b)
+	if err != nil {
+		return err
+	}
+	*x = LicenseType(num)
+	return nil
+}
+
+// Deprecated: Use LicenseType.Descriptor instead.
+func (LicenseType) EnumDescriptor() ([]byte, []int) {
+	return file_license_protocol_proto_rawDescGZIP(), []int{0}
+}
+
+type ProtocolVersion int32
+
+const (
+	ProtocolVersion_VERSION_2_0 ProtocolVersion = 20
+	ProtocolVersion_VERSION_2_1 ProtocolVersion = 21
+	ProtocolVersion_VERSION_2_2 ProtocolVersion = 22
+)
+
+// Enum value maps for ProtocolVersion.
+var (
+	ProtocolVersion_name = map[int32]string{
+		20: "VERSION_2_0",
+		21: "VERSION_2_1",
+		22: "VERSION_2_2",
+	}
+	ProtocolVersion_value = map[string]int32{
+		"VERSION_2_0": 20,
+		"VERSION_2_1": 21,
+		"VERSION_2_2": 22,
+	}
+)
+
+func (x ProtocolVersion) Enum() *ProtocolVersion {
+	p := new(ProtocolVersion)
+	*p = x
+	return p
+}
+
+func (x ProtocolVersion) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ProtocolVersion) Descriptor() protoreflect.EnumDescriptor {
+	return file_license_protocol_proto_enumTypes[1].Descriptor()
+}
+
+func (ProtocolVersion) Type() protoreflect.EnumType {
+	return &file_license_protocol_proto_enumTypes[1]
+}
+
+func (x ProtocolVersion) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Do not use.
+func (x *ProtocolVersion) UnmarshalJSON(b []byte) error {
+	num, err := protoimpl.X.UnmarshalJSONEnum(x.Descriptor(), b)
+	if err != nil {
+		return err
+	}
+	*x = ProtocolVersion(num)
+	return nil
+}
+
+// Deprecated: Use ProtocolVersion.Descriptor instead.
+func (ProtocolVersion) EnumDescriptor() ([]byte, []int) {
+	return file_license_protocol_proto_rawDescGZIP(), []int{1}
+}
+
+type ClientIdentification_TokenType int32
+
+const (
+	ClientIdentification_KEYBOX                         ClientIdentification_TokenType = 0
+	ClientIdentification_DRM_DEVICE_CERTIFICATE         ClientIdentification_TokenType = 1
+	ClientIdentification_REMOTE_ATTESTATION_CERTIFICATE ClientIdentification_TokenType = 2
+	ClientIdentification_OEM_DEVICE_CERTIFICATE         ClientIdentification_TokenType = 3
+)
+
+// Enum value maps for ClientIdentification_TokenType.
+var (
+	ClientIdentification_TokenType_name = map[int32]string{
+		0: "KEYBOX",
+		1: "DRM_DEVICE_CERTIFICATE",
+		2: "REMOTE_ATTESTATION_CERTIFICATE",
+		3: "OEM_DEVICE_CERTIFICATE",
+	}
+	ClientIdentification_TokenType_value = map[string]int32{
+		"KEYBOX":                         0,
+		"DRM_DEVICE_CERTIFICATE":         1,
+		"REMOTE_ATTESTATION_CERTIFICATE": 2,
+		"OEM_DEVICE_CERTIFICATE":         3,
+	}
+)
+
+func (x ClientIdentification_TokenType) Enum() *ClientIdentification_TokenType {
+	p := new(ClientIdentification_TokenType)
+	*p = x
+	return p
+}
+
+func (x ClientIdentification_TokenType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ClientIdentification_TokenType) Descriptor() protoreflect.EnumDescriptor {
+	return file_license_protocol_proto_enumTypes[2].Descriptor()
+}
+
+func (ClientIdentification_TokenType) Type() protoreflect.EnumType {
+	return &file_license_protocol_proto_enumTypes[2]
+}
+
+func (x ClientIdentification_TokenType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Do not use.
+func (x *ClientIdentification_TokenType) UnmarshalJSON(b []byte) error {
+	num, err := protoimpl.X.UnmarshalJSONEnum(x.Descriptor(), b)
+	if err != nil {
+		return err
+	}
+	*x = ClientIdentification_TokenType(num)
+	return nil
+}
+
+// Deprecated: Use ClientIdentification_TokenType.Descriptor instead.
+func (ClientIdentification_TokenType) EnumDescriptor() ([]byte, []int) {
+	return file_license_protocol_proto_rawDescGZIP(), []int{1, 0}
+}
+
+type ClientIdentification_ClientCapabilities_HdcpVersion int32
+
+const (
+	ClientIdentification_ClientCapabilities_HDCP_NONE              ClientIdentification_ClientCapabilities_HdcpVersion = 0
+	ClientIdentification_ClientCapabilities_HDCP_V1                ClientIdentification_ClientCapabilities_HdcpVersion = 1
+	ClientIdentification_ClientCapabilities_HDCP_V2                ClientIdentification_ClientCapabilities_HdcpVersion = 2
+	ClientIdentification_ClientCapabilities_HDCP_V2_1              ClientIdentification_ClientCapabilities_HdcpVersion = 3
+	ClientIdentification_ClientCapabilities_HDCP_V2_2              ClientIdentification_ClientCapabilities_HdcpVersion = 4
+	ClientIdentification_ClientCapabilities_HDCP_V2_3              ClientIdentification_ClientCapabilities_HdcpVersion = 5
+	ClientIdentification_ClientCapabilities_HDCP_NO_DIGITAL_OUTPUT ClientIdentification_ClientCapabilities_HdcpVersion = 255
+)
+
+// Enum value maps for ClientIdentification_ClientCapabilities_HdcpVersion.
+var (
+	ClientIdentification_ClientCapabilities_HdcpVersion_name = map[int32]string{
+		0:   "HDCP_NONE",
+		1:   "HDCP_V1",
+		2:   "HDCP_V2",
+		3:   "HDCP_V2_1",
+		4:   "HDCP_V2_2",
+		5:   "HDCP_V2_3",
+		255: "HDCP_NO_DIGITAL_OUTPUT",
+	}
+	ClientIdentification_ClientCapabilities_HdcpVersion_value = map[string]int32{
+		"HDCP_NONE":              0,
+		"HDCP_V1":                1,
+		"HDCP_V2":                2,
+		"HDCP_V2_1":              3,
+		"HDCP_V2_2":              4,
+		"HDCP_V2_3":              5,
+		"HDCP_NO_DIGITAL_OUTPUT": 255,
+	}
+)
+
+func (x ClientIdentification_ClientCapabilities_HdcpVersion) Enum() *ClientIdentification_ClientCapabilities_HdcpVersion {
+	p := new(ClientIdentification_ClientCapabilities_HdcpVersion)
+	*p = x
+	return p
+}
+
+func (x ClientIdentification_ClientCapabilities_HdcpVersion) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (ClientIdentification_ClientCapabilities_HdcpVersion) Descriptor() protoreflect.EnumDescriptor {
+	return file_license_protocol_proto_enumTypes[3].Descriptor()
+}
+
+func (ClientIdentification_ClientCapabilities_HdcpVersion) Type() protoreflect.EnumType {
+	return &file_license_protocol_proto_enumTypes[3]
+}
+
+func (x ClientIdentification_ClientCapabilities_HdcpVersion) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Do not use.
+func (x *ClientIdentification_ClientCapabilities_HdcpVersion) UnmarshalJSON(b []byte) error {
+	num, err := protoimpl.X.UnmarshalJSONEnum(x.Descriptor(), b)
+	if err != nil {
+		return err
+	}
+	*x = ClientIdentification_ClientCapabilities_HdcpVersion(num)
+	return nil
+}
+
+// Deprecated: Use ClientIdentification_ClientCapabilities_HdcpVersion.Descriptor instead.
+func (ClientIdentification_ClientCapabilities_HdcpVersion) EnumDescriptor() ([]byte, []int) {
+	return file_license_protocol_proto_rawDescGZIP(), []int{1, 1, 0}
+}
+
+type LicenseRequest_RequestType int32
+
+const (
+	LicenseRequest_NEW     LicenseRequest_RequestType = 1
+	LicenseRequest_RENEWAL LicenseRequest_RequestType = 2
+	LicenseRequest_RELEASE LicenseRequest_RequestType = 3
+)
+
+// Enum value maps for LicenseRequest_RequestType.
+var (
+	LicenseRequest_RequestType_name = map[int32]string{
+		1: "NEW",
+		2: "RENEWAL",
+		3: "RELEASE",
+	}
+	LicenseRequest_RequestType_value = map[string]int32{
+		"NEW":     1,
+		"RENEWAL": 2,
+		"RELEASE": 3,
+	}
+)
+
+func (x LicenseRequest_RequestType) Enum() *LicenseRequest_RequestType {
+	p := new(LicenseRequest_RequestType)
+	*p = x
+	return p
+}
+
+func (x LicenseRequest_RequestType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (LicenseRequest_RequestType) Descriptor() protoreflect.EnumDescriptor {
+	return file_license_protocol_proto_enumTypes[4].Descriptor()
+}
+
+func (LicenseRequest_RequestType) Type() protoreflect.EnumType {
+	return &file_license_protocol_proto_enumTypes[4]
+}
+
+func (x LicenseRequest_RequestType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Do not use.
+func (x *LicenseRequest_RequestType) UnmarshalJSON(b []byte) error {
+	num, err := protoimpl.X.UnmarshalJSONEnum(x.Descriptor(), b)
+	if err != nil {
+		return err
+	}
+	*x = LicenseRequest_RequestType(num)
+	return nil
+}
+
+// Deprecated: Use LicenseRequest_RequestType.Descriptor instead.
+func (LicenseRequest_RequestType) EnumDescriptor() ([]byte, []int) {
+	return file_license_protocol_proto_rawDescGZIP(), []int{3, 0}
+}
+
+type LicenseRequest_ContentIdentification_InitData_InitDataType int32
+
+const (
+	LicenseRequest_ContentIdentification_InitData_CENC LicenseRequest_ContentIdentification_InitData_InitDataType = 1
+	LicenseRequest_ContentIdentification_InitData_WEBM LicenseRequest_ContentIdentification_InitData_InitDataType = 2
+)
+
+// Enum value maps for LicenseRequest_ContentIdentification_InitData_InitDataType.
+var (
+	LicenseRequest_ContentIdentification_InitData_InitDataType_name = map[int32]string{
+		1: "CENC",
+		2: "WEBM",
+	}
+	LicenseRequest_ContentIdentification_InitData_InitDataType_value = map[string]int32{
+		"CENC": 1,
+		"WEBM": 2,
+	}
+)
+
+func (x LicenseRequest_ContentIdentification_InitData_InitDataType) Enum() *LicenseRequest_ContentIdentification_InitData_InitDataType {
+	p := new(LicenseRequest_ContentIdentification_InitData_InitDataType)
+	*p = x
+	return p
+}
+
+func (x LicenseRequest_ContentIdentification_InitData_InitDataType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (LicenseRequest_ContentIdentification_InitData_InitDataType) Descriptor() protoreflect.EnumDescriptor {
+	return file_license_protocol_proto_enumTypes[5].Descriptor()
+}
+
+func (LicenseRequest_ContentIdentification_InitData_InitDataType) Type() protoreflect.EnumType {
+	return &file_license_protocol_proto_enumTypes[5]
+}
+
+func (x LicenseRequest_ContentIdentification_InitData_InitDataType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Do not use.
+func (x *LicenseRequest_ContentIdentification_InitData_InitDataType) UnmarshalJSON(b []byte) error {
+	num, err := protoimpl.X.UnmarshalJSONEnum(x.Descriptor(), b)
+	if err != nil {
+		return err
+	}
+	*x = LicenseRequest_ContentIdentification_InitData_InitDataType(num)
+	return nil
+}
+
+// Deprecated: Use LicenseRequest_ContentIdentification_InitData_InitDataType.Descriptor instead.
+func (LicenseRequest_ContentIdentification_InitData_InitDataType) EnumDescriptor() ([]byte, []int) {
+	return file_license_protocol_proto_rawDescGZIP(), []int{3, 0, 3, 0}
+}
+
+type SignedMessage_MessageType int32
+
+const (
+	SignedMessage_LICENSE_REQUEST             SignedMessage_MessageType = 1
+	SignedMessage_LICENSE                     SignedMessage_MessageType = 2
+	SignedMessage_ERROR_RESPONSE              SignedMessage_MessageType = 3
+	SignedMessage_SERVICE_CERTIFICATE_REQUEST SignedMessage_MessageType = 4
+	SignedMessage_SERVICE_CERTIFICATE         SignedMessage_MessageType = 5
+	SignedMessage_SUB_LICENSE                 SignedMessage_MessageType = 6
+	SignedMessage_CAS_LICENSE_REQUEST         SignedMessage_MessageType = 7
+	SignedMessage_CAS_LICENSE                 SignedMessage_MessageType = 8
+	SignedMessage_EXTERNAL_LICENSE_REQUEST    SignedMessage_MessageType = 9
+	SignedMessage_EXTERNAL_LICENSE            SignedMessage_MessageType = 10
+)
+
+// Enum value maps for SignedMessage_MessageType.
+var (
+	SignedMessage_MessageType_name = map[int32]string{
+		1:  "LICENSE_REQUEST",
+		2:  "LICENSE",
+		3:  "ERROR_RESPONSE",
+		4:  "SERVICE_CERTIFICATE_REQUEST",
+		5:  "SERVICE_CERTIFICATE",
+		6:  "SUB_LICENSE",
+		7:  "CAS_LICENSE_REQUEST",
+		8:  "CAS_LICENSE",
+		9:  "EXTERNAL_LICENSE_REQUEST",
+		10: "EXTERNAL_LICENSE",
+	}
+	SignedMessage_MessageType_value = map[string]int32{
+		"LICENSE_REQUEST":             1,
+		"LICENSE":                     2,
+		"ERROR_RESPONSE":              3,
+		"SERVICE_CERTIFICATE_REQUEST": 4,
+		"SERVICE_CERTIFICATE":         5,
+		"SUB_LICENSE":                 6,
+		"CAS_LICENSE_REQUEST":         7,
+		"CAS_LICENSE":                 8,
+		"EXTERNAL_LICENSE_REQUEST":    9,
+		"EXTERNAL_LICENSE":            10,
+	}
+)
+
+func (x SignedMessage_MessageType) Enum() *SignedMessage_MessageType {
+	p := new(SignedMessage_MessageType)
+	*p = x
+	return p
+}
+
+func (x SignedMessage_MessageType) String() string {
+	return protoimpl.X.EnumStringOf(x.Descriptor(), protoreflect.EnumNumber(x))
+}
+
+func (SignedMessage_MessageType) Descriptor() protoreflect.EnumDescriptor {
+	return file_license_protocol_proto_enumTypes[6].Descriptor()
+}
+
+func (SignedMessage_MessageType) Type() protoreflect.EnumType {
+	return &file_license_protocol_proto_enumTypes[6]
+}
+
+func (x SignedMessage_MessageType) Number() protoreflect.EnumNumber {
+	return protoreflect.EnumNumber(x)
+}
+
+// Deprecated: Do not use.
+func (x *SignedMessage_MessageType) UnmarshalJSON(b []byte) error {
+	num, err := protoimpl.X.UnmarshalJSONEnum(x.Descriptor(), b)
+	if err != nil {
+		return err
+	}
+	*x = SignedMessage_MessageType(num)
+	return nil
+}
+
+// Deprecated: Use SignedMessage_MessageType.Descriptor instead.
+func (SignedMessage_MessageType) EnumDescriptor() ([]byte, []int) {
+	return file_license_protocol_proto_rawDescGZIP(), []int{4, 0}
+}
+
+// Identifies a license issued by the license service. Carried back by
+// renewal and release requests.
+type LicenseIdentification struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	RequestId            []byte       `protobuf:"bytes,1,opt,name=request_id,json=requestId" json:"request_id,omitempty"`
+	SessionId            []byte       `protobuf:"bytes,2,opt,name=session_id,json=sessionId" json:"session_id,omitempty"`
+	PurchaseId           []byte       `protobuf:"bytes,3,opt,name=purchase_id,json=purchaseId" json:"purchase_id,omitempty"`
+	Type                 *LicenseType `protobuf:"varint,4,opt,name=type,enum=proto.LicenseType" json:"type,omitempty"`
+	Version              *int32       `protobuf:"varint,5,opt,name=version" json:"version,omitempty"`
+	ProviderSessionToken []byte       `protobuf:"bytes,6,opt,name=provider_session_token,json=providerSessionToken" json:"provider_session_token,omitempty"`
+}
+
+func (x *LicenseIdentification) Reset() {
+	*x = LicenseIdentification{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_license_protocol_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *LicenseIdentification) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LicenseIdentification) ProtoMessage() {}
+
+func (x *LicenseIdentification) ProtoReflect() protoreflect.Message {
+	mi := &file_license_protocol_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LicenseIdentification.ProtoReflect.Descriptor instead.
+func (*LicenseIdentification) Descriptor() ([]byte, []int) {
+	return file_license_protocol_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *LicenseIdentification) GetRequestId() []byte {
+	if x != nil {
+		return x.RequestId
+	}
+	return nil
+}
+
+func (x *LicenseIdentification) GetSessionId() []byte {
+	if x != nil {
+		return x.SessionId
+	}
+	return nil
+}
+
+func (x *LicenseIdentification) GetPurchaseId() []byte {
+	if x != nil {
+		return x.PurchaseId
+	}
+	return nil
+}
+
+func (x *LicenseIdentification) GetType() LicenseType {
+	if x != nil && x.Type != nil {
+		return *x.Type
+	}
+	return LicenseType_STREAMING
+}
+
+func (x *LicenseIdentification) GetVersion() int32 {
+	if x != nil && x.Version != nil {
+		return *x.Version
+	}
+	return 0
+}
+
+func (x *LicenseIdentification) GetProviderSessionToken() []byte {
+	if x != nil {
+		return x.ProviderSessionToken
+	}
+	return nil
+}
+
+type ClientIdentification struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Type  *ClientIdentification_TokenType `protobuf:"varint,1,opt,name=type,enum=proto.ClientIdentification_TokenType,def=0" json:"type,omitempty"`
+	Token []byte                          `protobuf:"bytes,2,opt,name=token" json:"token,omitempty"`
+	// Device description such as company_name, model_name, architecture_name.
+	ClientInfo          []*ClientIdentification_NameValue        `protobuf:"bytes,3,rep,name=client_info,json=clientInfo" json:"client_info,omitempty"`
+	ProviderClientToken []byte                                   `protobuf:"bytes,4,opt,name=provider_client_token,json=providerClientToken" json:"provider_client_token,omitempty"`
+	LicenseCounter      *uint32                                  `protobuf:"varint,5,opt,name=license_counter,json=licenseCounter" json:"license_counter,omitempty"`
+	ClientCapabilities  *ClientIdentification_ClientCapabilities `protobuf:"bytes,6,opt,name=client_capabilities,json=clientCapabilities" json:"client_capabilities,omitempty"`
+	VmpData             []byte                                   `protobuf:"bytes,7,opt,name=vmp_data,json=vmpData" json:"vmp_data,omitempty"`
+}
+
+// Default values for ClientIdentification fields.
+const (
+	Default_ClientIdentification_Type = ClientIdentification_KEYBOX
+)
+
+func (x *ClientIdentification) Reset() {
+	*x = ClientIdentification{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_license_protocol_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ClientIdentification) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClientIdentification) ProtoMessage() {}
+
+func (x *ClientIdentification) ProtoReflect() protoreflect.Message {
+	mi := &file_license_protocol_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClientIdentification.ProtoReflect.Descriptor instead.
+func (*ClientIdentification) Descriptor() ([]byte, []int) {
+	return file_license_protocol_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ClientIdentification) GetType() ClientIdentification_TokenType {
+	if x != nil && x.Type != nil {
+		return *x.Type
+	}
+	return Default_ClientIdentification_Type
+}
+
+func (x *ClientIdentification) GetToken() []byte {
+	if x != nil {
+		return x.Token
+	}
+	return nil
+}
+
+func (x *ClientIdentification) GetClientInfo() []*ClientIdentification_NameValue {
+	if x != nil {
+		return x.ClientInfo
+	}
+	return nil
+}
+
+func (x *ClientIdentification) GetProviderClientToken() []byte {
+	if x != nil {
+		return x.ProviderClientToken
+	}
+	return nil
+}
+
+func (x *ClientIdentification) GetLicenseCounter() uint32 {
+	if x != nil && x.LicenseCounter != nil {
+		return *x.LicenseCounter
+	}
+	return 0
+}
+
+func (x *ClientIdentification) GetClientCapabilities() *ClientIdentification_ClientCapabilities {
+	if x != nil {
+		return x.ClientCapabilities
+	}
+	return nil
+}
+
+func (x *ClientIdentification) GetVmpData() []byte {
+	if x != nil {
+		return x.VmpData
+	}
+	return nil
+}
+
+// Client identification encrypted with the provider's service certificate
+// (privacy mode). Only the license service can decrypt it.
+type EncryptedClientIdentification struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	ProviderId                     *string `protobuf:"bytes,1,opt,name=provider_id,json=providerId" json:"provider_id,omitempty"`
+	ServiceCertificateSerialNumber []byte  `protobuf:"bytes,2,opt,name=service_certificate_serial_number,json=serviceCertificateSerialNumber" json:"service_certificate_serial_number,omitempty"`
+	EncryptedClientId              []byte  `protobuf:"bytes,3,opt,name=encrypted_client_id,json=encryptedClientId" json:"encrypted_client_id,omitempty"`
+	EncryptedClientIdIv            []byte  `protobuf:"bytes,4,opt,name=encrypted_client_id_iv,json=encryptedClientIdIv" json:"encrypted_client_id_iv,omitempty"`
+	EncryptedPrivacyKey            []byte  `protobuf:"bytes,5,opt,name=encrypted_privacy_key,json=encryptedPrivacyKey" json:"encrypted_privacy_key,omitempty"`
+}
+
+func (x *EncryptedClientIdentification) Reset() {
+	*x = EncryptedClientIdentification{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_license_protocol_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *EncryptedClientIdentification) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EncryptedClientIdentification) ProtoMessage() {}
+
+func (x *EncryptedClientIdentification) ProtoReflect() protoreflect.Message {
+	mi := &file_license_protocol_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EncryptedClientIdentification.ProtoReflect.Descriptor instead.
+func (*EncryptedClientIdentification) Descriptor() ([]byte, []int) {
+	return file_license_protocol_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *EncryptedClientIdentification) GetProviderId() string {
+	if x != nil && x.ProviderId != nil {
+		return *x.ProviderId
+	}
+	return ""
+}
+
+func (x *EncryptedClientIdentification) GetServiceCertificateSerialNumber() []byte {
+	if x != nil {
+		return x.ServiceCertificateSerialNumber
+	}
+	return nil
+}
+
+func (x *EncryptedClientIdentification) GetEncryptedClientId() []byte {
+	if x != nil {
+		return x.EncryptedClientId
+	}
+	return nil
+}
+
+func (x *EncryptedClientIdentification) GetEncryptedClientIdIv() []byte {
+	if x != nil {
+		return x.EncryptedClientIdIv
+	}
+	return nil
+}
+
+func (x *EncryptedClientIdentification) GetEncryptedPrivacyKey() []byte {
+	if x != nil {
+		return x.EncryptedPrivacyKey
+	}
+	return nil
+}
+
+type LicenseRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	ClientId  *ClientIdentification                 `protobuf:"bytes,1,opt,name=client_id,json=clientId" json:"client_id,omitempty"`
+	ContentId *LicenseRequest_ContentIdentification `protobuf:"bytes,2,opt,name=content_id,json=contentId" json:"content_id,omitempty"`
+	Type      *LicenseRequest_RequestType           `protobuf:"varint,3,opt,name=type,enum=proto.LicenseRequest_RequestType" json:"type,omitempty"`
+	// Time of the request in seconds (UTC) as set by the client.
+	RequestTime               *int64                         `protobuf:"varint,4,opt,name=request_time,json=requestTime" json:"request_time,omitempty"`
+	KeyControlNonceDeprecated []byte                         `protobuf:"bytes,5,opt,name=key_control_nonce_deprecated,json=keyControlNonceDeprecated" json:"key_control_nonce_deprecated,omitempty"`
+	ProtocolVersion           *ProtocolVersion               `protobuf:"varint,6,opt,name=protocol_version,json=protocolVersion,enum=proto.ProtocolVersion,def=20" json:"protocol_version,omitempty"`
+	KeyControlNonce           *uint32                        `protobuf:"varint,7,opt,name=key_control_nonce,json=keyControlNonce" json:"key_control_nonce,omitempty"`
+	EncryptedClientId         *EncryptedClientIdentification `protobuf:"bytes,8,opt,name=encrypted_client_id,json=encryptedClientId" json:"encrypted_client_id,omitempty"`
+}
+
+// Default values for LicenseRequest fields.
+const (
+	Default_LicenseRequest_ProtocolVersion = ProtocolVersion_VERSION_2_0
+)
+
+func (x *LicenseRequest) Reset() {
+	*x = LicenseRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_license_protocol_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *LicenseRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LicenseRequest) ProtoMessage() {}
+
+func (x *LicenseRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_license_protocol_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LicenseRequest.ProtoReflect.Descriptor instead.
+func (*LicenseRequest) Descriptor() ([]byte, []int) {
+	return file_license_protocol_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *LicenseRequest) GetClientId() *ClientIdentification {
+	if x != nil {
+		return x.ClientId
+	}
+	return nil
+}
+
+func (x *LicenseRequest) GetContentId() *LicenseRequest_ContentIdentification {
+	if x != nil {
+		return x.ContentId
+	}
+	return nil
+}
+
+func (x *LicenseRequest) GetType() LicenseRequest_RequestType {
+	if x != nil && x.Type != nil {
+		return *x.Type
+	}
+	return LicenseRequest_NEW
+}
+
+func (x *LicenseRequest) GetRequestTime() int64 {
+	if x != nil && x.RequestTime != nil {
+		return *x.RequestTime
+	}
+	return 0
+}
+
+func (x *LicenseRequest) GetKeyControlNonceDeprecated() []byte {
+	if x != nil {
+		return x.KeyControlNonceDeprecated
+	}
+	return nil
+}
+
+func (x *LicenseRequest) GetProtocolVersion() ProtocolVersion {
+	if x != nil && x.ProtocolVersion != nil {
+		return *x.ProtocolVersion
+	}
+	return Default_LicenseRequest_ProtocolVersion
+}
+
+func (x *LicenseRequest) GetKeyControlNonce() uint32 {
+	if x != nil && x.KeyControlNonce != nil {
+		return *x.KeyControlNonce
+	}
+	return 0
+}
+
+func (x *LicenseRequest) GetEncryptedClientId() *EncryptedClientIdentification {
+	if x != nil {
+		return x.EncryptedClientId
+	}
+	return nil
+}
+
+// Envelope of every message exchanged between the CDM and the license service.
+type SignedMessage struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Type *SignedMessage_MessageType `protobuf:"varint,1,opt,name=type,enum=proto.SignedMessage_MessageType" json:"type,omitempty"`
+	// Serialized message of the given type, e.g. a LicenseRequest.
+	Msg                  []byte `protobuf:"bytes,2,opt,name=msg" json:"msg,omitempty"`
+	Signature            []byte `protobuf:"bytes,3,opt,name=signature" json:"signature,omitempty"`
+	SessionKey           []byte `protobuf:"bytes,4,opt,name=session_key,json=sessionKey" json:"session_key,omitempty"`
+	OemcryptoCoreMessage []byte `protobuf:"bytes,9,opt,name=oemcrypto_core_message,json=oemcryptoCoreMessage" json:"oemcrypto_core_message,omitempty"`
+}
+
+func (x *SignedMessage) Reset() {
+	*x = SignedMessage{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_license_protocol_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SignedMessage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SignedMessage) ProtoMessage() {}
+
+func (x *SignedMessage) ProtoReflect() protoreflect.Message {
+	mi := &file_license_protocol_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SignedMessage.ProtoReflect.Descriptor instead.
+func (*SignedMessage) Descriptor() ([]byte, []int) {
+	return file_license_protocol_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *SignedMessage) GetType() SignedMessage_MessageType {
+	if x != nil && x.Type != nil {
+		return *x.Type
+	}
+	return SignedMessage_LICENSE_REQUEST
+}
+
+func (x *SignedMessage) GetMsg() []byte {
+	if x != nil {
+		return x.Msg
+	}
+	return nil
+}
+
+func (x *SignedMessage) GetSignature() []byte {
+	if x != nil {
+		return x.Signature
+	}
+	return nil
+}
+
+func (x *SignedMessage) GetSessionKey() []byte {
+	if x != nil {
+		return x.SessionKey
+	}
+	return nil
+}
+
+func (x *SignedMessage) GetOemcryptoCoreMessage() []byte {
+	if x != nil {
+		return x.OemcryptoCoreMessage
+	}
+	return nil
+}
+
+type ClientIdentification_NameValue struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Name  *string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Value *string `protobuf:"bytes,2,opt,name=value" json:"value,omitempty"`
+}
+
+func (x *ClientIdentification_NameValue) Reset() {
+	*x = ClientIdentification_NameValue{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_license_protocol_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ClientIdentification_NameValue) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClientIdentification_NameValue) ProtoMessage() {}
+
+func (x *ClientIdentification_NameValue) ProtoReflect() protoreflect.Message {
+	mi := &file_license_protocol_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClientIdentification_NameValue.ProtoReflect.Descriptor instead.
+func (*ClientIdentification_NameValue) Descriptor() ([]byte, []int) {
+	return file_license_protocol_proto_rawDescGZIP(), []int{1, 0}
+}
+
+func (x *ClientIdentification_NameValue) GetName() string {
+	if x != nil && x.Name != nil {
+		return *x.Name
+	}
+	return ""
+}
+
+func (x *ClientIdentification_NameValue) GetValue() string {
+	if x != nil && x.Value != nil {
+		return *x.Value
+	}
+	return ""
+}
+
+type ClientIdentification_ClientCapabilities struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	ClientToken                *bool                                                `protobuf:"varint,1,opt,name=client_token,json=clientToken,def=0" json:"client_token,omitempty"`
+	SessionToken               *bool                                                `protobuf:"varint,2,opt,name=session_token,json=sessionToken,def=0" json:"session_token,omitempty"`
+	VideoResolutionConstraints *bool                                                `protobuf:"varint,3,opt,name=video_resolution_constraints,json=videoResolutionConstraints,def=0" json:"video_resolution_constraints,omitempty"`
+	MaxHdcpVersion             *ClientIdentification_ClientCapabilities_HdcpVersion `protobuf:"varint,4,opt,name=max_hdcp_version,json=maxHdcpVersion,enum=proto.ClientIdentification_ClientCapabilities_HdcpVersion,def=0" json:"max_hdcp_version,omitempty"`
+	OemCryptoApiVersion        *uint32                                              `protobuf:"varint,5,opt,name=oem_crypto_api_version,json=oemCryptoApiVersion" json:"oem_crypto_api_version,omitempty"`
+	AntiRollbackUsageTable     *bool                                                `protobuf:"varint,6,opt,name=anti_rollback_usage_table,json=antiRollbackUsageTable,def=0" json:"anti_rollback_usage_table,omitempty"`
+	SrmVersion                 *uint32                                              `protobuf:"varint,7,opt,name=srm_version,json=srmVersion" json:"srm_version,omitempty"`
+	CanUpdateSrm               *bool                                                `protobuf:"varint,8,opt,name=can_update_srm,json=canUpdateSrm,def=0" json:"can_update_srm,omitempty"`
+	ResourceRatingTier         *uint32                                              `protobuf:"varint,12,opt,name=resource_rating_tier,json=resourceRatingTier,def=0" json:"resource_rating_tier,omitempty"`
+}
+
+// Default values for ClientIdentification_ClientCapabilities fields.
+const (
+	Default_ClientIdentification_ClientCapabilities_ClientToken                = bool(false)
+	Default_ClientIdentification_ClientCapabilities_SessionToken               = bool(false)
+	Default_ClientIdentification_ClientCapabilities_VideoResolutionConstraints = bool(false)
+	Default_ClientIdentification_ClientCapabilities_MaxHdcpVersion             = ClientIdentification_ClientCapabilities_HDCP_NONE
+	Default_ClientIdentification_ClientCapabilities_AntiRollbackUsageTable     = bool(false)
+	Default_ClientIdentification_ClientCapabilities_CanUpdateSrm               = bool(false)
+	Default_ClientIdentification_ClientCapabilities_ResourceRatingTier         = uint32(0)
+)
+
+func (x *ClientIdentification_ClientCapabilities) Reset() {
+	*x = ClientIdentification_ClientCapabilities{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_license_protocol_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ClientIdentification_ClientCapabilities) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClientIdentification_ClientCapabilities) ProtoMessage() {}
+
+func (x *ClientIdentification_ClientCapabilities) ProtoReflect() protoreflect.Message {
+	mi := &file_license_protocol_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClientIdentification_ClientCapabilities.ProtoReflect.Descriptor instead.
+func (*ClientIdentification_ClientCapabilities) Descriptor() ([]byte, []int) {
+	return file_license_protocol_proto_rawDescGZIP(), []int{1, 1}
+}
+
+func (x *ClientIdentification_ClientCapabilities) GetClientToken() bool {
+	if x != nil && x.ClientToken != nil {
+		return *x.ClientToken
+	}
+	return Default_ClientIdentification_ClientCapabilities_ClientToken
+}
+
+func (x *ClientIdentification_ClientCapabilities) GetSessionToken() bool {
+	if x != nil && x.SessionToken != nil {
+		return *x.SessionToken
+	}
+	return Default_ClientIdentification_ClientCapabilities_SessionToken
+}
+
+func (x *ClientIdentification_ClientCapabilities) GetVideoResolutionConstraints() bool {
+	if x != nil && x.VideoResolutionConstraints != nil {
+		return *x.VideoResolutionConstraints
+	}
+	return Default_ClientIdentification_ClientCapabilities_VideoResolutionConstraints
+}
+
+func (x *ClientIdentification_ClientCapabilities) GetMaxHdcpVersion() ClientIdentification_ClientCapabilities_HdcpVersion {
+	if x != nil && x.MaxHdcpVersion != nil {
+		return *x.MaxHdcpVersion
+	}
+	return Default_ClientIdentification_ClientCapabilities_MaxHdcpVersion
+}
+
+func (x *ClientIdentification_ClientCapabilities) GetOemCryptoApiVersion() uint32 {
+	if x != nil && x.OemCryptoApiVersion != nil {
+		return *x.OemCryptoApiVersion
+	}
+	return 0
+}
+
+func (x *ClientIdentification_ClientCapabilities) GetAntiRollbackUsageTable() bool {
+	if x != nil && x.AntiRollbackUsageTable != nil {
+		return *x.AntiRollbackUsageTable
+	}
+	return Default_ClientIdentification_ClientCapabilities_AntiRollbackUsageTable
+}
+
+func (x *ClientIdentification_ClientCapabilities) GetSrmVersion() uint32 {
+	if x != nil && x.SrmVersion != nil {
+		return *x.SrmVersion
+	}
+	return 0
+}
+
+func (x *ClientIdentification_ClientCapabilities) GetCanUpdateSrm() bool {
+	if x != nil && x.CanUpdateSrm != nil {
+		return *x.CanUpdateSrm
+	}
+	return Default_ClientIdentification_ClientCapabilities_CanUpdateSrm
+}
+
+func (x *ClientIdentification_ClientCapabilities) GetResourceRatingTier() uint32 {
+	if x != nil && x.ResourceRatingTier != nil {
+		return *x.ResourceRatingTier
+	}
+	return Default_ClientIdentification_ClientCapabilities_ResourceRatingTier
+}
+
+type LicenseRequest_ContentIdentification struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Types that are assignable to ContentIdVariant:
+	//	*LicenseRequest_ContentIdentification_WidevinePsshData_
+	//	*LicenseRequest_ContentIdentification_WebmKeyId_
+	//	*LicenseRequest_ContentIdentification_ExistingLicense_
+	//	*LicenseRequest_ContentIdentification_InitData_
+	ContentIdVariant isLicenseRequest_ContentIdentification_ContentIdVariant `protobuf_oneof:"content_id_variant"`
+}
+
+func (x *LicenseRequest_ContentIdentification) Reset() {
+	*x = LicenseRequest_ContentIdentification{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_license_protocol_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *LicenseRequest_ContentIdentification) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LicenseRequest_ContentIdentification) ProtoMessage() {}
+
+func (x *LicenseRequest_ContentIdentification) ProtoReflect() protoreflect.Message {
+	mi := &file_license_protocol_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LicenseRequest_ContentIdentification.ProtoReflect.Descriptor instead.
+func (*LicenseRequest_ContentIdentification) Descriptor() ([]byte, []int) {
+	return file_license_protocol_proto_rawDescGZIP(), []int{3, 0}
+}
+
+func (m *LicenseRequest_ContentIdentification) GetContentIdVariant() isLicenseRequest_ContentIdentification_ContentIdVariant {
+	if m != nil {
+		return m.ContentIdVariant
+	}
+	return nil
+}
+
+func (x *LicenseRequest_ContentIdentification) GetWidevinePsshData() *LicenseRequest_ContentIdentification_WidevinePsshData {
+	if x, ok := x.GetContentIdVariant().(*LicenseRequest_ContentIdentification_WidevinePsshData_); ok {
+		return x.WidevinePsshData
+	}
+	return nil
+}
+
+func (x *LicenseRequest_ContentIdentification) GetWebmKeyId() *LicenseRequest_ContentIdentification_WebmKeyId {
+	if x, ok := x.GetContentIdVariant().(*LicenseRequest_ContentIdentification_WebmKeyId_); ok {
+		return x.WebmKeyId
+	}
+	return nil
+}
+
+func (x *LicenseRequest_ContentIdentification) GetExistingLicense() *LicenseRequest_ContentIdentification_ExistingLicense {
+	if x, ok := x.GetContentIdVariant().(*LicenseRequest_ContentIdentification_ExistingLicense_); ok {
+		return x.ExistingLicense
+	}
+	return nil
+}
+
+func (x *LicenseRequest_ContentIdentification) GetInitData() *LicenseRequest_ContentIdentification_InitData {
+	if x, ok := x.GetContentIdVariant().(*LicenseRequest_ContentIdentification_InitData_); ok {
+		return x.InitData
+	}
+	return nil
+}
+
+type isLicenseRequest_ContentIdentification_ContentIdVariant interface {
+	isLicenseRequest_ContentIdentification_ContentIdVariant()
+}
+
+type LicenseRequest_ContentIdentification_WidevinePsshData_ struct {
+	WidevinePsshData *LicenseRequest_ContentIdentification_WidevinePsshData `protobuf:"bytes,1,opt,name=widevine_pssh_data,json=widevinePsshData,oneof"`
+}
+
+type LicenseRequest_ContentIdentification_WebmKeyId_ struct {
+	WebmKeyId *LicenseRequest_ContentIdentification_WebmKeyId `protobuf:"bytes,2,opt,name=webm_key_id,json=webmKeyId,oneof"`
+}
+
+type LicenseRequest_ContentIdentification_ExistingLicense_ struct {
+	ExistingLicense *LicenseRequest_ContentIdentification_ExistingLicense `protobuf:"bytes,3,opt,name=existing_license,json=existingLicense,oneof"`
+}
+
+type LicenseRequest_ContentIdentification_InitData_ struct {
+	InitData *LicenseRequest_ContentIdentification_InitData `protobuf:"bytes,4,opt,name=init_data,json=initData,oneof"`
+}
+
+func (*LicenseRequest_ContentIdentification_WidevinePsshData_) isLicenseRequest_ContentIdentification_ContentIdVariant() {
+}
+
+func (*LicenseRequest_ContentIdentification_WebmKeyId_) isLicenseRequest_ContentIdentification_ContentIdVariant() {
+}
+
+func (*LicenseRequest_ContentIdentification_ExistingLicense_) isLicenseRequest_ContentIdentification_ContentIdVariant() {
+}
+
+func (*LicenseRequest_ContentIdentification_InitData_) isLicenseRequest_ContentIdentification_ContentIdVariant() {
+}
+
+type LicenseRequest_ContentIdentification_WidevinePsshData struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Data field of the Widevine pssh box, a serialized WidevineCencHeader.
+	PsshData    [][]byte     `protobuf:"bytes,1,rep,name=pssh_data,json=psshData" json:"pssh_data,omitempty"`
+	LicenseType *LicenseType `protobuf:"varint,2,opt,name=license_type,json=licenseType,enum=proto.LicenseType" json:"license_type,omitempty"`
+	RequestId   []byte       `protobuf:"bytes,3,opt,name=request_id,json=requestId" json:"request_id,omitempty"`
+}
+
+func (x *LicenseRequest_ContentIdentification_WidevinePsshData) Reset() {
+	*x = LicenseRequest_ContentIdentification_WidevinePsshData{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_license_protocol_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *LicenseRequest_ContentIdentification_WidevinePsshData) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LicenseRequest_ContentIdentification_WidevinePsshData) ProtoMessage() {}
+
+func (x *LicenseRequest_ContentIdentification_WidevinePsshData) ProtoReflect() protoreflect.Message {
+	mi := &file_license_protocol_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LicenseRequest_ContentIdentification_WidevinePsshData.ProtoReflect.Descriptor instead.
+func (*LicenseRequest_ContentIdentification_WidevinePsshData) Descriptor() ([]byte, []int) {
+	return file_license_protocol_proto_rawDescGZIP(), []int{3, 0, 0}
+}
+
+func (x *LicenseRequest_ContentIdentification_WidevinePsshData) GetPsshData() [][]byte {
+	if x != nil {
+		return x.PsshData
+	}
+	return nil
+}
+
+func (x *LicenseRequest_ContentIdentification_WidevinePsshData) GetLicenseType() LicenseType {
+	if x != nil && x.LicenseType != nil {
+		return *x.LicenseType
+	}
+	return LicenseType_STREAMING
+}
+
+func (x *LicenseRequest_ContentIdentification_WidevinePsshData) GetRequestId() []byte {
+	if x != nil {
+		return x.RequestId
+	}
+	return nil
+}
+
+type LicenseRequest_ContentIdentification_WebmKeyId struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Header      []byte       `protobuf:"bytes,1,opt,name=header" json:"header,omitempty"`
+	LicenseType *LicenseType `protobuf:"varint,2,opt,name=license_type,json=licenseType,enum=proto.LicenseType" json:"license_type,omitempty"`
+	RequestId   []byte       `protobuf:"bytes,3,opt,name=request_id,json=requestId" json:"request_id,omitempty"`
+}
+
+func (x *LicenseRequest_ContentIdentification_WebmKeyId) Reset() {
+	*x = LicenseRequest_ContentIdentification_WebmKeyId{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_license_protocol_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *LicenseRequest_ContentIdentification_WebmKeyId) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LicenseRequest_ContentIdentification_WebmKeyId) ProtoMessage() {}
+
+func (x *LicenseRequest_ContentIdentification_WebmKeyId) ProtoReflect() protoreflect.Message {
+	mi := &file_license_protocol_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LicenseRequest_ContentIdentification_WebmKeyId.ProtoReflect.Descriptor instead.
+func (*LicenseRequest_ContentIdentification_WebmKeyId) Descriptor() ([]byte, []int) {
+	return file_license_protocol_proto_rawDescGZIP(), []int{3, 0, 1}
+}
+
+func (x *LicenseRequest_ContentIdentification_WebmKeyId) GetHeader() []byte {
+	if x != nil {
+		return x.Header
+	}
+	return nil
+}
+
+func (x *LicenseRequest_ContentIdentification_WebmKeyId) GetLicenseType() LicenseType {
+	if x != nil && x.LicenseType != nil {
+		return *x.LicenseType
+	}
+	return LicenseType_STREAMING
+}
+
+func (x *LicenseRequest_ContentIdentification_WebmKeyId) GetRequestId() []byte {
+	if x != nil {
+		return x.RequestId
+	}
+	return nil
+}
+
+// Reference to a previously issued license, used by renewals and releases.
+type LicenseRequest_ContentIdentification_ExistingLicense struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	LicenseId              *LicenseIdentification `protobuf:"bytes,1,opt,name=license_id,json=licenseId" json:"license_id,omitempty"`
+	SecondsSinceStarted    *int64                 `protobuf:"varint,2,opt,name=seconds_since_started,json=secondsSinceStarted" json:"seconds_since_started,omitempty"`
+	SecondsSinceLastPlayed *int64                 `protobuf:"varint,3,opt,name=seconds_since_last_played,json=secondsSinceLastPlayed" json:"seconds_since_last_played,omitempty"`
+	SessionUsageTableEntry []byte                 `protobuf:"bytes,4,opt,name=session_usage_table_entry,json=sessionUsageTableEntry" json:"session_usage_table_entry,omitempty"`
+}
+
+func (x *LicenseRequest_ContentIdentification_ExistingLicense) Reset() {
+	*x = LicenseRequest_ContentIdentification_ExistingLicense{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_license_protocol_proto_msgTypes[10]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *LicenseRequest_ContentIdentification_ExistingLicense) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LicenseRequest_ContentIdentification_ExistingLicense) ProtoMessage() {}
+
+func (x *LicenseRequest_ContentIdentification_ExistingLicense) ProtoReflect() protoreflect.Message {
+	mi := &file_license_protocol_proto_msgTypes[10]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LicenseRequest_ContentIdentification_ExistingLicense.ProtoReflect.Descriptor instead.
+func (*LicenseRequest_ContentIdentification_ExistingLicense) Descriptor() ([]byte, []int) {
+	return file_license_protocol_proto_rawDescGZIP(), []int{3, 0, 2}
+}
+
+func (x *LicenseRequest_ContentIdentification_ExistingLicense) GetLicenseId() *LicenseIdentification {
+	if x != nil {
+		return x.LicenseId
+	}
+	return nil
+}
+
+func (x *LicenseRequest_ContentIdentification_ExistingLicense) GetSecondsSinceStarted() int64 {
+	if x != nil && x.SecondsSinceStarted != nil {
+		return *x.SecondsSinceStarted
+	}
+	return 0
+}
+
+func (x *LicenseRequest_ContentIdentification_ExistingLicense) GetSecondsSinceLastPlayed() int64 {
+	if x != nil && x.SecondsSinceLastPlayed != nil {
+		return *x.SecondsSinceLastPlayed
+	}
+	return 0
+}
+
+func (x *LicenseRequest_ContentIdentification_ExistingLicense) GetSessionUsageTableEntry() []byte {
+	if x != nil {
+		return x.SessionUsageTableEntry
+	}
+	return nil
+}
+
+type LicenseRequest_ContentIdentification_InitData struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	InitDataType *LicenseRequest_ContentIdentification_InitData_InitDataType `protobuf:"varint,1,opt,name=init_data_type,json=initDataType,enum=proto.LicenseRequest_ContentIdentification_InitData_InitDataType,def=1" json:"init_data_type,omitempty"`
+	// Complete pssh box(es) when init_data_type is CENC.
+	InitData    []byte       `protobuf:"bytes,2,opt,name=init_data,json=initData" json:"init_data,omitempty"`
+	LicenseType *LicenseType `protobuf:"varint,3,opt,name=license_type,json=licenseType,enum=proto.LicenseType" json:"license_type,omitempty"`
+	RequestId   []byte       `protobuf:"bytes,4,opt,name=request_id,json=requestId" json:"request_id,omitempty"`
+}
+
+// Default values for LicenseRequest_ContentIdentification_InitData fields.
+const (
+	Default_LicenseRequest_ContentIdentification_InitData_InitDataType = LicenseRequest_ContentIdentification_InitData_CENC
+)
+
+func (x *LicenseRequest_ContentIdentification_InitData) Reset() {
+	*x = LicenseRequest_ContentIdentification_InitData{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_license_protocol_proto_msgTypes[11]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *LicenseRequest_ContentIdentification_InitData) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*LicenseRequest_ContentIdentification_InitData) ProtoMessage() {}
+
+func (x *LicenseRequest_ContentIdentification_InitData) ProtoReflect() protoreflect.Message {
+	mi := &file_license_protocol_proto_msgTypes[11]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use LicenseRequest_ContentIdentification_InitData.ProtoReflect.Descriptor instead.
+func (*LicenseRequest_ContentIdentification_InitData) Descriptor() ([]byte, []int) {
+	return file_license_protocol_proto_rawDescGZIP(), []int{3, 0, 3}
+}
+
+func (x *LicenseRequest_ContentIdentification_InitData) GetInitDataType() LicenseRequest_ContentIdentification_InitData_InitDataType {
+	if x != nil && x.InitDataType != nil {
+		return *x.InitDataType
+	}
+	return Default_LicenseRequest_ContentIdentification_InitData_InitDataType
+}
+
+func (x *LicenseRequest_ContentIdentification_InitData) GetInitData() []byte {
+	if x != nil {
+		return x.InitData
+	}
+	return nil
+}
+
+func (x *LicenseRequest_ContentIdentification_InitData) GetLicenseType() LicenseType {
+	if x != nil && x.LicenseType != nil {
+		return *x.LicenseType
+	}
+	return LicenseType_STREAMING
+}
+
+func (x *LicenseRequest_ContentIdentification_InitData) GetRequestId() []byte {
+	if x != nil {
+		return x.RequestId
+	}
+	return nil
+}
+
+var File_license_protocol_proto protoreflect.FileDescriptor
+
+var file_license_protocol_proto_rawDesc = []byte{
+	0x0a, 0x16, 0x6c, 0x69, 0x63, 0x65, 0x6e, 0x73, 0x65, 0x5f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63,
+	0x6f, 0x6c, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x05, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22,
+	0xee, 0x01, 0x0a, 0x15, 0x4c, 0x69, 0x63, 0x65, 0x6e, 0x73, 0x65, 0x49, 0x64, 0x65, 0x6e, 0x74,
+	0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x1d, 0x0a, 0x0a, 0x72, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x09, 0x72,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x49, 0x64, 0x12, 0x1d, 0x0a, 0x0a, 0x73, 0x65, 0x73, 0x73,
+	0x69, 0x6f, 0x6e, 0x5f, 0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x09, 0x73, 0x65,
+	0x73, 0x73, 0x69, 0x6f, 0x6e, 0x49, 0x64, 0x12, 0x1f, 0x0a, 0x0b, 0x70, 0x75, 0x72, 0x63, 0x68,
+	0x61, 0x73, 0x65, 0x5f, 0x69, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x0a, 0x70, 0x75,
+	0x72, 0x63, 0x68, 0x61, 0x73, 0x65, 0x49, 0x64, 0x12, 0x26, 0x0a, 0x04, 0x74, 0x79, 0x70, 0x65,
+	0x18, 0x04, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x12, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x4c,
+	0x69, 0x63, 0x65, 0x6e, 0x73, 0x65, 0x54, 0x79, 0x70, 0x65, 0x52, 0x04, 0x74, 0x79, 0x70, 0x65,
+	0x12, 0x18, 0x0a, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x05, 0x20, 0x01, 0x28,
+	0x05, 0x52, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x34, 0x0a, 0x16, 0x70, 0x72,
+	0x6f, 0x76, 0x69, 0x64, 0x65, 0x72, 0x5f, 0x73, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x5f, 0x74,
+	0x6f, 0x6b, 0x65, 0x6e, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x14, 0x70, 0x72, 0x6f, 0x76,
+	0x69, 0x64, 0x65, 0x72, 0x53, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x54, 0x6f, 0x6b, 0x65, 0x6e,
+	0x22, 0xe0, 0x09, 0x0a, 0x14, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x49, 0x64, 0x65, 0x6e, 0x74,
+	0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x41, 0x0a, 0x04, 0x74, 0x79, 0x70,
+	0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x25, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e,
+	0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x49, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61,
+	0x74, 0x69, 0x6f, 0x6e, 0x2e, 0x54, 0x6f, 0x6b, 0x65, 0x6e, 0x54, 0x79, 0x70, 0x65, 0x3a, 0x06,
+	0x4b, 0x45, 0x59, 0x42, 0x4f, 0x58, 0x52, 0x04, 0x74, 0x79, 0x70, 0x65, 0x12, 0x14, 0x0a, 0x05,
+	0x74, 0x6f, 0x6b, 0x65, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x05, 0x74, 0x6f, 0x6b,
+	0x65, 0x6e, 0x12, 0x46, 0x0a, 0x0b, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x5f, 0x69, 0x6e, 0x66,
+	0x6f, 0x18, 0x03, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x25, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e,
+	0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x49, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61,
+	0x74, 0x69, 0x6f, 0x6e, 0x2e, 0x4e, 0x61, 0x6d, 0x65, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x52, 0x0a,
+	0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x49, 0x6e, 0x66, 0x6f, 0x12, 0x32, 0x0a, 0x15, 0x70, 0x72,
+	0x6f, 0x76, 0x69, 0x64, 0x65, 0x72, 0x5f, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x5f, 0x74, 0x6f,
+	0x6b, 0x65, 0x6e, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x13, 0x70, 0x72, 0x6f, 0x76, 0x69,
+	0x64, 0x65, 0x72, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x54, 0x6f, 0x6b, 0x65, 0x6e, 0x12, 0x27,
+	0x0a, 0x0f, 0x6c, 0x69, 0x63, 0x65, 0x6e, 0x73, 0x65, 0x5f, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x65,
+	0x72, 0x18, 0x05, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0e, 0x6c, 0x69, 0x63, 0x65, 0x6e, 0x73, 0x65,
+	0x43, 0x6f, 0x75, 0x6e, 0x74, 0x65, 0x72, 0x12, 0x5f, 0x0a, 0x13, 0x63, 0x6c, 0x69, 0x65, 0x6e,
+	0x74, 0x5f, 0x63, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x69, 0x65, 0x73, 0x18, 0x06,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x2e, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x43, 0x6c, 0x69,
+	0x65, 0x6e, 0x74, 0x49, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f,
+	0x6e, 0x2e, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x43, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c, 0x69,
+	0x74, 0x69, 0x65, 0x73, 0x52, 0x12, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x43, 0x61, 0x70, 0x61,
+	0x62, 0x69, 0x6c, 0x69, 0x74, 0x69, 0x65, 0x73, 0x12, 0x19, 0x0a, 0x08, 0x76, 0x6d, 0x70, 0x5f,
+	0x64, 0x61, 0x74, 0x61, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x07, 0x76, 0x6d, 0x70, 0x44,
+	0x61, 0x74, 0x61, 0x1a, 0x35, 0x0a, 0x09, 0x4e, 0x61, 0x6d, 0x65, 0x56, 0x61, 0x6c, 0x75, 0x65,
+	0x12, 0x12, 0x0a, 0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04,
+	0x6e, 0x61, 0x6d, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x1a, 0xa1, 0x05, 0x0a, 0x12, 0x43,
+	0x6c, 0x69, 0x65, 0x6e, 0x74, 0x43, 0x61, 0x70, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x69, 0x65,
+	0x73, 0x12, 0x28, 0x0a, 0x0c, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x5f, 0x74, 0x6f, 0x6b, 0x65,
+	0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x08, 0x3a, 0x05, 0x66, 0x61, 0x6c, 0x73, 0x65, 0x52, 0x0b,
+	0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x54, 0x6f, 0x6b, 0x65, 0x6e, 0x12, 0x2a, 0x0a, 0x0d, 0x73,
+	0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x5f, 0x74, 0x6f, 0x6b, 0x65, 0x6e, 0x18, 0x02, 0x20, 0x01,
+	0x28, 0x08, 0x3a, 0x05, 0x66, 0x61, 0x6c, 0x73, 0x65, 0x52, 0x0c, 0x73, 0x65, 0x73, 0x73, 0x69,
+	0x6f, 0x6e, 0x54, 0x6f, 0x6b, 0x65, 0x6e, 0x12, 0x47, 0x0a, 0x1c, 0x76, 0x69, 0x64, 0x65, 0x6f,
+	0x5f, 0x72, 0x65, 0x73, 0x6f, 0x6c, 0x75, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x63, 0x6f, 0x6e, 0x73,
+	0x74, 0x72, 0x61, 0x69, 0x6e, 0x74, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x3a, 0x05, 0x66,
+	0x61, 0x6c, 0x73, 0x65, 0x52, 0x1a, 0x76, 0x69, 0x64, 0x65, 0x6f, 0x52, 0x65, 0x73, 0x6f, 0x6c,
+	0x75, 0x74, 0x69, 0x6f, 0x6e, 0x43, 0x6f, 0x6e, 0x73, 0x74, 0x72, 0x61, 0x69, 0x6e, 0x74, 0x73,
+	0x12, 0x6f, 0x0a, 0x10, 0x6d, 0x61, 0x78, 0x5f, 0x68, 0x64, 0x63, 0x70, 0x5f, 0x76, 0x65, 0x72,
+	0x73, 0x69, 0x6f, 0x6e, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x3a, 0x2e, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x2e, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x49, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x66,
+	0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x2e, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x43, 0x61,
+	0x70, 0x61, 0x62, 0x69, 0x6c, 0x69, 0x74, 0x69, 0x65, 0x73, 0x2e, 0x48, 0x64, 0x63, 0x70, 0x56,
+	0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x3a, 0x09, 0x48, 0x44, 0x43, 0x50, 0x5f, 0x4e, 0x4f, 0x4e,
+	0x45, 0x52, 0x0e, 0x6d, 0x61, 0x78, 0x48, 0x64, 0x63, 0x70, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f,
+	0x6e, 0x12, 0x33, 0x0a, 0x16, 0x6f, 0x65, 0x6d, 0x5f, 0x63, 0x72, 0x79, 0x70, 0x74, 0x6f, 0x5f,
+	0x61, 0x70, 0x69, 0x5f, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x05, 0x20, 0x01, 0x28,
+	0x0d, 0x52, 0x13, 0x6f, 0x65, 0x6d, 0x43, 0x72, 0x79, 0x70, 0x74, 0x6f, 0x41, 0x70, 0x69, 0x56,
+	0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x40, 0x0a, 0x19, 0x61, 0x6e, 0x74, 0x69, 0x5f, 0x72,
+	0x6f, 0x6c, 0x6c, 0x62, 0x61, 0x63, 0x6b, 0x5f, 0x75, 0x73, 0x61, 0x67, 0x65, 0x5f, 0x74, 0x61,
+	0x62, 0x6c, 0x65, 0x18, 0x06, 0x20, 0x01, 0x28, 0x08, 0x3a, 0x05, 0x66, 0x61, 0x6c, 0x73, 0x65,
+	0x52, 0x16, 0x61, 0x6e, 0x74, 0x69, 0x52, 0x6f, 0x6c, 0x6c, 0x62, 0x61, 0x63, 0x6b, 0x55, 0x73,
+	0x61, 0x67, 0x65, 0x54, 0x61, 0x62, 0x6c, 0x65, 0x12, 0x1f, 0x0a, 0x0b, 0x73, 0x72, 0x6d, 0x5f,
+	0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0a, 0x73,
+	0x72, 0x6d, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x2b, 0x0a, 0x0e, 0x63, 0x61, 0x6e,
+	0x5f, 0x75, 0x70, 0x64, 0x61, 0x74, 0x65, 0x5f, 0x73, 0x72, 0x6d, 0x18, 0x08, 0x20, 0x01, 0x28,
+	0x08, 0x3a, 0x05, 0x66, 0x61, 0x6c, 0x73, 0x65, 0x52, 0x0c, 0x63, 0x61, 0x6e, 0x55, 0x70, 0x64,
+	0x61, 0x74, 0x65, 0x53, 0x72, 0x6d, 0x12, 0x33, 0x0a, 0x14, 0x72, 0x65, 0x73, 0x6f, 0x75, 0x72,
+	0x63, 0x65, 0x5f, 0x72, 0x61, 0x74, 0x69, 0x6e, 0x67, 0x5f, 0x74, 0x69, 0x65, 0x72, 0x18, 0x0c,
+	0x20, 0x01, 0x28, 0x0d, 0x3a, 0x01, 0x30, 0x52, 0x12, 0x72, 0x65, 0x73, 0x6f, 0x75, 0x72, 0x63,
+	0x65, 0x52, 0x61, 0x74, 0x69, 0x6e, 0x67, 0x54, 0x69, 0x65, 0x72, 0x22, 0x80, 0x01, 0x0a, 0x0b,
+	0x48, 0x64, 0x63, 0x70, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x0d, 0x0a, 0x09, 0x48,
+	0x44, 0x43, 0x50, 0x5f, 0x4e, 0x4f, 0x4e, 0x45, 0x10, 0x00, 0x12, 0x0b, 0x0a, 0x07, 0x48, 0x44,
+	0x43, 0x50, 0x5f, 0x56, 0x31, 0x10, 0x01, 0x12, 0x0b, 0x0a, 0x07, 0x48, 0x44, 0x43, 0x50, 0x5f,
+	0x56, 0x32, 0x10, 0x02, 0x12, 0x0d, 0x0a, 0x09, 0x48, 0x44, 0x43, 0x50, 0x5f, 0x56, 0x32, 0x5f,
+	0x31, 0x10, 0x03, 0x12, 0x0d, 0x0a, 0x09, 0x48, 0x44, 0x43, 0x50, 0x5f, 0x56, 0x32, 0x5f, 0x32,
+	0x10, 0x04, 0x12, 0x0d, 0x0a, 0x09, 0x48, 0x44, 0x43, 0x50, 0x5f, 0x56, 0x32, 0x5f, 0x33, 0x10,
+	0x05, 0x12, 0x1b, 0x0a, 0x16, 0x48, 0x44, 0x43, 0x50, 0x5f, 0x4e, 0x4f, 0x5f, 0x44, 0x49, 0x47,
+	0x49, 0x54, 0x41, 0x4c, 0x5f, 0x4f, 0x55, 0x54, 0x50, 0x55, 0x54, 0x10, 0xff, 0x01, 0x22, 0x73,
+	0x0a, 0x09, 0x54, 0x6f, 0x6b, 0x65, 0x6e, 0x54, 0x79, 0x70, 0x65, 0x12, 0x0a, 0x0a, 0x06, 0x4b,
+	0x45, 0x59, 0x42, 0x4f, 0x58, 0x10, 0x00, 0x12, 0x1a, 0x0a, 0x16, 0x44, 0x52, 0x4d, 0x5f, 0x44,
+	0x45, 0x56, 0x49, 0x43, 0x45, 0x5f, 0x43, 0x45, 0x52, 0x54, 0x49, 0x46, 0x49, 0x43, 0x41, 0x54,
+	0x45, 0x10, 0x01, 0x12, 0x22, 0x0a, 0x1e, 0x52, 0x45, 0x4d, 0x4f, 0x54, 0x45, 0x5f, 0x41, 0x54,
+	0x54, 0x45, 0x53, 0x54, 0x41, 0x54, 0x49, 0x4f, 0x4e, 0x5f, 0x43, 0x45, 0x52, 0x54, 0x49, 0x46,
+	0x49, 0x43, 0x41, 0x54, 0x45, 0x10, 0x02, 0x12, 0x1a, 0x0a, 0x16, 0x4f, 0x45, 0x4d, 0x5f, 0x44,
+	0x45, 0x56, 0x49, 0x43, 0x45, 0x5f, 0x43, 0x45, 0x52, 0x54, 0x49, 0x46, 0x49, 0x43, 0x41, 0x54,
+	0x45, 0x10, 0x03, 0x22, 0xa4, 0x02, 0x0a, 0x1d, 0x45, 0x6e, 0x63, 0x72, 0x79, 0x70, 0x74, 0x65,
+	0x64, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x49, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x66, 0x69, 0x63,
+	0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x1f, 0x0a, 0x0b, 0x70, 0x72, 0x6f, 0x76, 0x69, 0x64, 0x65,
+	0x72, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x70, 0x72, 0x6f, 0x76,
+	0x69, 0x64, 0x65, 0x72, 0x49, 0x64, 0x12, 0x49, 0x0a, 0x21, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63,
+	0x65, 0x5f, 0x63, 0x65, 0x72, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x65, 0x5f, 0x73, 0x65,
+	0x72, 0x69, 0x61, 0x6c, 0x5f, 0x6e, 0x75, 0x6d, 0x62, 0x65, 0x72, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x0c, 0x52, 0x1e, 0x73, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x43, 0x65, 0x72, 0x74, 0x69, 0x66,
+	0x69, 0x63, 0x61, 0x74, 0x65, 0x53, 0x65, 0x72, 0x69, 0x61, 0x6c, 0x4e, 0x75, 0x6d, 0x62, 0x65,
+	0x72, 0x12, 0x2e, 0x0a, 0x13, 0x65, 0x6e, 0x63, 0x72, 0x79, 0x70, 0x74, 0x65, 0x64, 0x5f, 0x63,
+	0x6c, 0x69, 0x65, 0x6e, 0x74, 0x5f, 0x69, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x11,
+	0x65, 0x6e, 0x63, 0x72, 0x79, 0x70, 0x74, 0x65, 0x64, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x49,
+	0x64, 0x12, 0x33, 0x0a, 0x16, 0x65, 0x6e, 0x63, 0x72, 0x79, 0x70, 0x74, 0x65, 0x64, 0x5f, 0x63,
+	0x6c, 0x69, 0x65, 0x6e, 0x74, 0x5f, 0x69, 0x64, 0x5f, 0x69, 0x76, 0x18, 0x04, 0x20, 0x01, 0x28,
+	0x0c, 0x52, 0x13, 0x65, 0x6e, 0x63, 0x72, 0x79, 0x70, 0x74, 0x65, 0x64, 0x43, 0x6c, 0x69, 0x65,
+	0x6e, 0x74, 0x49, 0x64, 0x49, 0x76, 0x12, 0x32, 0x0a, 0x15, 0x65, 0x6e, 0x63, 0x72, 0x79, 0x70,
+	0x74, 0x65, 0x64, 0x5f, 0x70, 0x72, 0x69, 0x76, 0x61, 0x63, 0x79, 0x5f, 0x6b, 0x65, 0x79, 0x18,
+	0x05, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x13, 0x65, 0x6e, 0x63, 0x72, 0x79, 0x70, 0x74, 0x65, 0x64,
+	0x50, 0x72, 0x69, 0x76, 0x61, 0x63, 0x79, 0x4b, 0x65, 0x79, 0x22, 0xfc, 0x0d, 0x0a, 0x0e, 0x4c,
+	0x69, 0x63, 0x65, 0x6e, 0x73, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x38, 0x0a,
+	0x09, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b,
+	0x32, 0x1b, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x49,
+	0x64, 0x65, 0x6e, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x08, 0x63,
+	0x6c, 0x69, 0x65, 0x6e, 0x74, 0x49, 0x64, 0x12, 0x4a, 0x0a, 0x0a, 0x63, 0x6f, 0x6e, 0x74, 0x65,
+	0x6e, 0x74, 0x5f, 0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x2b, 0x2e, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x2e, 0x4c, 0x69, 0x63, 0x65, 0x6e, 0x73, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x2e, 0x43, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x49, 0x64, 0x65, 0x6e, 0x74, 0x69,
+	0x66, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x09, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e,
+	0x74, 0x49, 0x64, 0x12, 0x35, 0x0a, 0x04, 0x74, 0x79, 0x70, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28,
+	0x0e, 0x32, 0x21, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x4c, 0x69, 0x63, 0x65, 0x6e, 0x73,
+	0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x2e, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x54, 0x79, 0x70, 0x65, 0x52, 0x04, 0x74, 0x79, 0x70, 0x65, 0x12, 0x21, 0x0a, 0x0c, 0x72, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x03,
+	0x52, 0x0b, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x54, 0x69, 0x6d, 0x65, 0x12, 0x3f, 0x0a,
+	0x1c, 0x6b, 0x65, 0x79, 0x5f, 0x63, 0x6f, 0x6e, 0x74, 0x72, 0x6f, 0x6c, 0x5f, 0x6e, 0x6f, 0x6e,
+	0x63, 0x65, 0x5f, 0x64, 0x65, 0x70, 0x72, 0x65, 0x63, 0x61, 0x74, 0x65, 0x64, 0x18, 0x05, 0x20,
+	0x01, 0x28, 0x0c, 0x52, 0x19, 0x6b, 0x65, 0x79, 0x43, 0x6f, 0x6e, 0x74, 0x72, 0x6f, 0x6c, 0x4e,
+	0x6f, 0x6e, 0x63, 0x65, 0x44, 0x65, 0x70, 0x72, 0x65, 0x63, 0x61, 0x74, 0x65, 0x64, 0x12, 0x4e,
+	0x0a, 0x10, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x5f, 0x76, 0x65, 0x72, 0x73, 0x69,
+	0x6f, 0x6e, 0x18, 0x06, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x16, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x2e, 0x50, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e,
+	0x3a, 0x0b, 0x56, 0x45, 0x52, 0x53, 0x49, 0x4f, 0x4e, 0x5f, 0x32, 0x5f, 0x30, 0x52, 0x0f, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x2a,
+	0x0a, 0x11, 0x6b, 0x65, 0x79, 0x5f, 0x63, 0x6f, 0x6e, 0x74, 0x72, 0x6f, 0x6c, 0x5f, 0x6e, 0x6f,
+	0x6e, 0x63, 0x65, 0x18, 0x07, 0x20, 0x01, 0x28, 0x0d, 0x52, 0x0f, 0x6b, 0x65, 0x79, 0x43, 0x6f,
+	0x6e, 0x74, 0x72, 0x6f, 0x6c, 0x4e, 0x6f, 0x6e, 0x63, 0x65, 0x12, 0x54, 0x0a, 0x13, 0x65, 0x6e,
+	0x63, 0x72, 0x79, 0x70, 0x74, 0x65, 0x64, 0x5f, 0x63, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x5f, 0x69,
+	0x64, 0x18, 0x08, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x24, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e,
+	0x45, 0x6e, 0x63, 0x72, 0x79, 0x70, 0x74, 0x65, 0x64, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x49,
+	0x64, 0x65, 0x6e, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x11, 0x65,
+	0x6e, 0x63, 0x72, 0x79, 0x70, 0x74, 0x65, 0x64, 0x43, 0x6c, 0x69, 0x65, 0x6e, 0x74, 0x49, 0x64,
+	0x1a, 0xc4, 0x09, 0x0a, 0x15, 0x43, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x49, 0x64, 0x65, 0x6e,
+	0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x6c, 0x0a, 0x12, 0x77, 0x69,
+	0x64, 0x65, 0x76, 0x69, 0x6e, 0x65, 0x5f, 0x70, 0x73, 0x73, 0x68, 0x5f, 0x64, 0x61, 0x74, 0x61,
+	0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x3c, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x4c,
+	0x69, 0x63, 0x65, 0x6e, 0x73, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x2e, 0x43, 0x6f,
+	0x6e, 0x74, 0x65, 0x6e, 0x74, 0x49, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74,
+	0x69, 0x6f, 0x6e, 0x2e, 0x57, 0x69, 0x64, 0x65, 0x76, 0x69, 0x6e, 0x65, 0x50, 0x73, 0x73, 0x68,
+	0x44, 0x61, 0x74, 0x61, 0x48, 0x00, 0x52, 0x10, 0x77, 0x69, 0x64, 0x65, 0x76, 0x69, 0x6e, 0x65,
+	0x50, 0x73, 0x73, 0x68, 0x44, 0x61, 0x74, 0x61, 0x12, 0x57, 0x0a, 0x0b, 0x77, 0x65, 0x62, 0x6d,
+	0x5f, 0x6b, 0x65, 0x79, 0x5f, 0x69, 0x64, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x35, 0x2e,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x4c, 0x69, 0x63, 0x65, 0x6e, 0x73, 0x65, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x2e, 0x43, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x49, 0x64, 0x65, 0x6e,
+	0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x2e, 0x57, 0x65, 0x62, 0x6d, 0x4b,
+	0x65, 0x79, 0x49, 0x64, 0x48, 0x00, 0x52, 0x09, 0x77, 0x65, 0x62, 0x6d, 0x4b, 0x65, 0x79, 0x49,
+	0x64, 0x12, 0x68, 0x0a, 0x10, 0x65, 0x78, 0x69, 0x73, 0x74, 0x69, 0x6e, 0x67, 0x5f, 0x6c, 0x69,
+	0x63, 0x65, 0x6e, 0x73, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x3b, 0x2e, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x2e, 0x4c, 0x69, 0x63, 0x65, 0x6e, 0x73, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x2e, 0x43, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x49, 0x64, 0x65, 0x6e, 0x74, 0x69,
+	0x66, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x2e, 0x45, 0x78, 0x69, 0x73, 0x74, 0x69, 0x6e,
+	0x67, 0x4c, 0x69, 0x63, 0x65, 0x6e, 0x73, 0x65, 0x48, 0x00, 0x52, 0x0f, 0x65, 0x78, 0x69, 0x73,
+	0x74, 0x69, 0x6e, 0x67, 0x4c, 0x69, 0x63, 0x65, 0x6e, 0x73, 0x65, 0x12, 0x53, 0x0a, 0x09, 0x69,
+	0x6e, 0x69, 0x74, 0x5f, 0x64, 0x61, 0x74, 0x61, 0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x34,
+	0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x4c, 0x69, 0x63, 0x65, 0x6e, 0x73, 0x65, 0x52, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x2e, 0x43, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x49, 0x64, 0x65,
+	0x6e, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x2e, 0x49, 0x6e, 0x69, 0x74,
+	0x44, 0x61, 0x74, 0x61, 0x48, 0x00, 0x52, 0x08, 0x69, 0x6e, 0x69, 0x74, 0x44, 0x61, 0x74, 0x61,
+	0x1a, 0x85, 0x01, 0x0a, 0x10, 0x57, 0x69, 0x64, 0x65, 0x76, 0x69, 0x6e, 0x65, 0x50, 0x73, 0x73,
+	0x68, 0x44, 0x61, 0x74, 0x61, 0x12, 0x1b, 0x0a, 0x09, 0x70, 0x73, 0x73, 0x68, 0x5f, 0x64, 0x61,
+	0x74, 0x61, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0c, 0x52, 0x08, 0x70, 0x73, 0x73, 0x68, 0x44, 0x61,
+	0x74, 0x61, 0x12, 0x35, 0x0a, 0x0c, 0x6c, 0x69, 0x63, 0x65, 0x6e, 0x73, 0x65, 0x5f, 0x74, 0x79,
+	0x70, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x12, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f,
+	0x2e, 0x4c, 0x69, 0x63, 0x65, 0x6e, 0x73, 0x65, 0x54, 0x79, 0x70, 0x65, 0x52, 0x0b, 0x6c, 0x69,
+	0x63, 0x65, 0x6e, 0x73, 0x65, 0x54, 0x79, 0x70, 0x65, 0x12, 0x1d, 0x0a, 0x0a, 0x72, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x5f, 0x69, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x09, 0x72,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x49, 0x64, 0x1a, 0x79, 0x0a, 0x09, 0x57, 0x65, 0x62, 0x6d,
+	0x4b, 0x65, 0x79, 0x49, 0x64, 0x12, 0x16, 0x0a, 0x06, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x06, 0x68, 0x65, 0x61, 0x64, 0x65, 0x72, 0x12, 0x35, 0x0a,
+	0x0c, 0x6c, 0x69, 0x63, 0x65, 0x6e, 0x73, 0x65, 0x5f, 0x74, 0x79, 0x70, 0x65, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x0e, 0x32, 0x12, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x4c, 0x69, 0x63, 0x65,
+	0x6e, 0x73, 0x65, 0x54, 0x79, 0x70, 0x65, 0x52, 0x0b, 0x6c, 0x69, 0x63, 0x65, 0x6e, 0x73, 0x65,
+	0x54, 0x79, 0x70, 0x65, 0x12, 0x1d, 0x0a, 0x0a, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x5f,
+	0x69, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x09, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x49, 0x64, 0x1a, 0xf8, 0x01, 0x0a, 0x0f, 0x45, 0x78, 0x69, 0x73, 0x74, 0x69, 0x6e, 0x67,
+	0x4c, 0x69, 0x63, 0x65, 0x6e, 0x73, 0x65, 0x12, 0x3b, 0x0a, 0x0a, 0x6c, 0x69, 0x63, 0x65, 0x6e,
+	0x73, 0x65, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1c, 0x2e, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x2e, 0x4c, 0x69, 0x63, 0x65, 0x6e, 0x73, 0x65, 0x49, 0x64, 0x65, 0x6e, 0x74,
+	0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x09, 0x6c, 0x69, 0x63, 0x65, 0x6e,
+	0x73, 0x65, 0x49, 0x64, 0x12, 0x32, 0x0a, 0x15, 0x73, 0x65, 0x63, 0x6f, 0x6e, 0x64, 0x73, 0x5f,
+	0x73, 0x69, 0x6e, 0x63, 0x65, 0x5f, 0x73, 0x74, 0x61, 0x72, 0x74, 0x65, 0x64, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x03, 0x52, 0x13, 0x73, 0x65, 0x63, 0x6f, 0x6e, 0x64, 0x73, 0x53, 0x69, 0x6e, 0x63,
+	0x65, 0x53, 0x74, 0x61, 0x72, 0x74, 0x65, 0x64, 0x12, 0x39, 0x0a, 0x19, 0x73, 0x65, 0x63, 0x6f,
+	0x6e, 0x64, 0x73, 0x5f, 0x73, 0x69, 0x6e, 0x63, 0x65, 0x5f, 0x6c, 0x61, 0x73, 0x74, 0x5f, 0x70,
+	0x6c, 0x61, 0x79, 0x65, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x16, 0x73, 0x65, 0x63,
+	0x6f, 0x6e, 0x64, 0x73, 0x53, 0x69, 0x6e, 0x63, 0x65, 0x4c, 0x61, 0x73, 0x74, 0x50, 0x6c, 0x61,
+	0x79, 0x65, 0x64, 0x12, 0x39, 0x0a, 0x19, 0x73, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x5f, 0x75,
+	0x73, 0x61, 0x67, 0x65, 0x5f, 0x74, 0x61, 0x62, 0x6c, 0x65, 0x5f, 0x65, 0x6e, 0x74, 0x72, 0x79,
+	0x18, 0x04, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x16, 0x73, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x55,
+	0x73, 0x61, 0x67, 0x65, 0x54, 0x61, 0x62, 0x6c, 0x65, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x1a, 0x90,
+	0x02, 0x0a, 0x08, 0x49, 0x6e, 0x69, 0x74, 0x44, 0x61, 0x74, 0x61, 0x12, 0x6d, 0x0a, 0x0e, 0x69,
+	0x6e, 0x69, 0x74, 0x5f, 0x64, 0x61, 0x74, 0x61, 0x5f, 0x74, 0x79, 0x70, 0x65, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x0e, 0x32, 0x41, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x4c, 0x69, 0x63, 0x65,
+	0x6e, 0x73, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x2e, 0x43, 0x6f, 0x6e, 0x74, 0x65,
+	0x6e, 0x74, 0x49, 0x64, 0x65, 0x6e, 0x74, 0x69, 0x66, 0x69, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e,
+	0x2e, 0x49, 0x6e, 0x69, 0x74, 0x44, 0x61, 0x74, 0x61, 0x2e, 0x49, 0x6e, 0x69, 0x74, 0x44, 0x61,
+	0x74, 0x61, 0x54, 0x79, 0x70, 0x65, 0x3a, 0x04, 0x43, 0x45, 0x4e, 0x43, 0x52, 0x0c, 0x69, 0x6e,
+	0x69, 0x74, 0x44, 0x61, 0x74, 0x61, 0x54, 0x79, 0x70, 0x65, 0x12, 0x1b, 0x0a, 0x09, 0x69, 0x6e,
+	0x69, 0x74, 0x5f, 0x64, 0x61, 0x74, 0x61, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x08, 0x69,
+	0x6e, 0x69, 0x74, 0x44, 0x61, 0x74, 0x61, 0x12, 0x35, 0x0a, 0x0c, 0x6c, 0x69, 0x63, 0x65, 0x6e,
+	0x73, 0x65, 0x5f, 0x74, 0x79, 0x70, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x12, 0x2e,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2e, 0x4c, 0x69, 0x63, 0x65, 0x6e, 0x73, 0x65, 0x54, 0x79, 0x70,
+	0x65, 0x52, 0x0b, 0x6c, 0x69, 0x63, 0x65, 0x6e, 0x73, 0x65, 0x54, 0x79, 0x70, 0x65, 0x12, 0x1d,
+	0x0a, 0x0a, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x5f, 0x69, 0x64, 0x18, 0x04, 0x20, 0x01,
+	0x28, 0x0c, 0x52, 0x09, 0x72, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x49, 0x64, 0x22, 0x22, 0x0a,
+	0x0c, 0x49, 0x6e, 0x69, 0x74, 0x44, 0x61, 0x74, 0x61, 0x54, 0x79, 0x70, 0x65, 0x12, 0x08, 0x0a,
+	0x04, 0x43, 0x45, 0x4e, 0x43, 0x10, 0x01, 0x12, 0x08, 0x0a, 0x04, 0x57, 0x45, 0x42, 0x4d, 0x10,
+	0x02, 0x42, 0x14, 0x0a, 0x12, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x5f, 0x69, 0x64, 0x5f,
+	0x76, 0x61, 0x72, 0x69, 0x61, 0x6e, 0x74, 0x22, 0x30, 0x0a, 0x0b, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x54, 0x79, 0x70, 0x65, 0x12, 0x07, 0x0a, 0x03, 0x4e, 0x45, 0x57, 0x10, 0x01, 0x12,
+	0x0b, 0x0a, 0x07, 0x52, 0x45, 0x4e, 0x45, 0x57, 0x41, 0x4c, 0x10, 0x02, 0x12, 0x0b, 0x0a, 0x07,
+	0x52, 0x45, 0x4c, 0x45, 0x41, 0x53, 0x45, 0x10, 0x03, 0x22, 0xbb, 0x03, 0x0a, 0x0d, 0x53, 0x69,
+	0x67, 0x6e, 0x65, 0x64, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x12, 0x34, 0x0a, 0x04, 0x74,
+	0x79, 0x70, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0e, 0x32, 0x20, 0x2e, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x2e, 0x53, 0x69, 0x67, 0x6e, 0x65, 0x64, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x2e,
+	0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x54, 0x79, 0x70, 0x65, 0x52, 0x04, 0x74, 0x79, 0x70,
+	0x65, 0x12, 0x10, 0x0a, 0x03, 0x6d, 0x73, 0x67, 0x18, 0x02, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x03,
+	0x6d, 0x73, 0x67, 0x12, 0x1c, 0x0a, 0x09, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65,
+	0x18, 0x03, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x09, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72,
+	0x65, 0x12, 0x1f, 0x0a, 0x0b, 0x73, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x5f, 0x6b, 0x65, 0x79,
+	0x18, 0x04, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x0a, 0x73, 0x65, 0x73, 0x73, 0x69, 0x6f, 0x6e, 0x4b,
+	0x65, 0x79, 0x12, 0x34, 0x0a, 0x16, 0x6f, 0x65, 0x6d, 0x63, 0x72, 0x79, 0x70, 0x74, 0x6f, 0x5f,
+	0x63, 0x6f, 0x72, 0x65, 0x5f, 0x6d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x18, 0x09, 0x20, 0x01,
+	0x28, 0x0c, 0x52, 0x14, 0x6f, 0x65, 0x6d, 0x63, 0x72, 0x79, 0x70, 0x74, 0x6f, 0x43, 0x6f, 0x72,
+	0x65, 0x4d, 0x65, 0x73, 0x73, 0x61, 0x67, 0x65, 0x22, 0xec, 0x01, 0x0a, 0x0b, 0x4d, 0x65, 0x73,
+	0x73, 0x61, 0x67, 0x65, 0x54, 0x79, 0x70, 0x65, 0x12, 0x13, 0x0a, 0x0f, 0x4c, 0x49, 0x43, 0x45,
+	0x4e, 0x53, 0x45, 0x5f, 0x52, 0x45, 0x51, 0x55, 0x45, 0x53, 0x54, 0x10, 0x01, 0x12, 0x0b, 0x0a,
+	0x07, 0x4c, 0x49, 0x43, 0x45, 0x4e, 0x53, 0x45, 0x10, 0x02, 0x12, 0x12, 0x0a, 0x0e, 0x45, 0x52,
+	0x52, 0x4f, 0x52, 0x5f, 0x52, 0x45, 0x53, 0x50, 0x4f, 0x4e, 0x53, 0x45, 0x10, 0x03, 0x12, 0x1f,
+	0x0a, 0x1b, 0x53, 0x45, 0x52, 0x56, 0x49, 0x43, 0x45, 0x5f, 0x43, 0x45, 0x52, 0x54, 0x49, 0x46,
+	0x49, 0x43, 0x41, 0x54, 0x45, 0x5f, 0x52, 0x45, 0x51, 0x55, 0x45, 0x53, 0x54, 0x10, 0x04, 0x12,
+	0x17, 0x0a, 0x13, 0x53, 0x45, 0x52, 0x56, 0x49, 0x43, 0x45, 0x5f, 0x43, 0x45, 0x52, 0x54, 0x49,
+	0x46, 0x49, 0x43, 0x41, 0x54, 0x45, 0x10, 0x05, 0x12, 0x0f, 0x0a, 0x0b, 0x53, 0x55, 0x42, 0x5f,
+	0x4c, 0x49, 0x43, 0x45, 0x4e, 0x53, 0x45, 0x10, 0x06, 0x12, 0x17, 0x0a, 0x13, 0x43, 0x41, 0x53,
+	0x5f, 0x4c, 0x49, 0x43, 0x45, 0x4e, 0x53, 0x45, 0x5f, 0x52, 0x45, 0x51, 0x55, 0x45, 0x53, 0x54,
+	0x10, 0x07, 0x12, 0x0f, 0x0a, 0x0b, 0x43, 0x41, 0x53, 0x5f, 0x4c, 0x49, 0x43, 0x45, 0x4e, 0x53,
+	0x45, 0x10, 0x08, 0x12, 0x1c, 0x0a, 0x18, 0x45, 0x58, 0x54, 0x45, 0x52, 0x4e, 0x41, 0x4c, 0x5f,
+	0x4c, 0x49, 0x43, 0x45, 0x4e, 0x53, 0x45, 0x5f, 0x52, 0x45, 0x51, 0x55, 0x45, 0x53, 0x54, 0x10,
+	0x09, 0x12, 0x14, 0x0a, 0x10, 0x45, 0x58, 0x54, 0x45, 0x52, 0x4e, 0x41, 0x4c, 0x5f, 0x4c, 0x49,
+	0x43, 0x45, 0x4e, 0x53, 0x45, 0x10, 0x0a, 0x2a, 0x38, 0x0a, 0x0b, 0x4c, 0x69, 0x63, 0x65, 0x6e,
+	0x73, 0x65, 0x54, 0x79, 0x70, 0x65, 0x12, 0x0d, 0x0a, 0x09, 0x53, 0x54, 0x52, 0x45, 0x41, 0x4d,
+	0x49, 0x4e, 0x47, 0x10, 0x01, 0x12, 0x0b, 0x0a, 0x07, 0x4f, 0x46, 0x46, 0x4c, 0x49, 0x4e, 0x45,
+	0x10, 0x02, 0x12, 0x0d, 0x0a, 0x09, 0x41, 0x55, 0x54, 0x4f, 0x4d, 0x41, 0x54, 0x49, 0x43, 0x10,
+	0x03, 0x2a, 0x44, 0x0a, 0x0f, 0x50, 0x72, 0x6f, 0x74, 0x6f, 0x63, 0x6f, 0x6c, 0x56, 0x65, 0x72,
+	0x73, 0x69, 0x6f, 0x6e, 0x12, 0x0f, 0x0a, 0x0b, 0x56, 0x45, 0x52, 0x53, 0x49, 0x4f, 0x4e, 0x5f,
+	0x32, 0x5f, 0x30, 0x10, 0x14, 0x12, 0x0f, 0x0a, 0x0b, 0x56, 0x45, 0x52, 0x53, 0x49, 0x4f, 0x4e,
+	0x5f, 0x32, 0x5f, 0x31, 0x10, 0x15, 0x12, 0x0f, 0x0a, 0x0b, 0x56, 0x45, 0x52, 0x53, 0x49, 0x4f,
+	0x4e, 0x5f, 0x32, 0x5f, 0x32, 0x10, 0x16, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x32,
+}
+
+var (
+	file_license_protocol_proto_rawDescOnce sync.Once
+	file_license_protocol_proto_rawDescData = file_license_protocol_proto_rawDesc
+)
+
+func file_license_protocol_proto_rawDescGZIP() []byte {
+	file_license_protocol_proto_rawDescOnce.Do(func() {
+		file_license_protocol_proto_rawDescData = protoimpl.X.CompressGZIP(file_license_protocol_proto_rawDescData)
+	})
+	return file_license_protocol_proto_rawDescData
+}
+
+var file_license_protocol_proto_enumTypes = make([]protoimpl.EnumInfo, 7)
+var file_license_protocol_proto_msgTypes = make([]protoimpl.MessageInfo, 12)
+var file_license_protocol_proto_goTypes = []interface{}{
+	(LicenseType)(0),                                                // 0: proto.LicenseType
+	(ProtocolVersion)(0),                                            // 1: proto.ProtocolVersion
+	(ClientIdentification_TokenType)(0),                             // 2: proto.ClientIdentification.TokenType
+	(ClientIdentification_ClientCapabilities_HdcpVersion)(0),        // 3: proto.ClientIdentification.ClientCapabilities.HdcpVersion
+	(LicenseRequest_RequestType)(0),                                 // 4: proto.LicenseRequest.RequestType
+	(LicenseRequest_ContentIdentification_InitData_InitDataType)(0), // 5: proto.LicenseRequest.ContentIdentification.InitData.InitDataType
+	(SignedMessage_MessageType)(0),                                  // 6: proto.SignedMessage.MessageType
+	(*LicenseIdentification)(nil),                                   // 7: proto.LicenseIdentification
+	(*ClientIdentification)(nil),                                    // 8: proto.ClientIdentification
+	(*EncryptedClientIdentification)(nil),                           // 9: proto.EncryptedClientIdentification
+	(*LicenseRequest)(nil),                                          // 10: proto.LicenseRequest
+	(*SignedMessage)(nil),                                           // 11: proto.SignedMessage
+	(*ClientIdentification_NameValue)(nil),                          // 12: proto.ClientIdentification.NameValue
+	(*ClientIdentification_ClientCapabilities)(nil),                 // 13: proto.ClientIdentification.ClientCapabilities
+	(*LicenseRequest_ContentIdentification)(nil),                    // 14: proto.LicenseRequest.ContentIdentification
+	(*LicenseRequest_ContentIdentification_WidevinePsshData)(nil),   // 15: proto.LicenseRequest.ContentIdentification.WidevinePsshData
+	(*LicenseRequest_ContentIdentification_WebmKeyId)(nil),          // 16: proto.LicenseRequest.ContentIdentification.WebmKeyId
+	(*LicenseRequest_ContentIdentification_ExistingLicense)(nil),    // 17: proto.LicenseRequest.ContentIdentification.ExistingLicense
+	(*LicenseRequest_ContentIdentification_InitData)(nil),           // 18: proto.LicenseRequest.ContentIdentification.InitData
+}
+var file_license_protocol_proto_depIdxs = []int32{
+	0,  // 0: proto.LicenseIdentification.type:type_name -> proto.LicenseType
+	2,  // 1: proto.ClientIdentification.type:type_name -> proto.ClientIdentification.TokenType
+	12, // 2: proto.ClientIdentification.client_info:type_name -> proto.ClientIdentification.NameValue
+	13, // 3: proto.ClientIdentification.client_capabilities:type_name -> proto.ClientIdentification.ClientCapabilities
+	8,  // 4: proto.LicenseRequest.client_id:type_name -> proto.ClientIdentification
+	14, // 5: proto.LicenseRequest.content_id:type_name -> proto.LicenseRequest.ContentIdentification
+	4,  // 6: proto.LicenseRequest.type:type_name -> proto.LicenseRequest.RequestType
+	1,  // 7: proto.LicenseRequest.protocol_version:type_name -> proto.ProtocolVersion
+	9,  // 8: proto.LicenseRequest.encrypted_client_id:type_name -> proto.EncryptedClientIdentification
+	6,  // 9: proto.SignedMessage.type:type_name -> proto.SignedMessage.MessageType
+	3,  // 10: proto.ClientIdentification.ClientCapabilities.max_hdcp_version:type_name -> proto.ClientIdentification.ClientCapabilities.HdcpVersion
+	15, // 11: proto.LicenseRequest.ContentIdentification.widevine_pssh_data:type_name -> proto.LicenseRequest.ContentIdentification.WidevinePsshData
+	16, // 12: proto.LicenseRequest.ContentIdentification.webm_key_id:type_name -> proto.LicenseRequest.ContentIdentification.WebmKeyId
+	17, // 13: proto.LicenseRequest.ContentIdentification.existing_license:type_name -> proto.LicenseRequest.ContentIdentification.ExistingLicense
+	18, // 14: proto.LicenseRequest.ContentIdentification.init_data:type_name -> proto.LicenseRequest.ContentIdentification.InitData
+	0,  // 15: proto.LicenseRequest.ContentIdentification.WidevinePsshData.license_type:type_name -> proto.LicenseType
+	0,  // 16: proto.LicenseRequest.ContentIdentification.WebmKeyId.license_type:type_name -> proto.LicenseType
+	7,  // 17: proto.LicenseRequest.ContentIdentification.ExistingLicense.license_id:type_name -> proto.LicenseIdentification
+	5,  // 18: proto.LicenseRequest.ContentIdentification.InitData.init_data_type:type_name -> proto.LicenseRequest.ContentIdentification.InitData.InitDataType
+	0,  // 19: proto.LicenseRequest.ContentIdentification.InitData.license_type:type_name -> proto.LicenseType
+	20, // [20:20] is the sub-list for method output_type
+	20, // [20:20] is the sub-list for method input_type
+	20, // [20:20] is the sub-list for extension type_name
+	20, // [20:20] is the sub-list for extension extendee
+	0,  // [0:20] is the sub-list for field type_name
+}
+
+func init() { file_license_protocol_proto_init() }
+func file_license_protocol_proto_init() {
+	if File_license_protocol_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_license_protocol_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*LicenseIdentification); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_license_protocol_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ClientIdentification); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_license_protocol_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*EncryptedClientIdentification); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_license_protocol_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*LicenseRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_license_protocol_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SignedMessage); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_license_protocol_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ClientIdentification_NameValue); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_license_protocol_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ClientIdentification_ClientCapabilities); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_license_protocol_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*LicenseRequest_ContentIdentification); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_license_protocol_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*LicenseRequest_ContentIdentification_WidevinePsshData); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_license_protocol_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*LicenseRequest_ContentIdentification_WebmKeyId); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_license_protocol_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*LicenseRequest_ContentIdentification_ExistingLicense); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_license_protocol_proto_msgTypes[11].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*LicenseRequest_ContentIdentification_InitData); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	file_license_protocol_proto_msgTypes[7].OneofWrappers = []interface{}{
+		(*LicenseRequest_ContentIdentification_WidevinePsshData_)(nil),
+		(*LicenseRequest_ContentIdentification_WebmKeyId_)(nil),
+		(*LicenseRequest_ContentIdentification_ExistingLicense_)(nil),
+		(*LicenseRequest_ContentIdentification_InitData_)(nil),
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_license_protocol_proto_rawDesc,
+			NumEnums:      7,
+			NumMessages:   12,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_license_protocol_proto_goTypes,
+		DependencyIndexes: file_license_protocol_proto_depIdxs,
+		EnumInfos:         file_license_protocol_proto_enumTypes,
+		MessageInfos:      file_license_protocol_proto_msgTypes,
+	}.Build()
+	File_license_protocol_proto = out.File
+	file_license_protocol_proto_rawDesc = nil
+	file_license_protocol_proto_goTypes = nil
+	file_license_protocol_proto_depIdxs = nil
+}
@@ -0,0 +1,119 @@
+package widevineproxy
+
+import (
+	"context"
+	"fmt"
+)
+
+// Authorizer decides whether a license may be issued, after the license request is parsed
+// and before it is built by the LicenseAuthority.
+// An Authorizer relying on AuthorizationRequest.Device must implement DeviceInfoRequirer.
+// Returning an error denies the license; use Deny to explain the denial to the client.
+type Authorizer interface {
+	Authorize(ctx context.Context, req *AuthorizationRequest) error
+}
+
+// AuthorizerFunc adapts a function to the Authorizer interface.
+type AuthorizerFunc func(ctx context.Context, req *AuthorizationRequest) error
+
+// Authorize calls f(ctx, req).
+func (f AuthorizerFunc) Authorize(ctx context.Context, req *AuthorizationRequest) error {
+	return f(ctx, req)
+}
+
+// Policy sets the policy of a license message built by the LicenseAuthority: track types, policy overrides,
+// output protection. It is applied before the restrictions of the claims, see Claims.Restrict.
+// A Policy relying on AuthorizationRequest.Device must implement DeviceInfoRequirer.
+// Returning an error fails the license request; use Deny to refuse the license.
+type Policy interface {
+	ApplyPolicy(ctx context.Context, req *AuthorizationRequest, message *Message) error
+}
+
+// PolicyChain is a Policy applying its policies in order, e.g. a rule engine then a DeviceGate.
+type PolicyChain []Policy
+
+// ApplyPolicy applies the policies of c to message, stopping at the first error.
+func (c PolicyChain) ApplyPolicy(ctx context.Context, req *AuthorizationRequest, message *Message) error {
+	for _, p := range c {
+		if err := p.ApplyPolicy(ctx, req, message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RequiresDeviceInfo reports whether a policy of c requires the device details.
+func (c PolicyChain) RequiresDeviceInfo(psshData *PsshData) bool {
+	for _, p := range c {
+		if r, ok := p.(DeviceInfoRequirer); ok && r.RequiresDeviceInfo(psshData) {
+			return true
+		}
+	}
+	return false
+}
+
+// AuthorizationRequest is what is known about a license request before it is issued.
+type AuthorizationRequest struct {
+	Claims   *Claims // Nil if the caller did not authenticate the user.
+	PsshData PsshData
+	Device   DeviceInfo // Zero unless an extension of the Proxy is a DeviceInfoRequirer.
+	// LicenseType is the type of license asked by the CDM, LicenseTypeStreaming or LicenseTypeOffline.
+	LicenseType string
+
+	// Parsed is the PARSE_ONLY response of the license service, nil if the challenge was parsed locally.
+	// It carries the device details beyond DeviceInfo, e.g. the client info or the supported tracks.
+	Parsed *LicenseResponse
+}
+
+// DeviceInfo describes the client device as reported by the license service.
+type DeviceInfo struct {
+	Make          string
+	Model         string
+	Platform      string
+	SecurityLevel int64
+	SystemID      int64
+	// DRMCertSerialNumber identifies the DRM certificate of the device, base64 encoded.
+	DRMCertSerialNumber string
+	// DeviceState is the state of the device model in the Widevine device list, e.g. RELEASED or REVOKED.
+	DeviceState string
+
+	// MaxHDCPVersion is the highest HDCP version the client supports, e.g. HDCP_V2_2. Empty if unknown.
+	MaxHDCPVersion HDCPVersion
+	// WhitelistState is DEVICE_WHITELISTED when the provider whitelisted the device model.
+	WhitelistState string
+	// PlatformVerificationStatus tells whether the platform of the client was verified, e.g. PLATFORM_HARDWARE_VERIFIED.
+	PlatformVerificationStatus string
+}
+
+func deviceInfoOf(response *LicenseResponse) DeviceInfo {
+	if response == nil {
+		return DeviceInfo{}
+	}
+	return DeviceInfo{
+		Make:          response.Make,
+		Model:         response.Model,
+		Platform:      response.Platform,
+		SecurityLevel: response.SecurityLevel,
+		SystemID:      response.SystemID,
+
+		DRMCertSerialNumber:        response.DRMCERTSerialNumber,
+		DeviceState:                response.DeviceState,
+		MaxHDCPVersion:             HDCPVersion(response.ClientMaxHdcpVersion),
+		WhitelistState:             response.DeviceWhitelistState,
+		PlatformVerificationStatus: response.PlatformVerificationStatus,
+	}
+}
+
+// AuthorizationDeniedError is returned when the Authorizer refuses to issue a license.
+type AuthorizationDeniedError struct {
+	Reason string
+}
+
+func (e *AuthorizationDeniedError) Error() string {
+	return fmt.Sprintf("license request denied: %s", e.Reason)
+}
+
+// Deny returns an AuthorizationDeniedError with a formatted reason.
+func Deny(format string, a ...interface{}) error {
+	return &AuthorizationDeniedError{Reason: fmt.Sprintf(format, a...)}
+}
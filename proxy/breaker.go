@@ -0,0 +1,186 @@
+package widevineproxy
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without calling the license service while the CircuitBreaker is open.
+var ErrCircuitOpen = errors.New("license service circuit breaker is open")
+
+// CircuitState is the state of a CircuitBreaker.
+type CircuitState int
+
+// CircuitBreaker states.
+const (
+	CircuitClosed   CircuitState = iota // Calls go through.
+	CircuitOpen                         // Calls fail fast with ErrCircuitOpen.
+	CircuitHalfOpen                     // A single probe call goes through.
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return "unknown"
+}
+
+// CircuitBreaker fails the calls to the license service fast once it looks degraded.
+// It opens after FailureThreshold consecutive failures, and lets a probe call through
+// after OpenTimeout: the circuit closes again if the probe succeeds.
+type CircuitBreaker struct {
+	FailureThreshold int
+	OpenTimeout      time.Duration
+
+	// OnStateChange, if set, is called on every state transition, outside of the breaker lock.
+	OnStateChange func(from, to CircuitState)
+	Now           func() time.Time
+
+	mu       sync.Mutex
+	state    CircuitState
+	failures int
+	openedAt time.Time
+	probing  bool
+	stats    CircuitStats
+}
+
+// CircuitStats are the counters of a CircuitBreaker, e.g. for exporting as metrics.
+type CircuitStats struct {
+	State               string `json:"state"`
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+	Successes           uint64 `json:"successes"`
+	Failures            uint64 `json:"failures"`
+	Rejected            uint64 `json:"rejected"` // Calls failed fast while open.
+	Trips               uint64 `json:"trips"`    // Transitions to open.
+}
+
+// NewCircuitBreaker creates a closed CircuitBreaker.
+func NewCircuitBreaker(failureThreshold int, openTimeout time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		FailureThreshold: failureThreshold,
+		OpenTimeout:      openTimeout,
+		Now:              time.Now,
+	}
+}
+
+// CircuitCall is a call allowed by a CircuitBreaker, which reports its result with Success, Failure or Abandon.
+type CircuitCall struct {
+	breaker *CircuitBreaker
+	probe   bool // The call probing the license service while half-open.
+}
+
+// Allow reports whether a call may be made. Every allowed call must report its result with Success,
+// Failure or Abandon. While the circuit is open or half-open, only the result of the probe call changes it.
+func (b *CircuitBreaker) Allow() (CircuitCall, bool) {
+	b.mu.Lock()
+	from := b.state
+	call := CircuitCall{breaker: b}
+	allowed := true
+	switch b.state {
+	case CircuitOpen:
+		if b.Now().Sub(b.openedAt) < b.OpenTimeout {
+			allowed = false
+			break
+		}
+		b.state = CircuitHalfOpen
+		b.probing = true
+		call.probe = true
+	case CircuitHalfOpen:
+		if b.probing {
+			allowed = false
+			break
+		}
+		b.probing = true
+		call.probe = true
+	}
+	if !allowed {
+		b.stats.Rejected++
+	}
+	to := b.state
+	b.mu.Unlock()
+
+	b.notify(from, to)
+	return call, allowed
+}
+
+// Success records a call answered by the license service. The probe closes the circuit.
+func (c CircuitCall) Success() {
+	b := c.breaker
+	b.mu.Lock()
+	from := b.state
+	b.stats.Successes++
+	if b.state == CircuitClosed || c.probe {
+		b.failures = 0
+		b.probing = false
+		b.state = CircuitClosed
+	}
+	to := b.state
+	b.mu.Unlock()
+
+	b.notify(from, to)
+}
+
+// Failure records a call failed by the license service being unreachable or degraded.
+// The probe opens the circuit again.
+func (c CircuitCall) Failure() {
+	b := c.breaker
+	b.mu.Lock()
+	from := b.state
+	b.stats.Failures++
+	if b.state == CircuitClosed || c.probe {
+		b.failures++
+		b.probing = false
+		if c.probe || b.failures >= b.FailureThreshold {
+			if b.state != CircuitOpen {
+				b.stats.Trips++
+			}
+			b.state = CircuitOpen
+			b.openedAt = b.Now()
+		}
+	}
+	to := b.state
+	b.mu.Unlock()
+
+	b.notify(from, to)
+}
+
+// Abandon records a call given up by the caller, which tells nothing about the license service.
+// An abandoned probe lets the next call probe.
+func (c CircuitCall) Abandon() {
+	if !c.probe {
+		return
+	}
+	b := c.breaker
+	b.mu.Lock()
+	b.probing = false
+	b.mu.Unlock()
+}
+
+// State returns the current state of the breaker.
+func (b *CircuitBreaker) State() CircuitState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// Stats returns a snapshot of the breaker counters.
+func (b *CircuitBreaker) Stats() CircuitStats {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	stats := b.stats
+	stats.State = b.state.String()
+	stats.ConsecutiveFailures = b.failures
+	return stats
+}
+
+func (b *CircuitBreaker) notify(from, to CircuitState) {
+	if from != to && b.OnStateChange != nil {
+		b.OnStateChange(from, to)
+	}
+}
@@ -0,0 +1,101 @@
+package widevineproxy
+
+import (
+	"context"
+	"encoding/base64"
+	"time"
+)
+
+// Claims identifies the user on whose behalf a license is requested, and what the user is entitled to.
+type Claims struct {
+	UserID string
+
+	// ContentIDs restricts the licenses to these content ids. Empty allows any content.
+	ContentIDs []string
+	// MaxTrackType caps Message.AllowedTrackTypes and drops the ContentKeySpecs above it. Empty keeps the message as is.
+	MaxTrackType AllowedTrackType
+	// PolicyOverrides narrows Message.PolicyOverrides down when not nil, see PolicyOverrides.Narrow: it withholds
+	// the permissions it does not set, and shortens the durations it sets.
+	PolicyOverrides *PolicyOverrides
+	// ExpiresAt is the expiry of the credential the claims come from.
+	ExpiresAt time.Time
+
+	// Attributes holds provider specific claims, e.g. the subscription tier.
+	Attributes map[string]interface{}
+}
+
+type claimsKey struct{}
+
+// WithClaims returns a copy of ctx carrying claims.
+func WithClaims(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, claimsKey{}, claims)
+}
+
+// ClaimsFromContext returns the claims carried by ctx, nil if none.
+func ClaimsFromContext(ctx context.Context) *Claims {
+	claims, _ := ctx.Value(claimsKey{}).(*Claims)
+	return claims
+}
+
+// AllowsContent reports whether the claims grant a license for contentID, base64 encoded as in PsshData.
+// ContentIDs may list either the raw or the base64 form of the content id.
+func (c *Claims) AllowsContent(contentID string) bool {
+	if len(c.ContentIDs) == 0 {
+		return true
+	}
+	raw, _ := base64.StdEncoding.DecodeString(contentID)
+	for _, allowed := range c.ContentIDs {
+		if allowed == contentID || allowed == string(raw) {
+			return true
+		}
+	}
+	return false
+}
+
+// Restrict narrows message down to what the claims grant.
+func (c *Claims) Restrict(message *Message) {
+	if c.PolicyOverrides != nil {
+		message.PolicyOverrides = message.PolicyOverrides.Narrow(c.PolicyOverrides)
+	}
+	if c.MaxTrackType == "" {
+		return
+	}
+	max := trackTypeRank(c.MaxTrackType)
+	if message.AllowedTrackTypes == "" || trackTypeRank(message.AllowedTrackTypes) > max {
+		message.AllowedTrackTypes = c.MaxTrackType
+	}
+	// The specs may be shared with the authority: filter into a new slice.
+	var specs []ContentKeySpec
+	for _, spec := range message.ContentKeySpecs {
+		if contentTrackTypeRank(spec.TrackType) <= max {
+			specs = append(specs, spec)
+		}
+	}
+	message.ContentKeySpecs = specs
+}
+
+func trackTypeRank(t AllowedTrackType) int {
+	switch t {
+	case AllowedTrackTypeSD:
+		return 0
+	case AllowedTrackTypeHD:
+		return 1
+	case AllowedTrackTypeUHD1:
+		return 2
+	case AllowedTrackTypeUHD2:
+		return 3
+	}
+	return 0
+}
+
+func contentTrackTypeRank(t ContentTrackType) int {
+	switch t {
+	case ContentTrackTypeHD:
+		return 1
+	case ContentTrackTypeUHD1:
+		return 2
+	case ContentTrackTypeUHD2:
+		return 3
+	}
+	return 0
+}
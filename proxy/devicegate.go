@@ -0,0 +1,124 @@
+package widevineproxy
+
+import (
+	"context"
+	"strings"
+)
+
+// TrackRequirement is what a client device needs to get the keys of a track type.
+type TrackRequirement struct {
+	// MaxSecurityLevel is the highest Widevine security level of the devices getting the keys:
+	// 1 for L1 only, 2 for L1 and L2. 0 allows any device.
+	MaxSecurityLevel int64 `json:"max_security_level"`
+	// HDCP is the output protection required for the keys. The keys are dropped for a client
+	// reporting a lower maximum HDCP version, or none.
+	HDCP HDCPVersion `json:"hdcp"`
+	// VerifiedPlatform drops the keys for a client whose platform is not verified.
+	VerifiedPlatform bool `json:"verified_platform"`
+}
+
+// DeviceGate is a Policy restricting the licenses to what the client device can protect. It drops the
+// ContentKeySpecs of the track types the device does not meet the requirements of, caps AllowedTrackTypes
+// accordingly and sets the required HDCP version of the remaining keys.
+type DeviceGate struct {
+	Tracks map[ContentTrackType]TrackRequirement
+}
+
+// DefaultDeviceGate returns a DeviceGate requiring HDCP v1 for HD, and an L1 device with HDCP v2.2 for UHD.
+func DefaultDeviceGate() *DeviceGate {
+	uhd := TrackRequirement{MaxSecurityLevel: 1, HDCP: HDCPVersionV2d2}
+	return &DeviceGate{Tracks: map[ContentTrackType]TrackRequirement{
+		ContentTrackTypeHD:   {HDCP: HDCPVersionV1},
+		ContentTrackTypeUHD1: uhd,
+		ContentTrackTypeUHD2: uhd,
+	}}
+}
+
+// ApplyPolicy restricts message to the tracks the device of req meets the requirements of.
+func (g *DeviceGate) ApplyPolicy(ctx context.Context, req *AuthorizationRequest, message *Message) error {
+	// The specs may be shared with the authority: filter copies of them into a new slice.
+	specs := make([]ContentKeySpec, 0, len(message.ContentKeySpecs))
+	for _, spec := range message.ContentKeySpecs {
+		requirement, ok := g.Tracks[spec.TrackType]
+		if !ok {
+			specs = append(specs, spec)
+			continue
+		}
+		if !requirement.metBy(&req.Device) {
+			continue
+		}
+		if hdcpRank(spec.OutputProtection.HDCP) < hdcpRank(requirement.HDCP) {
+			spec.OutputProtection.HDCP = requirement.HDCP
+		}
+		specs = append(specs, spec)
+	}
+	message.ContentKeySpecs = specs
+
+	// The keys derived by the license service are capped by the allowed track types.
+	allowed := AllowedTrackType(AllowedTrackTypeSD)
+	for _, t := range []struct {
+		content ContentTrackType
+		allowed AllowedTrackType
+	}{
+		{ContentTrackTypeHD, AllowedTrackTypeHD},
+		{ContentTrackTypeUHD1, AllowedTrackTypeUHD1},
+		{ContentTrackTypeUHD2, AllowedTrackTypeUHD2},
+	} {
+		if requirement, ok := g.Tracks[t.content]; ok && !requirement.metBy(&req.Device) {
+			break
+		}
+		allowed = t.allowed
+	}
+	if trackTypeRank(message.AllowedTrackTypes) > trackTypeRank(allowed) {
+		message.AllowedTrackTypes = allowed
+	}
+	return nil
+}
+
+// RequiresDeviceInfo reports whether a track has a requirement, which is checked against the PARSE_ONLY response.
+func (g *DeviceGate) RequiresDeviceInfo(psshData *PsshData) bool {
+	for _, requirement := range g.Tracks {
+		if requirement != (TrackRequirement{}) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *TrackRequirement) metBy(device *DeviceInfo) bool {
+	if r.MaxSecurityLevel > 0 && (device.SecurityLevel < 1 || device.SecurityLevel > r.MaxSecurityLevel) {
+		return false
+	}
+	if r.HDCP != "" && r.HDCP != HDCPVersionNone && hdcpRank(device.MaxHDCPVersion) < hdcpRank(r.HDCP) {
+		return false
+	}
+	if r.VerifiedPlatform && !verifiedPlatform(device.PlatformVerificationStatus) {
+		return false
+	}
+	return true
+}
+
+// verifiedPlatform reports whether status is one of the PLATFORM_*_VERIFIED statuses, but PLATFORM_UNVERIFIED.
+func verifiedPlatform(status string) bool {
+	return strings.HasPrefix(status, "PLATFORM_") && strings.HasSuffix(status, "_VERIFIED") && status != "PLATFORM_UNVERIFIED"
+}
+
+// hdcpRank orders the HDCP versions, a client without digital output meeting any of them.
+// Unknown versions rank below HDCP_NONE.
+func hdcpRank(v HDCPVersion) int {
+	switch v {
+	case HDCPVersionNone, "":
+		return 0
+	case HDCPVersionV1:
+		return 1
+	case HDCPVersionV2:
+		return 2
+	case HDCPVersionV2d1:
+		return 3
+	case HDCPVersionV2d2:
+		return 4
+	case HDCPVersionNoDigtalOutput:
+		return 5
+	}
+	return -1
+}
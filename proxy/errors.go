@@ -0,0 +1,94 @@
+package widevineproxy
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Status strings returned by the Widevine license service.
+const (
+	StatusOK                      = "OK"
+	StatusSignatureFailure        = "SIGNATURE_FAILURE"
+	StatusInvalidLicenseChallenge = "INVALID_LICENSE_CHALLENGE"
+	StatusProviderAccessDenied    = "PROVIDER_ACCESS_DENIED"
+	StatusInternalError           = "INTERNAL_ERROR"
+)
+
+// Errors matched with errors.Is against the errors returned by the proxy.
+var (
+	// ErrSignatureFailure means the license service rejected the signing key, IV or provider of the proxy.
+	ErrSignatureFailure = errors.New("license service signature failure")
+	// ErrInvalidLicenseChallenge means the CDM message was rejected by the license service.
+	ErrInvalidLicenseChallenge = errors.New("invalid license challenge")
+	// ErrProviderAccessDenied means the provider may not issue licenses for the content.
+	ErrProviderAccessDenied = errors.New("provider access denied")
+	// ErrUpstreamUnavailable means the license service could not be reached, failed or answered garbage.
+	ErrUpstreamUnavailable = errors.New("license service unavailable")
+	// ErrLicenseDenied is any other status refusing the license, e.g. a revoked device.
+	ErrLicenseDenied = errors.New("license denied")
+)
+
+// LicenseError is returned when the license service answers with a status other than OK.
+// It unwraps to the Err* matching the status.
+type LicenseError struct {
+	Response *LicenseResponse
+}
+
+func (e *LicenseError) Error() string {
+	msg := fmt.Sprintf("license service status %s", e.Response.Status)
+	if e.Response.StatusMessage != "" {
+		msg += ": " + e.Response.StatusMessage
+	}
+	if e.Response.InternalStatus != 0 {
+		msg += fmt.Sprintf(" (internal status %d)", e.Response.InternalStatus)
+	}
+	return msg
+}
+
+func (e *LicenseError) Unwrap() error {
+	switch e.Response.Status {
+	case StatusSignatureFailure:
+		return ErrSignatureFailure
+	case StatusInvalidLicenseChallenge:
+		return ErrInvalidLicenseChallenge
+	case StatusProviderAccessDenied:
+		return ErrProviderAccessDenied
+	case StatusInternalError:
+		return ErrUpstreamUnavailable
+	}
+	return ErrLicenseDenied
+}
+
+// UpstreamError is returned when the call to the license service failed before a status was received:
+// transport failure, timeout, HTTP error, undecodable body or open circuit breaker.
+// It matches ErrUpstreamUnavailable and unwraps to the cause.
+type UpstreamError struct {
+	Err error
+}
+
+func (e *UpstreamError) Error() string {
+	return fmt.Sprintf("%v: %v", ErrUpstreamUnavailable, e.Err)
+}
+
+func (e *UpstreamError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports ErrUpstreamUnavailable as a match.
+func (e *UpstreamError) Is(target error) bool {
+	return target == ErrUpstreamUnavailable
+}
+
+// Timeout reports whether the call timed out.
+func (e *UpstreamError) Timeout() bool {
+	var timeout interface{ Timeout() bool }
+	return errors.As(e.Err, &timeout) && timeout.Timeout()
+}
+
+// checkStatus returns a LicenseError unless the status of response is OK.
+func checkStatus(response *LicenseResponse) error {
+	if response.Status == StatusOK {
+		return nil
+	}
+	return &LicenseError{Response: response}
+}
@@ -105,6 +105,48 @@ type PolicyOverrides struct {
 	AlwaysIncludeClientId          bool   `json:"always_include_client_id,omitempty"`          // Default: false; Indicates to clients that license renewal and release requests must include client identification (client_id).
 }
 
+// Copy returns a copy of p, nil if p is nil, for a message to own overrides shared with a configuration.
+func (p *PolicyOverrides) Copy() *PolicyOverrides {
+	if p == nil {
+		return nil
+	}
+	copied := *p
+	return &copied
+}
+
+// Narrow returns a copy of p, or of the overrides only allowing playback if p is nil, narrowed down to from:
+// a permission (CanPlay, CanPersist, CanRenew) is kept if both allow it, a requirement (RenewWithUsage,
+// AlwaysIncludeClientId) applies if either sets it, and a duration or delay is the shorter of both, zero
+// being unlimited. The RenewalServerUrl of p is kept.
+func (p *PolicyOverrides) Narrow(from *PolicyOverrides) *PolicyOverrides {
+	narrowed := &PolicyOverrides{CanPlay: true}
+	if p != nil {
+		*narrowed = *p
+	}
+	if from == nil {
+		return narrowed
+	}
+	narrowed.CanPlay = narrowed.CanPlay && from.CanPlay
+	narrowed.CanPersist = narrowed.CanPersist && from.CanPersist
+	narrowed.CanRenew = narrowed.CanRenew && from.CanRenew
+	narrowed.RenewWithUsage = narrowed.RenewWithUsage || from.RenewWithUsage
+	narrowed.AlwaysIncludeClientId = narrowed.AlwaysIncludeClientId || from.AlwaysIncludeClientId
+	for _, f := range []struct{ dst, src *uint64 }{
+		{&narrowed.LicenseDurationSeconds, &from.LicenseDurationSeconds},
+		{&narrowed.RentalDurationSeconds, &from.RentalDurationSeconds},
+		{&narrowed.PlaybackDurationSeconds, &from.PlaybackDurationSeconds},
+		{&narrowed.TimeShiftLimitSeconds, &from.TimeShiftLimitSeconds},
+		{&narrowed.RenewalDelaySeconds, &from.RenewalDelaySeconds},
+		{&narrowed.RenewalRetryIntervalSeconds, &from.RenewalRetryIntervalSeconds},
+		{&narrowed.RenewalRecoveryDurationSeconds, &from.RenewalRecoveryDurationSeconds},
+	} {
+		if *f.src != 0 && (*f.dst == 0 || *f.src < *f.dst) {
+			*f.dst = *f.src
+		}
+	}
+	return narrowed
+}
+
 type SessionInit struct {
 	ProviderClientToken         string `json:"provider_client_token"`          // provider_client_token​ is only supported in the Chrome CDM and ​persistentState​ must be enabled by the Application.
 	OverrideProviderClientToken bool   `json:"override_provider_client_token"` // Default: false;
@@ -0,0 +1,125 @@
+package widevineproxy
+
+import (
+	"fmt"
+
+	pb "github.com/cooomma/widevine-proxy/proto"
+	proto "github.com/golang/protobuf/proto"
+)
+
+// MessageType is the kind of CDM message received by the proxy.
+type MessageType int
+
+const (
+	MessageTypeUnknown MessageType = iota
+	MessageTypeServiceCertificateRequest
+	MessageTypeLicenseRequest
+	MessageTypeLicenseRenewal
+	MessageTypeLicenseRelease
+)
+
+func (t MessageType) String() string {
+	switch t {
+	case MessageTypeServiceCertificateRequest:
+		return "SERVICE_CERTIFICATE_REQUEST"
+	case MessageTypeLicenseRequest:
+		return "LICENSE_REQUEST"
+	case MessageTypeLicenseRenewal:
+		return "LICENSE_RENEWAL"
+	case MessageTypeLicenseRelease:
+		return "LICENSE_RELEASE"
+	}
+	return "UNKNOWN"
+}
+
+// MalformedMessageError is returned when the body received from the CDM is not a message the proxy can handle.
+type MalformedMessageError struct {
+	Reason string
+	Err    error
+}
+
+func (e *MalformedMessageError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("malformed CDM message: %s: %v", e.Reason, e.Err)
+	}
+	return fmt.Sprintf("malformed CDM message: %s", e.Reason)
+}
+
+func (e *MalformedMessageError) Unwrap() error {
+	return e.Err
+}
+
+// CDMMessage is a SignedMessage received from the CDM.
+type CDMMessage struct {
+	Type           MessageType
+	Signed         *pb.SignedMessage
+	LicenseRequest *pb.LicenseRequest // Nil for service certificate requests.
+}
+
+// ParseCDMMessage decodes body as a SignedMessage and classifies it.
+func ParseCDMMessage(body []byte) (*CDMMessage, error) {
+	if len(body) == 0 {
+		return nil, &MalformedMessageError{Reason: "empty body"}
+	}
+	signed := &pb.SignedMessage{}
+	if err := proto.Unmarshal(body, signed); err != nil {
+		return nil, &MalformedMessageError{Reason: "invalid SignedMessage", Err: err}
+	}
+	if signed.Type == nil {
+		return nil, &MalformedMessageError{Reason: "missing message type"}
+	}
+
+	switch signed.GetType() {
+	case pb.SignedMessage_SERVICE_CERTIFICATE_REQUEST:
+		return &CDMMessage{Type: MessageTypeServiceCertificateRequest, Signed: signed}, nil
+	case pb.SignedMessage_LICENSE_REQUEST:
+	default:
+		return nil, &MalformedMessageError{Reason: fmt.Sprintf("unsupported message type %s", signed.GetType())}
+	}
+
+	request := &pb.LicenseRequest{}
+	if err := proto.Unmarshal(signed.GetMsg(), request); err != nil {
+		return nil, &MalformedMessageError{Reason: "invalid LicenseRequest", Err: err}
+	}
+	if request.GetContentId() == nil {
+		return nil, &MalformedMessageError{Reason: "missing content identification"}
+	}
+	message := &CDMMessage{Type: MessageTypeLicenseRequest, Signed: signed, LicenseRequest: request}
+
+	switch request.GetType() {
+	case pb.LicenseRequest_RENEWAL:
+		message.Type = MessageTypeLicenseRenewal
+	case pb.LicenseRequest_RELEASE:
+		message.Type = MessageTypeLicenseRelease
+	}
+	if message.Type != MessageTypeLicenseRequest && request.GetContentId().GetExistingLicense() == nil {
+		return nil, &MalformedMessageError{Reason: fmt.Sprintf("%s without existing license", message.Type)}
+	}
+	return message, nil
+}
+
+// ExistingLicenseID returns the identification of the license a renewal or release refers to.
+func (m *CDMMessage) ExistingLicenseID() *pb.LicenseIdentification {
+	return m.LicenseRequest.GetContentId().GetExistingLicense().GetLicenseId()
+}
+
+// License types of LicenseID.Type and AuthorizationRequest.LicenseType.
+const (
+	LicenseTypeStreaming = "STREAMING"
+	LicenseTypeOffline   = "OFFLINE" // A persisted license, e.g. of a download.
+)
+
+// LicenseType returns the type of license asked by a new license request, LicenseTypeStreaming unless
+// the CDM asked for a license to persist.
+func (m *CDMMessage) LicenseType() string {
+	contentID := m.LicenseRequest.GetContentId()
+	switch {
+	case contentID.GetWidevinePsshData() != nil:
+		return contentID.GetWidevinePsshData().GetLicenseType().String()
+	case contentID.GetWebmKeyId() != nil:
+		return contentID.GetWebmKeyId().GetLicenseType().String()
+	case contentID.GetInitData() != nil:
+		return contentID.GetInitData().GetLicenseType().String()
+	}
+	return LicenseTypeStreaming
+}
@@ -2,10 +2,10 @@ package widevineproxy
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha1"
 	"encoding/base64"
 	"encoding/json"
-	"fmt"
 	"io/ioutil"
 	"net"
 	"net/http"
@@ -23,11 +23,37 @@ type LicenseAuthority interface {
 	GetProvider() string
 }
 
+// ContextLicenseAuthority is implemented by a LicenseAuthority whose license message depends on the request context,
+// e.g. on the claims or on a deadline bound lookup. BuildLicenseMessage is not called when it is implemented.
+type ContextLicenseAuthority interface {
+	BuildLicenseMessageWithContext(ctx context.Context, reqBody []byte, psshData *PsshData) (*Message, error)
+}
+
+// RequestLicenseAuthority is implemented by a LicenseAuthority whose license message depends on the whole request:
+// the claims, and the device as reported by the PARSE_ONLY response (AuthorizationRequest.Parsed), which is only
+// made when the LicenseAuthority is a DeviceInfoRequirer. BuildLicenseMessage and BuildLicenseMessageWithContext
+// are not called when it is implemented.
+type RequestLicenseAuthority interface {
+	BuildLicenseMessageWithRequest(ctx context.Context, reqBody []byte, req *AuthorizationRequest) (*Message, error)
+}
+
 // Proxy structure.
 type Proxy struct {
 	LicenseAuthority LicenseAuthority
+	Authorizer       Authorizer     // Optional, authorizes new licenses before they are built.
+	Policy           Policy         // Optional, sets the policy of new licenses once built.
+	Tracker          LicenseTracker // Optional, follows the licenses from their issuance to their release.
 	httpCaller       *http.Client
 	Logger           *logrus.Logger
+	// LogFields are added to every log entry of the proxy, e.g. the tenant it serves.
+	LogFields logrus.Fields
+
+	// CallTimeout bounds each call to the license service, within the deadline of the caller's context.
+	CallTimeout time.Duration
+	// Retry applies to the idempotent calls to the license service.
+	Retry RetryPolicy
+	// Breaker, if not nil, fails the calls fast while the license service is degraded.
+	Breaker *CircuitBreaker
 }
 
 // NewWidevineProxy creates an instance for grant widevine license with Widevine Cloud-based services.
@@ -42,96 +68,223 @@ func NewWidevineProxy(la LicenseAuthority, logger *logrus.Logger) *Proxy {
 		},
 	}
 
-	return &Proxy{
+	wp := &Proxy{
 		LicenseAuthority: la,
 		Logger:           logger,
 		httpCaller:       client,
+		CallTimeout:      5 * time.Second,
+		Retry:            DefaultRetryPolicy,
+		Breaker:          NewCircuitBreaker(5, 30*time.Second),
 	}
+	wp.Breaker.OnStateChange = wp.logCircuitStateChange
+	return wp
 }
 
-// GetLicense is to create the certification to client if body size less than 50 or create license request for license.
+func (wp *Proxy) logCircuitStateChange(from, to CircuitState) {
+	logger := wp.logger(context.Background()).WithFields(logrus.Fields{"from": from.String(), "to": to.String()})
+	if to == CircuitOpen {
+		logger.Error("License Service Circuit Opened")
+		return
+	}
+	logger.Info("License Service Circuit State Changed")
+}
+
+// GetLicense parses the CDM message and creates the service certificate, license, renewal or release accordingly.
 func (wp *Proxy) GetLicense(body []byte) (*LicenseResponse, error) {
-	if len(body) < 50 {
-		req, err := wp.buildCertificateRequest(body)
-		if err != nil {
-			return nil, err
-		}
-		response, err := wp.sendReqeust(req)
-		if err != nil {
+	return wp.GetLicenseWithContext(context.Background(), body, nil)
+}
+
+// GetLicenseWithContext is GetLicense on behalf of the user identified by claims.
+// The claims are handed to the Authorizer and carried by the context, see ClaimsFromContext.
+// Cancelling ctx aborts the pending calls to the license service.
+func (wp *Proxy) GetLicenseWithContext(ctx context.Context, body []byte, claims *Claims) (*LicenseResponse, error) {
+	if claims != nil {
+		ctx = WithClaims(ctx, claims)
+	}
+	message, err := ParseCDMMessage(body)
+	if err != nil {
+		wp.logger(ctx).WithError(err).Warn("Malformed CDM Message")
+		return nil, err
+	}
+
+	switch message.Type {
+	case MessageTypeServiceCertificateRequest:
+		return wp.getServiceCertificate(ctx, body)
+	case MessageTypeLicenseRenewal:
+		return wp.renewLicense(ctx, body, message)
+	case MessageTypeLicenseRelease:
+		return wp.releaseLicense(ctx, body, message)
+	}
+	return wp.getNewLicense(ctx, body, message)
+}
+
+func (wp *Proxy) getServiceCertificate(ctx context.Context, body []byte) (*LicenseResponse, error) {
+	req, err := wp.buildPayloadRequest(body)
+	if err != nil {
+		return nil, err
+	}
+	response, err := wp.sendIdempotentRequest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkStatus(response); err != nil {
+		wp.logger(ctx).WithFields(responseFields(response)).Error("Certification Request Failure")
+		return nil, err
+	}
+	wp.logger(ctx).WithFields(responseFields(response)).Info("Certification Request Success.")
+	return response, nil
+}
+
+// getNewLicense builds the license from the pssh data of the challenge, parsed locally unless
+// the challenge carries none or the device details are required (see DeviceInfoRequirer).
+func (wp *Proxy) getNewLicense(ctx context.Context, body []byte, message *CDMMessage) (*LicenseResponse, error) {
+	psshData, ok := message.PsshData()
+	var parsed *LicenseResponse
+	if !ok || wp.requiresDeviceInfo(psshData) {
+		var err error
+		if parsed, err = wp.parseLicenseRemotely(ctx, body); err != nil {
 			return nil, err
 		}
-		wp.Logger.WithFields(
-			logrus.Fields{
-				"status":           response.Status,
-				"message_type":     response.MessageType,
-				"license_metadata": response.LicenseMetadata,
-				"supported_tracks": response.SupportedTracks,
-				"model":            response.Model,
-				"security_level":   response.SecurityLevel,
-				"session_state":    response.SessionState,
-				"platform":         response.Platform,
-				"client_info":      response.ClientInfo,
-			}).Info("Certification Request Success.")
-		return response, nil
-	}
-
-	// Parse License
+		psshData = &parsed.PsshData
+	}
+
+	authReq := &AuthorizationRequest{
+		Claims:      ClaimsFromContext(ctx),
+		PsshData:    *psshData,
+		Device:      deviceInfoOf(parsed),
+		LicenseType: message.LicenseType(),
+		Parsed:      parsed,
+	}
+	if err := wp.authorize(ctx, authReq); err != nil {
+		return nil, err
+	}
+
+	// Create Build License
+	licenseMessage, req, err := wp.buildLicenseRequest(ctx, body, authReq)
+	if err != nil {
+		return nil, err
+	}
+	response, err := wp.sendLicenseRequest(ctx, req)
+	if err != nil || wp.Tracker == nil {
+		return response, err
+	}
+	license := &IssuedLicense{Request: authReq, Message: licenseMessage, Response: response}
+	if err := wp.Tracker.LicenseIssued(ctx, license); err != nil {
+		wp.requestLogger(ctx, authReq).WithError(err).Warn("License Withheld")
+		return nil, err
+	}
+	return response, nil
+}
+
+func (wp *Proxy) parseLicenseRemotely(ctx context.Context, body []byte) (*LicenseResponse, error) {
 	parseLicenseReq, err := wp.parseLicense(body)
 	if err != nil {
 		return nil, err
 	}
-	rawMessage, err := wp.sendReqeust(parseLicenseReq)
+	rawMessage, err := wp.sendIdempotentRequest(ctx, parseLicenseReq)
 	if err != nil {
 		return nil, err
 	}
-	wp.Logger.WithFields(
-		logrus.Fields{
-			"status":           rawMessage.Status,
-			"message_type":     rawMessage.MessageType,
-			"license_metadata": rawMessage.LicenseMetadata,
-			"supported_tracks": rawMessage.SupportedTracks,
-			"model":            rawMessage.Model,
-			"security_level":   rawMessage.SecurityLevel,
-			"session_state":    rawMessage.SessionState,
-			"platform":         rawMessage.Platform,
-			"client_info":      rawMessage.ClientInfo,
-		}).Info("License Parse Success.")
+	if err := checkStatus(rawMessage); err != nil {
+		wp.logger(ctx).WithFields(responseFields(rawMessage)).Error("License Parse Failure")
+		return nil, err
+	}
+	wp.logger(ctx).WithFields(responseFields(rawMessage)).Info("License Parse Success.")
+	return rawMessage, nil
+}
 
-	// Create Build License
-	req, err := wp.buildLicenseRequest(body, &rawMessage.PsshData)
+// authorize checks the claims, the Authorizer and the Tracker.
+func (wp *Proxy) authorize(ctx context.Context, req *AuthorizationRequest) error {
+	err := authorizeClaims(req.Claims, &req.PsshData)
+	if err == nil && wp.Authorizer != nil {
+		err = wp.Authorizer.Authorize(ctx, req)
+	}
+	if err == nil && wp.Tracker != nil {
+		err = wp.Tracker.CheckLicense(ctx, req)
+	}
+	if err != nil {
+		wp.requestLogger(ctx, req).WithError(err).Warn("License Request Unauthorized")
+		return err
+	}
+	return nil
+}
+
+func (wp *Proxy) requestLogger(ctx context.Context, req *AuthorizationRequest) *logrus.Entry {
+	logger := wp.logger(ctx).WithFields(logrus.Fields{
+		"content_id": req.PsshData.ContentID,
+		"make":       req.Device.Make,
+		"model":      req.Device.Model,
+	})
+	if req.Claims != nil {
+		logger = logger.WithField("user_id", req.Claims.UserID)
+	}
+	return logger
+}
+
+func authorizeClaims(claims *Claims, psshData *PsshData) error {
+	if claims == nil {
+		return nil
+	}
+	if !claims.AllowsContent(psshData.ContentID) {
+		return Deny("content %s not granted", psshData.ContentID)
+	}
+	return nil
+}
+
+// forwardLicenseRequest sends renewal and release requests to the license service as they are.
+func (wp *Proxy) forwardLicenseRequest(ctx context.Context, body []byte, message *CDMMessage) (*LicenseResponse, error) {
+	req, err := wp.buildExistingLicenseRequest(body, nil)
 	if err != nil {
 		return nil, err
 	}
-	response, err := wp.sendReqeust(req)
+	wp.logger(ctx).WithField("message_type", message.Type.String()).Info("Forward License Request")
+	return wp.sendLicenseRequest(ctx, req)
+}
+
+func (wp *Proxy) sendLicenseRequest(ctx context.Context, req []byte) (*LicenseResponse, error) {
+	response, err := wp.sendReqeust(ctx, req)
 	if err != nil {
 		return nil, err
 	}
-	logger := wp.Logger.WithFields(
-		logrus.Fields{
-			"status":           response.Status,
-			"message_type":     response.MessageType,
-			"license_metadata": response.LicenseMetadata,
-			"supported_tracks": response.SupportedTracks,
-			"model":            response.Model,
-			"security_level":   response.SecurityLevel,
-			"session_state":    response.SessionState,
-			"platform":         response.Platform,
-			"client_info":      response.ClientInfo,
-		})
-	if response.Status == "OK" {
-		logger.Info("License Request Success")
-		return response, nil
+	logger := wp.logger(ctx).WithFields(responseFields(response))
+	if err := checkStatus(response); err != nil {
+		logger.Error("License Request Failure")
+		return nil, err
+	}
+	logger.Info("License Request Success")
+	return response, nil
+}
+
+func responseFields(response *LicenseResponse) logrus.Fields {
+	return logrus.Fields{
+		"status":           response.Status,
+		"message_type":     response.MessageType,
+		"license_metadata": response.LicenseMetadata,
+		"supported_tracks": response.SupportedTracks,
+		"model":            response.Model,
+		"security_level":   response.SecurityLevel,
+		"session_state":    response.SessionState,
+		"platform":         response.Platform,
+		"client_info":      response.ClientInfo,
 	}
-	logger.Error("License Request Failure")
-	return nil, fmt.Errorf(response.Status)
 }
 
 func (wp *Proxy) ParseLicense(body []byte) (*LicenseResponse, error) {
+	return wp.ParseLicenseWithContext(context.Background(), body)
+}
+
+// ParseLicenseWithContext sends a PARSE_ONLY request for body to the license service.
+// A status other than OK is returned as a LicenseError.
+func (wp *Proxy) ParseLicenseWithContext(ctx context.Context, body []byte) (*LicenseResponse, error) {
 	req, err := wp.parseLicense(body)
 	if err != nil {
 		return nil, err
 	}
-	return wp.sendReqeust(req)
+	response, err := wp.sendIdempotentRequest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	return response, checkStatus(response)
 }
 
 func (wp *Proxy) parseLicense(body []byte) ([]byte, error) {
@@ -145,7 +298,8 @@ func (wp *Proxy) parseLicense(body []byte) ([]byte, error) {
 	return wp.packingRequest(message)
 }
 
-func (wp *Proxy) buildCertificateRequest(body []byte) ([]byte, error) {
+// buildPayloadRequest signs a request carrying the CDM message alone.
+func (wp *Proxy) buildPayloadRequest(body []byte) ([]byte, error) {
 	message, err := json.Marshal(map[string]string{
 		"payload": base64.StdEncoding.EncodeToString(body),
 	})
@@ -155,34 +309,97 @@ func (wp *Proxy) buildCertificateRequest(body []byte) ([]byte, error) {
 	return wp.packingRequest(message)
 }
 
-func (wp *Proxy) buildLicenseRequest(body []byte, psshData *PsshData) ([]byte, error) {
-	message, err := wp.LicenseAuthority.BuildLicenseMessage(body, psshData)
+// buildLicenseRequest returns the license message once restricted by the Policy and the claims, and its signed request.
+func (wp *Proxy) buildLicenseRequest(ctx context.Context, body []byte, req *AuthorizationRequest) (*Message, []byte, error) {
+	message, err := wp.buildLicenseMessage(ctx, body, req)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	if wp.Policy != nil {
+		if err := wp.Policy.ApplyPolicy(ctx, req, message); err != nil {
+			wp.requestLogger(ctx, req).WithError(err).Warn("License Policy Failure")
+			return nil, nil, err
+		}
+	}
+	if req.Claims != nil {
+		req.Claims.Restrict(message)
 	}
 	messageJsonB, err := json.Marshal(message)
 	if err != nil {
+		return nil, nil, err
+	}
+	signed, err := wp.packingRequest(messageJsonB)
+	return message, signed, err
+}
+
+func (wp *Proxy) buildLicenseMessage(ctx context.Context, body []byte, req *AuthorizationRequest) (*Message, error) {
+	switch la := wp.LicenseAuthority.(type) {
+	case RequestLicenseAuthority:
+		return la.BuildLicenseMessageWithRequest(ctx, body, req)
+	case ContextLicenseAuthority:
+		return la.BuildLicenseMessageWithContext(ctx, body, &req.PsshData)
+	}
+	return wp.LicenseAuthority.BuildLicenseMessage(body, &req.PsshData)
+}
+
+func (wp *Proxy) sendReqeust(ctx context.Context, reqMessage []byte) (*LicenseResponse, error) {
+	if err := ctx.Err(); err != nil {
 		return nil, err
 	}
-	return wp.packingRequest(messageJsonB)
+	if wp.Breaker == nil {
+		return wp.callLicenseService(ctx, reqMessage)
+	}
+	call, ok := wp.Breaker.Allow()
+	if !ok {
+		wp.logger(ctx).Warn("License Service Circuit Open")
+		return nil, &UpstreamError{Err: ErrCircuitOpen}
+	}
+	response, err := wp.callLicenseService(ctx, reqMessage)
+	switch {
+	case ctx.Err() != nil:
+		call.Abandon()
+	case degraded(response, err):
+		call.Failure()
+	default:
+		call.Success()
+	}
+	return response, err
 }
 
-func (wp *Proxy) sendReqeust(reqMessage []byte) (*LicenseResponse, error) {
+func (wp *Proxy) callLicenseService(ctx context.Context, reqMessage []byte) (*LicenseResponse, error) {
+	if wp.CallTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, wp.CallTimeout)
+		defer cancel()
+	}
+
 	// Call Widevine License Server
-	req, err := http.NewRequest("POST", wp.LicenseAuthority.GetLicenseServerURL(), bytes.NewBuffer(reqMessage))
+	req, err := http.NewRequestWithContext(ctx, "POST", wp.LicenseAuthority.GetLicenseServerURL(), bytes.NewBuffer(reqMessage))
+	if err != nil {
+		return nil, err
+	}
 	req.Header.Add("Content-Type", "application/json")
+	for header, value := range TraceMetadataFromContext(ctx) {
+		req.Header.Set(header, value)
+	}
 	response, err := wp.httpCaller.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, &UpstreamError{Err: err}
 	}
 	defer response.Body.Close()
+	if response.StatusCode >= http.StatusMultipleChoices {
+		return nil, &UpstreamError{Err: &UpstreamHTTPError{StatusCode: response.StatusCode}}
+	}
 
 	// Extract License
-	b, _ := ioutil.ReadAll(response.Body)
+	b, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, &UpstreamError{Err: err}
+	}
 	var lr LicenseResponse
 	if err := json.Unmarshal(b, &lr); err != nil {
-		wp.Logger.Error("Get License JSON Decode Error")
-		return nil, err
+		wp.logger(ctx).Error("Get License JSON Decode Error")
+		return nil, &UpstreamError{Err: err}
 	}
 	return &lr, nil
 }
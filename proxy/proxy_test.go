@@ -0,0 +1,1050 @@
+package widevineproxy_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	pb "github.com/cooomma/widevine-proxy/proto"
+	widevineproxy "github.com/cooomma/widevine-proxy/proxy"
+	"github.com/cooomma/widevine-proxy/proxy/widevinetest"
+	widevineutils "github.com/cooomma/widevine-proxy/utils"
+	proto "github.com/golang/protobuf/proto"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	testKey, _ = hex.DecodeString("1ae8ccd0e7985cc0b6203a55855a1034afc252980e970ca90e5202689f947ab9")
+	testIV, _  = hex.DecodeString("d58ce954203b7c9a9a9d467f59839249")
+
+	testPssh = &pb.WidevineCencHeader{
+		KeyId:     [][]byte{bytes.Repeat([]byte{0x11}, 16), bytes.Repeat([]byte{0x22}, 16)},
+		Provider:  proto.String("widevine_test"),
+		ContentId: []byte("fake-content"),
+	}
+	licenseChallenge = widevinetest.LicenseChallenge(testPssh, pb.LicenseType_STREAMING)
+	testLicenseID    = &pb.LicenseIdentification{
+		RequestId: []byte("request-1"),
+		SessionId: []byte("session-1"),
+		Type:      pb.LicenseType_STREAMING.Enum(),
+		Version:   proto.Int32(1),
+	}
+)
+
+type testAuthority struct {
+	url      string
+	provider string
+	psshData *widevineproxy.PsshData
+}
+
+func (a *testAuthority) BuildLicenseMessage(reqBody []byte, psshData *widevineproxy.PsshData) (*widevineproxy.Message, error) {
+	a.psshData = psshData
+	return &widevineproxy.Message{
+		Payload:           base64.StdEncoding.EncodeToString(reqBody),
+		Provider:          a.provider,
+		ContentID:         psshData.ContentID,
+		AllowedTrackTypes: widevineproxy.AllowedTrackTypeSD,
+	}, nil
+}
+
+func (a *testAuthority) GetLicenseServerURL() string { return a.url }
+func (a *testAuthority) GetSigningKey() []byte       { return testKey }
+func (a *testAuthority) GetSigningIV() []byte        { return testIV }
+func (a *testAuthority) GetProvider() string         { return a.provider }
+
+func newTestProxy(t *testing.T, svc http.Handler) (*widevineproxy.Proxy, *testAuthority) {
+	t.Helper()
+	ts := httptest.NewServer(svc)
+	t.Cleanup(ts.Close)
+
+	logger := logrus.New()
+	logger.SetOutput(ioutil.Discard)
+	la := &testAuthority{url: ts.URL, provider: "widevine_test"}
+	return widevineproxy.NewWidevineProxy(la, logger), la
+}
+
+func TestGetLicenseCertificateRequest(t *testing.T) {
+	svc := widevinetest.NewService("widevine_test", testKey, testIV)
+	wp, _ := newTestProxy(t, svc)
+
+	response, err := wp.GetLicense(widevinetest.ServiceCertificateRequest)
+	assert.NoError(t, err)
+	assert.Equal(t, "OK", response.Status)
+	assert.Equal(t, base64.StdEncoding.EncodeToString(svc.ServiceCertificate), response.License)
+	assert.Len(t, svc.RequestsOf(widevinetest.RequestTypeCertificate), 1)
+}
+
+func TestGetLicenseFlow(t *testing.T) {
+	svc := widevinetest.NewService("widevine_test", testKey, testIV)
+	wp, la := newTestProxy(t, svc)
+
+	response, err := wp.GetLicense(licenseChallenge)
+	assert.NoError(t, err)
+	assert.Equal(t, base64.StdEncoding.EncodeToString(svc.License), response.License)
+
+	requests := svc.Requests()
+	if assert.Len(t, requests, 1) {
+		assert.Equal(t, widevinetest.RequestTypeLicense, requests[0].Type)
+		assert.Equal(t, licenseChallenge, requests[0].Payload)
+		assert.Equal(t, base64.StdEncoding.EncodeToString(testPssh.ContentId), requests[0].Message.ContentID)
+	}
+	assert.Equal(t, base64.StdEncoding.EncodeToString(testPssh.ContentId), la.psshData.ContentID)
+	assert.Len(t, la.psshData.KeyID, 2)
+}
+
+func TestGetLicenseRenewalAndRelease(t *testing.T) {
+	svc := widevinetest.NewService("widevine_test", testKey, testIV)
+	wp, la := newTestProxy(t, svc)
+
+	_, err := wp.GetLicense(widevinetest.RenewalChallenge(testLicenseID))
+	assert.NoError(t, err)
+	_, err = wp.GetLicense(widevinetest.ReleaseChallenge(testLicenseID))
+	assert.NoError(t, err)
+
+	assert.Len(t, svc.RequestsOf(widevinetest.RequestTypeRenewal), 1)
+	assert.Len(t, svc.RequestsOf(widevinetest.RequestTypeRelease), 1)
+	assert.Empty(t, svc.RequestsOf(widevinetest.RequestTypeParseOnly))
+	assert.Nil(t, la.psshData)
+}
+
+func TestParseCDMMessage(t *testing.T) {
+	cases := []struct {
+		body     []byte
+		expected widevineproxy.MessageType
+	}{
+		{widevinetest.ServiceCertificateRequest, widevineproxy.MessageTypeServiceCertificateRequest},
+		{licenseChallenge, widevineproxy.MessageTypeLicenseRequest},
+		{widevinetest.RenewalChallenge(testLicenseID), widevineproxy.MessageTypeLicenseRenewal},
+		{widevinetest.ReleaseChallenge(testLicenseID), widevineproxy.MessageTypeLicenseRelease},
+	}
+	for _, c := range cases {
+		message, err := widevineproxy.ParseCDMMessage(c.body)
+		if assert.NoError(t, err) {
+			assert.Equal(t, c.expected, message.Type)
+		}
+	}
+
+	message, err := widevineproxy.ParseCDMMessage(widevinetest.ReleaseChallenge(testLicenseID))
+	assert.NoError(t, err)
+	assert.Equal(t, testLicenseID.RequestId, message.ExistingLicenseID().GetRequestId())
+}
+
+func TestGetLicenseMalformedMessage(t *testing.T) {
+	svc := widevinetest.NewService("widevine_test", testKey, testIV)
+	wp, _ := newTestProxy(t, svc)
+
+	bodies := [][]byte{
+		nil,
+		[]byte("not a protobuf message"),
+		bytes.Repeat([]byte{0x08, 0x01}, 40),
+		{0x08, 0x02}, // LICENSE
+	}
+	for _, body := range bodies {
+		_, err := wp.GetLicense(body)
+		var malformed *widevineproxy.MalformedMessageError
+		assert.True(t, errors.As(err, &malformed), "body %x: %v", body, err)
+	}
+	assert.Empty(t, svc.Requests())
+}
+
+func TestGetLicenseFailure(t *testing.T) {
+	svc := widevinetest.NewService("widevine_test", testKey, testIV)
+	svc.Statuses[widevinetest.RequestTypeLicense] = widevinetest.StatusInvalidLicenseChallenge
+	wp, _ := newTestProxy(t, svc)
+
+	_, err := wp.GetLicense(licenseChallenge)
+	assert.True(t, errors.Is(err, widevineproxy.ErrInvalidLicenseChallenge))
+	var licenseErr *widevineproxy.LicenseError
+	if assert.True(t, errors.As(err, &licenseErr)) {
+		assert.Equal(t, widevinetest.StatusInvalidLicenseChallenge, licenseErr.Response.Status)
+	}
+
+	svc.Statuses[widevinetest.RequestTypeLicense] = "DRM_DEVICE_CERTIFICATE_REVOKED"
+	_, err = wp.GetLicense(licenseChallenge)
+	assert.True(t, errors.Is(err, widevineproxy.ErrLicenseDenied))
+
+	svc.Statuses[widevinetest.RequestTypeParseOnly] = widevinetest.StatusProviderAccessDenied
+	_, err = wp.ParseLicense(licenseChallenge)
+	assert.True(t, errors.Is(err, widevineproxy.ErrProviderAccessDenied))
+}
+
+func TestGetLicenseUpstreamUnavailable(t *testing.T) {
+	wp, _ := newTestProxy(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html>"))
+	}))
+	wp.Retry.MaxAttempts = 1
+
+	_, err := wp.GetLicense(licenseChallenge)
+	assert.True(t, errors.Is(err, widevineproxy.ErrUpstreamUnavailable))
+	var upstreamErr *widevineproxy.UpstreamError
+	assert.True(t, errors.As(err, &upstreamErr))
+	assert.False(t, upstreamErr.Timeout())
+}
+
+func TestGetLicenseSignatureFailure(t *testing.T) {
+	svc := widevinetest.NewService("widevine_test", testKey, bytes.Repeat([]byte{0x01}, 16))
+	wp, _ := newTestProxy(t, svc)
+
+	_, err := wp.GetLicense(licenseChallenge)
+	assert.True(t, errors.Is(err, widevineproxy.ErrSignatureFailure))
+	assert.Empty(t, svc.Requests())
+}
+
+type renewingAuthority struct {
+	*testAuthority
+	renewals []*widevineproxy.Renewal
+	decision *widevineproxy.RenewalDecision
+}
+
+func (a *renewingAuthority) RenewLicense(ctx context.Context, renewal *widevineproxy.Renewal) (*widevineproxy.RenewalDecision, error) {
+	a.renewals = append(a.renewals, renewal)
+	return a.decision, nil
+}
+
+func TestRenewLicense(t *testing.T) {
+	svc := widevinetest.NewService("widevine_test", testKey, testIV)
+	wp, la := newTestProxy(t, svc)
+	ra := &renewingAuthority{testAuthority: la, decision: &widevineproxy.RenewalDecision{
+		Approved:        true,
+		PolicyOverrides: &widevineproxy.PolicyOverrides{CanPlay: true, CanRenew: true, LicenseDurationSeconds: 600},
+	}}
+	wp.LicenseAuthority = ra
+
+	response, err := wp.RenewLicense(widevinetest.RenewalChallenge(testLicenseID))
+	assert.NoError(t, err)
+	assert.Equal(t, "OK", response.Status)
+	if assert.Len(t, ra.renewals, 1) {
+		assert.Equal(t, base64.StdEncoding.EncodeToString(testLicenseID.SessionId), ra.renewals[0].LicenseID.SessionID)
+		assert.Equal(t, int64(60), ra.renewals[0].SecondsSinceStarted)
+	}
+	if renewals := svc.RequestsOf(widevinetest.RequestTypeRenewal); assert.Len(t, renewals, 1) {
+		assert.Equal(t, "widevine_test", renewals[0].Message.Provider)
+		assert.Equal(t, uint64(600), renewals[0].Message.PolicyOverrides.LicenseDurationSeconds)
+	}
+
+	_, err = wp.RenewLicense(licenseChallenge)
+	var malformed *widevineproxy.MalformedMessageError
+	assert.True(t, errors.As(err, &malformed))
+}
+
+func TestRenewLicenseDenied(t *testing.T) {
+	svc := widevinetest.NewService("widevine_test", testKey, testIV)
+	wp, la := newTestProxy(t, svc)
+	wp.LicenseAuthority = &renewingAuthority{testAuthority: la, decision: &widevineproxy.RenewalDecision{Reason: "rental expired"}}
+
+	_, err := wp.GetLicense(widevinetest.RenewalChallenge(testLicenseID))
+	var denied *widevineproxy.RenewalDeniedError
+	if assert.True(t, errors.As(err, &denied)) {
+		assert.Equal(t, "rental expired", denied.Reason)
+	}
+	assert.Empty(t, svc.Requests())
+}
+
+type releasingAuthority struct {
+	*testAuthority
+	releases []*widevineproxy.Release
+	err      error
+}
+
+func (a *releasingAuthority) LicenseReleased(ctx context.Context, release *widevineproxy.Release) error {
+	a.releases = append(a.releases, release)
+	return a.err
+}
+
+func TestReleaseLicense(t *testing.T) {
+	offlineLicenseID := &pb.LicenseIdentification{
+		RequestId: []byte("request-2"),
+		SessionId: []byte("session-2"),
+		Type:      pb.LicenseType_OFFLINE.Enum(),
+	}
+	svc := widevinetest.NewService("widevine_test", testKey, testIV)
+	wp, la := newTestProxy(t, svc)
+	ra := &releasingAuthority{testAuthority: la}
+	wp.LicenseAuthority = ra
+
+	response, err := wp.ReleaseLicense(widevinetest.ReleaseChallenge(offlineLicenseID))
+	assert.NoError(t, err)
+	assert.Equal(t, "OK", response.Status)
+	if assert.Len(t, ra.releases, 1) {
+		assert.Equal(t, "OFFLINE", ra.releases[0].LicenseID.Type)
+		assert.Equal(t, base64.StdEncoding.EncodeToString(offlineLicenseID.SessionId), ra.releases[0].LicenseID.SessionID)
+		assert.Equal(t, response, ra.releases[0].Response)
+	}
+	if releases := svc.RequestsOf(widevinetest.RequestTypeRelease); assert.Len(t, releases, 1) {
+		assert.Equal(t, "widevine_test", releases[0].Message.Provider)
+	}
+
+	ra.err = errors.New("ledger unavailable")
+	_, err = wp.GetLicense(widevinetest.ReleaseChallenge(offlineLicenseID))
+	assert.Equal(t, ra.err, err)
+
+	svc.Statuses[widevinetest.RequestTypeRelease] = widevinetest.StatusInternalError
+	_, err = wp.ReleaseLicense(widevinetest.ReleaseChallenge(offlineLicenseID))
+	assert.True(t, errors.Is(err, widevineproxy.ErrUpstreamUnavailable))
+	assert.Len(t, ra.releases, 2)
+}
+
+// deviceAuthorizer is an Authorizer relying on the device details of the PARSE_ONLY response.
+type deviceAuthorizer widevineproxy.AuthorizerFunc
+
+func (f deviceAuthorizer) Authorize(ctx context.Context, req *widevineproxy.AuthorizationRequest) error {
+	return f(ctx, req)
+}
+
+func (f deviceAuthorizer) RequiresDeviceInfo(psshData *widevineproxy.PsshData) bool { return true }
+
+func TestGetLicenseWithContextAuthorizer(t *testing.T) {
+	svc := widevinetest.NewService("widevine_test", testKey, testIV)
+	wp, _ := newTestProxy(t, svc)
+
+	var authorized *widevineproxy.AuthorizationRequest
+	wp.Authorizer = deviceAuthorizer(func(ctx context.Context, req *widevineproxy.AuthorizationRequest) error {
+		authorized = req
+		assert.Equal(t, req.Claims, widevineproxy.ClaimsFromContext(ctx))
+		if req.Claims == nil || req.Claims.UserID != "user-1" {
+			return widevineproxy.Deny("user not entitled to %s", req.PsshData.ContentID)
+		}
+		return nil
+	})
+
+	_, err := wp.GetLicenseWithContext(context.Background(), licenseChallenge, &widevineproxy.Claims{UserID: "user-1"})
+	assert.NoError(t, err)
+	if assert.NotNil(t, authorized) {
+		assert.Equal(t, svc.Device.Make, authorized.Device.Make)
+		assert.Equal(t, svc.Device.Model, authorized.Device.Model)
+		assert.Equal(t, svc.Device.SecurityLevel, authorized.Device.SecurityLevel)
+		assert.Equal(t, base64.StdEncoding.EncodeToString(testPssh.ContentId), authorized.PsshData.ContentID)
+	}
+	assert.Len(t, svc.RequestsOf(widevinetest.RequestTypeLicense), 1)
+
+	_, err = wp.GetLicenseWithContext(context.Background(), licenseChallenge, &widevineproxy.Claims{UserID: "user-2"})
+	var denied *widevineproxy.AuthorizationDeniedError
+	if assert.True(t, errors.As(err, &denied)) {
+		assert.Contains(t, denied.Reason, "not entitled")
+	}
+	_, err = wp.GetLicense(licenseChallenge)
+	assert.True(t, errors.As(err, &denied))
+	assert.Len(t, svc.RequestsOf(widevinetest.RequestTypeLicense), 1)
+}
+
+type uhdAuthority struct {
+	*testAuthority
+}
+
+func (a *uhdAuthority) BuildLicenseMessage(reqBody []byte, psshData *widevineproxy.PsshData) (*widevineproxy.Message, error) {
+	message, _ := a.testAuthority.BuildLicenseMessage(reqBody, psshData)
+	message.AllowedTrackTypes = widevineproxy.AllowedTrackTypeUHD1
+	message.ContentKeySpecs = []widevineproxy.ContentKeySpec{
+		{TrackType: widevineproxy.ContentTrackTypeAudio},
+		{TrackType: widevineproxy.ContentTrackTypeSD},
+		{TrackType: widevineproxy.ContentTrackTypeHD},
+		{TrackType: widevineproxy.ContentTrackTypeUHD1},
+	}
+	return message, nil
+}
+
+func TestGetLicenseRestrictedByClaims(t *testing.T) {
+	svc := widevinetest.NewService("widevine_test", testKey, testIV)
+	wp, la := newTestProxy(t, svc)
+	wp.LicenseAuthority = &uhdAuthority{la}
+
+	claims := &widevineproxy.Claims{
+		UserID:          "user-1",
+		ContentIDs:      []string{string(testPssh.ContentId)},
+		MaxTrackType:    widevineproxy.AllowedTrackTypeHD,
+		PolicyOverrides: &widevineproxy.PolicyOverrides{CanPlay: true, RentalDurationSeconds: 172800},
+	}
+	_, err := wp.GetLicenseWithContext(context.Background(), licenseChallenge, claims)
+	assert.NoError(t, err)
+	if licenses := svc.RequestsOf(widevinetest.RequestTypeLicense); assert.Len(t, licenses, 1) {
+		message := licenses[0].Message
+		assert.EqualValues(t, widevineproxy.AllowedTrackTypeHD, message.AllowedTrackTypes)
+		assert.Len(t, message.ContentKeySpecs, 3)
+		assert.Equal(t, uint64(172800), message.PolicyOverrides.RentalDurationSeconds)
+	}
+
+	claims.ContentIDs = []string{"another-content"}
+	_, err = wp.GetLicenseWithContext(context.Background(), licenseChallenge, claims)
+	var denied *widevineproxy.AuthorizationDeniedError
+	assert.True(t, errors.As(err, &denied))
+	assert.Len(t, svc.RequestsOf(widevinetest.RequestTypeLicense), 1)
+}
+
+func TestPolicyOverridesNarrow(t *testing.T) {
+	policy := &widevineproxy.PolicyOverrides{CanPlay: true, CanPersist: true, CanRenew: true, LicenseDurationSeconds: 3600, RenewalDelaySeconds: 60}
+	narrowed := policy.Narrow(&widevineproxy.PolicyOverrides{CanPlay: true, CanRenew: true, LicenseDurationSeconds: 7200, RentalDurationSeconds: 600, AlwaysIncludeClientId: true})
+	assert.Equal(t, &widevineproxy.PolicyOverrides{
+		CanPlay:                true,
+		CanRenew:               true,
+		LicenseDurationSeconds: 3600,
+		RentalDurationSeconds:  600,
+		RenewalDelaySeconds:    60,
+		AlwaysIncludeClientId:  true,
+	}, narrowed)
+	assert.True(t, policy.CanPersist)
+
+	// Overrides granting what the policy withholds grant nothing.
+	assert.Equal(t, &widevineproxy.PolicyOverrides{CanPlay: true}, (*widevineproxy.PolicyOverrides)(nil).Narrow(
+		&widevineproxy.PolicyOverrides{CanPlay: true, CanPersist: true, CanRenew: true}))
+}
+
+func TestClaimsRestrict(t *testing.T) {
+	// The specs may be shared with the authority, which must find them untouched.
+	shared := []widevineproxy.ContentKeySpec{
+		{TrackType: widevineproxy.ContentTrackTypeAudio},
+		{TrackType: widevineproxy.ContentTrackTypeUHD1},
+		{TrackType: widevineproxy.ContentTrackTypeSD},
+		{TrackType: widevineproxy.ContentTrackTypeHD},
+	}
+	message := &widevineproxy.Message{ContentKeySpecs: shared}
+	(&widevineproxy.Claims{MaxTrackType: widevineproxy.AllowedTrackTypeHD}).Restrict(message)
+	assert.Equal(t, []widevineproxy.ContentKeySpec{
+		{TrackType: widevineproxy.ContentTrackTypeAudio},
+		{TrackType: widevineproxy.ContentTrackTypeSD},
+		{TrackType: widevineproxy.ContentTrackTypeHD},
+	}, message.ContentKeySpecs)
+	assert.EqualValues(t, widevineproxy.ContentTrackTypeUHD1, shared[1].TrackType)
+	assert.EqualValues(t, widevineproxy.ContentTrackTypeHD, shared[3].TrackType)
+}
+
+// policyFunc is a Policy relying on the device details of the PARSE_ONLY response.
+type policyFunc func(ctx context.Context, req *widevineproxy.AuthorizationRequest, message *widevineproxy.Message) error
+
+func (f policyFunc) ApplyPolicy(ctx context.Context, req *widevineproxy.AuthorizationRequest, message *widevineproxy.Message) error {
+	return f(ctx, req, message)
+}
+
+func (f policyFunc) RequiresDeviceInfo(psshData *widevineproxy.PsshData) bool { return true }
+
+func TestGetLicensePolicy(t *testing.T) {
+	svc := widevinetest.NewService("widevine_test", testKey, testIV)
+	wp, la := newTestProxy(t, svc)
+	wp.LicenseAuthority = &uhdAuthority{la}
+	wp.Policy = policyFunc(func(ctx context.Context, req *widevineproxy.AuthorizationRequest, message *widevineproxy.Message) error {
+		if req.Device.SecurityLevel != svc.Device.SecurityLevel {
+			return widevineproxy.Deny("unknown device")
+		}
+		message.AllowedTrackTypes = widevineproxy.AllowedTrackTypeUHD1
+		message.PolicyOverrides = &widevineproxy.PolicyOverrides{CanPlay: true, LicenseDurationSeconds: 3600}
+		return nil
+	})
+
+	// The claims restrict the message once the policy is applied, their overrides narrowing those of the policy down.
+	claims := &widevineproxy.Claims{
+		UserID:          "user-1",
+		MaxTrackType:    widevineproxy.AllowedTrackTypeHD,
+		PolicyOverrides: &widevineproxy.PolicyOverrides{CanPlay: true, CanPersist: true, LicenseDurationSeconds: 7200, RentalDurationSeconds: 7200},
+	}
+	_, err := wp.GetLicenseWithContext(context.Background(), licenseChallenge, claims)
+	assert.NoError(t, err)
+	assert.Len(t, svc.RequestsOf(widevinetest.RequestTypeParseOnly), 1)
+	if licenses := svc.RequestsOf(widevinetest.RequestTypeLicense); assert.Len(t, licenses, 1) {
+		message := licenses[0].Message
+		assert.EqualValues(t, widevineproxy.AllowedTrackTypeHD, message.AllowedTrackTypes)
+		assert.Equal(t, uint64(3600), message.PolicyOverrides.LicenseDurationSeconds)
+		assert.Equal(t, uint64(7200), message.PolicyOverrides.RentalDurationSeconds)
+		assert.True(t, message.PolicyOverrides.CanPlay)
+		assert.False(t, message.PolicyOverrides.CanPersist)
+	}
+
+	wp.Policy = policyFunc(func(ctx context.Context, req *widevineproxy.AuthorizationRequest, message *widevineproxy.Message) error {
+		return widevineproxy.Deny("no policy")
+	})
+	_, err = wp.GetLicense(licenseChallenge)
+	var denied *widevineproxy.AuthorizationDeniedError
+	assert.True(t, errors.As(err, &denied))
+	assert.Len(t, svc.RequestsOf(widevinetest.RequestTypeLicense), 1)
+}
+
+// requestAuthority is a RequestLicenseAuthority building the license from the PARSE_ONLY response.
+type requestAuthority struct {
+	*uhdAuthority
+	req *widevineproxy.AuthorizationRequest
+}
+
+func (a *requestAuthority) BuildLicenseMessageWithRequest(ctx context.Context, reqBody []byte, req *widevineproxy.AuthorizationRequest) (*widevineproxy.Message, error) {
+	a.req = req
+	return a.uhdAuthority.BuildLicenseMessage(reqBody, &req.PsshData)
+}
+
+func (a *requestAuthority) RequiresDeviceInfo(psshData *widevineproxy.PsshData) bool { return true }
+
+func TestGetLicenseRequestAuthority(t *testing.T) {
+	svc := widevinetest.NewService("widevine_test", testKey, testIV)
+	svc.Device.ClientMaxHdcpVersion = "HDCP_V2_2"
+	wp, la := newTestProxy(t, svc)
+	ra := &requestAuthority{uhdAuthority: &uhdAuthority{la}}
+	wp.LicenseAuthority = ra
+
+	claims := &widevineproxy.Claims{UserID: "user-1"}
+	_, err := wp.GetLicenseWithContext(context.Background(), licenseChallenge, claims)
+	assert.NoError(t, err)
+	if assert.NotNil(t, ra.req) && assert.NotNil(t, ra.req.Parsed) {
+		assert.Equal(t, claims, ra.req.Claims)
+		assert.Equal(t, svc.Device.DRMCERTSerialNumber, ra.req.Parsed.DRMCERTSerialNumber)
+		assert.EqualValues(t, widevineproxy.HDCPVersionV2d2, ra.req.Device.MaxHDCPVersion)
+		assert.Equal(t, svc.Device.PlatformVerificationStatus, ra.req.Device.PlatformVerificationStatus)
+	}
+}
+
+func TestDeviceGate(t *testing.T) {
+	gate := widevineproxy.DefaultDeviceGate()
+	newMessage := func() *widevineproxy.Message {
+		message, _ := (&uhdAuthority{&testAuthority{}}).BuildLicenseMessage(nil, &widevineproxy.PsshData{})
+		return message
+	}
+	trackTypes := func(message *widevineproxy.Message) []widevineproxy.ContentTrackType {
+		var types []widevineproxy.ContentTrackType
+		for _, spec := range message.ContentKeySpecs {
+			types = append(types, spec.TrackType)
+		}
+		return types
+	}
+
+	tests := []struct {
+		device  widevineproxy.DeviceInfo
+		allowed widevineproxy.AllowedTrackType
+		tracks  []widevineproxy.ContentTrackType
+	}{
+		{
+			widevineproxy.DeviceInfo{SecurityLevel: 3, MaxHDCPVersion: widevineproxy.HDCPVersionNone},
+			widevineproxy.AllowedTrackTypeSD,
+			[]widevineproxy.ContentTrackType{widevineproxy.ContentTrackTypeAudio, widevineproxy.ContentTrackTypeSD},
+		},
+		{
+			widevineproxy.DeviceInfo{SecurityLevel: 3, MaxHDCPVersion: widevineproxy.HDCPVersionV2d2},
+			widevineproxy.AllowedTrackTypeHD,
+			[]widevineproxy.ContentTrackType{widevineproxy.ContentTrackTypeAudio, widevineproxy.ContentTrackTypeSD, widevineproxy.ContentTrackTypeHD},
+		},
+		{
+			widevineproxy.DeviceInfo{SecurityLevel: 1, MaxHDCPVersion: widevineproxy.HDCPVersionV2d2},
+			widevineproxy.AllowedTrackTypeUHD1,
+			[]widevineproxy.ContentTrackType{widevineproxy.ContentTrackTypeAudio, widevineproxy.ContentTrackTypeSD, widevineproxy.ContentTrackTypeHD, widevineproxy.ContentTrackTypeUHD1},
+		},
+		{
+			widevineproxy.DeviceInfo{SecurityLevel: 1, MaxHDCPVersion: widevineproxy.HDCPVersionNoDigtalOutput},
+			widevineproxy.AllowedTrackTypeUHD1,
+			[]widevineproxy.ContentTrackType{widevineproxy.ContentTrackTypeAudio, widevineproxy.ContentTrackTypeSD, widevineproxy.ContentTrackTypeHD, widevineproxy.ContentTrackTypeUHD1},
+		},
+	}
+	for _, test := range tests {
+		message := newMessage()
+		req := &widevineproxy.AuthorizationRequest{Device: test.device}
+		assert.NoError(t, gate.ApplyPolicy(context.Background(), req, message))
+		assert.EqualValues(t, test.allowed, message.AllowedTrackTypes, test.device)
+		assert.Equal(t, test.tracks, trackTypes(message), test.device)
+	}
+
+	message := newMessage()
+	req := &widevineproxy.AuthorizationRequest{Device: widevineproxy.DeviceInfo{SecurityLevel: 1, MaxHDCPVersion: widevineproxy.HDCPVersionV2d2}}
+	gate.ApplyPolicy(context.Background(), req, message)
+	assert.Empty(t, message.ContentKeySpecs[1].OutputProtection.HDCP)
+	assert.EqualValues(t, widevineproxy.HDCPVersionV1, message.ContentKeySpecs[2].OutputProtection.HDCP)
+	assert.EqualValues(t, widevineproxy.HDCPVersionV2d2, message.ContentKeySpecs[3].OutputProtection.HDCP)
+
+	// The specs may be shared with the authority, which must find them untouched.
+	shared := []widevineproxy.ContentKeySpec{
+		{TrackType: widevineproxy.ContentTrackTypeUHD1},
+		{TrackType: widevineproxy.ContentTrackTypeHD},
+	}
+	message = &widevineproxy.Message{ContentKeySpecs: shared}
+	gate.ApplyPolicy(context.Background(), &widevineproxy.AuthorizationRequest{Device: widevineproxy.DeviceInfo{SecurityLevel: 3, MaxHDCPVersion: widevineproxy.HDCPVersionV1}}, message)
+	if assert.Len(t, message.ContentKeySpecs, 1) {
+		assert.EqualValues(t, widevineproxy.HDCPVersionV1, message.ContentKeySpecs[0].OutputProtection.HDCP)
+	}
+	assert.Equal(t, []widevineproxy.ContentKeySpec{
+		{TrackType: widevineproxy.ContentTrackTypeUHD1},
+		{TrackType: widevineproxy.ContentTrackTypeHD},
+	}, shared)
+
+	gate.Tracks[widevineproxy.ContentTrackTypeHD] = widevineproxy.TrackRequirement{VerifiedPlatform: true}
+	message = newMessage()
+	req.Device.PlatformVerificationStatus = "PLATFORM_UNVERIFIED"
+	gate.ApplyPolicy(context.Background(), req, message)
+	assert.EqualValues(t, widevineproxy.AllowedTrackTypeSD, message.AllowedTrackTypes)
+	assert.Len(t, message.ContentKeySpecs, 3)
+	assert.True(t, gate.RequiresDeviceInfo(nil))
+	assert.False(t, (&widevineproxy.DeviceGate{}).RequiresDeviceInfo(nil))
+}
+
+func TestGetLicenseDeviceGate(t *testing.T) {
+	svc := widevinetest.NewService("widevine_test", testKey, testIV)
+	wp, la := newTestProxy(t, svc)
+	wp.LicenseAuthority = &uhdAuthority{la}
+	wp.Policy = widevineproxy.PolicyChain{widevineproxy.DefaultDeviceGate()}
+
+	_, err := wp.GetLicense(licenseChallenge)
+	assert.NoError(t, err)
+	assert.Len(t, svc.RequestsOf(widevinetest.RequestTypeParseOnly), 1)
+	if licenses := svc.RequestsOf(widevinetest.RequestTypeLicense); assert.Len(t, licenses, 1) {
+		message := licenses[0].Message
+		assert.EqualValues(t, widevineproxy.AllowedTrackTypeSD, message.AllowedTrackTypes)
+		assert.Len(t, message.ContentKeySpecs, 2)
+	}
+}
+
+// recordingTracker is a LicenseTracker recording its calls, failing with the errors set by the test.
+type recordingTracker struct {
+	calls    []string
+	licenses []*widevineproxy.IssuedLicense
+	errs     map[string]error
+}
+
+func (r *recordingTracker) record(call string) error {
+	r.calls = append(r.calls, call)
+	return r.errs[call]
+}
+
+func (r *recordingTracker) CheckLicense(ctx context.Context, req *widevineproxy.AuthorizationRequest) error {
+	return r.record("check")
+}
+
+func (r *recordingTracker) LicenseIssued(ctx context.Context, license *widevineproxy.IssuedLicense) error {
+	r.licenses = append(r.licenses, license)
+	return r.record("issued")
+}
+
+func (r *recordingTracker) LicenseRenewed(ctx context.Context, renewal *widevineproxy.Renewal) error {
+	return r.record("renewed")
+}
+
+func (r *recordingTracker) LicenseReleased(ctx context.Context, release *widevineproxy.Release) error {
+	return r.record("released")
+}
+
+func TestGetLicenseTracker(t *testing.T) {
+	svc := widevinetest.NewService("widevine_test", testKey, testIV)
+	wp, _ := newTestProxy(t, svc)
+	tracker := &recordingTracker{errs: map[string]error{}}
+	wp.Tracker = widevineproxy.TrackerChain{tracker}
+	wp.Policy = policyFunc(func(ctx context.Context, req *widevineproxy.AuthorizationRequest, message *widevineproxy.Message) error {
+		message.PolicyOverrides = &widevineproxy.PolicyOverrides{CanPlay: true, CanRenew: true}
+		return nil
+	})
+
+	response, err := wp.GetLicense(licenseChallenge)
+	assert.NoError(t, err)
+	if assert.Len(t, tracker.licenses, 1) {
+		license := tracker.licenses[0]
+		assert.Equal(t, response, license.Response)
+		assert.True(t, license.Message.PolicyOverrides.CanRenew)
+		assert.Equal(t, base64.StdEncoding.EncodeToString(testPssh.ContentId), license.Request.PsshData.ContentID)
+		assert.Equal(t, widevineproxy.LicenseTypeStreaming, license.Request.LicenseType)
+	}
+	message, err := widevineproxy.ParseCDMMessage(widevinetest.LicenseChallenge(testPssh, pb.LicenseType_OFFLINE))
+	if assert.NoError(t, err) {
+		assert.Equal(t, widevineproxy.LicenseTypeOffline, message.LicenseType())
+	}
+	_, err = wp.GetLicense(widevinetest.RenewalChallenge(testLicenseID))
+	assert.NoError(t, err)
+	_, err = wp.GetLicense(widevinetest.ReleaseChallenge(testLicenseID))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"check", "issued", "renewed", "released"}, tracker.calls)
+
+	// A refused check spares the license service, a failed record withholds the license.
+	tracker.errs["check"] = widevineproxy.Deny("too many streams")
+	_, err = wp.GetLicense(licenseChallenge)
+	var denied *widevineproxy.AuthorizationDeniedError
+	assert.True(t, errors.As(err, &denied))
+	assert.Len(t, svc.RequestsOf(widevinetest.RequestTypeLicense), 1)
+	tracker.errs["check"] = nil
+	tracker.errs["issued"] = widevineproxy.Deny("too many streams")
+	response, err = wp.GetLicense(licenseChallenge)
+	assert.True(t, errors.As(err, &denied))
+	assert.Nil(t, response)
+	assert.Len(t, svc.RequestsOf(widevinetest.RequestTypeLicense), 2)
+
+	tracker.errs["renewed"] = &widevineproxy.RenewalDeniedError{Reason: "session expired"}
+	_, err = wp.GetLicense(widevinetest.RenewalChallenge(testLicenseID))
+	var renewalDenied *widevineproxy.RenewalDeniedError
+	assert.True(t, errors.As(err, &renewalDenied))
+	assert.Len(t, svc.RequestsOf(widevinetest.RequestTypeRenewal), 1)
+}
+
+type contextAuthority struct {
+	*testAuthority
+	claims *widevineproxy.Claims
+}
+
+func (a *contextAuthority) BuildLicenseMessageWithContext(ctx context.Context, reqBody []byte, psshData *widevineproxy.PsshData) (*widevineproxy.Message, error) {
+	a.claims = widevineproxy.ClaimsFromContext(ctx)
+	return a.testAuthority.BuildLicenseMessage(reqBody, psshData)
+}
+
+func TestGetLicenseWithContextAuthority(t *testing.T) {
+	svc := widevinetest.NewService("widevine_test", testKey, testIV)
+	wp, la := newTestProxy(t, svc)
+	ca := &contextAuthority{testAuthority: la}
+	wp.LicenseAuthority = ca
+
+	claims := &widevineproxy.Claims{UserID: "user-1"}
+	_, err := wp.GetLicenseWithContext(context.Background(), licenseChallenge, claims)
+	assert.NoError(t, err)
+	assert.Equal(t, claims, ca.claims)
+}
+
+func TestGetLicenseCancelled(t *testing.T) {
+	svc := widevinetest.NewService("widevine_test", testKey, testIV)
+	wp, _ := newTestProxy(t, svc)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := wp.GetLicenseWithContext(ctx, licenseChallenge, nil)
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.Empty(t, svc.Requests())
+}
+
+func TestGetLicenseDeadline(t *testing.T) {
+	svc := widevinetest.NewService("widevine_test", testKey, testIV)
+	release := make(chan struct{})
+	defer close(release)
+	slow := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+		svc.ServeHTTP(w, r)
+	})
+	wp, _ := newTestProxy(t, slow)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := wp.GetLicenseWithContext(ctx, licenseChallenge, nil)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+
+	wp.CallTimeout = 50 * time.Millisecond
+	_, err = wp.GetLicense(licenseChallenge)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+}
+
+func TestGetLicenseTraceMetadata(t *testing.T) {
+	svc := widevinetest.NewService("widevine_test", testKey, testIV)
+	var requestIDs []string
+	wp, _ := newTestProxy(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestIDs = append(requestIDs, r.Header.Get("X-Request-Id"))
+		svc.ServeHTTP(w, r)
+	}))
+
+	ctx := widevineproxy.WithTraceMetadata(context.Background(), widevineproxy.TraceMetadata{"X-Request-Id": "req-1"})
+	ctx = widevineproxy.WithTraceMetadata(ctx, widevineproxy.TraceMetadata{"Traceparent": "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"})
+	_, err := wp.GetLicenseWithContext(ctx, licenseChallenge, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"req-1"}, requestIDs)
+	assert.Len(t, widevineproxy.TraceMetadataFromContext(ctx), 2)
+}
+
+// flakyHandler answers 503 to the first failures calls and forwards the others to svc.
+func flakyHandler(svc http.Handler, failures int) http.Handler {
+	calls := 0
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		svc.ServeHTTP(w, r)
+	})
+}
+
+func TestGetLicenseRetry(t *testing.T) {
+	svc := widevinetest.NewService("widevine_test", testKey, testIV)
+	wp, _ := newTestProxy(t, flakyHandler(svc, 2))
+	wp.Retry = widevineproxy.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+
+	response, err := wp.GetLicense(widevinetest.ServiceCertificateRequest)
+	assert.NoError(t, err)
+	assert.Equal(t, "OK", response.Status)
+	assert.Equal(t, widevineproxy.CircuitClosed, wp.Breaker.State())
+
+	svc.Statuses[widevinetest.RequestTypeCertificate] = widevinetest.StatusInternalError
+	_, err = wp.GetLicense(widevinetest.ServiceCertificateRequest)
+	assert.True(t, errors.Is(err, widevineproxy.ErrUpstreamUnavailable))
+	assert.Len(t, svc.RequestsOf(widevinetest.RequestTypeCertificate), 4)
+
+	wp, _ = newTestProxy(t, flakyHandler(svc, 3))
+	wp.Retry = widevineproxy.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}
+	_, err = wp.GetLicense(widevinetest.ServiceCertificateRequest)
+	assert.True(t, errors.Is(err, widevineproxy.ErrUpstreamUnavailable))
+	var httpErr *widevineproxy.UpstreamHTTPError
+	assert.True(t, errors.As(err, &httpErr))
+	assert.Equal(t, http.StatusServiceUnavailable, httpErr.StatusCode)
+}
+
+func TestGetLicenseRetryPermanentError(t *testing.T) {
+	svc := widevinetest.NewService("widevine_test", testKey, testIV)
+	wp, la := newTestProxy(t, svc)
+	wp.Retry = widevineproxy.RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond}
+	wp.Breaker = widevineproxy.NewCircuitBreaker(1, time.Minute)
+
+	// A retry would find the circuit opened by the first attempt.
+	la.url = strings.Replace(la.url, "http://", "ftp://", 1)
+	_, err := wp.GetLicense(widevinetest.ServiceCertificateRequest)
+	var urlErr *url.Error
+	assert.True(t, errors.As(err, &urlErr))
+	assert.False(t, errors.Is(err, widevineproxy.ErrCircuitOpen))
+	assert.Equal(t, widevineproxy.CircuitClosed, wp.Breaker.State())
+
+	// A refused connection is transient.
+	ts := httptest.NewServer(svc)
+	ts.Close()
+	la.url = ts.URL
+	_, err = wp.GetLicense(widevinetest.ServiceCertificateRequest)
+	assert.True(t, errors.Is(err, widevineproxy.ErrCircuitOpen))
+	assert.Equal(t, widevineproxy.CircuitOpen, wp.Breaker.State())
+}
+
+func TestGetLicenseCircuitBreaker(t *testing.T) {
+	svc := widevinetest.NewService("widevine_test", testKey, testIV)
+	wp, _ := newTestProxy(t, flakyHandler(svc, 2))
+	wp.Retry = widevineproxy.RetryPolicy{MaxAttempts: 1}
+	now := time.Unix(1600000000, 0)
+	wp.Breaker = widevineproxy.NewCircuitBreaker(2, time.Minute)
+	wp.Breaker.Now = func() time.Time { return now }
+	var transitions []string
+	wp.Breaker.OnStateChange = func(from, to widevineproxy.CircuitState) {
+		transitions = append(transitions, to.String())
+	}
+
+	for i := 0; i < 2; i++ {
+		_, err := wp.GetLicense(licenseChallenge)
+		assert.Error(t, err)
+	}
+	assert.Equal(t, widevineproxy.CircuitOpen, wp.Breaker.State())
+
+	_, err := wp.GetLicense(licenseChallenge)
+	assert.True(t, errors.Is(err, widevineproxy.ErrCircuitOpen))
+	assert.Empty(t, svc.Requests())
+
+	now = now.Add(time.Minute)
+	_, err = wp.GetLicense(licenseChallenge)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"open", "half-open", "closed"}, transitions)
+
+	stats := wp.Breaker.Stats()
+	assert.Equal(t, "closed", stats.State)
+	assert.Equal(t, uint64(1), stats.Trips)
+	assert.Equal(t, uint64(1), stats.Rejected)
+	assert.Equal(t, uint64(2), stats.Failures)
+}
+
+func TestCircuitBreakerProbe(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	b := widevineproxy.NewCircuitBreaker(1, time.Minute)
+	b.Now = func() time.Time { return now }
+
+	stale, ok := b.Allow()
+	assert.True(t, ok)
+	call, _ := b.Allow()
+	call.Failure()
+	assert.Equal(t, widevineproxy.CircuitOpen, b.State())
+
+	// The calls allowed before the circuit opened change nothing.
+	stale.Success()
+	assert.Equal(t, widevineproxy.CircuitOpen, b.State())
+	stale.Failure()
+	stale.Abandon()
+
+	now = now.Add(time.Minute)
+	probe, ok := b.Allow()
+	assert.True(t, ok)
+	assert.Equal(t, widevineproxy.CircuitHalfOpen, b.State())
+	_, ok = b.Allow()
+	assert.False(t, ok)
+	stale.Success()
+	stale.Abandon()
+	assert.Equal(t, widevineproxy.CircuitHalfOpen, b.State())
+	_, ok = b.Allow()
+	assert.False(t, ok)
+
+	// An abandoned probe lets the next call probe, whose failure opens the circuit again.
+	probe.Abandon()
+	probe, ok = b.Allow()
+	assert.True(t, ok)
+	probe.Failure()
+	assert.Equal(t, widevineproxy.CircuitOpen, b.State())
+
+	now = now.Add(time.Minute)
+	probe, _ = b.Allow()
+	probe.Success()
+	assert.Equal(t, widevineproxy.CircuitClosed, b.State())
+	stats := b.Stats()
+	assert.Equal(t, uint64(2), stats.Trips)
+	assert.Equal(t, uint64(2), stats.Rejected)
+	assert.Equal(t, 0, stats.ConsecutiveFailures)
+}
+
+var testPlayReadyObject = widevinetest.PlayReadyObject(testPssh.KeyId)
+
+// psshBox wraps data in a version 0 pssh box of the given DRM system.
+func psshBox(systemID string, data []byte) []byte {
+	id, _ := hex.DecodeString(systemID)
+	box := make([]byte, 32, 32+len(data))
+	binary.BigEndian.PutUint32(box, uint32(32+len(data)))
+	copy(box[4:], "pssh")
+	copy(box[12:], id)
+	binary.BigEndian.PutUint32(box[28:], uint32(len(data)))
+	return append(box, data...)
+}
+
+func TestCDMMessagePsshData(t *testing.T) {
+	header, _ := proto.Marshal(testPssh)
+	expected := &widevineproxy.PsshData{
+		KeyID: []string{
+			base64.StdEncoding.EncodeToString(testPssh.KeyId[0]),
+			base64.StdEncoding.EncodeToString(testPssh.KeyId[1]),
+		},
+		ContentID: base64.StdEncoding.EncodeToString(testPssh.ContentId),
+	}
+	playready := psshBox(widevineutils.PLAYREADY_SYSTEM_ID, testPlayReadyObject)
+	widevine := psshBox(widevineutils.WIDEVINE_SYSTEM_ID, header)
+	systemID, _ := hex.DecodeString(widevineutils.WIDEVINE_SYSTEM_ID)
+	widevineV1, _ := widevineutils.BuildPSSHBox(1, systemID, testPssh.KeyId, header)
+
+	challenges := map[string][]byte{
+		"widevine pssh data": licenseChallenge,
+		"cenc init data":     widevinetest.InitDataChallenge(append(playready, widevine...), pb.LicenseType_STREAMING),
+		"cenc init data v1":  widevinetest.InitDataChallenge(append(playready, widevineV1...), pb.LicenseType_STREAMING),
+	}
+	for name, challenge := range challenges {
+		message, err := widevineproxy.ParseCDMMessage(challenge)
+		assert.NoError(t, err)
+		psshData, ok := message.PsshData()
+		assert.True(t, ok, name)
+		assert.Equal(t, expected, psshData, name)
+	}
+
+	message, _ := widevineproxy.ParseCDMMessage(widevinetest.InitDataChallenge(playready, pb.LicenseType_STREAMING))
+	_, ok := message.PsshData()
+	assert.False(t, ok)
+
+	// A box smaller than its own header is rejected, not sliced.
+	corrupt := []byte{0, 0, 0, 4, 'p', 's', 's', 'h', 0, 0, 0, 0}
+	message, _ = widevineproxy.ParseCDMMessage(widevinetest.InitDataChallenge(corrupt, pb.LicenseType_STREAMING))
+	_, ok = message.PsshData()
+	assert.False(t, ok)
+}
+
+type deviceAuthority struct {
+	*testAuthority
+}
+
+func (a *deviceAuthority) RequiresDeviceInfo(psshData *widevineproxy.PsshData) bool {
+	return psshData.ContentID == base64.StdEncoding.EncodeToString(testPssh.ContentId)
+}
+
+func TestGetLicenseParseOnlyFallback(t *testing.T) {
+	svc := widevinetest.NewService("widevine_test", testKey, testIV)
+	wp, la := newTestProxy(t, svc)
+
+	playready := psshBox(widevineutils.PLAYREADY_SYSTEM_ID, testPlayReadyObject)
+	_, err := wp.GetLicense(widevinetest.InitDataChallenge(playready, pb.LicenseType_STREAMING))
+	assert.NoError(t, err)
+	assert.Len(t, svc.RequestsOf(widevinetest.RequestTypeParseOnly), 1)
+	assert.Equal(t, svc.PsshData, *la.psshData)
+
+	// Corrupt init data is left to the license service as well.
+	corrupt := []byte{0, 0, 0, 1, 'p', 's', 's', 'h', 0, 0, 0, 0, 0, 0, 0, 10, 0, 0, 0, 0}
+	assert.NotPanics(t, func() {
+		_, err = wp.GetLicense(widevinetest.InitDataChallenge(corrupt, pb.LicenseType_STREAMING))
+	})
+	assert.NoError(t, err)
+	assert.Len(t, svc.RequestsOf(widevinetest.RequestTypeParseOnly), 2)
+
+	wp.LicenseAuthority = &deviceAuthority{la}
+	_, err = wp.GetLicense(licenseChallenge)
+	assert.NoError(t, err)
+	assert.Len(t, svc.RequestsOf(widevinetest.RequestTypeParseOnly), 3)
+	assert.Len(t, svc.RequestsOf(widevinetest.RequestTypeLicense), 3)
+}
+
+func TestTenantRegistry(t *testing.T) {
+	svc := widevinetest.NewService("widevine_test", testKey, testIV)
+	newTenant := func(name string) *widevineproxy.Tenant {
+		wp, _ := newTestProxy(t, svc)
+		return &widevineproxy.Tenant{Name: name, Proxy: wp}
+	}
+	a, b, c := newTenant("brand-a"), newTenant("brand-b"), newTenant("brand-c")
+	a.PathPrefix = "/brand-a"
+	b.HeaderValue = "b"
+	b.Hosts = []string{"license.brand-b.com"}
+	c.PathPrefix = "/brand-a/c"
+
+	registry := widevineproxy.NewTenantRegistry("X-Tenant")
+	for _, tenant := range []*widevineproxy.Tenant{a, b, c} {
+		assert.NoError(t, registry.Add(tenant))
+	}
+	assert.Error(t, registry.Add(newTenant("brand-a")), "duplicate name")
+	assert.Error(t, registry.Add(&widevineproxy.Tenant{Name: "brand-d", Proxy: a.Proxy, Hosts: []string{"LICENSE.brand-b.com"}}), "duplicate host")
+	assert.Error(t, registry.Add(&widevineproxy.Tenant{Name: "brand-d", Proxy: a.Proxy, PathPrefix: "/brand-d/"}), "trailing slash")
+	assert.Equal(t, []*widevineproxy.Tenant{a, b, c}, registry.Tenants())
+	assert.Equal(t, "brand-a", a.Proxy.LogFields["tenant"])
+
+	resolve := func(target, host, header string) (*widevineproxy.Tenant, error) {
+		req := httptest.NewRequest(http.MethodPost, target, nil)
+		req.Host = host
+		if header != "" {
+			req.Header.Set("X-Tenant", header)
+		}
+		return registry.Resolve(req)
+	}
+	requests := []struct {
+		target, host, header string
+		expected             *widevineproxy.Tenant
+	}{
+		{"/brand-a/license", "proxy.example.com", "b", a},
+		{"/brand-a/c/license", "proxy.example.com", "", c},
+		{"/license", "proxy.example.com", "b", b},
+		{"/license", "license.brand-b.com:8443", "", b},
+	}
+	for _, r := range requests {
+		tenant, err := resolve(r.target, r.host, r.header)
+		assert.NoError(t, err, r.target)
+		assert.Equal(t, r.expected, tenant, r.target)
+	}
+
+	_, err := resolve("/brand-ab/license", "proxy.example.com", "")
+	assert.True(t, errors.Is(err, widevineproxy.ErrUnknownTenant))
+	_, err = resolve("/license", "proxy.example.com", "z")
+	assert.True(t, errors.Is(err, widevineproxy.ErrUnknownTenant))
+
+	// The host of a tenant serves no other tenant, whatever the client sends.
+	a.Hosts = []string{"license.brand-a.com"}
+	_, err = resolve("/license", "license.brand-a.com", "b")
+	assert.True(t, errors.Is(err, widevineproxy.ErrTenantMismatch))
+	_, err = resolve("/brand-a/license", "license.brand-b.com", "")
+	assert.True(t, errors.Is(err, widevineproxy.ErrTenantMismatch))
+	_, err = resolve("/license", "license.brand-a.com", "z")
+	assert.True(t, errors.Is(err, widevineproxy.ErrUnknownTenant))
+	tenant, err := resolve("/license", "license.brand-b.com", "b")
+	assert.NoError(t, err)
+	assert.Equal(t, b, tenant)
+	a.Hosts = nil
+
+	registry.Default = c
+	tenant, err = resolve("/license", "proxy.example.com", "")
+	assert.NoError(t, err)
+	assert.Equal(t, c, tenant)
+}
+
+func TestProxyLogFields(t *testing.T) {
+	svc := widevinetest.NewService("widevine_test", testKey, testIV)
+	wp, _ := newTestProxy(t, svc)
+	var logs bytes.Buffer
+	wp.Logger.SetOutput(&logs)
+	assert.NoError(t, widevineproxy.NewTenantRegistry("").Add(&widevineproxy.Tenant{Name: "brand-a", Proxy: wp}))
+
+	_, err := wp.GetLicense(licenseChallenge)
+	assert.NoError(t, err)
+	assert.Contains(t, logs.String(), "tenant=brand-a")
+}
@@ -0,0 +1,71 @@
+package widevineproxy
+
+import (
+	"encoding/base64"
+
+	pb "github.com/cooomma/widevine-proxy/proto"
+	widevineutils "github.com/cooomma/widevine-proxy/utils"
+	proto "github.com/golang/protobuf/proto"
+)
+
+// DeviceInfoRequirer is implemented by a LicenseAuthority, an Authorizer or a Policy needing the device details
+// (make, model, security level, ...) known only to the license service.
+// The proxy then makes a PARSE_ONLY request before building the license, instead of parsing the challenge locally.
+type DeviceInfoRequirer interface {
+	RequiresDeviceInfo(psshData *PsshData) bool
+}
+
+// PsshData returns the key ids and content id of the Widevine pssh data carried by a new license request,
+// encoded as in the PARSE_ONLY response of the license service.
+// It reports false when the challenge carries no Widevine pssh data, e.g. for WebM content.
+func (m *CDMMessage) PsshData() (*PsshData, bool) {
+	contentID := m.LicenseRequest.GetContentId()
+	if pssh := contentID.GetWidevinePsshData().GetPsshData(); len(pssh) > 0 {
+		return psshDataOf(pssh[0])
+	}
+	if initData := contentID.GetInitData(); initData.GetInitDataType() == pb.LicenseRequest_ContentIdentification_InitData_CENC {
+		if data, ok := widevinePsshBoxData(initData.GetInitData()); ok {
+			return psshDataOf(data)
+		}
+	}
+	return nil, false
+}
+
+// psshDataOf decodes the data of a Widevine pssh box, a serialized WidevineCencHeader.
+func psshDataOf(data []byte) (*PsshData, bool) {
+	header := &pb.WidevineCencHeader{}
+	if err := proto.Unmarshal(data, header); err != nil {
+		return nil, false
+	}
+	if len(header.GetKeyId()) == 0 && len(header.GetContentId()) == 0 {
+		return nil, false
+	}
+	psshData := &PsshData{ContentID: base64.StdEncoding.EncodeToString(header.GetContentId())}
+	for _, keyID := range header.GetKeyId() {
+		psshData.KeyID = append(psshData.KeyID, base64.StdEncoding.EncodeToString(keyID))
+	}
+	return psshData, true
+}
+
+// widevinePsshBoxData returns the data of the first Widevine box among the pssh boxes of initData.
+func widevinePsshBoxData(initData []byte) ([]byte, bool) {
+	summaries, err := widevineutils.ParseBoxes(initData)
+	if err != nil {
+		return nil, false
+	}
+	for _, summary := range summaries {
+		if summary.Widevine != nil {
+			return summary.DataRaw, true
+		}
+	}
+	return nil, false
+}
+
+func (wp *Proxy) requiresDeviceInfo(psshData *PsshData) bool {
+	for _, v := range []interface{}{wp.LicenseAuthority, wp.Authorizer, wp.Policy, wp.Tracker} {
+		if r, ok := v.(DeviceInfoRequirer); ok && r.RequiresDeviceInfo(psshData) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+package widevineproxy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// ReleaseListener is implemented by a LicenseAuthority tracking persisted (offline) licenses.
+// LicenseReleased is called once the license service acknowledged the release.
+// An error is returned to the client so that the CDM retries the release later.
+type ReleaseListener interface {
+	LicenseReleased(ctx context.Context, release *Release) error
+}
+
+// Release describes a release request of a license previously issued by the proxy.
+type Release struct {
+	LicenseID              LicenseID
+	SecondsSinceStarted    int64
+	SecondsSinceLastPlayed int64
+
+	// Response is the release acknowledgement of the license service.
+	Response *LicenseResponse
+}
+
+// ReleaseLicense forwards a release (e.g. a deleted download or a secure stop) to the license service
+// and notifies the Tracker and the ReleaseListener, if any.
+func (wp *Proxy) ReleaseLicense(body []byte) (*LicenseResponse, error) {
+	return wp.ReleaseLicenseWithContext(context.Background(), body)
+}
+
+// ReleaseLicenseWithContext is ReleaseLicense bound to ctx, which is handed to the ReleaseListener.
+func (wp *Proxy) ReleaseLicenseWithContext(ctx context.Context, body []byte) (*LicenseResponse, error) {
+	message, err := ParseCDMMessage(body)
+	if err != nil {
+		return nil, err
+	}
+	if message.Type != MessageTypeLicenseRelease {
+		return nil, &MalformedMessageError{Reason: fmt.Sprintf("%s is not a release", message.Type)}
+	}
+	return wp.releaseLicense(ctx, body, message)
+}
+
+func (wp *Proxy) releaseLicense(ctx context.Context, body []byte, message *CDMMessage) (*LicenseResponse, error) {
+	response, err := wp.forwardLicenseRequest(ctx, body, message)
+	if err != nil {
+		return nil, err
+	}
+
+	existing := message.LicenseRequest.GetContentId().GetExistingLicense()
+	release := &Release{
+		LicenseID:              licenseIDFromProto(existing.GetLicenseId()),
+		SecondsSinceStarted:    existing.GetSecondsSinceStarted(),
+		SecondsSinceLastPlayed: existing.GetSecondsSinceLastPlayed(),
+		Response:               response,
+	}
+	logger := wp.logger(ctx).WithFields(logrus.Fields{
+		"license_id":   release.LicenseID,
+		"license_type": release.LicenseID.Type,
+		"message_type": response.MessageType,
+	})
+
+	if wp.Tracker != nil {
+		if err := wp.Tracker.LicenseReleased(ctx, release); err != nil {
+			logger.WithError(err).Error("License Release Tracker Failure")
+			return nil, err
+		}
+	}
+	rl, ok := wp.LicenseAuthority.(ReleaseListener)
+	if !ok {
+		logger.Info("License Released")
+		return response, nil
+	}
+	if err := rl.LicenseReleased(ctx, release); err != nil {
+		logger.WithError(err).Error("License Release Listener Failure")
+		return nil, err
+	}
+	logger.Info("License Released")
+	return response, nil
+}
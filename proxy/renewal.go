@@ -0,0 +1,131 @@
+package widevineproxy
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+
+	pb "github.com/cooomma/widevine-proxy/proto"
+	"github.com/sirupsen/logrus"
+)
+
+// RenewalAuthority is implemented by a LicenseAuthority enforcing license renewals (heartbeats).
+// Without it, renewals are forwarded to the license service which applies the original policy.
+type RenewalAuthority interface {
+	RenewLicense(ctx context.Context, renewal *Renewal) (*RenewalDecision, error)
+}
+
+// Renewal describes a renewal request of a license previously issued by the proxy.
+type Renewal struct {
+	LicenseID              LicenseID
+	SecondsSinceStarted    int64
+	SecondsSinceLastPlayed int64
+}
+
+// RenewalDecision is the answer of a RenewalAuthority to a renewal.
+type RenewalDecision struct {
+	Approved bool
+	Reason   string // Reason of the denial, returned in RenewalDeniedError.
+
+	// PolicyOverrides extends the renewed license, e.g. a new LicenseDurationSeconds. Nil keeps the original policy.
+	PolicyOverrides *PolicyOverrides
+}
+
+// RenewalDeniedError is returned when the RenewalAuthority refuses a renewal.
+type RenewalDeniedError struct {
+	LicenseID LicenseID
+	Reason    string
+}
+
+func (e *RenewalDeniedError) Error() string {
+	return fmt.Sprintf("license renewal denied: %s", e.Reason)
+}
+
+// RenewLicense asks the Tracker and the RenewalAuthority, if any, to approve the renewal and then forwards it to the license service.
+func (wp *Proxy) RenewLicense(body []byte) (*LicenseResponse, error) {
+	return wp.RenewLicenseWithContext(context.Background(), body)
+}
+
+// RenewLicenseWithContext is RenewLicense bound to ctx, which is handed to the RenewalAuthority.
+func (wp *Proxy) RenewLicenseWithContext(ctx context.Context, body []byte) (*LicenseResponse, error) {
+	message, err := ParseCDMMessage(body)
+	if err != nil {
+		return nil, err
+	}
+	if message.Type != MessageTypeLicenseRenewal {
+		return nil, &MalformedMessageError{Reason: fmt.Sprintf("%s is not a renewal", message.Type)}
+	}
+	return wp.renewLicense(ctx, body, message)
+}
+
+func (wp *Proxy) renewLicense(ctx context.Context, body []byte, message *CDMMessage) (*LicenseResponse, error) {
+	ra, ok := wp.LicenseAuthority.(RenewalAuthority)
+	if !ok && wp.Tracker == nil {
+		return wp.forwardLicenseRequest(ctx, body, message)
+	}
+
+	existing := message.LicenseRequest.GetContentId().GetExistingLicense()
+	renewal := &Renewal{
+		LicenseID:              licenseIDFromProto(existing.GetLicenseId()),
+		SecondsSinceStarted:    existing.GetSecondsSinceStarted(),
+		SecondsSinceLastPlayed: existing.GetSecondsSinceLastPlayed(),
+	}
+	logger := wp.logger(ctx).WithFields(logrus.Fields{
+		"license_id":                renewal.LicenseID,
+		"seconds_since_started":     renewal.SecondsSinceStarted,
+		"seconds_since_last_played": renewal.SecondsSinceLastPlayed,
+	})
+	if wp.Tracker != nil {
+		if err := wp.Tracker.LicenseRenewed(ctx, renewal); err != nil {
+			logger.WithError(err).Warn("License Renewal Refused By Tracker")
+			return nil, err
+		}
+	}
+	if !ok {
+		return wp.forwardLicenseRequest(ctx, body, message)
+	}
+
+	decision, err := ra.RenewLicense(ctx, renewal)
+	if err != nil {
+		return nil, err
+	}
+	if !decision.Approved {
+		logger.WithField("reason", decision.Reason).Warn("License Renewal Denied")
+		return nil, &RenewalDeniedError{LicenseID: renewal.LicenseID, Reason: decision.Reason}
+	}
+	logger.Info("License Renewal Approved")
+
+	req, err := wp.buildExistingLicenseRequest(body, decision.PolicyOverrides)
+	if err != nil {
+		return nil, err
+	}
+	return wp.sendLicenseRequest(ctx, req)
+}
+
+// buildExistingLicenseRequest signs a renewal or release request, optionally overriding the license policy.
+func (wp *Proxy) buildExistingLicenseRequest(body []byte, policy *PolicyOverrides) ([]byte, error) {
+	request := map[string]interface{}{
+		"payload":  base64.StdEncoding.EncodeToString(body),
+		"provider": wp.LicenseAuthority.GetProvider(),
+	}
+	if policy != nil {
+		request["policy_overrides"] = policy
+	}
+	message, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	return wp.packingRequest(message)
+}
+
+// licenseIDFromProto converts the license identification sent by the CDM into the form used by the license service.
+func licenseIDFromProto(id *pb.LicenseIdentification) LicenseID {
+	return LicenseID{
+		RequestID:  base64.StdEncoding.EncodeToString(id.GetRequestId()),
+		SessionID:  base64.StdEncoding.EncodeToString(id.GetSessionId()),
+		PurchaseID: base64.StdEncoding.EncodeToString(id.GetPurchaseId()),
+		Type:       id.GetType().String(),
+		Version:    int64(id.GetVersion()),
+	}
+}
@@ -0,0 +1,98 @@
+package widevineproxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy controls the retries of the idempotent calls to the license service,
+// i.e. the service certificate and PARSE_ONLY requests. License builds are never retried.
+type RetryPolicy struct {
+	MaxAttempts int           // Attempts including the first one, 1 disables retries.
+	BaseDelay   time.Duration // Delay before the first retry, doubled on each retry.
+	MaxDelay    time.Duration // Cap of the delay between two attempts.
+}
+
+// DefaultRetryPolicy is the RetryPolicy of NewWidevineProxy.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	BaseDelay:   100 * time.Millisecond,
+	MaxDelay:    2 * time.Second,
+}
+
+// backoff returns the jittered delay before the given retry, 1 being the first one.
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	delay := p.BaseDelay << uint(retry-1)
+	if delay <= 0 || (p.MaxDelay > 0 && delay > p.MaxDelay) {
+		delay = p.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// UpstreamHTTPError is returned when the license service answers with an HTTP error status.
+type UpstreamHTTPError struct {
+	StatusCode int
+}
+
+func (e *UpstreamHTTPError) Error() string {
+	return fmt.Sprintf("license service answered %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// sendIdempotentRequest is sendReqeust retried according to the RetryPolicy.
+func (wp *Proxy) sendIdempotentRequest(ctx context.Context, reqMessage []byte) (*LicenseResponse, error) {
+	attempts := wp.Retry.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	for attempt := 1; ; attempt++ {
+		response, err := wp.sendReqeust(ctx, reqMessage)
+		if attempt >= attempts || ctx.Err() != nil || !degraded(response, err) {
+			return response, err
+		}
+
+		delay := wp.Retry.backoff(attempt)
+		logger := wp.logger(ctx).WithField("attempt", attempt).WithField("delay", delay.String())
+		if err != nil {
+			logger = logger.WithError(err)
+		} else {
+			logger = logger.WithField("status", response.Status)
+		}
+		logger.Warn("License Service Call Retry")
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// degraded reports whether the outcome of a call shows the license service unavailable or failing.
+// Only the timeouts, refused or reset connections, 5xx and 429 answers count: the other errors,
+// e.g. a bad URL or a failed TLS verification, would not go away with a retry.
+func degraded(response *LicenseResponse, err error) bool {
+	if err != nil {
+		var httpErr *UpstreamHTTPError
+		if errors.As(err, &httpErr) {
+			return httpErr.StatusCode >= 500 || httpErr.StatusCode == http.StatusTooManyRequests
+		}
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return true
+		}
+		return errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET)
+	}
+	return response.Status == StatusInternalError
+}
@@ -0,0 +1,177 @@
+package widevineproxy
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	// ErrUnknownTenant is returned when a request matches no tenant and the registry has no default tenant.
+	ErrUnknownTenant = errors.New("unknown tenant")
+	// ErrTenantMismatch is returned when the path prefix or the header of a request selects another tenant
+	// than the host of the request.
+	ErrTenantMismatch = errors.New("tenant mismatch")
+)
+
+// Tenant is a provider served by a shared deployment. Its Proxy holds the LicenseAuthority of the provider
+// (signing key and IV, license server URL, policies), and its own HTTP client and circuit breaker,
+// so that a degraded tenant does not affect the others.
+type Tenant struct {
+	Name  string
+	Proxy *Proxy
+
+	// Hosts are the request hosts of the tenant, without port.
+	Hosts []string
+	// PathPrefix is the path prefix of the requests of the tenant, e.g. /brand-a.
+	PathPrefix string
+	// HeaderValue is the value of the TenantRegistry.Header of the requests of the tenant.
+	HeaderValue string
+}
+
+// TenantRegistry selects the Tenant of a request by path prefix, then by header, then by host.
+// A host bound to a tenant only serves that tenant, whatever the path prefix and the header.
+type TenantRegistry struct {
+	// Header names the request header selecting the tenant, e.g. X-Tenant. Ignored if empty.
+	Header string
+	// Default serves the requests matching no tenant. They fail with ErrUnknownTenant if nil.
+	Default *Tenant
+
+	tenants []*Tenant
+}
+
+// NewTenantRegistry creates an empty TenantRegistry selecting tenants by header, if not empty.
+func NewTenantRegistry(header string) *TenantRegistry {
+	return &TenantRegistry{Header: header}
+}
+
+// Add registers t. The proxy of the tenant logs its name in the tenant field.
+func (r *TenantRegistry) Add(t *Tenant) error {
+	if t.Name == "" || t.Proxy == nil {
+		return fmt.Errorf("tenant needs a name and a proxy")
+	}
+	for _, other := range r.tenants {
+		if other.Name == t.Name {
+			return fmt.Errorf("duplicate tenant %s", t.Name)
+		}
+		if t.PathPrefix != "" && other.PathPrefix == t.PathPrefix {
+			return fmt.Errorf("tenants %s and %s share the path prefix %s", other.Name, t.Name, t.PathPrefix)
+		}
+		if t.HeaderValue != "" && other.HeaderValue == t.HeaderValue {
+			return fmt.Errorf("tenants %s and %s share the header value %s", other.Name, t.Name, t.HeaderValue)
+		}
+		for _, host := range t.Hosts {
+			if other.hasHost(host) {
+				return fmt.Errorf("tenants %s and %s share the host %s", other.Name, t.Name, host)
+			}
+		}
+	}
+	if t.PathPrefix != "" && (!strings.HasPrefix(t.PathPrefix, "/") || strings.HasSuffix(t.PathPrefix, "/")) {
+		return fmt.Errorf("path prefix %q of tenant %s must start and not end with /", t.PathPrefix, t.Name)
+	}
+
+	fields := logrus.Fields{}
+	for k, v := range t.Proxy.LogFields {
+		fields[k] = v
+	}
+	fields["tenant"] = t.Name
+	t.Proxy.LogFields = fields
+	r.tenants = append(r.tenants, t)
+	return nil
+}
+
+// Tenants returns the registered tenants ordered by name, the default tenant excluded if not registered.
+func (r *TenantRegistry) Tenants() []*Tenant {
+	tenants := append([]*Tenant(nil), r.tenants...)
+	sort.Slice(tenants, func(i, j int) bool { return tenants[i].Name < tenants[j].Name })
+	return tenants
+}
+
+// Tenant returns the tenant registered under name.
+func (r *TenantRegistry) Tenant(name string) (*Tenant, bool) {
+	for _, t := range r.tenants {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return nil, false
+}
+
+// Resolve returns the tenant of req, the default tenant if none matches.
+// The path prefix and the header, both set by the client, may not select another tenant than the one
+// the host of the request is bound to: such a request fails with ErrTenantMismatch.
+func (r *TenantRegistry) Resolve(req *http.Request) (*Tenant, error) {
+	host := req.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	var bound *Tenant
+	for _, t := range r.tenants {
+		if t.hasHost(host) {
+			bound = t
+		}
+	}
+
+	selected, err := r.selected(req)
+	if err != nil {
+		return nil, err
+	}
+	if selected != nil {
+		if bound != nil && bound != selected {
+			return nil, fmt.Errorf("%w: host %s is bound to tenant %s, not %s", ErrTenantMismatch, host, bound.Name, selected.Name)
+		}
+		return selected, nil
+	}
+	if bound != nil {
+		return bound, nil
+	}
+
+	if r.Default == nil {
+		return nil, fmt.Errorf("%w: %s%s", ErrUnknownTenant, req.Host, req.URL.Path)
+	}
+	return r.Default, nil
+}
+
+// selected returns the tenant selected by the path prefix, or else by the header, of req. Nil if neither does.
+func (r *TenantRegistry) selected(req *http.Request) (*Tenant, error) {
+	var matched *Tenant
+	for _, t := range r.tenants {
+		if t.PathPrefix == "" || !hasPathPrefix(req.URL.Path, t.PathPrefix) {
+			continue
+		}
+		if matched == nil || len(t.PathPrefix) > len(matched.PathPrefix) {
+			matched = t
+		}
+	}
+	if matched != nil {
+		return matched, nil
+	}
+
+	if value := req.Header.Get(r.Header); r.Header != "" && value != "" {
+		for _, t := range r.tenants {
+			if t.HeaderValue == value {
+				return t, nil
+			}
+		}
+		return nil, fmt.Errorf("%w: %s %q", ErrUnknownTenant, r.Header, value)
+	}
+	return nil, nil
+}
+
+func (t *Tenant) hasHost(host string) bool {
+	for _, h := range t.Hosts {
+		if strings.EqualFold(h, host) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasPathPrefix(path, prefix string) bool {
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
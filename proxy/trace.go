@@ -0,0 +1,44 @@
+package widevineproxy
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+)
+
+// TraceMetadata maps HTTP header names to values identifying a request across services,
+// e.g. X-Request-Id or the W3C traceparent. It is sent along every call to the license service
+// and added to the log entries of the request.
+type TraceMetadata map[string]string
+
+type traceMetadataKey struct{}
+
+// WithTraceMetadata returns a copy of ctx carrying md, merged over the metadata ctx already carries.
+func WithTraceMetadata(ctx context.Context, md TraceMetadata) context.Context {
+	merged := TraceMetadata{}
+	for k, v := range TraceMetadataFromContext(ctx) {
+		merged[k] = v
+	}
+	for k, v := range md {
+		merged[k] = v
+	}
+	return context.WithValue(ctx, traceMetadataKey{}, merged)
+}
+
+// TraceMetadataFromContext returns the trace metadata carried by ctx, nil if none.
+func TraceMetadataFromContext(ctx context.Context) TraceMetadata {
+	md, _ := ctx.Value(traceMetadataKey{}).(TraceMetadata)
+	return md
+}
+
+// logger returns the proxy logger annotated with the LogFields of the proxy and the trace metadata of ctx.
+func (wp *Proxy) logger(ctx context.Context) *logrus.Entry {
+	fields := logrus.Fields{}
+	for k, v := range wp.LogFields {
+		fields[k] = v
+	}
+	for k, v := range TraceMetadataFromContext(ctx) {
+		fields[k] = v
+	}
+	return wp.Logger.WithFields(fields)
+}
@@ -0,0 +1,84 @@
+package widevineproxy
+
+import "context"
+
+// LicenseTracker follows the licenses issued by the proxy until they are released, e.g. to limit the concurrent
+// streams or the offline licenses of a user:
+//
+//   - CheckLicense is called after the Authorizer, before the license is built. An error refuses the license;
+//     use Deny to explain the refusal to the client.
+//   - LicenseIssued is called once the license service issued the license, before it is returned to the client.
+//     An error withholds the license, e.g. when a concurrent request took the last free slot.
+//   - LicenseRenewed is called for every renewal (heartbeat), before the RenewalAuthority. An error refuses
+//     the renewal; return a RenewalDeniedError to explain the refusal to the client.
+//   - LicenseReleased is called once the license service acknowledged a release, before the ReleaseListener.
+//
+// A LicenseTracker relying on AuthorizationRequest.Device must implement DeviceInfoRequirer.
+type LicenseTracker interface {
+	CheckLicense(ctx context.Context, req *AuthorizationRequest) error
+	LicenseIssued(ctx context.Context, license *IssuedLicense) error
+	LicenseRenewed(ctx context.Context, renewal *Renewal) error
+	LicenseReleased(ctx context.Context, release *Release) error
+}
+
+// IssuedLicense describes a license issued by the license service.
+type IssuedLicense struct {
+	Request *AuthorizationRequest
+	// Message is the license message sent to the license service, once the Policy and the claims applied.
+	Message  *Message
+	Response *LicenseResponse
+}
+
+// TrackerChain is a LicenseTracker calling its trackers in order, stopping at the first error,
+// e.g. a session tracker then an offline license ledger.
+type TrackerChain []LicenseTracker
+
+// CheckLicense checks req against the trackers of c.
+func (c TrackerChain) CheckLicense(ctx context.Context, req *AuthorizationRequest) error {
+	for _, t := range c {
+		if err := t.CheckLicense(ctx, req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LicenseIssued records license in the trackers of c.
+func (c TrackerChain) LicenseIssued(ctx context.Context, license *IssuedLicense) error {
+	for _, t := range c {
+		if err := t.LicenseIssued(ctx, license); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LicenseRenewed checks renewal against the trackers of c.
+func (c TrackerChain) LicenseRenewed(ctx context.Context, renewal *Renewal) error {
+	for _, t := range c {
+		if err := t.LicenseRenewed(ctx, renewal); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LicenseReleased records release in the trackers of c.
+func (c TrackerChain) LicenseReleased(ctx context.Context, release *Release) error {
+	for _, t := range c {
+		if err := t.LicenseReleased(ctx, release); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RequiresDeviceInfo reports whether a tracker of c requires the device details.
+func (c TrackerChain) RequiresDeviceInfo(psshData *PsshData) bool {
+	for _, t := range c {
+		if r, ok := t.(DeviceInfoRequirer); ok && r.RequiresDeviceInfo(psshData) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,132 @@
+package widevinetest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	pb "github.com/cooomma/widevine-proxy/proto"
+	proto "github.com/golang/protobuf/proto"
+)
+
+// ServiceCertificateRequest is the message sent by a CDM asking for the provider's service certificate.
+var ServiceCertificateRequest = mustMarshal(&pb.SignedMessage{
+	Type: pb.SignedMessage_SERVICE_CERTIFICATE_REQUEST.Enum(),
+})
+
+// ClientInfo is the device description carried by the challenges built in this package.
+var ClientInfo = []*pb.ClientIdentification_NameValue{
+	{Name: proto.String("company_name"), Value: proto.String(DefaultDevice.Make)},
+	{Name: proto.String("model_name"), Value: proto.String(DefaultDevice.Model)},
+	{Name: proto.String("platform_name"), Value: proto.String(DefaultDevice.Platform)},
+}
+
+// LicenseChallenge builds a new license request, as generated by a CDM, for the given Widevine pssh data.
+func LicenseChallenge(header *pb.WidevineCencHeader, licenseType pb.LicenseType) []byte {
+	return signedLicenseRequest(&pb.LicenseRequest{
+		Type: pb.LicenseRequest_NEW.Enum(),
+		ContentId: &pb.LicenseRequest_ContentIdentification{
+			ContentIdVariant: &pb.LicenseRequest_ContentIdentification_WidevinePsshData_{
+				WidevinePsshData: &pb.LicenseRequest_ContentIdentification_WidevinePsshData{
+					PsshData:    [][]byte{mustMarshal(header)},
+					LicenseType: licenseType.Enum(),
+					RequestId:   []byte("fake-request-id"),
+				},
+			},
+		},
+	})
+}
+
+// InitDataChallenge builds a new license request carrying the pssh boxes of the content as CENC init data,
+// as sent by browsers through EME.
+func InitDataChallenge(psshBoxes []byte, licenseType pb.LicenseType) []byte {
+	return signedLicenseRequest(&pb.LicenseRequest{
+		Type: pb.LicenseRequest_NEW.Enum(),
+		ContentId: &pb.LicenseRequest_ContentIdentification{
+			ContentIdVariant: &pb.LicenseRequest_ContentIdentification_InitData_{
+				InitData: &pb.LicenseRequest_ContentIdentification_InitData{
+					InitDataType: pb.LicenseRequest_ContentIdentification_InitData_CENC.Enum(),
+					InitData:     psshBoxes,
+					LicenseType:  licenseType.Enum(),
+					RequestId:    []byte("fake-request-id"),
+				},
+			},
+		},
+	})
+}
+
+// PlayReadyObject builds the data of a PlayReady pssh box, a PlayReady Object holding a version 4.3.0.0
+// WRMHEADER, for key ids in the byte order of the other DRM systems.
+func PlayReadyObject(keyIDs [][]byte) []byte {
+	var wrmHeader strings.Builder
+	wrmHeader.WriteString(`<WRMHEADER xmlns="http://schemas.microsoft.com/DRM/2007/03/PlayReadyHeader" version="4.3.0.0"><DATA><PROTECTINFO><KIDS>`)
+	for _, keyID := range keyIDs {
+		// The KIDs are little-endian GUIDs.
+		kid := append([]byte{}, keyID...)
+		kid[0], kid[1], kid[2], kid[3] = keyID[3], keyID[2], keyID[1], keyID[0]
+		kid[4], kid[5], kid[6], kid[7] = keyID[5], keyID[4], keyID[7], keyID[6]
+		fmt.Fprintf(&wrmHeader, `<KID ALGID="AESCTR" VALUE="%s"></KID>`, base64.StdEncoding.EncodeToString(kid))
+	}
+	wrmHeader.WriteString(`</KIDS></PROTECTINFO></DATA></WRMHEADER>`)
+
+	u := utf16.Encode([]rune(wrmHeader.String()))
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, uint32(6+4+2*len(u)))
+	binary.Write(&b, binary.LittleEndian, uint16(1))
+	binary.Write(&b, binary.LittleEndian, uint16(1)) // Rights management header record.
+	binary.Write(&b, binary.LittleEndian, uint16(2*len(u)))
+	binary.Write(&b, binary.LittleEndian, u)
+	return b.Bytes()
+}
+
+// RenewalChallenge builds a renewal request for a license previously issued.
+func RenewalChallenge(licenseID *pb.LicenseIdentification) []byte {
+	return existingLicenseRequest(pb.LicenseRequest_RENEWAL, licenseID)
+}
+
+// ReleaseChallenge builds a release request for a license previously issued.
+func ReleaseChallenge(licenseID *pb.LicenseIdentification) []byte {
+	return existingLicenseRequest(pb.LicenseRequest_RELEASE, licenseID)
+}
+
+func existingLicenseRequest(t pb.LicenseRequest_RequestType, licenseID *pb.LicenseIdentification) []byte {
+	return signedLicenseRequest(&pb.LicenseRequest{
+		Type: t.Enum(),
+		ContentId: &pb.LicenseRequest_ContentIdentification{
+			ContentIdVariant: &pb.LicenseRequest_ContentIdentification_ExistingLicense_{
+				ExistingLicense: &pb.LicenseRequest_ContentIdentification_ExistingLicense{
+					LicenseId:              licenseID,
+					SecondsSinceStarted:    proto.Int64(60),
+					SecondsSinceLastPlayed: proto.Int64(0),
+				},
+			},
+		},
+	})
+}
+
+func signedLicenseRequest(request *pb.LicenseRequest) []byte {
+	request.ClientId = &pb.ClientIdentification{
+		Type:       pb.ClientIdentification_DRM_DEVICE_CERTIFICATE.Enum(),
+		Token:      []byte("fake-drm-device-certificate"),
+		ClientInfo: ClientInfo,
+	}
+	request.RequestTime = proto.Int64(time.Now().Unix())
+	request.ProtocolVersion = pb.ProtocolVersion_VERSION_2_1.Enum()
+	return mustMarshal(&pb.SignedMessage{
+		Type:      pb.SignedMessage_LICENSE_REQUEST.Enum(),
+		Msg:       mustMarshal(request),
+		Signature: []byte("fake-signature"),
+	})
+}
+
+func mustMarshal(m proto.Message) []byte {
+	b, err := proto.Marshal(m)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
@@ -0,0 +1,349 @@
+// Package widevinetest provides a fake Widevine GetLicense service for offline integration tests.
+//
+// It verifies the request/signature/signer envelope built by widevineproxy.Proxy and answers
+// certificate, PARSE_ONLY and license requests with realistic LicenseResponse JSON.
+//
+//	svc := widevinetest.NewService("widevine_test", key, iv)
+//	ts := httptest.NewServer(svc)
+//	defer ts.Close()
+package widevinetest
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+
+	pb "github.com/cooomma/widevine-proxy/proto"
+	widevineproxy "github.com/cooomma/widevine-proxy/proxy"
+	proto "github.com/golang/protobuf/proto"
+)
+
+// RequestType is the kind of call received by the fake service.
+type RequestType string
+
+const (
+	RequestTypeCertificate RequestType = "SERVICE_CERTIFICATE"
+	RequestTypeParseOnly   RequestType = "PARSE_ONLY"
+	RequestTypeLicense     RequestType = "LICENSE"
+	RequestTypeRenewal     RequestType = "RENEWAL"
+	RequestTypeRelease     RequestType = "RELEASE"
+)
+
+// Status strings returned by the Widevine license service.
+const (
+	StatusOK                      = widevineproxy.StatusOK
+	StatusSignatureFailure        = widevineproxy.StatusSignatureFailure
+	StatusInvalidLicenseChallenge = widevineproxy.StatusInvalidLicenseChallenge
+	StatusProviderAccessDenied    = widevineproxy.StatusProviderAccessDenied
+	StatusInternalError           = widevineproxy.StatusInternalError
+)
+
+// Request is a verified call received by the fake service.
+type Request struct {
+	Type       RequestType
+	Signer     string
+	Payload    []byte                    // CDM bytes carried by the request.
+	CDMMessage *widevineproxy.CDMMessage // Parsed CDM bytes.
+	Message    *widevineproxy.Message    // Decoded request message.
+}
+
+// Device is the client description reported in PARSE_ONLY and license responses.
+type Device struct {
+	Make                       string
+	Model                      string
+	Platform                   string
+	SecurityLevel              int64
+	SystemID                   int64
+	DRMCERTSerialNumber        string
+	ClientMaxHdcpVersion       string
+	DeviceWhitelistState       string
+	DeviceState                string
+	PlatformVerificationStatus string
+}
+
+// DefaultDevice looks like a desktop Chrome CDM.
+var DefaultDevice = Device{
+	Make:                       "Google",
+	Model:                      "ChromeCDM-Linux-x64",
+	Platform:                   "Linux",
+	SecurityLevel:              3,
+	SystemID:                   4464,
+	DRMCERTSerialNumber:        "ZmFrZS1kcm0tY2VydC1zZXJpYWw=",
+	ClientMaxHdcpVersion:       "HDCP_NONE",
+	DeviceWhitelistState:       "DEVICE_NOT_WHITELISTED",
+	DeviceState:                "RELEASED",
+	PlatformVerificationStatus: "PLATFORM_UNVERIFIED",
+}
+
+// Service is a fake Widevine GetLicense endpoint implementing http.Handler.
+type Service struct {
+	Provider string
+	Key      []byte
+	IV       []byte
+
+	// Device is reported back for every verified request.
+	Device Device
+	// PsshData is returned by PARSE_ONLY requests when the challenge carries no Widevine pssh data.
+	PsshData widevineproxy.PsshData
+	// License is the blob returned for license requests.
+	License []byte
+	// ServiceCertificate is the blob returned for certificate requests.
+	ServiceCertificate []byte
+
+	// Statuses overrides the Widevine status per request type, StatusOK if absent.
+	Statuses map[RequestType]string
+	// HTTPStatus overrides the HTTP status code of every response, 200 if zero.
+	HTTPStatus int
+	// Respond replaces the built-in response when not nil.
+	Respond func(req *Request) *widevineproxy.LicenseResponse
+
+	mu       sync.Mutex
+	requests []*Request
+}
+
+// NewService creates a fake service accepting requests signed by provider with key and iv.
+func NewService(provider string, key, iv []byte) *Service {
+	return &Service{
+		Provider: provider,
+		Key:      key,
+		IV:       iv,
+		Device:   DefaultDevice,
+		PsshData: widevineproxy.PsshData{
+			KeyID:     []string{"k+S38OKP6ocwSmiDW85scQ=="},
+			ContentID: base64.StdEncoding.EncodeToString([]byte("fake-content")),
+		},
+		License:            []byte("fake-widevine-license"),
+		ServiceCertificate: []byte("fake-service-certificate"),
+		Statuses:           map[RequestType]string{},
+	}
+}
+
+// Requests returns the verified requests received so far.
+func (s *Service) Requests() []*Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*Request(nil), s.requests...)
+}
+
+// RequestsOf returns the verified requests of the given type received so far.
+func (s *Service) RequestsOf(t RequestType) []*Request {
+	var requests []*Request
+	for _, req := range s.Requests() {
+		if req.Type == t {
+			requests = append(requests, req)
+		}
+	}
+	return requests
+}
+
+func (s *Service) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	req, status := s.verify(body)
+	var response *widevineproxy.LicenseResponse
+	switch {
+	case req == nil:
+		response = &widevineproxy.LicenseResponse{Status: status}
+	case s.Respond != nil:
+		s.record(req)
+		response = s.Respond(req)
+	default:
+		s.record(req)
+		response = s.respond(req)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if s.HTTPStatus != 0 {
+		w.WriteHeader(s.HTTPStatus)
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+func (s *Service) record(req *Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests = append(s.requests, req)
+}
+
+// verify checks the signed envelope and decodes the request message.
+// It returns the Widevine status explaining the rejection when the request is nil.
+func (s *Service) verify(body []byte) (*Request, string) {
+	var envelope struct {
+		Request   string `json:"request"`
+		Signature string `json:"signature"`
+		Signer    string `json:"signer"`
+	}
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return nil, StatusInvalidLicenseChallenge
+	}
+	if envelope.Signer != s.Provider {
+		return nil, StatusProviderAccessDenied
+	}
+	message, err := base64.StdEncoding.DecodeString(envelope.Request)
+	if err != nil {
+		return nil, StatusInvalidLicenseChallenge
+	}
+	signature, err := base64.StdEncoding.DecodeString(envelope.Signature)
+	if err != nil {
+		return nil, StatusSignatureFailure
+	}
+	if !s.validSignature(message, signature) {
+		return nil, StatusSignatureFailure
+	}
+
+	var msg widevineproxy.Message
+	if err := json.Unmarshal(message, &msg); err != nil {
+		return nil, StatusInvalidLicenseChallenge
+	}
+	payload, err := base64.StdEncoding.DecodeString(msg.Payload)
+	if err != nil {
+		return nil, StatusInvalidLicenseChallenge
+	}
+	cdmMessage, err := widevineproxy.ParseCDMMessage(payload)
+	if err != nil {
+		return nil, StatusInvalidLicenseChallenge
+	}
+
+	req := &Request{Type: RequestTypeLicense, Signer: envelope.Signer, Payload: payload, CDMMessage: cdmMessage, Message: &msg}
+	switch {
+	case cdmMessage.Type == widevineproxy.MessageTypeServiceCertificateRequest:
+		req.Type = RequestTypeCertificate
+	case msg.ParseOnly:
+		req.Type = RequestTypeParseOnly
+	case cdmMessage.Type == widevineproxy.MessageTypeLicenseRenewal:
+		req.Type = RequestTypeRenewal
+	case cdmMessage.Type == widevineproxy.MessageTypeLicenseRelease:
+		req.Type = RequestTypeRelease
+	}
+	return req, StatusOK
+}
+
+func (s *Service) validSignature(message, signature []byte) bool {
+	h := sha1.Sum(message)
+	expected, err := widevineproxy.AESCBCEncrypt(s.Key, s.IV, h[:])
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(expected, signature)
+}
+
+// psshData returns the key ids and content id carried by the challenge, falling back on s.PsshData.
+func (s *Service) psshData(req *Request) widevineproxy.PsshData {
+	data := req.CDMMessage.LicenseRequest.GetContentId().GetWidevinePsshData().GetPsshData()
+	if len(data) == 0 {
+		return s.PsshData
+	}
+	header := &pb.WidevineCencHeader{}
+	if err := proto.Unmarshal(data[0], header); err != nil {
+		return s.PsshData
+	}
+	psshData := widevineproxy.PsshData{ContentID: base64.StdEncoding.EncodeToString(header.GetContentId())}
+	for _, keyID := range header.GetKeyId() {
+		psshData.KeyID = append(psshData.KeyID, base64.StdEncoding.EncodeToString(keyID))
+	}
+	return psshData
+}
+
+func (s *Service) statusOf(t RequestType) string {
+	if status, ok := s.Statuses[t]; ok {
+		return status
+	}
+	return StatusOK
+}
+
+func (s *Service) respond(req *Request) *widevineproxy.LicenseResponse {
+	status := s.statusOf(req.Type)
+	response := &widevineproxy.LicenseResponse{
+		Status: status,
+		ServiceVersionInfo: widevineproxy.ServiceVersionInfo{
+			LicenseSDKVersion:     "16.4.2",
+			LicenseServiceVersion: "Widevine Fake License Service",
+		},
+	}
+	if status != StatusOK {
+		response.StatusMessage = fmt.Sprintf("fake service answered %s", status)
+		return response
+	}
+
+	if req.Type == RequestTypeCertificate {
+		response.MessageType = "SERVICE_CERTIFICATE_REQUEST"
+		response.License = base64.StdEncoding.EncodeToString(s.ServiceCertificate)
+		return response
+	}
+
+	n := len(s.Requests())
+	psshData := s.psshData(req)
+	response.MessageType = "LICENSE_REQUEST"
+	response.Make = s.Device.Make
+	response.Model = s.Device.Model
+	response.Platform = s.Device.Platform
+	response.SecurityLevel = s.Device.SecurityLevel
+	response.SystemID = s.Device.SystemID
+	response.DRMCERTSerialNumber = s.Device.DRMCERTSerialNumber
+	response.ClientMaxHdcpVersion = s.Device.ClientMaxHdcpVersion
+	response.DeviceWhitelistState = s.Device.DeviceWhitelistState
+	response.DeviceState = s.Device.DeviceState
+	response.PlatformVerificationStatus = s.Device.PlatformVerificationStatus
+	response.OEMCryptoAPIVersion = 15
+	response.ContentProvider = s.Provider
+	response.ContentOwner = s.Provider
+	response.LicenseMetadata = widevineproxy.LicenseMetadata{
+		ContentID:   psshData.ContentID,
+		LicenseType: "STREAMING",
+		RequestType: "NEW",
+	}
+	response.SessionState = widevineproxy.SessionState{
+		LicenseID: widevineproxy.LicenseID{
+			RequestID: base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("request-%d", n))),
+			SessionID: base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf("session-%d", n))),
+			Type:      "STREAMING",
+		},
+		KeyboxSystemID: s.Device.SystemID,
+		LicenseCounter: int64(n),
+	}
+	if licenseType := req.CDMMessage.LicenseRequest.GetContentId().GetWidevinePsshData().GetLicenseType(); licenseType == pb.LicenseType_OFFLINE {
+		response.LicenseMetadata.LicenseType = licenseType.String()
+		response.SessionState.LicenseID.Type = licenseType.String()
+	}
+
+	switch req.Type {
+	case RequestTypeParseOnly:
+		response.PsshData = psshData
+		return response
+	case RequestTypeRenewal, RequestTypeRelease:
+		licenseID := req.CDMMessage.ExistingLicenseID()
+		response.LicenseMetadata.RequestType = string(req.Type)
+		response.LicenseMetadata.LicenseType = licenseID.GetType().String()
+		response.SessionState.LicenseID = widevineproxy.LicenseID{
+			RequestID:  base64.StdEncoding.EncodeToString(licenseID.GetRequestId()),
+			SessionID:  base64.StdEncoding.EncodeToString(licenseID.GetSessionId()),
+			PurchaseID: base64.StdEncoding.EncodeToString(licenseID.GetPurchaseId()),
+			Type:       licenseID.GetType().String(),
+			Version:    int64(licenseID.GetVersion()) + 1,
+		}
+		response.License = base64.StdEncoding.EncodeToString(s.License)
+		return response
+	}
+
+	if req.Message.ContentID != "" {
+		response.LicenseMetadata.ContentID = req.Message.ContentID
+	}
+	response.License = base64.StdEncoding.EncodeToString(s.License)
+	response.SupportedTracks = []interface{}{
+		map[string]interface{}{"type": "SD", "key_id": psshData.KeyID},
+	}
+	return response
+}
@@ -0,0 +1,168 @@
+package widevinesession
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryStore is a Store in memory, for a single instance of the proxy: its sessions are lost on restart.
+type MemoryStore struct {
+	mu       sync.Mutex
+	sessions map[string]*Session            // Keyed by session id.
+	users    map[string]map[string]*Session // Keyed by user id and session id.
+	devices  map[string]map[string]*Device  // Keyed by user id and device id.
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		sessions: map[string]*Session{},
+		users:    map[string]map[string]*Session{},
+		devices:  map[string]map[string]*Device{},
+	}
+}
+
+func (s *MemoryStore) Check(ctx context.Context, a *Admission) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sessions, devices := s.active(a.Session.UserID, a.Session.Started)
+	return admit(a, sessions, devices)
+}
+
+func (s *MemoryStore) Start(ctx context.Context, a *Admission) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	userID := a.Session.UserID
+	sessions, devices := s.active(userID, a.Session.Started)
+	if err := admit(a, sessions, devices); err != nil {
+		return err
+	}
+
+	session := *a.Session
+	s.remove(s.sessions[session.ID])
+	s.sessions[session.ID] = &session
+	if s.users[userID] == nil {
+		s.users[userID] = map[string]*Session{}
+	}
+	s.users[userID][session.ID] = &session
+	if a.Device != nil {
+		if s.devices[userID] == nil {
+			s.devices[userID] = map[string]*Device{}
+		}
+		s.devices[userID][a.Device.ID] = registration(a, s.devices[userID][a.Device.ID])
+	}
+	return nil
+}
+
+func (s *MemoryStore) Touch(ctx context.Context, userID, id string, now, expiresAt time.Time) (*Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.sessions[id]
+	if !ok || session.UserID != userID {
+		return nil, ErrSessionNotFound
+	}
+	if session.expired(now) {
+		s.remove(session)
+		return nil, ErrSessionNotFound
+	}
+	session.LastSeen, session.ExpiresAt = now, expiresAt
+	touched := *session
+	return &touched, nil
+}
+
+func (s *MemoryStore) End(ctx context.Context, userID, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if session, ok := s.sessions[id]; ok && session.UserID == userID {
+		s.remove(session)
+	}
+	return nil
+}
+
+func (s *MemoryStore) Sessions(ctx context.Context, userID string, now time.Time) ([]*Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	active, _ := s.active(userID, now)
+	sessions := make([]*Session, len(active))
+	for i, session := range active {
+		copied := *session
+		sessions[i] = &copied
+	}
+	return sessions, nil
+}
+
+func (s *MemoryStore) Devices(ctx context.Context, userID string, now time.Time) ([]*Device, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, registered := s.active(userID, now)
+	devices := make([]*Device, len(registered))
+	for i, d := range registered {
+		copied := *d
+		devices[i] = &copied
+	}
+	return devices, nil
+}
+
+func (s *MemoryStore) RemoveDevice(ctx context.Context, userID, deviceID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.devices[userID], deviceID)
+	return nil
+}
+
+// active purges the expired sessions and devices of userID and returns the others.
+func (s *MemoryStore) active(userID string, now time.Time) ([]*Session, []*Device) {
+	var sessions []*Session
+	for _, session := range s.users[userID] {
+		if session.expired(now) {
+			s.remove(session)
+			continue
+		}
+		sessions = append(sessions, session)
+	}
+	var devices []*Device
+	for id, d := range s.devices[userID] {
+		if d.expired(now) {
+			delete(s.devices[userID], id)
+			continue
+		}
+		devices = append(devices, d)
+	}
+	if len(s.devices[userID]) == 0 {
+		delete(s.devices, userID)
+	}
+	sortSessions(sessions)
+	sortDevices(devices)
+	return sessions, devices
+}
+
+func (s *MemoryStore) remove(session *Session) {
+	if session == nil {
+		return
+	}
+	delete(s.sessions, session.ID)
+	delete(s.users[session.UserID], session.ID)
+	if len(s.users[session.UserID]) == 0 {
+		delete(s.users, session.UserID)
+	}
+}
+
+func sortSessions(sessions []*Session) {
+	sort.Slice(sessions, func(i, j int) bool {
+		if !sessions[i].Started.Equal(sessions[j].Started) {
+			return sessions[i].Started.Before(sessions[j].Started)
+		}
+		return sessions[i].ID < sessions[j].ID
+	})
+}
+
+func sortDevices(devices []*Device) {
+	sort.Slice(devices, func(i, j int) bool {
+		if !devices[i].FirstSeen.Equal(devices[j].FirstSeen) {
+			return devices[i].FirstSeen.Before(devices[j].FirstSeen)
+		}
+		return devices[i].ID < devices[j].ID
+	})
+}
@@ -0,0 +1,178 @@
+// Package widevinesession limits the concurrent streams and the registered devices of the users.
+// A Tracker records a session per streaming license, identified by the session id of the license
+// (SessionState.LicenseID.SessionID), keeps it alive with the renewals (heartbeats) of the license
+// and ends it on release. A session whose heartbeats stop expires after the heartbeat timeout.
+package widevinesession
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	widevineproxy "github.com/cooomma/widevine-proxy/proxy"
+)
+
+var (
+	// ErrLimitExceeded matches the LimitError with errors.Is.
+	ErrLimitExceeded = errors.New("limit exceeded")
+	// ErrSessionNotFound is returned by a Store for an unknown or expired session.
+	ErrSessionNotFound = errors.New("session not found")
+)
+
+// Limits of a LimitError.
+const (
+	LimitStreams       = "streams"
+	LimitDevices       = "devices"
+	LimitDeviceStreams = "device_streams"
+)
+
+// Limits bounds the sessions of a user. Zero values are unlimited.
+type Limits struct {
+	MaxStreams       int `json:"max_streams"`        // Concurrent streams of the user.
+	MaxDevices       int `json:"max_devices"`        // Registered devices of the user.
+	MaxDeviceStreams int `json:"max_device_streams"` // Concurrent streams of the user on a device.
+}
+
+func (l Limits) countsDevices() bool {
+	return l.MaxDevices > 0 || l.MaxDeviceStreams > 0
+}
+
+// Session is the playback of a streaming license.
+type Session struct {
+	ID        string `json:"id"` // Session id of the license, base64 encoded.
+	UserID    string `json:"user_id"`
+	DeviceID  string `json:"device_id"` // DRM certificate serial number of the device, empty if unknown.
+	ContentID string `json:"content_id"`
+
+	Started   time.Time `json:"started"`
+	LastSeen  time.Time `json:"last_seen"`  // Time of the last heartbeat.
+	ExpiresAt time.Time `json:"expires_at"` // The session ends unless a heartbeat comes before.
+}
+
+// Device is a device registered by a user, when a session started on it.
+type Device struct {
+	UserID string `json:"user_id"`
+	ID     string `json:"id"` // DRM certificate serial number, base64 encoded.
+	Make   string `json:"make"`
+	Model  string `json:"model"`
+
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+	ExpiresAt time.Time `json:"expires_at"` // Zero if the device stays registered until removed.
+}
+
+func (s *Session) expired(now time.Time) bool {
+	return !now.Before(s.ExpiresAt)
+}
+
+func (d *Device) expired(now time.Time) bool {
+	return !d.ExpiresAt.IsZero() && !now.Before(d.ExpiresAt)
+}
+
+// Admission is a session to start within limits.
+type Admission struct {
+	Session *Session // Its Started time is the current time. The id is empty when the license is not issued yet.
+	Device  *Device  // Registered, or refreshed, along the session. Nil if the device is unknown.
+	Limits  Limits
+}
+
+// Store holds the sessions and the devices. The expired sessions and devices are neither counted nor returned.
+type Store interface {
+	// Check returns a LimitError if starting the session of a would exceed its limits.
+	Check(ctx context.Context, a *Admission) error
+	// Start records the session and the device of a, unless they exceed its limits: it then returns a LimitError.
+	// The check and the records are atomic for a user.
+	Start(ctx context.Context, a *Admission) error
+	// Touch extends the session id of userID until expiresAt. It returns ErrSessionNotFound if the session
+	// is unknown, expired at now or started by another user.
+	Touch(ctx context.Context, userID, id string, now, expiresAt time.Time) (*Session, error)
+	// End removes the session id of userID, if any.
+	End(ctx context.Context, userID, id string) error
+
+	// Sessions returns the active sessions of userID at now, by start time.
+	Sessions(ctx context.Context, userID string, now time.Time) ([]*Session, error)
+	// Devices returns the registered devices of userID at now, by registration time.
+	Devices(ctx context.Context, userID string, now time.Time) ([]*Device, error)
+	// RemoveDevice unregisters a device of userID, freeing its slot. Its sessions are left to expire.
+	RemoveDevice(ctx context.Context, userID, deviceID string) error
+}
+
+// LimitError is returned for a session exceeding the limits of its user. It unwraps to an AuthorizationDeniedError.
+type LimitError struct {
+	UserID string
+	Limit  string // LimitStreams, LimitDevices or LimitDeviceStreams.
+	Max    int
+}
+
+func (e *LimitError) Error() string {
+	return fmt.Sprintf("%v: %s", ErrLimitExceeded, e.reason())
+}
+
+func (e *LimitError) reason() string {
+	switch e.Limit {
+	case LimitDevices:
+		return fmt.Sprintf("user %s reached the limit of %d registered devices", e.UserID, e.Max)
+	case LimitDeviceStreams:
+		return fmt.Sprintf("user %s reached the limit of %d concurrent streams on the device", e.UserID, e.Max)
+	}
+	return fmt.Sprintf("user %s reached the limit of %d concurrent streams", e.UserID, e.Max)
+}
+
+// Is reports whether target is ErrLimitExceeded.
+func (e *LimitError) Is(target error) bool {
+	return target == ErrLimitExceeded
+}
+
+// Unwrap returns the AuthorizationDeniedError of the denial.
+func (e *LimitError) Unwrap() error {
+	return &widevineproxy.AuthorizationDeniedError{Reason: e.reason()}
+}
+
+// admit checks a against the active sessions and devices of its user, for the implementations of Store.
+// A session started again, with the same id, does not count against itself.
+func admit(a *Admission, sessions []*Session, devices []*Device) error {
+	s, limits := a.Session, a.Limits
+	others := sessions[:0:0]
+	for _, other := range sessions {
+		if s.ID == "" || other.ID != s.ID {
+			others = append(others, other)
+		}
+	}
+	sessions = others
+	if limits.MaxStreams > 0 && len(sessions) >= limits.MaxStreams {
+		return &LimitError{UserID: s.UserID, Limit: LimitStreams, Max: limits.MaxStreams}
+	}
+	if s.DeviceID == "" {
+		return nil
+	}
+	if limits.MaxDeviceStreams > 0 {
+		n := 0
+		for _, other := range sessions {
+			if other.DeviceID == s.DeviceID {
+				n++
+			}
+		}
+		if n >= limits.MaxDeviceStreams {
+			return &LimitError{UserID: s.UserID, Limit: LimitDeviceStreams, Max: limits.MaxDeviceStreams}
+		}
+	}
+	if limits.MaxDevices > 0 && len(devices) >= limits.MaxDevices {
+		for _, d := range devices {
+			if d.ID == s.DeviceID {
+				return nil
+			}
+		}
+		return &LimitError{UserID: s.UserID, Limit: LimitDevices, Max: limits.MaxDevices}
+	}
+	return nil
+}
+
+// registration returns the device of a as registered once a is admitted, given the current registration, if any.
+func registration(a *Admission, current *Device) *Device {
+	d := *a.Device
+	if current != nil {
+		d.FirstSeen = current.FirstSeen
+	}
+	return &d
+}
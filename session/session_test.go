@@ -0,0 +1,264 @@
+package widevinesession
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cooomma/widevine-proxy/internal/sqltest"
+	widevineproxy "github.com/cooomma/widevine-proxy/proxy"
+	"github.com/stretchr/testify/assert"
+)
+
+var testStart = time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+
+func testAdmission(id, userID, deviceID string, at time.Time, limits Limits) *Admission {
+	a := &Admission{
+		Session: &Session{ID: id, UserID: userID, DeviceID: deviceID, ContentID: "movie-1",
+			Started: at, LastSeen: at, ExpiresAt: at.Add(3 * time.Minute)},
+		Limits: limits,
+	}
+	if deviceID != "" {
+		a.Device = &Device{UserID: userID, ID: deviceID, Make: "Google", FirstSeen: at, LastSeen: at}
+	}
+	return a
+}
+
+func sessionIDs(t *testing.T, store Store, userID string, now time.Time) []string {
+	t.Helper()
+	sessions, err := store.Sessions(context.Background(), userID, now)
+	assert.NoError(t, err)
+	var ids []string
+	for _, s := range sessions {
+		ids = append(ids, s.ID)
+	}
+	return ids
+}
+
+func deviceIDs(t *testing.T, store Store, userID string, now time.Time) []string {
+	t.Helper()
+	devices, err := store.Devices(context.Background(), userID, now)
+	assert.NoError(t, err)
+	var ids []string
+	for _, d := range devices {
+		ids = append(ids, d.ID)
+	}
+	return ids
+}
+
+// stores returns the implementations of Store under test, empty.
+func stores(t *testing.T) map[string]Store {
+	db, err := sql.Open(sqltest.Driver, t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return map[string]Store{"memory": NewMemoryStore(), "sql": NewSQLStore(db, PlaceholderQuestion)}
+}
+
+func assertLimit(t *testing.T, limit string, err error, store string) {
+	t.Helper()
+	var limitErr *LimitError
+	if assert.True(t, errors.As(err, &limitErr), store) {
+		assert.Equal(t, limit, limitErr.Limit, store)
+	}
+}
+
+func TestStoreLimits(t *testing.T) {
+	ctx := context.Background()
+	limits := Limits{MaxStreams: 2, MaxDevices: 2, MaxDeviceStreams: 1}
+	for name, store := range stores(t) {
+		assert.NoError(t, store.Start(ctx, testAdmission("s1", "user-1", "device-a", testStart, limits)), name)
+		assertLimit(t, LimitDeviceStreams, store.Start(ctx, testAdmission("s2", "user-1", "device-a", testStart, limits)), name)
+		assert.NoError(t, store.Start(ctx, testAdmission("s2", "user-1", "device-b", testStart.Add(time.Second), limits)), name)
+		assertLimit(t, LimitStreams, store.Check(ctx, testAdmission("", "user-1", "device-c", testStart, limits)), name)
+		assert.NoError(t, store.Check(ctx, testAdmission("", "user-2", "device-c", testStart, limits)), name)
+		// A session started again does not count against itself.
+		assert.NoError(t, store.Start(ctx, testAdmission("s1", "user-1", "device-a", testStart, limits)), name)
+		assert.Equal(t, []string{"s1", "s2"}, sessionIDs(t, store, "user-1", testStart), name)
+		assert.Equal(t, []string{"device-a", "device-b"}, deviceIDs(t, store, "user-1", testStart), name)
+
+		// The devices stay registered without sessions, until removed.
+		assert.NoError(t, store.End(ctx, "user-1", "s2"), name)
+		assertLimit(t, LimitDevices, store.Start(ctx, testAdmission("s3", "user-1", "device-c", testStart, limits)), name)
+		assert.NoError(t, store.RemoveDevice(ctx, "user-1", "device-b"), name)
+		assert.NoError(t, store.Start(ctx, testAdmission("s3", "user-1", "device-c", testStart, limits)), name)
+		assert.Equal(t, []string{"device-a", "device-c"}, deviceIDs(t, store, "user-1", testStart), name)
+
+		// A device unused beyond its expiry frees its slot.
+		a := testAdmission("s4", "user-2", "device-x", testStart, limits)
+		a.Device.ExpiresAt = testStart.Add(time.Hour)
+		assert.NoError(t, store.Start(ctx, a), name)
+		assert.Equal(t, []string{"device-x"}, deviceIDs(t, store, "user-2", testStart), name)
+		assert.Empty(t, deviceIDs(t, store, "user-2", testStart.Add(2*time.Hour)), name)
+	}
+}
+
+func TestStoreHeartbeats(t *testing.T) {
+	ctx := context.Background()
+	for name, store := range stores(t) {
+		assert.NoError(t, store.Start(ctx, testAdmission("s1", "user-1", "device-a", testStart, Limits{})), name)
+		assert.NoError(t, store.Start(ctx, testAdmission("s2", "user-1", "device-b", testStart.Add(time.Second), Limits{})), name)
+
+		// Only the heartbeats of the user keep s1 alive, while s2 expires.
+		_, err := store.Touch(ctx, "user-2", "s1", testStart.Add(2*time.Minute), testStart.Add(5*time.Minute))
+		assert.Equal(t, ErrSessionNotFound, err, name)
+		session, err := store.Touch(ctx, "user-1", "s1", testStart.Add(2*time.Minute), testStart.Add(5*time.Minute))
+		if assert.NoError(t, err, name) {
+			assert.Equal(t, "device-a", session.DeviceID, name)
+			assert.True(t, session.ExpiresAt.Equal(testStart.Add(5*time.Minute)), name)
+		}
+		now := testStart.Add(4 * time.Minute)
+		assert.Equal(t, []string{"s1"}, sessionIDs(t, store, "user-1", now), name)
+		_, err = store.Touch(ctx, "user-1", "s2", now, now.Add(3*time.Minute))
+		assert.Equal(t, ErrSessionNotFound, err, name)
+
+		// A heartbeat coming once the session expired does not bring it back.
+		now = testStart.Add(5 * time.Minute)
+		_, err = store.Touch(ctx, "user-1", "s1", now, now.Add(3*time.Minute))
+		assert.Equal(t, ErrSessionNotFound, err, name)
+		assert.Empty(t, sessionIDs(t, store, "user-1", now), name)
+
+		// Only the user of a session ends it.
+		assert.NoError(t, store.Start(ctx, testAdmission("s3", "user-1", "device-a", now, Limits{})), name)
+		assert.NoError(t, store.End(ctx, "user-2", "s3"), name)
+		assert.Equal(t, []string{"s3"}, sessionIDs(t, store, "user-1", now), name)
+		assert.NoError(t, store.End(ctx, "user-1", "s3"), name)
+		assert.Empty(t, sessionIDs(t, store, "user-1", now), name)
+	}
+}
+
+func TestSQLStoreQuery(t *testing.T) {
+	store := NewSQLStore(nil, PlaceholderDollar)
+	assert.Equal(t, "DELETE FROM license_devices WHERE user_id = $1 AND device_id = $2",
+		store.query("DELETE FROM %s WHERE user_id = ? AND device_id = ?", store.DevicesTable))
+}
+
+func testRequest(userID, tier string) *widevineproxy.AuthorizationRequest {
+	req := &widevineproxy.AuthorizationRequest{
+		PsshData: widevineproxy.PsshData{ContentID: "bW92aWUtMQ=="},
+		Device:   widevineproxy.DeviceInfo{Make: "Google", DRMCertSerialNumber: "ZGV2aWNlLWE="},
+	}
+	if userID != "" {
+		req.Claims = &widevineproxy.Claims{UserID: userID, Attributes: map[string]interface{}{"tier": tier}}
+	}
+	return req
+}
+
+func issued(req *widevineproxy.AuthorizationRequest, sessionID, licenseType string) *widevineproxy.IssuedLicense {
+	response := &widevineproxy.LicenseResponse{}
+	response.SessionState.LicenseID = widevineproxy.LicenseID{SessionID: sessionID, Type: licenseType}
+	return &widevineproxy.IssuedLicense{Request: req, Message: &widevineproxy.Message{}, Response: response}
+}
+
+func TestTracker(t *testing.T) {
+	now := testStart
+	tracker := NewTracker(NewMemoryStore(), Limits{MaxStreams: 1})
+	tracker.Tiers = map[string]Limits{"premium": {MaxStreams: 2}}
+	tracker.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	req := testRequest("user-1", "basic")
+	assert.NoError(t, tracker.CheckLicense(ctx, req))
+	assert.NoError(t, tracker.LicenseIssued(ctx, issued(req, "c2Vzc2lvbi0x", "STREAMING")))
+	var limitDenied *widevineproxy.AuthorizationDeniedError
+	if assert.True(t, errors.As(tracker.CheckLicense(ctx, req), &limitDenied)) {
+		assert.Equal(t, "user user-1 reached the limit of 1 concurrent streams", limitDenied.Reason)
+	}
+	assert.True(t, errors.Is(tracker.LicenseIssued(ctx, issued(req, "c2Vzc2lvbi0y", "STREAMING")), ErrLimitExceeded))
+	assert.Error(t, tracker.LicenseIssued(ctx, issued(testRequest("user-2", ""), "", "STREAMING")))
+
+	// Neither the premium tier, the offline licenses nor the anonymous users are bound by the basic limits.
+	premium := testRequest("user-3", "premium")
+	assert.NoError(t, tracker.LicenseIssued(ctx, issued(premium, "c2Vzc2lvbi0z", "STREAMING")))
+	assert.NoError(t, tracker.CheckLicense(ctx, premium))
+	assert.NoError(t, tracker.LicenseIssued(ctx, issued(req, "b2ZmbGluZS0x", "OFFLINE")))
+	assert.NoError(t, tracker.CheckLicense(ctx, testRequest("", "")))
+	assert.False(t, tracker.RequiresDeviceInfo(&req.PsshData))
+
+	// Heartbeats keep the session alive until they stop.
+	userCtx := widevineproxy.WithClaims(ctx, req.Claims)
+	renewal := &widevineproxy.Renewal{LicenseID: widevineproxy.LicenseID{SessionID: "c2Vzc2lvbi0x", Type: "STREAMING"}}
+	now = now.Add(2 * time.Minute)
+	assert.NoError(t, tracker.LicenseRenewed(userCtx, renewal))
+	var denied *widevineproxy.RenewalDeniedError
+	assert.True(t, errors.As(tracker.LicenseRenewed(widevineproxy.WithClaims(ctx, premium.Claims), renewal), &denied))
+	now = now.Add(DefaultHeartbeatTimeout - time.Second)
+	assert.True(t, errors.Is(tracker.CheckLicense(ctx, req), ErrLimitExceeded))
+	now = now.Add(time.Second)
+	assert.NoError(t, tracker.CheckLicense(ctx, req))
+	if assert.True(t, errors.As(tracker.LicenseRenewed(userCtx, renewal), &denied)) {
+		assert.Equal(t, "session expired", denied.Reason)
+	}
+
+	// A release frees the slot at once.
+	assert.NoError(t, tracker.LicenseIssued(ctx, issued(req, "c2Vzc2lvbi00", "STREAMING")))
+	assert.Error(t, tracker.CheckLicense(ctx, req))
+	release := &widevineproxy.Release{LicenseID: widevineproxy.LicenseID{SessionID: "c2Vzc2lvbi00", Type: "STREAMING"}}
+	assert.NoError(t, tracker.LicenseReleased(userCtx, release))
+	assert.NoError(t, tracker.CheckLicense(ctx, req))
+}
+
+func TestTrackerDevices(t *testing.T) {
+	now := testStart
+	tracker := NewTracker(NewMemoryStore(), Limits{})
+	tracker.Tiers = map[string]Limits{"family": {MaxDevices: 1}}
+	tracker.DeviceTTL = 24 * time.Hour
+	tracker.now = func() time.Time { return now }
+	ctx := context.Background()
+	assert.True(t, tracker.RequiresDeviceInfo(&widevineproxy.PsshData{}))
+
+	req := testRequest("user-1", "family")
+	assert.NoError(t, tracker.LicenseIssued(ctx, issued(req, "c2Vzc2lvbi0x", "STREAMING")))
+	other := testRequest("user-1", "family")
+	other.Device.DRMCertSerialNumber = "ZGV2aWNlLWI="
+	var limitErr *LimitError
+	if assert.True(t, errors.As(tracker.CheckLicense(ctx, other), &limitErr)) {
+		assert.Equal(t, LimitDevices, limitErr.Limit)
+	}
+	assert.NoError(t, tracker.CheckLicense(ctx, req))
+
+	now = now.Add(25 * time.Hour)
+	assert.NoError(t, tracker.CheckLicense(ctx, other))
+	devices, err := tracker.Store.Devices(ctx, "user-1", testStart)
+	assert.NoError(t, err)
+	assert.Empty(t, devices)
+}
+
+func TestTrackerApplyPolicy(t *testing.T) {
+	tracker := NewTracker(NewMemoryStore(), Limits{MaxStreams: 1})
+	ctx := context.Background()
+
+	message := &widevineproxy.Message{}
+	assert.NoError(t, tracker.ApplyPolicy(ctx, testRequest("user-1", ""), message))
+	if assert.NotNil(t, message.PolicyOverrides) {
+		assert.True(t, message.PolicyOverrides.CanPlay)
+		assert.True(t, message.PolicyOverrides.CanRenew)
+		assert.Equal(t, uint64(60), message.PolicyOverrides.RenewalDelaySeconds)
+		// Playback stops with the session unless renewed.
+		assert.Equal(t, uint64(180), message.PolicyOverrides.LicenseDurationSeconds)
+		assert.Equal(t, uint64(180), message.PolicyOverrides.RenewalRecoveryDurationSeconds)
+	}
+
+	short := &widevineproxy.Message{PolicyOverrides: &widevineproxy.PolicyOverrides{CanPlay: true, LicenseDurationSeconds: 90}}
+	assert.NoError(t, tracker.ApplyPolicy(ctx, testRequest("user-1", ""), short))
+	assert.Equal(t, uint64(90), short.PolicyOverrides.LicenseDurationSeconds)
+
+	offline := &widevineproxy.Message{PolicyOverrides: &widevineproxy.PolicyOverrides{CanPlay: true, CanPersist: true}}
+	assert.NoError(t, tracker.ApplyPolicy(ctx, testRequest("user-1", ""), offline))
+	assert.False(t, offline.PolicyOverrides.CanRenew)
+
+	anonymous := &widevineproxy.Message{}
+	assert.NoError(t, tracker.ApplyPolicy(ctx, testRequest("", ""), anonymous))
+	assert.Nil(t, anonymous.PolicyOverrides)
+
+	// Overrides shared with the configuration of the authority are left untouched.
+	shared := &widevineproxy.PolicyOverrides{CanPlay: true, LicenseDurationSeconds: 3600}
+	message = &widevineproxy.Message{PolicyOverrides: shared}
+	assert.NoError(t, tracker.ApplyPolicy(ctx, testRequest("user-1", ""), message))
+	assert.True(t, message.PolicyOverrides.CanRenew)
+	assert.Equal(t, uint64(180), message.PolicyOverrides.LicenseDurationSeconds)
+	assert.Equal(t, &widevineproxy.PolicyOverrides{CanPlay: true, LicenseDurationSeconds: 3600}, shared)
+}
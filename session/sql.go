@@ -0,0 +1,273 @@
+package widevinesession
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Schema holds the statements creating the tables of a SQLStore, with the default table names.
+// Times are stored as Unix seconds, 0 standing for the zero time.
+var Schema = []string{
+	`CREATE TABLE license_sessions (
+	id         VARCHAR(255) NOT NULL,
+	user_id    VARCHAR(255) NOT NULL,
+	device_id  VARCHAR(255) NOT NULL DEFAULT '',
+	content_id VARCHAR(255) NOT NULL DEFAULT '',
+	started    BIGINT       NOT NULL,
+	last_seen  BIGINT       NOT NULL,
+	expires_at BIGINT       NOT NULL,
+	PRIMARY KEY (id)
+)`,
+	`CREATE INDEX license_sessions_user_id ON license_sessions (user_id)`,
+	`CREATE TABLE license_devices (
+	user_id    VARCHAR(255) NOT NULL,
+	device_id  VARCHAR(255) NOT NULL,
+	make       VARCHAR(255) NOT NULL DEFAULT '',
+	model      VARCHAR(255) NOT NULL DEFAULT '',
+	first_seen BIGINT       NOT NULL,
+	last_seen  BIGINT       NOT NULL,
+	expires_at BIGINT       NOT NULL DEFAULT 0,
+	PRIMARY KEY (user_id, device_id)
+)`,
+}
+
+// PlaceholderStyle is the bind variable syntax of the database driver.
+type PlaceholderStyle int
+
+const (
+	PlaceholderQuestion PlaceholderStyle = iota // ?, e.g. MySQL and SQLite.
+	PlaceholderDollar                           // $1, e.g. PostgreSQL.
+)
+
+// SQLStore is a Store backed by database tables, see Schema, shared by the instances of the proxy.
+// The driver is up to the caller, e.g. a blank import of github.com/lib/pq.
+type SQLStore struct {
+	DB            *sql.DB
+	SessionsTable string
+	DevicesTable  string
+	Placeholders  PlaceholderStyle
+	// Isolation is the isolation level of the transactions starting sessions. The limits hold across
+	// concurrent instances with sql.LevelSerializable, if the database supports it.
+	Isolation sql.IsolationLevel
+}
+
+// NewSQLStore creates a SQLStore on the license_sessions and license_devices tables of db.
+func NewSQLStore(db *sql.DB, placeholders PlaceholderStyle) *SQLStore {
+	return &SQLStore{DB: db, SessionsTable: "license_sessions", DevicesTable: "license_devices", Placeholders: placeholders}
+}
+
+const (
+	sessionColumns = "id, user_id, device_id, content_id, started, last_seen, expires_at"
+	deviceColumns  = "user_id, device_id, make, model, first_seen, last_seen, expires_at"
+)
+
+// queryer is implemented by *sql.DB and *sql.Tx.
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+func (s *SQLStore) Check(ctx context.Context, a *Admission) error {
+	sessions, devices, err := s.active(ctx, s.DB, a.Session.UserID, a.Session.Started)
+	if err != nil {
+		return err
+	}
+	return admit(a, sessions, devices)
+}
+
+func (s *SQLStore) Start(ctx context.Context, a *Admission) error {
+	tx, err := s.DB.BeginTx(ctx, &sql.TxOptions{Isolation: s.Isolation})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	session := a.Session
+	sessions, devices, err := s.active(ctx, tx, session.UserID, session.Started)
+	if err != nil {
+		return err
+	}
+	if err := admit(a, sessions, devices); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, s.query("DELETE FROM %s WHERE id = ?", s.SessionsTable), session.ID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx,
+		s.query("INSERT INTO %s ("+sessionColumns+") VALUES (?, ?, ?, ?, ?, ?, ?)", s.SessionsTable),
+		session.ID, session.UserID, session.DeviceID, session.ContentID,
+		unix(session.Started), unix(session.LastSeen), unix(session.ExpiresAt)); err != nil {
+		return err
+	}
+	if a.Device != nil {
+		var current *Device
+		for _, d := range devices {
+			if d.ID == a.Device.ID {
+				current = d
+			}
+		}
+		d := registration(a, current)
+		if _, err := tx.ExecContext(ctx, s.query("DELETE FROM %s WHERE user_id = ? AND device_id = ?", s.DevicesTable),
+			d.UserID, d.ID); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx,
+			s.query("INSERT INTO %s ("+deviceColumns+") VALUES (?, ?, ?, ?, ?, ?, ?)", s.DevicesTable),
+			d.UserID, d.ID, d.Make, d.Model, unix(d.FirstSeen), unix(d.LastSeen), unix(d.ExpiresAt)); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Touch extends the session with a single conditional update, so that a session expiring concurrently,
+// e.g. swept by another instance, is not brought back. The session is read back rather than relying on
+// the affected rows, which some databases count only when the values change.
+func (s *SQLStore) Touch(ctx context.Context, userID, id string, now, expiresAt time.Time) (*Session, error) {
+	if _, err := s.DB.ExecContext(ctx,
+		s.query("UPDATE %s SET last_seen = ?, expires_at = ? WHERE user_id = ? AND id = ? AND expires_at > ?", s.SessionsTable),
+		unix(now), unix(expiresAt), userID, id, unix(now)); err != nil {
+		return nil, err
+	}
+	sessions, err := s.sessions(ctx, s.DB, "id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(sessions) == 0 || sessions[0].UserID != userID || sessions[0].expired(now) {
+		return nil, ErrSessionNotFound
+	}
+	return sessions[0], nil
+}
+
+func (s *SQLStore) End(ctx context.Context, userID, id string) error {
+	_, err := s.DB.ExecContext(ctx, s.query("DELETE FROM %s WHERE user_id = ? AND id = ?", s.SessionsTable), userID, id)
+	return err
+}
+
+func (s *SQLStore) Sessions(ctx context.Context, userID string, now time.Time) ([]*Session, error) {
+	sessions, _, err := s.active(ctx, s.DB, userID, now)
+	return sessions, err
+}
+
+func (s *SQLStore) Devices(ctx context.Context, userID string, now time.Time) ([]*Device, error) {
+	_, devices, err := s.active(ctx, s.DB, userID, now)
+	return devices, err
+}
+
+func (s *SQLStore) RemoveDevice(ctx context.Context, userID, deviceID string) error {
+	_, err := s.DB.ExecContext(ctx, s.query("DELETE FROM %s WHERE user_id = ? AND device_id = ?", s.DevicesTable),
+		userID, deviceID)
+	return err
+}
+
+// active deletes the expired sessions and devices of userID and returns the others.
+func (s *SQLStore) active(ctx context.Context, q queryer, userID string, now time.Time) ([]*Session, []*Device, error) {
+	all, err := s.sessions(ctx, q, "user_id = ?", userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	var sessions []*Session
+	for _, session := range all {
+		if !session.expired(now) {
+			sessions = append(sessions, session)
+			continue
+		}
+		if _, err := q.ExecContext(ctx, s.query("DELETE FROM %s WHERE id = ?", s.SessionsTable), session.ID); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	rows, err := q.QueryContext(ctx, s.query("SELECT "+deviceColumns+" FROM %s WHERE user_id = ?", s.DevicesTable), userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	registered, err := scanDevices(rows)
+	if err != nil {
+		return nil, nil, err
+	}
+	var devices []*Device
+	for _, d := range registered {
+		if !d.expired(now) {
+			devices = append(devices, d)
+			continue
+		}
+		if _, err := q.ExecContext(ctx, s.query("DELETE FROM %s WHERE user_id = ? AND device_id = ?", s.DevicesTable),
+			d.UserID, d.ID); err != nil {
+			return nil, nil, err
+		}
+	}
+	sortSessions(sessions)
+	sortDevices(devices)
+	return sessions, devices, nil
+}
+
+func (s *SQLStore) sessions(ctx context.Context, q queryer, where string, arg interface{}) ([]*Session, error) {
+	rows, err := q.QueryContext(ctx, s.query("SELECT "+sessionColumns+" FROM %s WHERE "+where, s.SessionsTable), arg)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var sessions []*Session
+	for rows.Next() {
+		var session Session
+		var started, lastSeen, expiresAt int64
+		if err := rows.Scan(&session.ID, &session.UserID, &session.DeviceID, &session.ContentID,
+			&started, &lastSeen, &expiresAt); err != nil {
+			return nil, err
+		}
+		session.Started, session.LastSeen, session.ExpiresAt = fromUnix(started), fromUnix(lastSeen), fromUnix(expiresAt)
+		sessions = append(sessions, &session)
+	}
+	return sessions, rows.Err()
+}
+
+func scanDevices(rows *sql.Rows) ([]*Device, error) {
+	defer rows.Close()
+	var devices []*Device
+	for rows.Next() {
+		var d Device
+		var firstSeen, lastSeen, expiresAt int64
+		if err := rows.Scan(&d.UserID, &d.ID, &d.Make, &d.Model, &firstSeen, &lastSeen, &expiresAt); err != nil {
+			return nil, err
+		}
+		d.FirstSeen, d.LastSeen, d.ExpiresAt = fromUnix(firstSeen), fromUnix(lastSeen), fromUnix(expiresAt)
+		devices = append(devices, &d)
+	}
+	return devices, rows.Err()
+}
+
+// query formats the table name into q and rewrites its placeholders.
+func (s *SQLStore) query(q, table string) string {
+	q = fmt.Sprintf(q, table)
+	if s.Placeholders != PlaceholderDollar {
+		return q
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range q {
+		if r == '?' {
+			n++
+			fmt.Fprintf(&b, "$%d", n)
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func unix(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.Unix()
+}
+
+func fromUnix(sec int64) time.Time {
+	if sec == 0 {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0).UTC()
+}
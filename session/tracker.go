@@ -0,0 +1,190 @@
+package widevinesession
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	widevineproxy "github.com/cooomma/widevine-proxy/proxy"
+)
+
+// Defaults of the trackers created by NewTracker.
+const (
+	DefaultHeartbeatInterval = time.Minute
+	DefaultHeartbeatTimeout  = 3 * DefaultHeartbeatInterval
+	DefaultTierAttribute     = "tier"
+)
+
+// Tracker is a widevineproxy.LicenseTracker limiting the concurrent streams and the registered devices
+// of the authenticated users, see Limits. It is also a widevineproxy.Policy requiring the heartbeats
+// of the streaming licenses, to be set last in the policy chain of the proxy.
+// The licenses of anonymous users and the offline licenses are not tracked.
+type Tracker struct {
+	Store Store
+	// Limits applies to the users whose tier has no limits of its own.
+	Limits Limits
+	// Tiers holds the limits of the subscription tiers, read from the claims attribute TierAttribute.
+	Tiers         map[string]Limits
+	TierAttribute string // DefaultTierAttribute if empty.
+
+	// HeartbeatInterval is the renewal delay of the streaming licenses. Zero leaves their policy untouched.
+	HeartbeatInterval time.Duration
+	// HeartbeatTimeout ends a session whose last heartbeat is older.
+	HeartbeatTimeout time.Duration
+	// DeviceTTL unregisters the devices without a new session for that long. Zero keeps them registered.
+	DeviceTTL time.Duration
+
+	now func() time.Time // time.Now if nil.
+}
+
+// NewTracker creates a Tracker on store with the default heartbeat interval and timeout.
+func NewTracker(store Store, limits Limits) *Tracker {
+	return &Tracker{
+		Store:             store,
+		Limits:            limits,
+		HeartbeatInterval: DefaultHeartbeatInterval,
+		HeartbeatTimeout:  DefaultHeartbeatTimeout,
+	}
+}
+
+// CheckLicense refuses a license with a LimitError when its session would exceed the limits of the user.
+func (t *Tracker) CheckLicense(ctx context.Context, req *widevineproxy.AuthorizationRequest) error {
+	if !tracked(req.Claims) || req.LicenseType == widevineproxy.LicenseTypeOffline {
+		return nil
+	}
+	return t.Store.Check(ctx, t.admission(req, ""))
+}
+
+// LicenseIssued starts the session of a streaming license, unless a concurrent license took the last slot.
+func (t *Tracker) LicenseIssued(ctx context.Context, license *widevineproxy.IssuedLicense) error {
+	licenseID := license.Response.SessionState.LicenseID
+	if !tracked(license.Request.Claims) || license.Request.LicenseType == widevineproxy.LicenseTypeOffline ||
+		licenseID.Type == widevineproxy.LicenseTypeOffline {
+		return nil
+	}
+	if licenseID.SessionID == "" {
+		return fmt.Errorf("license of content %s has no session id", license.Request.PsshData.ContentID)
+	}
+	return t.Store.Start(ctx, t.admission(license.Request, licenseID.SessionID))
+}
+
+// LicenseRenewed extends the session of the license until the next heartbeat is due.
+// The renewal is refused with a RenewalDeniedError once the session expired.
+func (t *Tracker) LicenseRenewed(ctx context.Context, renewal *widevineproxy.Renewal) error {
+	claims := widevineproxy.ClaimsFromContext(ctx)
+	if !tracked(claims) || renewal.LicenseID.Type == widevineproxy.LicenseTypeOffline {
+		return nil
+	}
+	now := t.clock()
+	_, err := t.Store.Touch(ctx, claims.UserID, renewal.LicenseID.SessionID, now, now.Add(t.HeartbeatTimeout))
+	if errors.Is(err, ErrSessionNotFound) {
+		return &widevineproxy.RenewalDeniedError{LicenseID: renewal.LicenseID, Reason: "session expired"}
+	}
+	return err
+}
+
+// LicenseReleased ends the session of the license, freeing its slot.
+func (t *Tracker) LicenseReleased(ctx context.Context, release *widevineproxy.Release) error {
+	claims := widevineproxy.ClaimsFromContext(ctx)
+	if !tracked(claims) || release.LicenseID.Type == widevineproxy.LicenseTypeOffline {
+		return nil
+	}
+	return t.Store.End(ctx, claims.UserID, release.LicenseID.SessionID)
+}
+
+// ApplyPolicy makes the streaming licenses of the tracked users renewable every HeartbeatInterval.
+// Their license and renewal recovery durations are bounded by HeartbeatTimeout, so that a client
+// playing past the end of its session, without the heartbeats keeping its slot, is stopped.
+func (t *Tracker) ApplyPolicy(ctx context.Context, req *widevineproxy.AuthorizationRequest, message *widevineproxy.Message) error {
+	if !tracked(req.Claims) || req.LicenseType == widevineproxy.LicenseTypeOffline || t.HeartbeatInterval <= 0 {
+		return nil
+	}
+	// The overrides may be shared with the authority, e.g. its configuration: change a copy.
+	overrides := widevineproxy.PolicyOverrides{CanPlay: true}
+	if message.PolicyOverrides != nil {
+		overrides = *message.PolicyOverrides
+	}
+	if overrides.CanPersist {
+		return nil
+	}
+	overrides.CanRenew = true
+	overrides.RenewalDelaySeconds = uint64(t.HeartbeatInterval / time.Second)
+	if timeout := uint64(t.HeartbeatTimeout / time.Second); timeout > 0 {
+		for _, d := range []*uint64{&overrides.LicenseDurationSeconds, &overrides.RenewalRecoveryDurationSeconds} {
+			if *d == 0 || *d > timeout {
+				*d = timeout
+			}
+		}
+	}
+	message.PolicyOverrides = &overrides
+	return nil
+}
+
+// RequiresDeviceInfo reports whether some limits count the devices, which are identified by the PARSE_ONLY response.
+func (t *Tracker) RequiresDeviceInfo(psshData *widevineproxy.PsshData) bool {
+	if t.Limits.countsDevices() {
+		return true
+	}
+	for _, limits := range t.Tiers {
+		if limits.countsDevices() {
+			return true
+		}
+	}
+	return false
+}
+
+// LimitsOf returns the limits of the user identified by claims.
+func (t *Tracker) LimitsOf(claims *widevineproxy.Claims) Limits {
+	name := t.TierAttribute
+	if name == "" {
+		name = DefaultTierAttribute
+	}
+	if v, ok := claims.Attributes[name]; ok && v != nil {
+		if limits, ok := t.Tiers[fmt.Sprint(v)]; ok {
+			return limits
+		}
+	}
+	return t.Limits
+}
+
+func (t *Tracker) admission(req *widevineproxy.AuthorizationRequest, id string) *Admission {
+	now := t.clock()
+	a := &Admission{
+		Session: &Session{
+			ID:        id,
+			UserID:    req.Claims.UserID,
+			DeviceID:  req.Device.DRMCertSerialNumber,
+			ContentID: req.PsshData.ContentID,
+			Started:   now,
+			LastSeen:  now,
+			ExpiresAt: now.Add(t.HeartbeatTimeout),
+		},
+		Limits: t.LimitsOf(req.Claims),
+	}
+	if a.Session.DeviceID != "" {
+		a.Device = &Device{
+			UserID:    req.Claims.UserID,
+			ID:        a.Session.DeviceID,
+			Make:      req.Device.Make,
+			Model:     req.Device.Model,
+			FirstSeen: now,
+			LastSeen:  now,
+		}
+		if t.DeviceTTL > 0 {
+			a.Device.ExpiresAt = now.Add(t.DeviceTTL)
+		}
+	}
+	return a
+}
+
+func (t *Tracker) clock() time.Time {
+	if t.now != nil {
+		return t.now()
+	}
+	return time.Now()
+}
+
+func tracked(claims *widevineproxy.Claims) bool {
+	return claims != nil && claims.UserID != ""
+}
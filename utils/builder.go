@@ -0,0 +1,134 @@
+package widevineutils
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+
+	pb "github.com/cooomma/widevine-proxy/proto"
+	proto "github.com/golang/protobuf/proto"
+)
+
+// Protection schemes of the common encryption, ISO/IEC 23001-7.
+var protectionSchemes = [...]string{"cenc", "cbc1", "cens", "cbcs"}
+
+// PSSHBuilder builds the Widevine pssh box of a content, the inverse of PSSH.Parse.
+type PSSHBuilder struct {
+	// Version of the box. A version 1 box also lists the key ids in its header.
+	Version int
+
+	KeyIDs    [][]byte // 16 bytes each.
+	ContentID []byte
+	Provider  string
+	// ProtectionScheme is the 4CC of the encryption scheme: cenc, cbc1, cens or cbcs. Omitted if empty.
+	ProtectionScheme string
+	// CryptoPeriodIndex is the index of the crypto period, for media using key rotation. Omitted if nil.
+	CryptoPeriodIndex *uint32
+}
+
+// WidevineCencHeader returns the Widevine pssh data of the box. The deprecated algorithm is left out, as by Shaka Packager.
+func (b *PSSHBuilder) WidevineCencHeader() (*pb.WidevineCencHeader, error) {
+	if len(b.KeyIDs) == 0 && len(b.ContentID) == 0 {
+		return nil, fmt.Errorf("pssh data needs a key id or a content id")
+	}
+	header := &pb.WidevineCencHeader{
+		KeyId:             b.KeyIDs,
+		ContentId:         b.ContentID,
+		CryptoPeriodIndex: b.CryptoPeriodIndex,
+	}
+	if b.Provider != "" {
+		header.Provider = proto.String(b.Provider)
+	}
+	if b.ProtectionScheme != "" {
+		scheme, err := fourCC(b.ProtectionScheme)
+		if err != nil {
+			return nil, err
+		}
+		header.ProtectionScheme = proto.Uint32(scheme)
+	}
+	return header, nil
+}
+
+// Build returns the binary pssh box.
+func (b *PSSHBuilder) Build() ([]byte, error) {
+	header, err := b.WidevineCencHeader()
+	if err != nil {
+		return nil, err
+	}
+	data, err := proto.Marshal(header)
+	if err != nil {
+		return nil, err
+	}
+	var keyIDs [][]byte
+	if b.Version == 1 {
+		keyIDs = b.KeyIDs
+	}
+	systemID, _ := hex.DecodeString(WIDEVINE_SYSTEM_ID)
+	return BuildPSSHBox(b.Version, systemID, keyIDs, data)
+}
+
+// Base64 returns the pssh box encoded in base64, as in the cenc:pssh element of a DASH manifest.
+func (b *PSSHBuilder) Base64() (string, error) {
+	box, err := b.Build()
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(box), nil
+}
+
+// Hex returns the pssh box encoded in hex.
+func (b *PSSHBuilder) Hex() (string, error) {
+	box, err := b.Build()
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(box), nil
+}
+
+// BuildPSSHBox boxes the pssh data of a DRM system. keyIDs are only written in a version 1 box.
+func BuildPSSHBox(version int, systemID []byte, keyIDs [][]byte, data []byte) ([]byte, error) {
+	if version != 0 && version != 1 {
+		return nil, fmt.Errorf("unsupported pssh version %d", version)
+	}
+	if len(systemID) != 16 {
+		return nil, fmt.Errorf("system id must be 16 bytes")
+	}
+	if version == 0 && len(keyIDs) != 0 {
+		return nil, fmt.Errorf("a version 0 pssh box has no key ids")
+	}
+
+	size := 8 + 4 + 16 + 4 + len(data)
+	if version == 1 {
+		size += 4 + 16*len(keyIDs)
+	}
+	box := make([]byte, 0, size)
+	box = appendUint32(box, uint32(size))
+	box = append(box, "pssh"...)
+	box = appendUint32(box, uint32(version)<<24)
+	box = append(box, systemID...)
+	if version == 1 {
+		box = appendUint32(box, uint32(len(keyIDs)))
+		for _, keyID := range keyIDs {
+			if len(keyID) != 16 {
+				return nil, fmt.Errorf("key id %x must be 16 bytes", keyID)
+			}
+			box = append(box, keyID...)
+		}
+	}
+	box = appendUint32(box, uint32(len(data)))
+	return append(box, data...), nil
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func fourCC(scheme string) (uint32, error) {
+	for _, s := range protectionSchemes {
+		if s == scheme {
+			return binary.BigEndian.Uint32([]byte(s)), nil
+		}
+	}
+	return 0, fmt.Errorf("unsupported protection scheme %q", scheme)
+}
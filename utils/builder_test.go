@@ -0,0 +1,115 @@
+package widevineutils
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"testing"
+)
+
+func TestPSSHBuilder(t *testing.T) {
+	keyID, _ := hex.DecodeString("93e4b7f0e28fea87304a68835bce6c71")
+	b := &PSSHBuilder{KeyIDs: [][]byte{keyID}, ProtectionScheme: "cenc"}
+	encoded, err := b.Base64()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "AAAAOHBzc2gAAAAA7e+LqXnWSs6jyCfc1R0h7QAAABgSEJPkt/Dij+qHMEpog1vObHFI49yVmwY="
+	if encoded != expected {
+		t.Errorf("Base64 must be %s got %s", expected, encoded)
+	}
+
+	h, err := b.Hex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	box, _ := base64.StdEncoding.DecodeString(expected)
+	if h != hex.EncodeToString(box) {
+		t.Errorf("Hex must be %x got %s", box, h)
+	}
+}
+
+func TestPSSHBuilderRoundTrip(t *testing.T) {
+	cryptoPeriod := uint32(7)
+	b := &PSSHBuilder{
+		KeyIDs:            [][]byte{bytes.Repeat([]byte{0x11}, 16), bytes.Repeat([]byte{0x22}, 16)},
+		ContentID:         []byte("movie-1"),
+		Provider:          "widevine_test",
+		ProtectionScheme:  "cbcs",
+		CryptoPeriodIndex: &cryptoPeriod,
+	}
+	box, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pssh := NewPSSH(box)
+	if _, err := pssh.Parse(); err != nil {
+		t.Fatal(err)
+	}
+	if pssh.Summary.SizeDecimal != int64(len(box)) {
+		t.Errorf("Summary.SizeDecimal must be %d got %d", len(box), pssh.Summary.SizeDecimal)
+	}
+	if pssh.Summary.Version != "00" {
+		t.Errorf("Summary.Version must be 00 got %s", pssh.Summary.Version)
+	}
+	if pssh.Summary.DRMSystemID != WIDEVINE_SYSTEM_ID {
+		t.Errorf("Summary.DRMSystemID must be %s got %s", WIDEVINE_SYSTEM_ID, pssh.Summary.DRMSystemID)
+	}
+	if len(pssh.Summary.KeyIDs) != 2 || pssh.Summary.KeyIDs[1] != "22222222222222222222222222222222" {
+		t.Errorf("Summary.KeyIDs must be the 2 key ids got %v", pssh.Summary.KeyIDs)
+	}
+
+	header := pssh.Summary.Widevine
+	if string(header.ContentId) != "movie-1" || header.GetProvider() != "widevine_test" {
+		t.Errorf("unexpected content id %q or provider %q", header.ContentId, header.GetProvider())
+	}
+	if header.GetProtectionScheme() != 0x63626373 {
+		t.Errorf("ProtectionScheme must be cbcs got %x", header.GetProtectionScheme())
+	}
+	if header.GetCryptoPeriodIndex() != 7 {
+		t.Errorf("CryptoPeriodIndex must be 7 got %d", header.GetCryptoPeriodIndex())
+	}
+}
+
+func TestPSSHBuilderVersion1(t *testing.T) {
+	keyIDs := [][]byte{bytes.Repeat([]byte{0x11}, 16), bytes.Repeat([]byte{0x22}, 16)}
+	b := &PSSHBuilder{Version: 1, KeyIDs: keyIDs}
+	box, err := b.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	summary, err := NewPSSH(box).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Version != "01" {
+		t.Errorf("Summary.Version must be 01 got %s", summary.Version)
+	}
+	if len(summary.KeyIDs) != 2 || summary.KeyIDs[1] != "22222222222222222222222222222222" {
+		t.Errorf("Summary.KeyIDs must be the 2 key ids got %v", summary.KeyIDs)
+	}
+	if summary.Widevine == nil || len(summary.Widevine.KeyId) != 2 {
+		t.Errorf("the data must carry the key ids got %v", summary.Widevine)
+	}
+	if kidCount := box[28:32]; !bytes.Equal(kidCount, []byte{0, 0, 0, 2}) {
+		t.Errorf("KID count must be 2 got %x", kidCount)
+	}
+	if !bytes.Equal(box[32:48], keyIDs[0]) || !bytes.Equal(box[48:64], keyIDs[1]) {
+		t.Errorf("the key ids must be listed in the box header got %x", box[32:64])
+	}
+}
+
+func TestPSSHBuilderErrors(t *testing.T) {
+	builders := []*PSSHBuilder{
+		{},
+		{ContentID: []byte("movie-1"), ProtectionScheme: "ctr"},
+		{ContentID: []byte("movie-1"), Version: 2},
+		{Version: 1, KeyIDs: [][]byte{{0x11}}},
+	}
+	for i, b := range builders {
+		if _, err := b.Build(); err == nil {
+			t.Errorf("builder %d must fail", i)
+		}
+	}
+}
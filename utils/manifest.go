@@ -0,0 +1,173 @@
+package widevineutils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// mp4ProtectionScheme is the scheme of the ContentProtection element announcing the common encryption.
+const mp4ProtectionScheme = "urn:mpeg:dash:mp4protection:2011"
+
+type mpdContentProtection struct {
+	SchemeIDURI string   `xml:"schemeIdUri,attr"`
+	Value       string   `xml:"value,attr"`
+	DefaultKID  string   `xml:"default_KID,attr"` // cenc:default_KID
+	PSSH        []string `xml:"pssh"`             // cenc:pssh
+	PRO         []string `xml:"pro"`              // mspr:pro
+}
+
+// ParseMPD generate the protection summary of a DASH manifest from its ContentProtection elements:
+// the cenc:pssh boxes, the mspr:pro objects, the cenc:default_KID and the scheme of the mp4protection element
+func ParseMPD(b []byte) (*ProtectionSummary, error) {
+	summary := &ProtectionSummary{}
+	decoder := xml.NewDecoder(bytes.NewReader(b))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid mpd: %v", err)
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "ContentProtection" {
+			continue
+		}
+
+		var cp mpdContentProtection
+		if err := decoder.DecodeElement(&cp, &start); err != nil {
+			return nil, fmt.Errorf("invalid mpd: %v", err)
+		}
+		if cp.DefaultKID != "" {
+			summary.addKeyID(cp.DefaultKID)
+		}
+		if strings.EqualFold(cp.SchemeIDURI, mp4ProtectionScheme) && cp.Value != "" {
+			summary.addScheme(cp.Value)
+		}
+		for _, encoded := range cp.PSSH {
+			box, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+			if err != nil {
+				return nil, fmt.Errorf("invalid mpd: cenc:pssh of %s: %v", cp.SchemeIDURI, err)
+			}
+			boxes, err := ParseBoxes(box)
+			if err != nil {
+				return nil, fmt.Errorf("cenc:pssh of %s: %w", cp.SchemeIDURI, err)
+			}
+			summary.PSSH = append(summary.PSSH, boxes...)
+		}
+		for _, encoded := range cp.PRO {
+			pro, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+			if err != nil {
+				return nil, fmt.Errorf("invalid mpd: mspr:pro: %v", err)
+			}
+			pr, err := ParsePlayReadyObject(pro)
+			if err != nil {
+				return nil, fmt.Errorf("mspr:pro: %w", err)
+			}
+			summary.PlayReady = append(summary.PlayReady, pr)
+		}
+	}
+	return summary, nil
+}
+
+// ParsePlaylist generate the protection summary of an HLS playlist from its EXT-X-KEY and EXT-X-SESSION-KEY tags:
+// the pssh boxes or PlayReady Objects of the data URIs, the KEYID and the scheme of the METHOD
+func ParsePlaylist(b []byte) (*ProtectionSummary, error) {
+	summary := &ProtectionSummary{}
+	scanner := bufio.NewScanner(bytes.NewReader(b))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		var attributes string
+		switch {
+		case strings.HasPrefix(line, "#EXT-X-KEY:"):
+			attributes = strings.TrimPrefix(line, "#EXT-X-KEY:")
+		case strings.HasPrefix(line, "#EXT-X-SESSION-KEY:"):
+			attributes = strings.TrimPrefix(line, "#EXT-X-SESSION-KEY:")
+		default:
+			continue
+		}
+
+		key := parseAttributeList(attributes)
+		switch key["METHOD"] {
+		case "SAMPLE-AES":
+			summary.addScheme("cbcs")
+		case "SAMPLE-AES-CTR":
+			summary.addScheme("cenc")
+		}
+		if keyID := key["KEYID"]; keyID != "" {
+			summary.addKeyID(strings.TrimPrefix(strings.TrimPrefix(keyID, "0x"), "0X"))
+		}
+		data, ok := base64DataURI(key["URI"])
+		switch {
+		case !ok:
+		case key["KEYFORMAT"] == "com.microsoft.playready":
+			pr, err := ParsePlayReadyObject(data)
+			if err != nil {
+				return nil, fmt.Errorf("URI of %s: %w", key["KEYFORMAT"], err)
+			}
+			summary.PlayReady = append(summary.PlayReady, pr)
+		case len(data) >= 8 && string(data[4:8]) == "pssh":
+			boxes, err := ParseBoxes(data)
+			if err != nil {
+				return nil, fmt.Errorf("URI of %s: %w", key["KEYFORMAT"], err)
+			}
+			summary.PSSH = append(summary.PSSH, boxes...)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+// base64DataURI decodes the data of a base64 data URI, e.g. the pssh boxes written by Shaka Packager for Widevine
+// or the PlayReady Object of a PlayReady key.
+func base64DataURI(uri string) ([]byte, bool) {
+	if !strings.HasPrefix(uri, "data:") {
+		return nil, false
+	}
+	i := strings.Index(uri, ",")
+	if i == -1 || !strings.HasSuffix(uri[:i], ";base64") {
+		return nil, false
+	}
+	b, err := base64.StdEncoding.DecodeString(uri[i+1:])
+	if err != nil {
+		return nil, false
+	}
+	return b, true
+}
+
+// parseAttributeList parses the attribute list of an HLS tag, NAME=VALUE pairs whose quoted values may hold commas.
+func parseAttributeList(s string) map[string]string {
+	attributes := map[string]string{}
+	for len(s) > 0 {
+		eq := strings.Index(s, "=")
+		if eq == -1 {
+			break
+		}
+		name := strings.TrimSpace(s[:eq])
+		s = s[eq+1:]
+
+		var value string
+		if strings.HasPrefix(s, `"`) {
+			end := strings.Index(s[1:], `"`)
+			if end == -1 {
+				value, s = s[1:], ""
+			} else {
+				value, s = s[1:end+1], s[end+2:]
+			}
+		} else if comma := strings.Index(s, ","); comma != -1 {
+			value, s = s[:comma], s[comma:]
+		} else {
+			value, s = s, ""
+		}
+		attributes[name] = value
+		s = strings.TrimPrefix(s, ",")
+	}
+	return attributes
+}
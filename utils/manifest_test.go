@@ -0,0 +1,88 @@
+package widevineutils
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+const testPSSH = "AAAAOHBzc2gAAAAA7e+LqXnWSs6jyCfc1R0h7QAAABgSEJPkt/Dij+qHMEpog1vObHFI49yVmwY="
+
+var testPRO = buildPlayReadyObject(`<WRMHEADER version="4.0.0.0"><DATA><KID>8Lfkk4/ih+owSmiDW85scQ==</KID></DATA></WRMHEADER>`)
+
+func TestParseMPD(t *testing.T) {
+	mpd := `<?xml version="1.0" encoding="UTF-8"?>
+<MPD xmlns="urn:mpeg:dash:schema:mpd:2011" xmlns:cenc="urn:mpeg:cenc:2013" type="static">
+  <Period>
+    <AdaptationSet contentType="video">
+      <ContentProtection schemeIdUri="urn:mpeg:dash:mp4protection:2011" value="cenc" cenc:default_KID="93e4b7f0-e28f-ea87-304a-68835bce6c71"/>
+      <ContentProtection schemeIdUri="urn:uuid:edef8ba9-79d6-4ace-a3c8-27dcd51d21ed">
+        <cenc:pssh>` + testPSSH + `</cenc:pssh>
+      </ContentProtection>
+      <ContentProtection schemeIdUri="urn:uuid:9a04f079-9840-4286-ab92-e65be0885f95">
+        <mspr:pro xmlns:mspr="urn:microsoft:playready">` + base64.StdEncoding.EncodeToString(testPRO) + `</mspr:pro>
+      </ContentProtection>
+      <Representation id="video" bandwidth="1000000"/>
+    </AdaptationSet>
+    <AdaptationSet contentType="audio">
+      <ContentProtection schemeIdUri="urn:mpeg:dash:mp4protection:2011" value="cenc" cenc:default_KID="93E4B7F0-E28F-EA87-304A-68835BCE6C71"/>
+    </AdaptationSet>
+  </Period>
+</MPD>`
+	summary, err := ParseMPD([]byte(mpd))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summary.PSSH) != 1 || summary.PSSH[0].DRMName != "widevine" {
+		t.Errorf("the cenc:pssh box must be parsed got %v", summary.PSSH)
+	}
+	if len(summary.DefaultKeyIDs) != 1 || summary.DefaultKeyIDs[0] != "93e4b7f0e28fea87304a68835bce6c71" {
+		t.Errorf("DefaultKeyIDs must be the cenc:default_KID got %v", summary.DefaultKeyIDs)
+	}
+	if len(summary.Schemes) != 1 || summary.Schemes[0] != "cenc" {
+		t.Errorf("Schemes must be cenc got %v", summary.Schemes)
+	}
+	if len(summary.PlayReady) != 1 || summary.PlayReady[0].KeyIDs[0] != summary.PSSH[0].KeyIDs[0] {
+		t.Errorf("the mspr:pro must carry the Widevine key id got %v", summary.PlayReady)
+	}
+
+	if _, err := ParseMPD([]byte(`<MPD><ContentProtection><cenc:pssh>AAAA</cenc:pssh></ContentProtection></MPD>`)); err == nil {
+		t.Error("an invalid cenc:pssh must fail")
+	}
+}
+
+func TestParsePlaylist(t *testing.T) {
+	playlist := `#EXTM3U
+#EXT-X-VERSION:6
+#EXT-X-SESSION-KEY:METHOD=SAMPLE-AES,URI="data:text/plain;base64,` + testPSSH + `",KEYID=0x93E4B7F0E28FEA87304A68835BCE6C71,KEYFORMAT="urn:uuid:edef8ba9-79d6-4ace-a3c8-27dcd51d21ed",KEYFORMATVERSIONS="1"
+#EXT-X-SESSION-KEY:METHOD=SAMPLE-AES,URI="data:text/plain;charset=UTF-16;base64,` + base64.StdEncoding.EncodeToString(testPRO) + `",KEYFORMAT="com.microsoft.playready",KEYFORMATVERSIONS="1"
+#EXT-X-KEY:METHOD=SAMPLE-AES,URI="skd://93e4b7f0e28fea87304a68835bce6c71",KEYFORMAT="com.apple.streamingkeydelivery",KEYFORMATVERSIONS="1"
+#EXT-X-STREAM-INF:BANDWIDTH=1000000,CODECS="avc1.64001f,mp4a.40.2"
+video.m3u8
+`
+	summary, err := ParsePlaylist([]byte(playlist))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summary.PSSH) != 1 || len(summary.PSSH[0].KeyIDs) != 1 {
+		t.Errorf("the pssh box of the data URI must be parsed got %v", summary.PSSH)
+	}
+	if len(summary.DefaultKeyIDs) != 1 || summary.DefaultKeyIDs[0] != "93e4b7f0e28fea87304a68835bce6c71" {
+		t.Errorf("DefaultKeyIDs must be the KEYID got %v", summary.DefaultKeyIDs)
+	}
+	if len(summary.Schemes) != 1 || summary.Schemes[0] != "cbcs" {
+		t.Errorf("Schemes must be cbcs got %v", summary.Schemes)
+	}
+	if len(summary.PlayReady) != 1 {
+		t.Errorf("the PlayReady Object of the data URI must be parsed got %v", summary.PlayReady)
+	}
+}
+
+func TestParseAttributeList(t *testing.T) {
+	attributes := parseAttributeList(`METHOD=SAMPLE-AES,URI="data:a,b",KEYFORMATVERSIONS="1/2"`)
+	expected := map[string]string{"METHOD": "SAMPLE-AES", "URI": "data:a,b", "KEYFORMATVERSIONS": "1/2"}
+	for name, value := range expected {
+		if attributes[name] != value {
+			t.Errorf("%s must be %q got %q", name, value, attributes[name])
+		}
+	}
+}
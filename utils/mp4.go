@@ -0,0 +1,123 @@
+package widevineutils
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrInvalidMP4 is returned when the box tree of an MP4 file is truncated or malformed.
+var ErrInvalidMP4 = errors.New("invalid mp4")
+
+// ProtectionSummary is the protection of a content, as read from an init segment or a manifest
+type ProtectionSummary struct {
+	// pssh boxes of the content
+	PSSH []*PSSHSummary
+
+	// Default key ids of the tracks, hex encoded
+	DefaultKeyIDs []string
+
+	// Protection schemes of the tracks, e.g. cenc or cbcs
+	Schemes []string
+
+	// PlayReady Objects given outside of a pssh box, by the mspr:pro element of an MPD or an HLS key
+	PlayReady []*PlayReadyHeader
+}
+
+func (s *ProtectionSummary) addKeyID(keyID string) {
+	keyID = strings.ToLower(strings.Replace(keyID, "-", "", -1))
+	for _, k := range s.DefaultKeyIDs {
+		if k == keyID {
+			return
+		}
+	}
+	s.DefaultKeyIDs = append(s.DefaultKeyIDs, keyID)
+}
+
+func (s *ProtectionSummary) addScheme(scheme string) {
+	for _, sc := range s.Schemes {
+		if sc == scheme {
+			return
+		}
+	}
+	s.Schemes = append(s.Schemes, scheme)
+}
+
+// containerBoxes are the boxes walked into to find the protection boxes, with the size of the fields
+// preceding their children.
+var containerBoxes = map[string]int{
+	"moov": 0, "trak": 0, "mdia": 0, "minf": 0, "stbl": 0, "moof": 0, "traf": 0, "sinf": 0, "schi": 0,
+	"stsd": 8,  // Full box header and entry count.
+	"encv": 78, // Visual sample entry.
+	"enca": 28, // Audio sample entry.
+}
+
+// ParseInitSegment generate the protection summary of an fMP4 init segment (or media segment):
+// the pssh boxes of moov and moof, the default_KID of the tenc boxes and the scheme type of the schm boxes
+func ParseInitSegment(b []byte) (*ProtectionSummary, error) {
+	summary := &ProtectionSummary{}
+	if err := walkBoxes(b, summary); err != nil {
+		return nil, err
+	}
+	return summary, nil
+}
+
+func walkBoxes(b []byte, summary *ProtectionSummary) error {
+	for len(b) > 0 {
+		if len(b) < 8 {
+			return fmt.Errorf("%w: %d trailing bytes", ErrInvalidMP4, len(b))
+		}
+		size := uint64(binary.BigEndian.Uint32(b))
+		boxType := string(b[4:8])
+		header := 8
+		switch size {
+		case 0:
+			size = uint64(len(b))
+		case 1:
+			if len(b) < 16 {
+				return fmt.Errorf("%w: truncated %s box", ErrInvalidMP4, boxType)
+			}
+			size = binary.BigEndian.Uint64(b[8:])
+			header = 16
+		}
+		if size < uint64(header) || size > uint64(len(b)) {
+			return fmt.Errorf("%w: %s box size %d out of the %d bytes available", ErrInvalidMP4, boxType, size, len(b))
+		}
+		box, payload := b[:size], b[header:size]
+		b = b[size:]
+
+		if skip, ok := containerBoxes[boxType]; ok {
+			if len(payload) < skip {
+				return fmt.Errorf("%w: truncated %s box", ErrInvalidMP4, boxType)
+			}
+			if err := walkBoxes(payload[skip:], summary); err != nil {
+				return err
+			}
+			continue
+		}
+
+		switch boxType {
+		case "pssh":
+			pssh, _, err := parseBox(box)
+			if err != nil {
+				return err
+			}
+			summary.PSSH = append(summary.PSSH, pssh)
+		case "tenc":
+			// Full box header, 2 reserved or pattern bytes, isProtected, per sample IV size, then the default KID.
+			if len(payload) < 24 {
+				return fmt.Errorf("%w: truncated tenc box", ErrInvalidMP4)
+			}
+			summary.addKeyID(hex.EncodeToString(payload[8:24]))
+		case "schm":
+			// Full box header, then the scheme type.
+			if len(payload) < 8 {
+				return fmt.Errorf("%w: truncated schm box", ErrInvalidMP4)
+			}
+			summary.addScheme(string(payload[4:8]))
+		}
+	}
+	return nil
+}
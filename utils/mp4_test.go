@@ -0,0 +1,57 @@
+package widevineutils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+func box(boxType string, payload ...[]byte) []byte {
+	b := make([]byte, 8)
+	copy(b[4:], boxType)
+	for _, p := range payload {
+		b = append(b, p...)
+	}
+	binary.BigEndian.PutUint32(b, uint32(len(b)))
+	return b
+}
+
+func testInitSegment(t *testing.T) []byte {
+	keyID := bytes.Repeat([]byte{0x11}, 16)
+	pssh, err := (&PSSHBuilder{KeyIDs: [][]byte{keyID}, ContentID: []byte("movie-1")}).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tenc := box("tenc", []byte{0, 0, 0, 0, 0, 0, 1, 8}, keyID)
+	sinf := box("sinf", box("frma", []byte("avc1")), box("schm", []byte{0, 0, 0, 0}, []byte("cenc"), []byte{0, 1, 0, 0}), box("schi", tenc))
+	encv := box("encv", make([]byte, 78), box("avcC", []byte{1, 2, 3}), sinf)
+	stsd := box("stsd", []byte{0, 0, 0, 0, 0, 0, 0, 1}, encv)
+	trak := box("trak", box("tkhd", make([]byte, 84)), box("mdia", box("minf", box("stbl", stsd))))
+	return append(box("ftyp", []byte("iso6")), box("moov", box("mvhd", make([]byte, 100)), trak, pssh)...)
+}
+
+func TestParseInitSegment(t *testing.T) {
+	summary, err := ParseInitSegment(testInitSegment(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summary.PSSH) != 1 || summary.PSSH[0].Widevine == nil || string(summary.PSSH[0].Widevine.ContentId) != "movie-1" {
+		t.Errorf("the Widevine pssh box must be found got %v", summary.PSSH)
+	}
+	if len(summary.DefaultKeyIDs) != 1 || summary.DefaultKeyIDs[0] != "11111111111111111111111111111111" {
+		t.Errorf("DefaultKeyIDs must be the tenc default_KID got %v", summary.DefaultKeyIDs)
+	}
+	if len(summary.Schemes) != 1 || summary.Schemes[0] != "cenc" {
+		t.Errorf("Schemes must be cenc got %v", summary.Schemes)
+	}
+}
+
+func TestParseInitSegmentInvalid(t *testing.T) {
+	segment := testInitSegment(t)
+	for _, b := range [][]byte{segment[:len(segment)-3], append(segment, 0, 0)} {
+		if _, err := ParseInitSegment(b); !errors.Is(err, ErrInvalidMP4) {
+			t.Errorf("ParseInitSegment must fail with ErrInvalidMP4 got %v", err)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package widevineutils
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf16"
+)
+
+const PLAYREADY_SYSTEM_ID = "9a04f07998404286ab92e65be0885f95"
+
+// PlayReady Object record types
+const (
+	PlayReadyRecordRightsManagementHeader = 1
+	PlayReadyRecordLicenseStore           = 3
+)
+
+// ErrInvalidPlayReadyObject is returned when a PlayReady Object or its WRMHEADER is malformed.
+var ErrInvalidPlayReadyObject = errors.New("invalid playready object")
+
+// PlayReadyRecord is a record of a PlayReady Object
+type PlayReadyRecord struct {
+	Type  uint16
+	Value []byte
+}
+
+// PlayReadyHeader is the PlayReady Object (PRO) carried by a PlayReady pssh box
+type PlayReadyHeader struct {
+	Records []PlayReadyRecord
+
+	// WRMHEADER XML, decoded from UTF-16
+	WRMHeader string
+	Version   string
+
+	// KeyIDs in the byte order of the other DRM systems, hex encoded
+	KeyIDs []string
+
+	// Algorithm of the keys, e.g. AESCTR or AESCBC
+	Algorithm string
+
+	LAURL  string
+	LUIURL string
+}
+
+type wrmHeader struct {
+	Version string `xml:"version,attr"`
+	Data    struct {
+		// Version 4.0.0.0
+		KID     string `xml:"KID"`
+		Protect struct {
+			// Version 4.0.0.0
+			AlgID string `xml:"ALGID"`
+			// Version 4.1.0.0
+			KID []wrmKID `xml:"KID"`
+			// Version 4.2.0.0 and 4.3.0.0
+			KIDs []wrmKID `xml:"KIDS>KID"`
+		} `xml:"PROTECTINFO"`
+		LAURL  string `xml:"LA_URL"`
+		LUIURL string `xml:"LUI_URL"`
+	} `xml:"DATA"`
+}
+
+type wrmKID struct {
+	Value string `xml:"VALUE,attr"`
+	AlgID string `xml:"ALGID,attr"`
+}
+
+// ParsePlayReadyObject parses a PlayReady Object and the WRMHEADER of its rights management record
+func ParsePlayReadyObject(b []byte) (*PlayReadyHeader, error) {
+	if len(b) < 6 {
+		return nil, fmt.Errorf("%w: %d bytes is too short", ErrInvalidPlayReadyObject, len(b))
+	}
+	if size := binary.LittleEndian.Uint32(b); int64(size) != int64(len(b)) {
+		return nil, fmt.Errorf("%w: length %d does not match the %d bytes", ErrInvalidPlayReadyObject, size, len(b))
+	}
+	count := int(binary.LittleEndian.Uint16(b[4:]))
+	r := b[6:]
+
+	header := &PlayReadyHeader{}
+	for i := 0; i < count; i++ {
+		if len(r) < 4 {
+			return nil, fmt.Errorf("%w: truncated record %d", ErrInvalidPlayReadyObject, i+1)
+		}
+		recordType := binary.LittleEndian.Uint16(r)
+		length := int(binary.LittleEndian.Uint16(r[2:]))
+		if length > len(r)-4 {
+			return nil, fmt.Errorf("%w: record %d length %d exceeds the object", ErrInvalidPlayReadyObject, i+1, length)
+		}
+		header.Records = append(header.Records, PlayReadyRecord{Type: recordType, Value: r[4 : 4+length]})
+		r = r[4+length:]
+	}
+	if len(r) != 0 {
+		return nil, fmt.Errorf("%w: %d bytes after the records", ErrInvalidPlayReadyObject, len(r))
+	}
+
+	for _, record := range header.Records {
+		if record.Type != PlayReadyRecordRightsManagementHeader {
+			continue
+		}
+		if err := header.parseWRMHeader(record.Value); err != nil {
+			return nil, err
+		}
+		break
+	}
+	return header, nil
+}
+
+func (h *PlayReadyHeader) parseWRMHeader(b []byte) error {
+	if len(b)%2 != 0 {
+		return fmt.Errorf("%w: odd WRMHEADER length %d", ErrInvalidPlayReadyObject, len(b))
+	}
+	u := make([]uint16, len(b)/2)
+	for i := range u {
+		u[i] = binary.LittleEndian.Uint16(b[2*i:])
+	}
+	h.WRMHeader = strings.TrimPrefix(string(utf16.Decode(u)), "\ufeff")
+
+	var wrm wrmHeader
+	decoder := xml.NewDecoder(strings.NewReader(h.WRMHeader))
+	// The XML is already decoded from UTF-16, whatever its declaration says.
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) { return input, nil }
+	if err := decoder.Decode(&wrm); err != nil {
+		return fmt.Errorf("%w: WRMHEADER: %v", ErrInvalidPlayReadyObject, err)
+	}
+	h.Version = wrm.Version
+	h.LAURL = strings.TrimSpace(wrm.Data.LAURL)
+	h.LUIURL = strings.TrimSpace(wrm.Data.LUIURL)
+	h.Algorithm = strings.TrimSpace(wrm.Data.Protect.AlgID)
+
+	kids := append(wrm.Data.Protect.KID, wrm.Data.Protect.KIDs...)
+	if kid := strings.TrimSpace(wrm.Data.KID); kid != "" {
+		kids = append(kids, wrmKID{Value: kid})
+	}
+	for _, kid := range kids {
+		keyID, err := PlayReadyKeyID(kid.Value)
+		if err != nil {
+			return err
+		}
+		h.KeyIDs = append(h.KeyIDs, keyID)
+		if h.Algorithm == "" {
+			h.Algorithm = kid.AlgID
+		}
+	}
+	return nil
+}
+
+// PlayReadyKeyID converts the base64 KID of a WRMHEADER, a little-endian GUID, to a hex key id in the byte order of the other DRM systems
+func PlayReadyKeyID(kid string) (string, error) {
+	b, err := base64.StdEncoding.DecodeString(kid)
+	if err != nil || len(b) != 16 {
+		return "", fmt.Errorf("%w: KID %q is not a base64 GUID", ErrInvalidPlayReadyObject, kid)
+	}
+	return hex.EncodeToString(swapGUID(b)), nil
+}
+
+// swapGUID switches the first three fields of a GUID between little and big endian.
+func swapGUID(b []byte) []byte {
+	s := append([]byte{}, b...)
+	s[0], s[1], s[2], s[3] = b[3], b[2], b[1], b[0]
+	s[4], s[5] = b[5], b[4]
+	s[6], s[7] = b[7], b[6]
+	return s
+}
@@ -0,0 +1,117 @@
+package widevineutils
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+// buildPlayReadyObject wraps a WRMHEADER into a PlayReady Object, the data of a PlayReady pssh box
+func buildPlayReadyObject(wrmHeader string) []byte {
+	u := utf16.Encode([]rune(wrmHeader))
+	record := make([]byte, 2*len(u))
+	for i, c := range u {
+		binary.LittleEndian.PutUint16(record[2*i:], c)
+	}
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, uint32(6+4+len(record)))
+	binary.Write(&b, binary.LittleEndian, uint16(1))
+	binary.Write(&b, binary.LittleEndian, uint16(PlayReadyRecordRightsManagementHeader))
+	binary.Write(&b, binary.LittleEndian, uint16(len(record)))
+	b.Write(record)
+	return b.Bytes()
+}
+
+// playReadyWRMHeader returns a version 4.3.0.0 WRMHEADER for key ids in the byte order of the other DRM systems
+func playReadyWRMHeader(keyIDs [][]byte, laURL string) string {
+	var kids strings.Builder
+	for _, keyID := range keyIDs {
+		fmt.Fprintf(&kids, `<KID ALGID="AESCTR" VALUE="%s"></KID>`, base64.StdEncoding.EncodeToString(swapGUID(keyID)))
+	}
+	var la strings.Builder
+	if laURL != "" {
+		la.WriteString("<LA_URL>")
+		xml.EscapeText(&la, []byte(laURL))
+		la.WriteString("</LA_URL>")
+	}
+	return `<WRMHEADER xmlns="http://schemas.microsoft.com/DRM/2007/03/PlayReadyHeader" version="4.3.0.0"><DATA><PROTECTINFO><KIDS>` +
+		kids.String() + `</KIDS></PROTECTINFO>` + la.String() + `</DATA></WRMHEADER>`
+}
+
+func TestPlayReadyKeyID(t *testing.T) {
+	keyID, err := PlayReadyKeyID("8Lfkk4/ih+owSmiDW85scQ==")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if keyID != "93e4b7f0e28fea87304a68835bce6c71" {
+		t.Errorf("KID must be byte swapped to 93e4b7f0e28fea87304a68835bce6c71 got %s", keyID)
+	}
+}
+
+func TestParsePlayReadyObject(t *testing.T) {
+	keyIDs := [][]byte{mustDecodeHex("93e4b7f0e28fea87304a68835bce6c71"), mustDecodeHex("22222222222222222222222222222222")}
+	headers := map[string]string{
+		"4.3.0.0": playReadyWRMHeader(keyIDs, "https://playready.example.com/rightsmanager.asmx?a=1&b=2"),
+		"4.1.0.0": `<?xml version="1.0" encoding="utf-16"?><WRMHEADER xmlns="http://schemas.microsoft.com/DRM/2007/03/PlayReadyHeader" version="4.1.0.0">` +
+			`<DATA><PROTECTINFO><KID ALGID="AESCTR" VALUE="8Lfkk4/ih+owSmiDW85scQ=="></KID></PROTECTINFO>` +
+			`<LA_URL>https://playready.example.com/rightsmanager.asmx?a=1&amp;b=2</LA_URL></DATA></WRMHEADER>`,
+		"4.0.0.0": `<WRMHEADER xmlns="http://schemas.microsoft.com/DRM/2007/03/PlayReadyHeader" version="4.0.0.0">` +
+			`<DATA><PROTECTINFO><KEYLEN>16</KEYLEN><ALGID>AESCTR</ALGID></PROTECTINFO><KID>8Lfkk4/ih+owSmiDW85scQ==</KID>` +
+			`<LA_URL>https://playready.example.com/rightsmanager.asmx?a=1&amp;b=2</LA_URL></DATA></WRMHEADER>`,
+	}
+	for version, wrmHeader := range headers {
+		header, err := ParsePlayReadyObject(buildPlayReadyObject(wrmHeader))
+		if err != nil {
+			t.Errorf("%s: %v", version, err)
+			continue
+		}
+		if header.Version != version {
+			t.Errorf("Version must be %s got %s", version, header.Version)
+		}
+		if header.WRMHeader != wrmHeader {
+			t.Errorf("%s: WRMHeader must be decoded from UTF-16 got %q", version, header.WRMHeader)
+		}
+		if len(header.KeyIDs) == 0 || header.KeyIDs[0] != "93e4b7f0e28fea87304a68835bce6c71" {
+			t.Errorf("%s: KeyIDs must start with 93e4b7f0e28fea87304a68835bce6c71 got %v", version, header.KeyIDs)
+		}
+		if header.Algorithm != "AESCTR" {
+			t.Errorf("%s: Algorithm must be AESCTR got %s", version, header.Algorithm)
+		}
+		if header.LAURL != "https://playready.example.com/rightsmanager.asmx?a=1&b=2" {
+			t.Errorf("%s: unexpected LA_URL %s", version, header.LAURL)
+		}
+	}
+}
+
+func TestParsePlayReadyObjectInvalid(t *testing.T) {
+	pro := buildPlayReadyObject(`<WRMHEADER version="4.0.0.0"><DATA><KID>8Lfkk4/ih+owSmiDW85scQ==</KID></DATA></WRMHEADER>`)
+	badLength := append([]byte{}, pro...)
+	binary.LittleEndian.PutUint16(badLength[8:], 0xffff)
+	objects := map[string][]byte{
+		"short":         pro[:4],
+		"size":          pro[:len(pro)-2],
+		"record length": badLength,
+		"bad xml":       buildPlayReadyObject(`<WRMHEADER>`),
+		"bad kid":       buildPlayReadyObject(`<WRMHEADER version="4.0.0.0"><DATA><KID>AAAA</KID></DATA></WRMHEADER>`),
+	}
+	for name, object := range objects {
+		if _, err := ParsePlayReadyObject(object); !errors.Is(err, ErrInvalidPlayReadyObject) {
+			t.Errorf("%s: ParsePlayReadyObject must fail with ErrInvalidPlayReadyObject got %v", name, err)
+		}
+	}
+}
+
+func mustDecodeHex(s string) []byte {
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
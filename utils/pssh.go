@@ -1,11 +1,15 @@
 package widevineutils
 
 import (
+	"encoding/binary"
 	"encoding/hex"
+	"errors"
 	"fmt"
-	"log"
-	"strconv"
+	"io"
+	"os"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 
 	pb "github.com/cooomma/widevine-proxy/proto"
 	proto "github.com/golang/protobuf/proto"
@@ -13,9 +17,15 @@ import (
 
 const WIDEVINE_SYSTEM_ID = "edef8ba979d64acea3c827dcd51d21ed"
 
+// ErrInvalidPSSH is returned when a pssh box is truncated or malformed.
+var ErrInvalidPSSH = errors.New("invalid pssh box")
+
+// ErrKeyIDMismatch is returned when the pssh boxes of a content carry different key ids.
+var ErrKeyIDMismatch = errors.New("key ids of the DRM systems do not match")
+
 // PSSH represents mp4 FullBox
 type PSSH struct {
-	HexBin  string
+	Raw     []byte
 	Summary *PSSHSummary
 }
 
@@ -44,80 +54,277 @@ type PSSHSummary struct {
 	DataHex string
 	DataRaw []byte
 
-	// PSSH KeyIDs
+	// PSSH KeyIDs, from the box header of a version 1 box, else from the Widevine data
 	KeyIDs []string
+
+	// Widevine data, nil for the other DRM systems
+	Widevine *pb.WidevineCencHeader
+
+	// PlayReady Object, nil for the other DRM systems
+	PlayReady *PlayReadyHeader
 }
 
 // NewPSSH generate PSSH
 func NewPSSH(p []byte) *PSSH {
-	return &PSSH{HexBin: hex.EncodeToString(p)}
+	return &PSSH{Raw: p}
 }
 
-// Parse generate PSSH summary
-func (p *PSSH) Parse() {
-	sizeHex := p.HexBin[0:8]
-	psshType := p.HexBin[8:16]
-	sizeDecimal, err := strconv.ParseInt(sizeHex, 16, 64)
+// Parse generate PSSH summary of a single box, version 0 or 1
+func (p *PSSH) Parse() (*PSSHSummary, error) {
+	summary, n, err := parseBox(p.Raw)
 	if err != nil {
-		log.Println("hex int cast error")
+		return nil, err
 	}
-	version := p.HexBin[16:18]
-	flag := p.HexBin[18:24]
-	drmSystem := p.HexBin[24:56]
-	valid, drmName := p.validateSystemID(drmSystem)
-	if !valid {
-		log.Printf("Unsupported DRM %s\n", drmSystem)
+	if n != len(p.Raw) {
+		return nil, fmt.Errorf("%w: %d bytes after the box, use ParseBoxes for several boxes", ErrInvalidPSSH, len(p.Raw)-n)
 	}
-	dataSize, err := strconv.ParseInt(p.HexBin[56:64], 16, 64)
-	if err != nil {
-		log.Println("hex int cast error")
+	p.Summary = summary
+	return summary, nil
+}
+
+// ParseBoxes generate the PSSH summaries of concatenated boxes, e.g. the CENC init data of a license request
+func ParseBoxes(b []byte) ([]*PSSHSummary, error) {
+	var summaries []*PSSHSummary
+	for len(b) > 0 {
+		summary, n, err := parseBox(b)
+		if err != nil {
+			return nil, fmt.Errorf("box %d: %w", len(summaries)+1, err)
+		}
+		summaries = append(summaries, summary)
+		b = b[n:]
+	}
+	if len(summaries) == 0 {
+		return nil, fmt.Errorf("%w: empty", ErrInvalidPSSH)
+	}
+	return summaries, nil
+}
+
+// parseBox parses the box at the start of b and returns its size
+func parseBox(b []byte) (*PSSHSummary, int, error) {
+	if len(b) < 8 {
+		return nil, 0, fmt.Errorf("%w: %d bytes is too short for a box header", ErrInvalidPSSH, len(b))
+	}
+	size := uint64(binary.BigEndian.Uint32(b))
+	header := 8
+	switch size {
+	case 0: // The box extends to the end of the input.
+		size = uint64(len(b))
+	case 1: // 64-bit size.
+		if len(b) < 16 {
+			return nil, 0, fmt.Errorf("%w: truncated large size", ErrInvalidPSSH)
+		}
+		size = binary.BigEndian.Uint64(b[8:])
+		header = 16
+	}
+	if size < uint64(header) || size > uint64(len(b)) {
+		return nil, 0, fmt.Errorf("%w: size %d out of the %d bytes available", ErrInvalidPSSH, size, len(b))
+	}
+	if string(b[4:8]) != "pssh" {
+		return nil, 0, fmt.Errorf("%w: type %q is not pssh", ErrInvalidPSSH, b[4:8])
+	}
+	box := b[:size]
+	r := box[header:]
+	if len(r) < 4+16+4 {
+		return nil, 0, fmt.Errorf("%w: size %d is too short", ErrInvalidPSSH, size)
 	}
 
-	dataHex := p.HexBin[64:]
+	version := r[0]
+	summary := &PSSHSummary{
+		SizeHex:     hex.EncodeToString(b[:4]),
+		SizeDecimal: int64(size),
+		Type:        hex.EncodeToString(b[4:8]),
+		Version:     hex.EncodeToString(r[:1]),
+		Flag:        hex.EncodeToString(r[1:4]),
+		DRMSystemID: hex.EncodeToString(r[4:20]),
+	}
+	_, summary.DRMName = validateSystemID(summary.DRMSystemID)
+	r = r[20:]
+
+	switch version {
+	case 0:
+	case 1:
+		kidCount := uint64(binary.BigEndian.Uint32(r))
+		r = r[4:]
+		if kidCount*16+4 > uint64(len(r)) {
+			return nil, 0, fmt.Errorf("%w: %d key ids exceed the box", ErrInvalidPSSH, kidCount)
+		}
+		for i := uint64(0); i < kidCount; i++ {
+			summary.KeyIDs = append(summary.KeyIDs, hex.EncodeToString(r[:16]))
+			r = r[16:]
+		}
+	default:
+		return nil, 0, fmt.Errorf("%w: unsupported version %d", ErrInvalidPSSH, version)
+	}
+
+	if len(r) < 4 {
+		return nil, 0, fmt.Errorf("%w: missing data size", ErrInvalidPSSH)
+	}
+	dataSize := binary.BigEndian.Uint32(r)
+	r = r[4:]
+	if uint64(dataSize) != uint64(len(r)) {
+		return nil, 0, fmt.Errorf("%w: data size %d does not match the %d bytes left in the box", ErrInvalidPSSH, dataSize, len(r))
+	}
+	summary.DataSize = int64(dataSize)
+	summary.DataRaw = r
+	summary.DataHex = hex.EncodeToString(r)
+
+	if summary.DRMSystemID == WIDEVINE_SYSTEM_ID {
+		wv := &pb.WidevineCencHeader{}
+		if err := proto.Unmarshal(r, wv); err != nil {
+			return nil, 0, fmt.Errorf("%w: widevine data: %v", ErrInvalidPSSH, err)
+		}
+		summary.Widevine = wv
+		if version == 0 {
+			for _, keyID := range wv.GetKeyId() {
+				summary.KeyIDs = append(summary.KeyIDs, hex.EncodeToString(keyID))
+			}
+		}
+	}
+	if summary.DRMSystemID == PLAYREADY_SYSTEM_ID {
+		pr, err := ParsePlayReadyObject(r)
+		if err != nil {
+			return nil, 0, fmt.Errorf("%w: %v", ErrInvalidPSSH, err)
+		}
+		summary.PlayReady = pr
+		if version == 0 {
+			summary.KeyIDs = append(summary.KeyIDs, pr.KeyIDs...)
+		}
+	}
+	return summary, int(size), nil
+}
 
-	p.Summary = &PSSHSummary{
-		Type:        psshType,
-		SizeHex:     sizeHex,
-		SizeDecimal: sizeDecimal,
-		Version:     version,
-		Flag:        flag,
-		DRMSystemID: drmSystem,
-		DRMName:     drmName,
-		DataSize:    dataSize,
-		DataHex:     dataHex,
-		KeyIDs:      parsePSSHKeyIDs(dataHex),
+// MatchKeyIDs checks that the pssh boxes of the DRM systems of a content, e.g. Widevine and PlayReady, carry the same key ids.
+// Boxes without key ids are ignored.
+func MatchKeyIDs(summaries []*PSSHSummary) error {
+	var reference *PSSHSummary
+	for _, summary := range summaries {
+		if len(summary.KeyIDs) == 0 {
+			continue
+		}
+		if reference == nil {
+			reference = summary
+			continue
+		}
+		if !sameKeyIDs(reference.KeyIDs, summary.KeyIDs) {
+			return fmt.Errorf("%w: %s %v, %s %v", ErrKeyIDMismatch, reference.DRMName, reference.KeyIDs, summary.DRMName, summary.KeyIDs)
+		}
 	}
+	return nil
+}
 
+func sameKeyIDs(a, b []string) bool {
+	set := map[string]bool{}
+	for _, k := range a {
+		set[k] = true
+	}
+	for _, k := range b {
+		if !set[k] {
+			return false
+		}
+		delete(set, k)
+	}
+	return len(set) == 0
 }
 
 // Print display PSSH summary
 func (p *PSSH) Print() {
-	fmt.Println("[PSSH Summary]")
-	fmt.Println("Size          ", p.Summary.SizeHex)
-	fmt.Println("Size(decimal) ", p.Summary.SizeDecimal)
-	fmt.Println("Type          ", p.Summary.Type)
-	fmt.Println("Version       ", p.Summary.Version)
-	fmt.Println("Flag          ", p.Summary.Flag)
-	fmt.Println("DRM           ", p.Summary.DRMSystemID)
-	fmt.Println("DRM Name      ", p.Summary.DRMName)
-	fmt.Println("DataSize      ", p.Summary.DataSize)
-	fmt.Println("Data          ", p.Summary.DataHex)
-	fmt.Println("KeyIDs        ", p.Summary.KeyIDs)
-
-	// TODO: support PlayReady parse
-	switch strings.ToLower(p.Summary.DRMSystemID) {
-	case WIDEVINE_SYSTEM_ID:
-		// parse
-		wv := &pb.WidevineCencHeader{}
-		if err := proto.Unmarshal(p.Summary.DataRaw, wv); err != nil {
-			log.Print("could not unmarshal Widevine proto")
+	p.Fprint(os.Stdout)
+}
+
+// Fprint writes the PSSH summary to w
+func (p *PSSH) Fprint(w io.Writer) {
+	fmt.Fprintln(w, "[PSSH Summary]")
+	fmt.Fprintln(w, "Size          ", p.Summary.SizeHex)
+	fmt.Fprintln(w, "Size(decimal) ", p.Summary.SizeDecimal)
+	fmt.Fprintln(w, "Type          ", p.Summary.Type)
+	fmt.Fprintln(w, "Version       ", p.Summary.Version)
+	fmt.Fprintln(w, "Flag          ", p.Summary.Flag)
+	fmt.Fprintln(w, "DRM           ", p.Summary.DRMSystemID)
+	fmt.Fprintln(w, "DRM Name      ", p.Summary.DRMName)
+	fmt.Fprintln(w, "DataSize      ", p.Summary.DataSize)
+	fmt.Fprintln(w, "Data          ", p.Summary.DataHex)
+	fmt.Fprintln(w, "KeyIDs        ", p.Summary.KeyIDs)
+
+	if p.Summary.Widevine != nil {
+		wv := NewWidevineInfo(p.Summary.Widevine)
+		fmt.Fprintln(w, "Algorithm     ", wv.Algorithm)
+		fmt.Fprintln(w, "Provider      ", wv.Provider)
+		fmt.Fprintln(w, "ContentID     ", wv.ContentID)
+		fmt.Fprintln(w, "ContentID(hex)", wv.ContentIDHex)
+		fmt.Fprintln(w, "Policy        ", wv.Policy)
+		fmt.Fprintln(w, "Protection    ", wv.ProtectionScheme)
+		if wv.CryptoPeriodIndex != nil {
+			fmt.Fprintln(w, "CryptoPeriod  ", *wv.CryptoPeriodIndex)
+		}
+		if wv.CryptoPeriodSeconds != nil {
+			fmt.Fprintln(w, "CryptoSeconds ", *wv.CryptoPeriodSeconds)
 		}
-		fmt.Println("Parsed Data  ", wv)
 	}
+	if pr := p.Summary.PlayReady; pr != nil {
+		fmt.Fprintln(w, "WRMHEADER     ", pr.Version)
+		fmt.Fprintln(w, "Algorithm     ", pr.Algorithm)
+		fmt.Fprintln(w, "LA_URL        ", pr.LAURL)
+		fmt.Fprintln(w, "Parsed Data  ", pr.WRMHeader)
+	}
+}
+
+// WidevineInfo is the readable form of the Widevine data of a pssh box
+type WidevineInfo struct {
+	Algorithm           string   `json:"algorithm,omitempty"`
+	KeyIDs              []string `json:"key_ids,omitempty"`
+	Provider            string   `json:"provider,omitempty"`
+	ContentID           string   `json:"content_id,omitempty"` // Empty if not printable
+	ContentIDHex        string   `json:"content_id_hex,omitempty"`
+	Policy              string   `json:"policy,omitempty"`
+	ProtectionScheme    string   `json:"protection_scheme,omitempty"`
+	CryptoPeriodIndex   *uint32  `json:"crypto_period_index,omitempty"`
+	CryptoPeriodSeconds *uint32  `json:"crypto_period_seconds,omitempty"`
+}
+
+// NewWidevineInfo decodes the fields of the Widevine data of a pssh box
+func NewWidevineInfo(h *pb.WidevineCencHeader) *WidevineInfo {
+	info := &WidevineInfo{
+		Provider:            h.GetProvider(),
+		ContentIDHex:        hex.EncodeToString(h.GetContentId()),
+		Policy:              h.GetPolicy(),
+		CryptoPeriodIndex:   h.CryptoPeriodIndex,
+		CryptoPeriodSeconds: h.CryptoPeriodSeconds,
+	}
+	if h.Algorithm != nil {
+		info.Algorithm = h.GetAlgorithm().String()
+	}
+	for _, keyID := range h.GetKeyId() {
+		info.KeyIDs = append(info.KeyIDs, hex.EncodeToString(keyID))
+	}
+	if printable(h.GetContentId()) {
+		info.ContentID = string(h.GetContentId())
+	}
+	if h.ProtectionScheme != nil {
+		scheme := make([]byte, 4)
+		binary.BigEndian.PutUint32(scheme, h.GetProtectionScheme())
+		if printable(scheme) {
+			info.ProtectionScheme = string(scheme)
+		} else {
+			info.ProtectionScheme = fmt.Sprintf("0x%08x", h.GetProtectionScheme())
+		}
+	}
+	return info
+}
+
+func printable(b []byte) bool {
+	if !utf8.Valid(b) {
+		return false
+	}
+	for _, r := range string(b) {
+		if !unicode.IsPrint(r) {
+			return false
+		}
+	}
+	return true
 }
 
 // cf. https://dashif.org/identifiers/content_protection/
-func (p *PSSH) validateSystemID(s string) (bool, string) {
+func validateSystemID(s string) (bool, string) {
 	// Remove hyphen
 	sArr := strings.Split(s, "-")
 	s = strings.Join(sArr, "")
@@ -165,16 +372,3 @@ func paddingNumber(n string) string {
 	b := "00" + n
 	return b[len(b)-2:]
 }
-
-func parsePSSHKeyIDs(psshData string) []string {
-	var keyIDs []string
-	for {
-		sp := strings.Index(psshData, "1210")
-		if sp == -1 {
-			break
-		}
-		keyIDs = append(keyIDs, psshData[sp+4:sp+32+4])
-		psshData = psshData[sp+32+4:]
-	}
-	return keyIDs
-}
@@ -2,6 +2,8 @@ package widevineutils
 
 import (
 	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"testing"
 )
 
@@ -21,9 +23,12 @@ func TestParse(t *testing.T) {
 	testDataBin := "AAAAOHBzc2gAAAAA7e+LqXnWSs6jyCfc1R0h7QAAABgSEJPkt/Dij+qHMEpog1vObHFI49yVmwY="
 	psshBox, _ := base64.StdEncoding.DecodeString(testDataBin)
 	pssh := NewPSSH(psshBox)
-	pssh.Parse()
-	if pssh.Summary == nil {
-		t.Error("pssh summary must not be nil")
+	summary, err := pssh.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pssh.Summary != summary {
+		t.Error("pssh summary must be set")
 	}
 	if pssh.Summary.SizeHex != "00000038" {
 		t.Errorf("Summary.SizeHex must be 00000038 got %s", pssh.Summary.SizeHex)
@@ -59,5 +64,87 @@ func TestParse(t *testing.T) {
 	if pssh.Summary.KeyIDs[0] != "93e4b7f0e28fea87304a68835bce6c71" {
 		t.Errorf("Summary.KeyIDs must be 93e4b7f0e28fea87304a68835bce6c71 got %s", pssh.Summary.KeyIDs[0])
 	}
+	if pssh.Summary.Widevine == nil || len(pssh.Summary.Widevine.KeyId) != 1 {
+		t.Errorf("Summary.Widevine must carry the key id got %v", pssh.Summary.Widevine)
+	}
+}
+
+func TestParseVersion1(t *testing.T) {
+	// A version 1 box listing 2 key ids in its header, with the Widevine data of the second one only.
+	box, _ := hex.DecodeString("000000567073736801000000edef8ba979d64acea3c827dcd51d21ed" +
+		"00000002" + "11111111111111111111111111111111" + "22222222222222222222222222222222" +
+		"00000012" + "121022222222222222222222222222222222")
+	summary, err := NewPSSH(box).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if summary.Version != "01" {
+		t.Errorf("Summary.Version must be 01 got %s", summary.Version)
+	}
+	if summary.DataSize != 18 {
+		t.Errorf("Summary.DataSize must be 18 got %d", summary.DataSize)
+	}
+	expected := []string{"11111111111111111111111111111111", "22222222222222222222222222222222"}
+	if len(summary.KeyIDs) != 2 || summary.KeyIDs[0] != expected[0] || summary.KeyIDs[1] != expected[1] {
+		t.Errorf("Summary.KeyIDs must be %v got %v", expected, summary.KeyIDs)
+	}
+}
+
+func TestParseBoxes(t *testing.T) {
+	widevine, _ := base64.StdEncoding.DecodeString("AAAAOHBzc2gAAAAA7e+LqXnWSs6jyCfc1R0h7QAAABgSEJPkt/Dij+qHMEpog1vObHFI49yVmwY=")
+	systemID, _ := hex.DecodeString(PLAYREADY_SYSTEM_ID)
+	wrmHeader := `<WRMHEADER version="4.0.0.0"><DATA><KID>8Lfkk4/ih+owSmiDW85scQ==</KID></DATA></WRMHEADER>`
+	playready, _ := BuildPSSHBox(0, systemID, nil, buildPlayReadyObject(wrmHeader))
+	summaries, err := ParseBoxes(append(append([]byte{}, playready...), widevine...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(summaries) != 2 {
+		t.Fatalf("2 boxes must be parsed got %d", len(summaries))
+	}
+	if summaries[0].DRMName != "playready" || summaries[0].Widevine != nil || summaries[0].PlayReady.WRMHeader != wrmHeader {
+		t.Errorf("unexpected PlayReady summary %+v", summaries[0])
+	}
+	if summaries[1].DRMName != "widevine" || len(summaries[1].KeyIDs) != 1 {
+		t.Errorf("unexpected Widevine summary %+v", summaries[1])
+	}
 
+	if _, err := NewPSSH(append(widevine, playready...)).Parse(); err == nil {
+		t.Error("Parse must reject several boxes")
+	}
+	if err := MatchKeyIDs(summaries); err != nil {
+		t.Errorf("the Widevine and PlayReady key ids must match: %v", err)
+	}
+	summaries[0].KeyIDs = []string{"11111111111111111111111111111111"}
+	if err := MatchKeyIDs(summaries); !errors.Is(err, ErrKeyIDMismatch) {
+		t.Errorf("MatchKeyIDs must fail with ErrKeyIDMismatch got %v", err)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	valid, _ := base64.StdEncoding.DecodeString("AAAAOHBzc2gAAAAA7e+LqXnWSs6jyCfc1R0h7QAAABgSEJPkt/Dij+qHMEpog1vObHFI49yVmwY=")
+	corrupt := func(i int, b byte) []byte {
+		box := append([]byte{}, valid...)
+		box[i] = b
+		return box
+	}
+	boxes := map[string][]byte{
+		"empty":                   nil,
+		"short":                   valid[:6],
+		"truncated":               valid[:40],
+		"not pssh":                corrupt(4, 'm'),
+		"version 2":               corrupt(8, 2),
+		"data size":               corrupt(31, 0x10),
+		"size too large":          corrupt(3, 0x40),
+		"size below header":       {0, 0, 0, 4, 'p', 's', 's', 'h', 0, 0, 0, 0},
+		"large size below header": {0, 0, 0, 1, 'p', 's', 's', 'h', 0, 0, 0, 0, 0, 0, 0, 10, 0, 0, 0, 0},
+		"bad widevine data":       corrupt(32, 0xff),
+		"kid count":               corrupt(8, 1),
+	}
+	for name, box := range boxes {
+		_, err := NewPSSH(box).Parse()
+		if !errors.Is(err, ErrInvalidPSSH) {
+			t.Errorf("%s: Parse must fail with ErrInvalidPSSH got %v", name, err)
+		}
+	}
 }